@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/output"
+	"example.com/hello/takeout/parser"
+	"example.com/hello/takeout/sheets"
+)
+
+// runAnalyze is the original single-command behavior: aggregate the inputs
+// and write the output files (or a SQLite database, or with -dump every
+// parsed activity to stdout).
+func runAnalyze(args []string) {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	in := addInputFlags(fs)
+	wf := addWriterFlags(fs)
+	outDir := fs.String("outdir", "out", "Output directory to write JSON files into")
+	outSpec := fs.String("out", "", "Alternative output backend instead of -outdir files: sqlite:<path> writes a SQLite database, parquet:<dir> every entry and the aggregates as Parquet tables for DuckDB")
+	parquetPath := fs.String("parquet", "", "Also write one row per counted watch event to this Parquet file")
+	showStats := fs.Bool("stats", false, "Print throughput statistics to stderr")
+	dump := fs.String("dump", "", "Instead of writing outputs, print every parsed activity with its is_ad/is_removed/counted flags to stdout: ndjson (one JSON object per line)")
+	statePath := fs.String("state", "", "Aggregation state file: count only the entries newer than the state saved by the last run, add its counts and save the result (created on the first run)")
+	redactKey := fs.String("redact", "", "Replace channels and videos in the outputs with pseudonyms derived from this secret key and drop their URLs, for sharing; the same key gives the same pseudonyms")
+	channelName := fs.String("channel", "", "Also write channel_report.json for the channel with this name (ignoring case): watches per year and month, rank per year, every watched video and the rewatched ones")
+	channelURL := fs.String("channel-url", "", "Like -channel, but match the channel URL (following renames with -group-by url); with -channel both must match")
+	toStdout := fs.Bool("stdout", false, "Instead of writing files to -outdir, print every JSON output as one JSON document to stdout, keyed by file name without .json")
+	versioned := fs.Bool("out-versioned", false, "Write each run into a new timestamped subdirectory of -outdir (e.g. out/2025-01-07T18-30) and point the symlink out/latest at it, instead of overwriting the last run")
+	dryRun := fs.Bool("dry-run", false, "Parse and aggregate, then print the files that would be written to -outdir (new, changed or unchanged, with record counts and sizes) without touching it; the outputs are written in full to a temporary directory, which needs as much free space, and removed after")
+	bundle := fs.String("bundle", "", "Also pack every file in -outdir, with its manifest.json, into this .zip, .tar.gz or .tgz; the manifest then also has a SHA-256 of every input")
+	emitSchemas := fs.Bool("emit-schemas", false, "Also write a JSON Schema of every JSON output into -outdir/schemas, with an index.json naming the schema of each file, for typed consumers")
+	sheetsID := fs.String("sheets-id", "", "Also push the summary and each year's -top channels into tabs of this existing Google Sheet (the ID in its URL), shared for edit with the -sheets-credentials service account")
+	sheetsCreds := fs.String("sheets-credentials", "", "Service account JSON key file for -sheets-id (default: $GOOGLE_APPLICATION_CREDENTIALS)")
+	var searchPaths stringList
+	fs.Var(&searchPaths, "search", "Also analyze search-history.json/.html (or a Takeout .zip or directory) into search_*.json outputs (repeatable)")
+	parseFlags(fs, args)
+
+	output.InstallInterruptCleanup()
+
+	location := in.validate()
+	w := wf.writer(in)
+
+	var outBackend, outPath string
+	if *outSpec != "" {
+		outBackend, outPath, _ = strings.Cut(*outSpec, ":")
+		if (outBackend != "sqlite" && outBackend != "parquet") || outPath == "" {
+			fmt.Fprintln(os.Stderr, "error: -out must be sqlite:<path> or parquet:<dir>")
+			os.Exit(2)
+		}
+	}
+	if *dump != "" {
+		if *dump != "ndjson" {
+			fmt.Fprintln(os.Stderr, "error: -dump must be ndjson")
+			os.Exit(2)
+		}
+		if outPath != "" || len(searchPaths) > 0 {
+			fmt.Fprintln(os.Stderr, "error: -dump writes stdout and cannot be combined with -out or -search")
+			os.Exit(2)
+		}
+	}
+
+	if *channelName != "" || *channelURL != "" {
+		if outPath != "" || *dump != "" {
+			fmt.Fprintln(os.Stderr, "error: -channel and -channel-url write -outdir files and cannot be combined with -out or -dump")
+			os.Exit(2)
+		}
+		if *statePath != "" || *redactKey != "" {
+			fmt.Fprintln(os.Stderr, "error: -channel and -channel-url list every watch of the channel and cannot be combined with -state or -redact")
+			os.Exit(2)
+		}
+	}
+
+	if *toStdout {
+		if outPath != "" || *dump != "" || *bundle != "" {
+			fmt.Fprintln(os.Stderr, "error: -stdout cannot be combined with -out, -dump or -bundle")
+			os.Exit(2)
+		}
+		if !w.Formats.JSON || w.Formats.CSV || w.Formats.Parquet || w.Report != "" || len(w.Templates) > 0 {
+			fmt.Fprintln(os.Stderr, "error: -stdout prints the JSON outputs only and cannot be combined with -formats csv or parquet, -report or -template")
+			os.Exit(2)
+		}
+		dir, err := os.MkdirTemp("", "takeout-stdout-")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error creating temporary directory:", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(dir)
+		*outDir = dir
+	}
+
+	if *versioned && (outPath != "" || *dump != "" || *toStdout || *dryRun) {
+		fmt.Fprintln(os.Stderr, "error: -out-versioned writes -outdir and cannot be combined with -out, -dump, -stdout or -dry-run")
+		os.Exit(2)
+	}
+
+	realOutDir := *outDir
+	if *dryRun {
+		if outPath != "" || *dump != "" || *toStdout || *bundle != "" || *statePath != "" || *parquetPath != "" || w.MetricsOut != "" {
+			fmt.Fprintln(os.Stderr, "error: -dry-run cannot be combined with -out, -dump, -stdout, -bundle, -state, -parquet or -metrics-out, which write outside -outdir")
+			os.Exit(2)
+		}
+		if wf.ytAPIKey != "" && wf.ytCache != "" {
+			fmt.Fprintln(os.Stderr, "error: -dry-run cannot be combined with -yt-api-key, which writes -yt-cache; pass -yt-cache '' to look the videos up without caching them")
+			os.Exit(2)
+		}
+		dir, err := os.MkdirTemp("", "takeout-dry-run-")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error creating temporary directory:", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(dir)
+		*outDir = dir
+	}
+
+	if *bundle != "" {
+		if !output.IsBundlePath(*bundle) {
+			fmt.Fprintln(os.Stderr, "error: -bundle must end in .zip, .tar.gz or .tgz")
+			os.Exit(2)
+		}
+		if outPath != "" || *dump != "" {
+			fmt.Fprintln(os.Stderr, "error: -bundle packs -outdir and cannot be combined with -out or -dump")
+			os.Exit(2)
+		}
+	}
+	if *statePath != "" && (outPath != "" || *dump != "" || *parquetPath != "" || w.Formats.Parquet) {
+		fmt.Fprintln(os.Stderr, "error: -state only reads the new entries and cannot be combined with -out, -dump, -parquet or -formats parquet, which list every entry")
+		os.Exit(2)
+	}
+
+	if len(in.accounts) > 0 && (outPath != "" || *dump != "" || *toStdout || *statePath != "") {
+		fmt.Fprintln(os.Stderr, "error: labeled -in (label=path) writes each account's outputs under -outdir/accounts and cannot be combined with -out, -dump, -stdout or -state")
+		os.Exit(2)
+	}
+
+	if w.Plugins != nil && (outPath != "" || *dump != "" || *statePath != "" || *redactKey != "") {
+		fmt.Fprintln(os.Stderr, "error: -plugin writes its outputs to -outdir from every entry and cannot be combined with -out, -dump, -state or -redact")
+		os.Exit(2)
+	}
+
+	if *redactKey != "" && (outPath != "" || *dump != "" || *parquetPath != "" || w.Formats.Parquet ||
+		len(searchPaths) > 0 || w.Subscriptions != nil || w.Likes != nil || w.Playlists != nil || w.Comments != nil || wf.ytAPIKey != "" || w.Categories != nil || w.Blocklist != nil) {
+		fmt.Fprintln(os.Stderr, "error: -redact cannot be combined with -out, -dump, -parquet, -formats parquet, -search, -subscriptions, -likes, -playlists, -comments, -yt-api-key, -categories or -what-if-block, which write, look up or match unredacted entries")
+		os.Exit(2)
+	}
+
+	if *emitSchemas {
+		if outPath != "" || *dump != "" || *toStdout {
+			fmt.Fprintln(os.Stderr, "error: -emit-schemas writes -outdir/schemas and cannot be combined with -out, -dump or -stdout")
+			os.Exit(2)
+		}
+		w.Schemas = true
+	}
+
+	var sheet *sheets.Client
+	if *sheetsID != "" {
+		if outPath != "" || *dump != "" || *dryRun {
+			fmt.Fprintln(os.Stderr, "error: -sheets-id pushes the -outdir results and cannot be combined with -out, -dump or -dry-run")
+			os.Exit(2)
+		}
+		path := *sheetsCreds
+		if path == "" {
+			path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		if path == "" {
+			fmt.Fprintln(os.Stderr, "error: -sheets-id needs a service account key file in -sheets-credentials or $GOOGLE_APPLICATION_CREDENTIALS")
+			os.Exit(2)
+		}
+		creds, err := sheets.LoadCredentials(path)
+		if err == nil {
+			sheet, err = sheets.NewClient(*sheetsID, creds)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error loading -sheets-credentials:", err)
+			os.Exit(1)
+		}
+	}
+
+	versionBase := *outDir
+	if *versioned {
+		dir, err := output.NewVersionedDir(versionBase, time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error creating outdir:", err)
+			os.Exit(1)
+		}
+		*outDir = dir
+	}
+	if outPath == "" && *dump == "" {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, "error creating outdir:", err)
+			os.Exit(1)
+		}
+	}
+
+	in.statePath = *statePath
+	opts, inputs := in.options(location)
+	wf.apply(&opts, &w)
+	if in.statePath != "" {
+		in.years(&opts, location)
+	}
+	// Accounts are counted without the sinks added below, which only
+	// take the combined entries.
+	accountOpts := opts
+
+	var searchInputs []string
+	if len(searchPaths) > 0 {
+		if outPath != "" {
+			fmt.Fprintln(os.Stderr, "error: -search writes -outdir files and cannot be combined with -out")
+			os.Exit(2)
+		}
+		var err error
+		searchInputs, err = parser.ExpandHistory(searchPaths, parser.SearchHistory)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error opening search input:", err)
+			os.Exit(1)
+		}
+	}
+
+	var events *output.ParquetWriter
+	if *parquetPath != "" {
+		var err error
+		events, err = output.NewParquetWriter(*parquetPath, output.WatchEventColumns)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error creating parquet output:", err)
+			os.Exit(1)
+		}
+		opts.AddWatchSink(func(e aggregate.WatchEvent) error {
+			return events.WriteRow(output.WatchEventRow(e)...)
+		})
+	}
+
+	var activities *output.ParquetWriter
+	if w.Formats.Parquet && outPath == "" && *dump == "" {
+		var err error
+		activities, err = output.NewParquetWriter(filepath.Join(*outDir, "activities.parquet"), output.ActivityColumns)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error creating parquet output:", err)
+			os.Exit(1)
+		}
+		opts.AddWatchSink(func(e aggregate.WatchEvent) error {
+			return activities.WriteRow(output.ActivityRow(e)...)
+		})
+	}
+
+	var dumpOut *bufio.Writer
+	if *dump != "" {
+		dumpOut = bufio.NewWriter(os.Stdout)
+		enc := json.NewEncoder(dumpOut)
+		enc.SetEscapeHTML(false)
+		opts.AddActivitySink(func(r aggregate.ActivityRecord) error { return enc.Encode(r) })
+	}
+
+	var db *output.HistoryDB
+	if outBackend == "sqlite" {
+		var err error
+		db, err = output.NewHistoryDB(outPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error creating sqlite output:", err)
+			os.Exit(1)
+		}
+		db.Group = opts.ChannelGroup
+		opts.AddWatchSink(db.AddActivity)
+	}
+
+	var dataset *output.HistoryDataset
+	if outBackend == "parquet" {
+		var err error
+		dataset, err = output.NewHistoryDataset(outPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error creating parquet dataset:", err)
+			os.Exit(1)
+		}
+		opts.AddActivitySink(dataset.AddActivity)
+	}
+
+	if *channelName != "" || *channelURL != "" {
+		w.ChannelReport = &output.ChannelReport{Name: strings.TrimSpace(*channelName), URL: strings.TrimSpace(*channelURL), Group: opts.ChannelGroup}
+		opts.AddWatchSink(w.ChannelReport.Add)
+	}
+
+	agg, merged, processing := aggregateInputs(opts, inputs, in.progress, *statePath)
+	if len(in.accounts) > 0 {
+		w.Accounts = aggregateAccounts(accountOpts, in.accounts, in.progress, agg)
+	}
+	if *showStats {
+		fmt.Fprintf(os.Stderr, "processed %d entries (%d watched counted), %.1f MB in %.2fs with %d workers: %.1f MB/s, %.0f entries/s\n",
+			processing.EntriesDecoded, processing.WatchedCounted, float64(processing.BytesRead)/1e6,
+			processing.ElapsedSeconds, processing.Workers, processing.MBPerSecond, processing.EntriesPerSecond)
+		fmt.Fprintln(os.Stderr, "memory:", memoryStats())
+	}
+
+	for _, pw := range []*output.ParquetWriter{events, activities} {
+		if pw == nil {
+			continue
+		}
+		if err := pw.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing parquet output:", err)
+			os.Exit(1)
+		}
+	}
+
+	if dumpOut != nil {
+		if err := dumpOut.Flush(); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing dump:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if db != nil {
+		if err := db.Finish(agg); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing sqlite output:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote SQLite database to: %s\n", outPath)
+		return
+	}
+
+	if dataset != nil {
+		if err := dataset.Finish(agg); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing parquet dataset:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote Parquet dataset to: %s\n", outPath)
+		return
+	}
+
+	if *redactKey != "" {
+		agg.Redact(*redactKey)
+		for _, a := range w.Accounts {
+			a.Agg.Redact(*redactKey)
+		}
+	}
+	wf.lookupVideos(&w, agg)
+	w.Dir = *outDir
+	w.Inputs = merged
+	w.Processing = processing
+	if err := w.Write(agg); err != nil {
+		fmt.Fprintln(os.Stderr, "error writing outputs:", err)
+		os.Exit(1)
+	}
+
+	if len(searchInputs) > 0 {
+		searchOpts := opts
+		// Resuming a -state may have trimmed the year range.
+		searchOpts.EndYear = agg.Options().EndYear
+		searchOpts.Dedupe = len(searchInputs) > 1
+		searches := aggregate.NewSearches(searchOpts)
+		for _, p := range searchInputs {
+			if err := searches.ConsumeFile(p); err != nil {
+				fmt.Fprintf(os.Stderr, "error parsing search input %s: %v\n", p, err)
+				os.Exit(1)
+			}
+		}
+		if searches.Skipped > 0 {
+			fmt.Fprintf(os.Stderr, "warning: skipped %d search-history entries that could not be decoded; pass -strict to stop at the first one instead\n", searches.Skipped)
+		}
+		if err := w.WriteSearches(searches); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing search outputs:", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := w.WriteSchemas(); err != nil {
+		fmt.Fprintln(os.Stderr, "error writing schemas:", err)
+		os.Exit(1)
+	}
+
+	if *toStdout {
+		if err := output.CombineJSON(os.Stdout, *outDir); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing -stdout:", err)
+			os.Exit(1)
+		}
+	} else {
+		manifest, err := runManifest(fs, append(inputs, searchInputs...), location, *bundle != "")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error reading input:", err)
+			os.Exit(1)
+		}
+		if err := output.WriteManifest(*outDir, manifest, *bundle); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing manifest:", err)
+			os.Exit(1)
+		}
+		if *dryRun {
+			if err := printPlan(*outDir, realOutDir); err != nil {
+				fmt.Fprintln(os.Stderr, "error comparing with -outdir:", err)
+				os.Exit(1)
+			}
+		} else {
+			fmt.Printf("Wrote JSON outputs to: %s\n", *outDir)
+		}
+	}
+	if sheet != nil {
+		if err := sheet.Replace(output.SheetTabs(agg, w.TopN, time.Now())); err != nil {
+			fmt.Fprintln(os.Stderr, "error updating -sheets-id:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Updated Google Sheet: %s\n", *sheetsID)
+	}
+	if w.ChannelReport != nil && w.ChannelReport.Matched() == 0 {
+		fmt.Fprintln(os.Stderr, "warning: no counted watches matched -channel/-channel-url; channel_report.json is empty")
+	}
+
+	if *versioned {
+		if err := output.LinkLatest(versionBase, *outDir); err != nil {
+			fmt.Fprintln(os.Stderr, "warning: could not point the latest link at this run:", err)
+		}
+	}
+
+	if *bundle != "" {
+		if err := output.WriteBundle(*bundle, *outDir); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing -bundle:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote bundle to: %s\n", *bundle)
+	}
+}
+
+// printPlan prints what a -dry-run wrote to scratch as the changes it would
+// make to outDir.
+func printPlan(scratch, outDir string) error {
+	files, kept, err := output.PlanOutputs(scratch, outDir)
+	if err != nil {
+		return err
+	}
+	var total int64
+	counts := make(map[string]int)
+	for _, f := range files {
+		total += f.Size
+		counts[f.Status]++
+	}
+	fmt.Printf("Dry run: would write %d files (%s) to %s: %d new, %d changed, %d unchanged\n",
+		len(files), formatBytes(total), outDir, counts["new"], counts["changed"], counts["unchanged"])
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  STATUS\tRECORDS\tSIZE\tFILE")
+	for _, f := range files {
+		records := "-"
+		if f.Records != nil {
+			records = strconv.Itoa(*f.Records)
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", f.Status, records, formatBytes(f.Size), f.Path)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(kept) > 0 {
+		fmt.Printf("Left as they are, not written by this run: %s\n", strings.Join(kept, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"example.com/hello/takeout/output"
+)
+
+// runCompare aggregates two people's exports separately and reports how much
+// their channels overlap. The exports are given with -a/-b or as two
+// arguments: compare a.json b.json.
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	in := addFilterFlags(fs)
+	aPath := fs.String("a", "", "First person's export (file, .zip or directory; required)")
+	bPath := fs.String("b", "", "Second person's export (file, .zip or directory; required)")
+	aLabel := fs.String("a-label", "", "Name for the first export in the output (default: its file name)")
+	bLabel := fs.String("b-label", "", "Name for the second export in the output (default: its file name)")
+	limit := fs.Int("top", 20, "Maximum channels per list (0 = all)")
+	outPath := fs.String("o", "", "Write the comparison to this JSON file instead of stdout")
+	parseFlags(fs, args)
+
+	output.InstallInterruptCleanup()
+
+	if args := fs.Args(); len(args) > 0 {
+		if len(args) != 2 || *aPath != "" || *bPath != "" {
+			fmt.Fprintln(os.Stderr, "error: give the two exports either as arguments (compare a.json b.json) or with -a and -b")
+			os.Exit(2)
+		}
+		*aPath, *bPath = args[0], args[1]
+	}
+	if *aPath == "" || *bPath == "" {
+		fmt.Fprintln(os.Stderr, "error: -a and -b are required")
+		os.Exit(2)
+	}
+	if *aLabel == "" {
+		*aLabel = filepath.Base(*aPath)
+	}
+	if *bLabel == "" {
+		*bLabel = filepath.Base(*bPath)
+	}
+	// Both exports are counted over the same years.
+	in.inPaths = stringList{*aPath, *bPath}
+	location := in.validate()
+	in.resolveYears(location)
+
+	in.inPaths = stringList{*aPath}
+
+	aOpts, aInputs := in.options(location)
+	aAgg, _, _ := aggregateInputs(aOpts, aInputs, in.progress, "")
+
+	in.inPaths = stringList{*bPath}
+	bOpts, bInputs := in.options(location)
+	bAgg, _, _ := aggregateInputs(bOpts, bInputs, in.progress, "")
+
+	c := output.CompareAccounts(aAgg, bAgg, *aLabel, *bLabel, *limit)
+	if *outPath != "" {
+		if err := output.WriteJSON(*outPath, c); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing comparison:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote comparison to: %s\n", *outPath)
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		fmt.Fprintln(os.Stderr, "error writing comparison:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultConfigPath is read when -config is not given, if it exists.
+const defaultConfigPath = "takeout.yaml"
+
+// The config file holds flag values in a small subset of YAML:
+//
+//	# top-level keys apply to every command that has the flag
+//	tz: America/Chicago
+//	start: 2019
+//	formats: json,csv
+//	in:
+//	  - exports/2023.zip
+//	  - exports/2024.zip
+//	title-prefix: ["Watched ", "Regardé "]
+//
+//	# a section named after a command applies only to it
+//	analyze:
+//	  outdir: reports
+//	  report: html
+//
+// Keys are flag names (underscores may stand in for dashes). Lists set
+// repeatable flags once per item; for any other flag the items are joined
+// with commas, so formats: [json, csv] is formats: json,csv. Flags given on
+// the command line win over the file.
+
+// configNode is a scalar or list (values) or a mapping (keys).
+type configNode struct {
+	values []string
+	keys   map[string]*configNode
+	line   map[string]int
+}
+
+// parseFlags parses args into fs, then sets every flag not given on the
+// command line from the config file. Config errors exit with status 2.
+func parseFlags(fs *flag.FlagSet, args []string) {
+	configPath := fs.String("config", "", "YAML file with flag values; command-line flags override it (default "+defaultConfigPath+" if present)")
+	_ = fs.Parse(args)
+
+	path := *configPath
+	if path == "" {
+		path = defaultConfigPath
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return
+		}
+	}
+	if err := applyConfig(fs, path); err != nil {
+		fmt.Fprintln(os.Stderr, "error: -config:", err)
+		os.Exit(2)
+	}
+}
+
+func applyConfig(fs *flag.FlagSet, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	root, err := parseConfig(string(b))
+	if err != nil {
+		return fmt.Errorf("%s:%w", path, err)
+	}
+
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	given["config"] = true
+
+	set := func(key string, n *configNode, strict bool) error {
+		name := strings.ReplaceAll(key, "_", "-")
+		if fs.Lookup(name) == nil {
+			if strict {
+				return fmt.Errorf("%s:%d: %s has no flag -%s", path, n.line[key], fs.Name(), name)
+			}
+			return nil
+		}
+		if given[name] {
+			return nil
+		}
+		v := n.keys[key]
+		if v.keys != nil {
+			return fmt.Errorf("%s:%d: %s must be a value or a list", path, n.line[key], key)
+		}
+		values := v.values
+		if _, repeatable := fs.Lookup(name).Value.(*stringList); !repeatable && len(values) > 1 {
+			values = []string{strings.Join(values, ",")}
+		}
+		for _, s := range values {
+			if err := fs.Set(name, s); err != nil {
+				return fmt.Errorf("%s:%d: %s: %v", path, n.line[key], key, err)
+			}
+		}
+		return nil
+	}
+
+	// Command sections first, so they take precedence over top-level keys:
+	// once a flag is set from the section, it is marked as given.
+	if sec := root.keys[fs.Name()]; sec != nil {
+		if sec.keys == nil {
+			return fmt.Errorf("%s:%d: %s must be a section of flags", path, root.line[fs.Name()], fs.Name())
+		}
+		for key := range sec.keys {
+			if err := set(key, sec, true); err != nil {
+				return err
+			}
+			given[strings.ReplaceAll(key, "_", "-")] = true
+		}
+	}
+	for key, v := range root.keys {
+		if v.keys != nil {
+			continue // a command section
+		}
+		if err := set(key, root, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type configLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// parseConfig parses the YAML subset described above into a mapping.
+func parseConfig(src string) (*configNode, error) {
+	var lines []configLine
+	for i, raw := range strings.Split(src, "\n") {
+		text := stripComment(strings.TrimRight(raw, " \t\r"))
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("%d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, configLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	root, rest, err := parseMapping(lines, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%d: unexpected indentation", rest[0].num)
+	}
+	return root, nil
+}
+
+// parseMapping parses "key: value" lines at indent, returning the lines
+// after the mapping.
+func parseMapping(lines []configLine, indent int) (*configNode, []configLine, error) {
+	n := &configNode{keys: make(map[string]*configNode), line: make(map[string]int)}
+	for len(lines) > 0 && lines[0].indent == indent {
+		l := lines[0]
+		lines = lines[1:]
+		key, val, ok := strings.Cut(l.text, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.HasPrefix(key, "-") {
+			return nil, nil, fmt.Errorf("%d: expected key: value", l.num)
+		}
+		if _, dup := n.keys[key]; dup {
+			return nil, nil, fmt.Errorf("%d: duplicate key %s", l.num, key)
+		}
+		n.line[key] = l.num
+		val = strings.TrimSpace(val)
+
+		switch {
+		case val != "":
+			values, err := parseConfigValue(val)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%d: %v", l.num, err)
+			}
+			n.keys[key] = &configNode{values: values}
+		case len(lines) > 0 && lines[0].indent > indent && strings.HasPrefix(lines[0].text, "-"):
+			child := &configNode{}
+			in := lines[0].indent
+			for len(lines) > 0 && lines[0].indent == in && strings.HasPrefix(lines[0].text, "-") {
+				item, err := parseConfigScalar(strings.TrimSpace(strings.TrimPrefix(lines[0].text, "-")))
+				if err != nil {
+					return nil, nil, fmt.Errorf("%d: %v", lines[0].num, err)
+				}
+				child.values = append(child.values, item)
+				lines = lines[1:]
+			}
+			n.keys[key] = child
+		case len(lines) > 0 && lines[0].indent > indent:
+			child, rest, err := parseMapping(lines, lines[0].indent)
+			if err != nil {
+				return nil, nil, err
+			}
+			n.keys[key] = child
+			lines = rest
+		default:
+			n.keys[key] = &configNode{values: []string{""}}
+		}
+		if len(lines) > 0 && lines[0].indent > indent {
+			return nil, nil, fmt.Errorf("%d: unexpected indentation", lines[0].num)
+		}
+	}
+	return n, lines, nil
+}
+
+// parseConfigValue parses a scalar or a [a, b] flow list.
+func parseConfigValue(s string) ([]string, error) {
+	if !strings.HasPrefix(s, "[") {
+		v, err := parseConfigScalar(s)
+		return []string{v}, err
+	}
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated list %s", s)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+	if inner == "" {
+		return nil, nil
+	}
+	var out []string
+	for _, item := range splitOutsideQuotes(inner, ',') {
+		v, err := parseConfigScalar(strings.TrimSpace(item))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// parseConfigScalar unquotes "double" (Go escapes) and 'single' (” for a
+// quote) strings; anything else is taken as is.
+func parseConfigScalar(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("bad string %s", s)
+		}
+		return v, nil
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'"):
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+	return s, nil
+}
+
+// stripComment drops a # comment that starts the line or follows a space,
+// outside quotes.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func splitOutsideQuotes(s string, sep byte) []string {
+	var out []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == sep:
+			out = append(out, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, s[start:])
+}
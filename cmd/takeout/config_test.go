@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestApplyConfig sets the flags of an analyze-like command from a config
+// file and checks what each one ends up as.
+func TestApplyConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		want   map[string]string
+		in     []string
+		err    string
+	}{
+		{
+			name:   "scalars",
+			config: "tz: America/Chicago\nstart: 2019\nformats: json # a comment\noutdir: \"out dir\"\n",
+			want:   map[string]string{"tz": "America/Chicago", "start": "2019", "formats": "json", "outdir": "out dir"},
+		},
+		{
+			name:   "flow list joined for a plain flag",
+			config: "formats: [json, csv]\n",
+			want:   map[string]string{"formats": "json,csv"},
+		},
+		{
+			name:   "block list joined for a plain flag",
+			config: "formats:\n  - json\n  - csv\n",
+			want:   map[string]string{"formats": "json,csv"},
+		},
+		{
+			name:   "lists set a repeatable flag per item",
+			config: "in:\n  - exports/2023.zip\n  - exports/2024.zip\ntitle-prefix: [\"Watched \", 'Regardé ']\n",
+			in:     []string{"exports/2023.zip", "exports/2024.zip"},
+			want:   map[string]string{"title-prefix": "Watched ,Regardé "},
+		},
+		{
+			name:   "underscores for dashes",
+			config: "title_prefix: Vu\n",
+			want:   map[string]string{"title-prefix": "Vu"},
+		},
+		{
+			name:   "command section over top-level keys",
+			config: "outdir: top\nformats: json\nanalyze:\n  outdir: reports\nserve:\n  addr: :9000\n",
+			want:   map[string]string{"outdir": "reports", "formats": "json"},
+		},
+		{
+			name:   "command line over the file",
+			config: "outdir: top\nformats: [json, csv]\nin: [a.zip, b.zip]\nanalyze:\n  start: 2019\n",
+			args:   []string{"-outdir", "cli", "-start", "2021", "-in", "c.zip"},
+			want:   map[string]string{"outdir": "cli", "start": "2021", "formats": "json,csv"},
+			in:     []string{"c.zip"},
+		},
+		{
+			name:   "unknown top-level key ignored",
+			config: "addr: :9000\n",
+			want:   map[string]string{"outdir": "out"},
+		},
+		{
+			name:   "unknown key in the command section",
+			config: "analyze:\n  addr: :9000\n",
+			err:    ":2: analyze has no flag -addr",
+		},
+		{
+			name:   "mapping for a flag",
+			config: "analyze:\n  outdir:\n    a: b\n",
+			err:    ":2: outdir must be a value or a list",
+		},
+		{
+			name:   "bad indentation",
+			config: "tz: UTC\n  start: 2019\n",
+			err:    "2: unexpected indentation",
+		},
+		{
+			name:   "unterminated list",
+			config: "formats: [json, csv\n",
+			err:    "1: unterminated list [json, csv",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			fs.String("tz", "", "")
+			fs.String("start", "", "")
+			fs.String("formats", "json", "")
+			fs.String("outdir", "out", "")
+			var in, titlePrefix stringList
+			fs.Var(&in, "in", "")
+			fs.Var(&titlePrefix, "title-prefix", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), "takeout.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			err := applyConfig(fs, path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("applyConfig error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("-%s = %q, want %q", name, got, want)
+				}
+			}
+			if !reflect.DeepEqual([]string(in), tt.in) {
+				t.Errorf("-in = %q, want %q", in, tt.in)
+			}
+		})
+	}
+}
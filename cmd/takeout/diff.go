@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"example.com/hello/takeout/output"
+)
+
+// runDiff aggregates two exports separately and reports which channels and
+// videos were added, dropped, changed count or moved rank between them. The
+// exports are given with -old/-new or as two arguments: diff old.json new.json.
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	in := addFilterFlags(fs)
+	oldPath := fs.String("old", "", "Older export (file, .zip or directory; required)")
+	newPath := fs.String("new", "", "Newer export (file, .zip or directory; required)")
+	limit := fs.Int("top", 20, "Maximum channels or videos per list (0 = all)")
+	outPath := fs.String("o", "", "Write the diff to this JSON file instead of stdout")
+	parseFlags(fs, args)
+
+	output.InstallInterruptCleanup()
+
+	if args := fs.Args(); len(args) > 0 {
+		if len(args) != 2 || *oldPath != "" || *newPath != "" {
+			fmt.Fprintln(os.Stderr, "error: give the two exports either as arguments (diff old.json new.json) or with -old and -new")
+			os.Exit(2)
+		}
+		*oldPath, *newPath = args[0], args[1]
+	}
+	if *oldPath == "" || *newPath == "" {
+		fmt.Fprintln(os.Stderr, "error: -old and -new are required")
+		os.Exit(2)
+	}
+	// Both exports are counted over the same years.
+	in.inPaths = stringList{*oldPath, *newPath}
+	location := in.validate()
+	in.resolveYears(location)
+
+	in.inPaths = stringList{*oldPath}
+
+	oldOpts, oldInputs := in.options(location)
+	oldAgg, _, _ := aggregateInputs(oldOpts, oldInputs, in.progress, "")
+
+	in.inPaths = stringList{*newPath}
+	newOpts, newInputs := in.options(location)
+	newAgg, _, _ := aggregateInputs(newOpts, newInputs, in.progress, "")
+
+	d := output.DiffChannels(oldAgg, newAgg, *limit)
+	if *outPath != "" {
+		if err := output.WriteJSON(*outPath, d); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing diff:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote diff to: %s\n", *outPath)
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		fmt.Fprintln(os.Stderr, "error writing diff:", err)
+		os.Exit(1)
+	}
+}
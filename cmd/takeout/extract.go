@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/output"
+	"example.com/hello/takeout/parser"
+)
+
+// runExtract writes the raw activities analyze counts for a channel and/or
+// year, so the entries behind a surprising number can be read one by one.
+func runExtract(args []string) {
+	fs := flag.NewFlagSet("extract", flag.ExitOnError)
+	in := addInputFlags(fs)
+	channel := fs.String("channel", "", "Only entries of this channel: its name (any case), URL or channel ID; (unknown channel) for entries without one")
+	year := fs.Int("year", 0, "Only entries of this year in -tz; same as -start and -end both set to it")
+	outPath := fs.String("o", "-", "Path of the JSON export to write the entries to; - writes them to stdout")
+	parseFlags(fs, args)
+
+	if *year != 0 {
+		if in.startYear != 0 || in.endYear != 0 || in.fromDate != "" || in.toDate != "" {
+			fmt.Fprintln(os.Stderr, "error: -year cannot be combined with -start, -end, -from or -to")
+			os.Exit(2)
+		}
+		in.startYear, in.endYear = *year, *year
+	}
+	location := in.validate()
+	opts, inputs := in.options(location)
+
+	match := func(aggregate.ActivityRecord) bool { return true }
+	if want := strings.TrimSpace(*channel); want != "" {
+		match = func(r aggregate.ActivityRecord) bool {
+			name := r.ChannelName
+			if name == "" {
+				name = "(unknown channel)"
+			}
+			return strings.EqualFold(name, want) ||
+				(r.ChannelURL != "" && (r.ChannelURL == want || parser.ChannelIDFromURL(r.ChannelURL) == want))
+		}
+	}
+
+	w := createActivityOutput(*outPath)
+	counted, err := countedActivities(opts, inputs, func(a parser.Activity, r aggregate.ActivityRecord) error {
+		if !match(r) {
+			return nil
+		}
+		return w.Write(a)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error extracting entries:", err)
+		os.Exit(1)
+	}
+	closeActivityOutput(w)
+	fmt.Fprintf(os.Stderr, "Extracted %d of %d counted entries\n", w.Count(), counted)
+}
+
+// runSample writes a random sample of the raw activities analyze counts,
+// in the order of the inputs.
+func runSample(args []string) {
+	fs := flag.NewFlagSet("sample", flag.ExitOnError)
+	in := addInputFlags(fs)
+	n := fs.Int("n", 100, "Number of entries to sample")
+	seed := fs.Uint64("seed", 0, "Seed of the random sample, to draw the same one again (default: a new sample every run)")
+	outPath := fs.String("o", "-", "Path of the JSON export to write the sample to; - writes it to stdout")
+	parseFlags(fs, args)
+
+	if *n < 1 {
+		fmt.Fprintln(os.Stderr, "error: -n must be at least 1")
+		os.Exit(2)
+	}
+	location := in.validate()
+	opts, inputs := in.options(location)
+
+	s := *seed
+	if s == 0 {
+		s = uint64(time.Now().UnixNano())
+	}
+	rng := rand.New(rand.NewPCG(s, s))
+
+	// Reservoir sampling keeps every counted entry equally likely to be
+	// drawn without holding them all.
+	type drawn struct {
+		seq int
+		a   parser.Activity
+	}
+	var sample []drawn
+	seq := 0
+	counted, err := countedActivities(opts, inputs, func(a parser.Activity, _ aggregate.ActivityRecord) error {
+		if len(sample) < *n {
+			sample = append(sample, drawn{seq, a})
+		} else if i := rng.IntN(seq + 1); i < *n {
+			sample[i] = drawn{seq, a}
+		}
+		seq++
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error parsing input:", err)
+		os.Exit(1)
+	}
+	sort.Slice(sample, func(i, j int) bool { return sample[i].seq < sample[j].seq })
+
+	w := createActivityOutput(*outPath)
+	for _, d := range sample {
+		if err := w.Write(d.a); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing output:", err)
+			os.Exit(1)
+		}
+	}
+	closeActivityOutput(w)
+	fmt.Fprintf(os.Stderr, "Sampled %d of %d counted entries\n", w.Count(), counted)
+}
+
+// countedActivities passes fn each activity of the inputs that analyze
+// with opts counts, with the record Add made of it. Entries that cannot be
+// decoded are skipped unless opts.Strict is set. It returns how many
+// activities were counted.
+func countedActivities(opts aggregate.Options, inputs []string, fn func(parser.Activity, aggregate.ActivityRecord) error) (int, error) {
+	var rec aggregate.ActivityRecord
+	opts.Workers = 1
+	opts.OnProgress = nil
+	opts.AddActivitySink(func(r aggregate.ActivityRecord) error {
+		rec = r
+		return nil
+	})
+	agg := aggregate.New(opts)
+
+	counted := 0
+	for _, p := range inputs {
+		err := eachActivity(p, opts.Strict, func(a parser.Activity) error {
+			rec = aggregate.ActivityRecord{}
+			if err := agg.Add(a); err != nil {
+				return err
+			}
+			if !rec.Counted {
+				return nil
+			}
+			counted++
+			return fn(a, rec)
+		})
+		if err != nil {
+			return counted, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return counted, nil
+}
+
+// eachActivity passes fn every activity of the export at path, skipping
+// entries that cannot be decoded unless strict is set.
+func eachActivity(path string, strict bool, fn func(parser.Activity) error) error {
+	f, err := parser.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec, err := parser.NewDecoder(f)
+	if err != nil {
+		return err
+	}
+	for {
+		a, err := dec.Next()
+		if err == io.EOF {
+			return nil
+		}
+		var ee *parser.EntryError
+		if errors.As(err, &ee) && !strict {
+			if ee.Stop {
+				return nil
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+}
+
+// createActivityOutput opens the JSON export at path, or stdout for -,
+// exiting on error.
+func createActivityOutput(path string) *output.ActivityWriter {
+	var w *output.ActivityWriter
+	var err error
+	if path == "-" {
+		w, err = output.NewActivityStream(os.Stdout)
+	} else {
+		output.InstallInterruptCleanup()
+		w, err = output.NewActivityWriter(path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error creating output:", err)
+		os.Exit(1)
+	}
+	return w
+}
+
+// closeActivityOutput finishes w, exiting on error.
+func closeActivityOutput(w *output.ActivityWriter) {
+	if err := w.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "error writing output:", err)
+		os.Exit(1)
+	}
+}
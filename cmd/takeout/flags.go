@@ -0,0 +1,763 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/output"
+	"example.com/hello/takeout/parser"
+	"example.com/hello/takeout/youtube"
+)
+
+// inputFlags are the flags every subcommand that reads an export shares:
+// where to read it from and which watches to count.
+type inputFlags struct {
+	inPaths   stringList
+	accounts  []account
+	tzName    string
+	startYear int
+	endYear   int
+	allYears  bool
+	// statePath is analyze's -state. With it, options leaves the year range
+	// to years, for once the options are complete.
+	statePath    string
+	fromDate     string
+	toDate       string
+	from, until  time.Time
+	strictTimes  bool
+	strict       bool
+	countRemoved bool
+	excludeAds   bool
+	excludeMusic bool
+	prefixesPath string
+	titlePrefix  stringList
+	actionList   string
+	actions      []string
+	groupBy      string
+	names        string
+	mergeTopics  bool
+	progress     bool
+	excludeChans string
+	onlyChans    string
+	workers      int
+	maxMem       string
+}
+
+// account is the inputs given under one label with -in label=path.
+type account struct {
+	label string
+	paths stringList
+}
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	f := addFilterFlags(fs)
+	fs.Var(&f.inPaths, "in", "Path to watch-history.json/.html, a Takeout .zip, or a directory of them (required; repeat to merge exports); - reads the JSON or HTML export from stdin; label=path counts the export as that person's in a household run")
+	return f
+}
+
+// addFilterFlags registers the inputFlags other than -in, for subcommands
+// that take their inputs some other way.
+func addFilterFlags(fs *flag.FlagSet) *inputFlags {
+	f := &inputFlags{}
+	fs.StringVar(&f.tzName, "tz", "UTC", "IANA time zone (e.g. America/Chicago) used for year, day and hour buckets")
+	fs.IntVar(&f.startYear, "start", 0, "Start year (inclusive; default: the first year with a watch in the inputs)")
+	fs.IntVar(&f.endYear, "end", 0, "End year (inclusive; default: the last year with a watch in the inputs)")
+	fs.StringVar(&f.fromDate, "from", "", "First day to count, YYYY-MM-DD in -tz; overrides -start")
+	fs.StringVar(&f.toDate, "to", "", "Last day to count (inclusive), YYYY-MM-DD in -tz; overrides -end")
+	fs.BoolVar(&f.strictTimes, "strict-times", false, "Fail on any watched entry whose time is not valid RFC3339 (default: skip it)")
+	fs.BoolVar(&f.strict, "strict", false, "Fail on the first entry that cannot be decoded (default: skip it and list it in parse_errors.json)")
+	fs.BoolVar(&f.countRemoved, "count-removed", false, "Count removed, deleted and private videos under '(unknown channel)' in channel and video counts (default: leave them out; they are reported in removed_videos.json either way)")
+	fs.BoolVar(&f.excludeAds, "exclude-ads", true, "Leave ad views ('From Google Ads') out of channel and video counts; they are reported in ads_summary.json either way")
+	fs.BoolVar(&f.excludeMusic, "exclude-music", false, "Leave YouTube Music plays out of channel and video counts; they are reported in music_top_artists.json and music_top_tracks.json either way")
+	fs.StringVar(&f.prefixesPath, "prefixes", "", "JSON file mapping language to watched-title prefix; augments/overrides the built-in set")
+	fs.StringVar(&f.actionList, "actions", parser.ActionWatched, "Comma-separated actions to count like watches: "+strings.Join(parser.Actions(), ", ")+"; summary.json counts the entries of every action either way")
+	fs.Var(&f.titlePrefix, "title-prefix", "Watched-title prefix to use instead of the built-in locale table and -prefixes, e.g. 'Regardé ' (repeatable)")
+	fs.StringVar(&f.excludeChans, "exclude-channels", "", "File listing channels to leave out: names or URLs one per line, or /regexp/")
+	fs.StringVar(&f.onlyChans, "only-channels", "", "File listing the only channels to count, in the -exclude-channels format")
+	fs.BoolVar(&f.progress, "progress", false, "Report bytes read and entries decoded on stderr while parsing")
+	fs.IntVar(&f.workers, "workers", 1, "Goroutines decoding and counting entries; more than 1 helps on large exports (e.g. the number of CPU cores), inputs under 32 MB are counted on one")
+	fs.StringVar(&f.maxMem, "max-mem", "", "Keep memory under this size (e.g. 2GB): the GC works harder near it, and the run stops with memory stats if the live heap exceeds it")
+	fs.StringVar(&f.groupBy, "group-by", "name", "Channel identity: name keeps each name/URL pair apart; url merges renamed channels by URL under their most recent name")
+	fs.StringVar(&f.names, "normalize-names", "none", "Channel name normalization before counting: nfc merges names that differ only in how accents are encoded; strip also drops emoji and zero-width characters; none keeps names as exported")
+	fs.BoolVar(&f.mergeTopics, "merge-topics", false, "Count YouTube Music's auto-generated 'Artist - Topic' channels under the artist's own channel when it was watched too; merge_suggestions.json lists what was merged")
+	return f
+}
+
+// validate checks the flags that can be checked before touching any input,
+// exiting with status 2 on error.
+func (f *inputFlags) validate() *time.Location {
+	if len(f.inPaths) == 0 {
+		fmt.Fprintln(os.Stderr, "error: -in is required")
+		os.Exit(2)
+	}
+	if err := f.splitLabels(); err != nil {
+		fmt.Fprintln(os.Stderr, "error: -in:", err)
+		os.Exit(2)
+	}
+	if f.groupBy != "name" && f.groupBy != "url" {
+		fmt.Fprintln(os.Stderr, "error: -group-by must be name or url")
+		os.Exit(2)
+	}
+	switch f.names {
+	case "none", "nfc", "strip":
+	default:
+		fmt.Fprintln(os.Stderr, "error: -normalize-names must be none, nfc or strip")
+		os.Exit(2)
+	}
+	if f.workers < 1 {
+		fmt.Fprintln(os.Stderr, "error: -workers must be at least 1")
+		os.Exit(2)
+	}
+	actions, err := parser.ParseActions(f.actionList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: -actions:", err)
+		os.Exit(2)
+	}
+	f.actions = actions
+	if f.maxMem != "" {
+		limit, err := parseSize(f.maxMem)
+		if err != nil || limit == 0 {
+			fmt.Fprintln(os.Stderr, "error: -max-mem must be a positive size like 512MB or 2GiB")
+			os.Exit(2)
+		}
+		guardMemory(limit)
+	}
+	location, err := time.LoadLocation(f.tzName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: -tz:", err)
+		os.Exit(2)
+	}
+	if f.fromDate != "" {
+		if f.from, err = time.ParseInLocation(time.DateOnly, f.fromDate, location); err != nil {
+			fmt.Fprintln(os.Stderr, "error: -from must be a date like 2023-06-01")
+			os.Exit(2)
+		}
+		f.startYear = f.from.Year()
+	}
+	if f.toDate != "" {
+		to, err := time.ParseInLocation(time.DateOnly, f.toDate, location)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error: -to must be a date like 2024-05-31")
+			os.Exit(2)
+		}
+		f.until = to.AddDate(0, 0, 1)
+		f.endYear = to.Year()
+	}
+	if f.startYear != 0 && f.endYear != 0 && f.startYear > f.endYear {
+		fmt.Fprintln(os.Stderr, "error: -start (or -from) must be before -end (or -to)")
+		os.Exit(2)
+	}
+	if !f.from.IsZero() && !f.until.IsZero() && !f.from.Before(f.until) {
+		fmt.Fprintln(os.Stderr, "error: -from must not be after -to")
+		os.Exit(2)
+	}
+	return location
+}
+
+// accountLabel is what a label of -in label=path may look like.
+var accountLabel = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// splitLabels takes the labels off -in label=path, grouping the paths by
+// label into f.accounts in the order the labels first appear. Either every
+// -in has a label or none does; an -in naming an existing file is a path
+// even if it looks labeled.
+func (f *inputFlags) splitLabels() error {
+	paths := make(stringList, 0, len(f.inPaths))
+	byLabel := make(map[string]int)
+	for _, p := range f.inPaths {
+		label, path, ok := strings.Cut(p, "=")
+		if _, err := os.Stat(p); err == nil || !ok || path == "" || !accountLabel.MatchString(label) {
+			paths = append(paths, p)
+			continue
+		}
+		if path == parser.Stdin {
+			return fmt.Errorf("%s: a labeled export is read twice, once for the household and once for its account, which standard input cannot be", p)
+		}
+		i, seen := byLabel[label]
+		if !seen {
+			i = len(f.accounts)
+			byLabel[label] = i
+			f.accounts = append(f.accounts, account{label: label})
+		}
+		f.accounts[i].paths = append(f.accounts[i].paths, path)
+		paths = append(paths, path)
+	}
+	if len(f.accounts) > 0 {
+		labeled := 0
+		for _, a := range f.accounts {
+			labeled += len(a.paths)
+		}
+		if labeled != len(paths) {
+			return errors.New("label every export (label=path) or none")
+		}
+	}
+	f.inPaths = paths
+	return nil
+}
+
+// inputs expands -in, exiting on error.
+func (f *inputFlags) inputs() []string {
+	inputs, err := parser.ExpandInputs(f.inPaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error opening input:", err)
+		os.Exit(1)
+	}
+	return inputs
+}
+
+// prefixes loads the watched prefixes of -prefixes or -title-prefix,
+// exiting on error.
+func (f *inputFlags) prefixes() []string {
+	prefixes, err := parser.LoadWatchedPrefixes(f.prefixesPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error loading prefixes:", err)
+		os.Exit(1)
+	}
+	if len(f.titlePrefix) > 0 {
+		prefixes = parser.WatchedPrefixes(f.titlePrefix...)
+	}
+	return prefixes
+}
+
+// resolveYears sets -start and -end, where neither they nor -from and -to
+// were given, to the first and last year with a watch in the inputs, so no
+// part of the history is cut off. Standard input cannot be read twice, so
+// with it they default to every year since YouTube launched. It exits on
+// error and does nothing once both are set.
+func (f *inputFlags) resolveYears(location *time.Location) {
+	if f.startYear != 0 && f.endYear != 0 {
+		return
+	}
+	detected := f.startYear == 0 && f.endYear == 0
+	first, last := aggregate.FirstYouTubeYear, time.Now().In(location).Year()
+	inputs := f.inputs()
+	if !slices.Contains(inputs, parser.Stdin) {
+		opts := aggregate.Options{WatchedPrefixes: f.prefixes(), Actions: f.actions, Location: location}
+		start, end, ok, err := aggregate.DetectYears(inputs, opts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error reading input for the year range:", err)
+			os.Exit(1)
+		}
+		if ok {
+			first, last = start, end
+		} else {
+			first = last
+		}
+		f.allYears = detected
+	}
+	if f.startYear == 0 {
+		f.startYear = first
+		if f.endYear != 0 {
+			f.startYear = min(first, f.endYear)
+		}
+	}
+	if f.endYear == 0 {
+		f.endYear = max(last, f.startYear)
+	}
+}
+
+// options expands the inputs and builds the aggregation options, exiting on
+// error.
+func (f *inputFlags) options(location *time.Location) (aggregate.Options, []string) {
+	inputs := f.inputs()
+	prefixes := f.prefixes()
+
+	var err error
+	opts := aggregate.Options{
+		From:            f.from,
+		Until:           f.until,
+		StrictTimes:     f.strictTimes,
+		Strict:          f.strict,
+		SkipRemoved:     !f.countRemoved,
+		ExcludeAds:      f.excludeAds,
+		ExcludeMusic:    f.excludeMusic,
+		WatchedPrefixes: prefixes,
+		Actions:         f.actions,
+		GroupBy:         f.groupBy,
+		NormalizeNames:  f.names,
+		MergeTopics:     f.mergeTopics,
+		Location:        location,
+		Dedupe:          len(inputs) > 1,
+		Workers:         f.workers,
+	}
+	if f.excludeChans != "" {
+		if opts.ExcludeChannels, err = aggregate.LoadChannelFilter(f.excludeChans); err != nil {
+			fmt.Fprintln(os.Stderr, "error loading -exclude-channels:", err)
+			os.Exit(1)
+		}
+	}
+	if f.onlyChans != "" {
+		if opts.OnlyChannels, err = aggregate.LoadChannelFilter(f.onlyChans); err != nil {
+			fmt.Fprintln(os.Stderr, "error loading -only-channels:", err)
+			os.Exit(1)
+		}
+	}
+	if f.statePath == "" {
+		f.years(&opts, location)
+	}
+	return opts, inputs
+}
+
+// years sets the year range of opts, resumed from -state (see resumeYears)
+// or resolved from the inputs, exiting on error.
+func (f *inputFlags) years(opts *aggregate.Options, location *time.Location) {
+	f.resumeYears(*opts, location)
+	f.resolveYears(location)
+	opts.StartYear, opts.EndYear, opts.AllYears = f.startYear, f.endYear, f.allYears
+}
+
+// resumeYears sets -start and -end, where neither they nor -from and -to
+// were given, from a -state that opts resume and that was counted over every
+// year of its input: from its first year through the current one, which
+// MergeState trims back to the last year with a watch. The inputs are then
+// not read beforehand to detect the range. It does nothing without such a
+// state.
+func (f *inputFlags) resumeYears(opts aggregate.Options, location *time.Location) {
+	if f.statePath == "" || f.startYear != 0 || f.endYear != 0 {
+		return
+	}
+	start, end, ok := aggregate.StateYears(f.statePath, opts)
+	if !ok {
+		return
+	}
+	f.startYear, f.endYear = start, max(end, time.Now().In(location).Year())
+	f.allYears = true
+}
+
+// writerFlags configure output.Writer; they are shared by analyze and serve.
+type writerFlags struct {
+	granularity       string
+	formats           string
+	topN              int
+	fullLimit         int
+	longTailThreshold int
+	allTimeTop        int
+	sort              string
+	writeWorkers      int
+	channelAliases    bool
+	recapYear         int
+	report            string
+	reportTemplate    string
+	ytAPIKey          string
+	ytCache           string
+	ytRate            float64
+	durations         string
+	defaultDuration   time.Duration
+	sessionGap        time.Duration
+	rollingDays       int
+	gapDays           int
+	velocityChange    int
+	discoveryMin      int
+	icalThreshold     int
+	dailyChannels     int
+	categories        string
+	whatIfBlock       string
+	subscriptions     string
+	likes             string
+	playlists         string
+	comments          string
+	templates         stringList
+	keywordsByChannel bool
+	metricsOut        string
+	plugins           stringList
+}
+
+func addWriterFlags(fs *flag.FlagSet) *writerFlags {
+	f := &writerFlags{}
+	fs.StringVar(&f.granularity, "granularity", "year", "Bucket size for top channel files: year, month, week (ISO) or day")
+	fs.StringVar(&f.formats, "formats", "json", "Comma-separated formats for channel and video lists: json, csv, parquet (parquet also writes every counted watch to activities.parquet)")
+	fs.IntVar(&f.topN, "top", 6, "Top N channels per year")
+	fs.IntVar(&f.fullLimit, "full-limit", 0, "Limit for channels_full_<YEAR>.json (0 = all channels)")
+	fs.IntVar(&f.longTailThreshold, "long-tail-threshold", 0, "In channels_full_<YEAR>.json, fold channels with fewer than N watches into one '(long tail)' entry (0 = off)")
+	fs.IntVar(&f.allTimeTop, "alltime-top", 100, "Top N channels for all-time output")
+	fs.StringVar(&f.sort, "sort", "count_desc", "Order of the channel lists: "+strings.Join(aggregate.ChannelSorts, ", ")+"; the channels listed are the most watched either way")
+	fs.IntVar(&f.writeWorkers, "write-workers", 8, "Output files written at once; more help on network drives (1 = one after another)")
+	fs.BoolVar(&f.channelAliases, "channel-aliases", false, "Write aliases.json mapping each channel to the raw name/URL variants merged into it")
+	fs.IntVar(&f.recapYear, "recap", 0, "Also write recap_<YEAR>.json, a year-in-review summary for this year (0 = off)")
+	fs.StringVar(&f.report, "report", "", "Also write a report: html writes a self-contained report.html with charts, markdown a REPORT.md")
+	fs.Var(&f.templates, "template", "Go text/template file rendered into -outdir under its name without .tmpl, with the summary and every channel and video list as data (repeatable)")
+	fs.StringVar(&f.reportTemplate, "report-template", "", "Go template file to render the -report with instead of the built-in one")
+	fs.DurationVar(&f.sessionGap, "session-gap", 30*time.Minute, "Watches less than this apart form one session in sessions_<YEAR>.json (0 = no session files)")
+	fs.IntVar(&f.rollingDays, "rolling-days", 90, "Window length in days for rolling_top_channels.json, one window ending each month (0 = off)")
+	fs.IntVar(&f.gapDays, "gap-days", 7, "Write gaps.json with every break of at least N days without a watch and the longest breaks per year (0 = off)")
+	fs.IntVar(&f.velocityChange, "velocity-change", 50, "Write velocity.json with the videos per day of every month, flagging months up or down at least N percent from the month before as accelerating or decelerating (0 = off)")
+	fs.IntVar(&f.discoveryMin, "discovery-min", 5, "Write discoveries.json with the channels first watched each year that went on to have at least N watches (0 = off)")
+	fs.IntVar(&f.dailyChannels, "daily-channels", 0, "Add a column to daily_counts.csv for each of the N most watched channels, with its watches per day (0 = totals only)")
+	fs.IntVar(&f.icalThreshold, "ical-threshold", 0, "Write heavy_days.ics with an all-day calendar event for every day of more than N watches, with its count and top channels (0 = off)")
+	fs.StringVar(&f.categories, "categories", "", "YAML file mapping categories to lists of channel names, URLs or /regexp/ (e.g. \"education:\" then \"  - 3Blue1Brown\"): writes categories.json with each category's watches and share per year")
+	fs.StringVar(&f.whatIfBlock, "what-if-block", "", "File listing channels to imagine blocked, in the -exclude-channels format: writes reclaimed_time.json with the watches (and, with durations, hours) they took per year")
+	fs.BoolVar(&f.keywordsByChannel, "keywords-by-channel", false, "Also list the title keywords of each year's top channels (-top) in keywords_<YEAR>.json")
+	fs.Var(&f.plugins, "plugin", "Run the compiled-in custom aggregator with this name and write its outputs to -outdir (repeatable; see aggregate.RegisterPlugin)")
+	fs.StringVar(&f.metricsOut, "metrics-out", "", "Also write totals, per-year counts and top channel counts as Prometheus gauges to this file (serve also has them at /metrics)")
+	fs.StringVar(&f.subscriptions, "subscriptions", "", "subscriptions.csv, or a Takeout .zip or directory containing it: marks subscribed channels in the channel lists and writes subscriptions.json")
+	fs.StringVar(&f.likes, "likes", "", "Liked videos playlist CSV, My Activity JSON with \"Liked\" entries, or a Takeout .zip or directory containing the playlist: writes likes.json with watched vs liked videos and the like rate per channel")
+	fs.StringVar(&f.playlists, "playlists", "", "Playlist CSV, the Takeout playlists folder, or a Takeout .zip or directory containing it: writes playlists.json with how many of each playlist's videos were watched and the unwatched Watch Later videos")
+	fs.StringVar(&f.comments, "comments", "", "comments.csv or live chats.csv, or a Takeout .zip or directory containing them: adds comment and live chat counts per year, the most commented channels and comment lengths to summary.json as engagement")
+	fs.StringVar(&f.ytAPIKey, "yt-api-key", "", "YouTube Data API key; looks up video durations and categories to write watch_time_estimates.json and find Shorts for shorts.json")
+	fs.StringVar(&f.ytCache, "yt-cache", "yt-cache.json", "File caching YouTube Data API lookups between runs (empty = no cache)")
+	fs.Float64Var(&f.ytRate, "yt-rate", 5, "Maximum YouTube Data API requests per second")
+	fs.StringVar(&f.durations, "durations", "", "CSV of video ID and duration (seconds or ISO 8601) to write watch_time_estimates.json without the API; with -yt-api-key only the videos missing from it are looked up")
+	fs.DurationVar(&f.defaultDuration, "default-duration", 0, "Duration assumed in watch_time_estimates.json for watched videos without a known one, e.g. 8m; also writes it without -durations or -yt-api-key (0 = leave them out)")
+	return f
+}
+
+// writer validates the flags, exiting with status 2 on error, and returns the
+// configured Writer.
+func (f *writerFlags) writer(in *inputFlags) output.Writer {
+	formats, err := output.ParseFormats(f.formats)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: -formats:", err)
+		os.Exit(2)
+	}
+	switch f.granularity {
+	case "year", "month", "week", "day":
+	default:
+		fmt.Fprintln(os.Stderr, "error: -granularity must be year, month, week or day")
+		os.Exit(2)
+	}
+	if f.writeWorkers < 1 {
+		fmt.Fprintln(os.Stderr, "error: -write-workers must be at least 1")
+		os.Exit(2)
+	}
+	if !slices.Contains(aggregate.ChannelSorts, f.sort) {
+		fmt.Fprintln(os.Stderr, "error: -sort must be one of", strings.Join(aggregate.ChannelSorts, ", "))
+		os.Exit(2)
+	}
+	if f.recapYear != 0 && ((in.startYear != 0 && f.recapYear < in.startYear) || (in.endYear != 0 && f.recapYear > in.endYear)) {
+		fmt.Fprintln(os.Stderr, "error: -recap year must be within -start..-end")
+		os.Exit(2)
+	}
+	switch f.report {
+	case "", "html", "markdown":
+	default:
+		fmt.Fprintln(os.Stderr, "error: -report must be html or markdown")
+		os.Exit(2)
+	}
+	var reportTemplate string
+	if f.reportTemplate != "" {
+		if f.report == "" {
+			fmt.Fprintln(os.Stderr, "error: -report-template needs -report")
+			os.Exit(2)
+		}
+		b, err := os.ReadFile(f.reportTemplate)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error reading -report-template:", err)
+			os.Exit(1)
+		}
+		reportTemplate = string(b)
+	}
+	if f.sessionGap < 0 {
+		fmt.Fprintln(os.Stderr, "error: -session-gap must be >= 0")
+		os.Exit(2)
+	}
+	if f.rollingDays < 0 {
+		fmt.Fprintln(os.Stderr, "error: -rolling-days must be >= 0")
+		os.Exit(2)
+	}
+	if f.gapDays < 0 {
+		fmt.Fprintln(os.Stderr, "error: -gap-days must be >= 0")
+		os.Exit(2)
+	}
+	if f.velocityChange < 0 {
+		fmt.Fprintln(os.Stderr, "error: -velocity-change must be >= 0")
+		os.Exit(2)
+	}
+	if f.discoveryMin < 0 {
+		fmt.Fprintln(os.Stderr, "error: -discovery-min must be >= 0")
+		os.Exit(2)
+	}
+	if f.icalThreshold < 0 {
+		fmt.Fprintln(os.Stderr, "error: -ical-threshold must be >= 0")
+		os.Exit(2)
+	}
+	if f.dailyChannels < 0 {
+		fmt.Fprintln(os.Stderr, "error: -daily-channels must be >= 0")
+		os.Exit(2)
+	}
+	if f.ytRate <= 0 {
+		fmt.Fprintln(os.Stderr, "error: -yt-rate must be > 0")
+		os.Exit(2)
+	}
+	if f.defaultDuration < 0 {
+		fmt.Fprintln(os.Stderr, "error: -default-duration must be >= 0")
+		os.Exit(2)
+	}
+	var templates []*template.Template
+	for _, p := range f.templates {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error reading -template:", err)
+			os.Exit(1)
+		}
+		t, err := output.ParseCustomTemplate(p, string(b))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error parsing -template:", err)
+			os.Exit(1)
+		}
+		templates = append(templates, t)
+	}
+	var plugins map[string]aggregate.Plugin
+	for _, name := range f.plugins {
+		if _, dup := plugins[name]; dup {
+			continue
+		}
+		p, err := aggregate.NewPlugin(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error: -plugin:", err)
+			os.Exit(2)
+		}
+		if plugins == nil {
+			plugins = make(map[string]aggregate.Plugin)
+		}
+		plugins[name] = p
+	}
+	var subs []parser.Subscription
+	if f.subscriptions != "" {
+		var err error
+		if subs, err = parser.ReadSubscriptions(f.subscriptions); err != nil {
+			fmt.Fprintln(os.Stderr, "error reading -subscriptions:", err)
+			os.Exit(1)
+		}
+	}
+	var likes []parser.Like
+	if f.likes != "" {
+		var err error
+		if likes, err = parser.ReadLikes(f.likes); err != nil {
+			fmt.Fprintln(os.Stderr, "error reading -likes:", err)
+			os.Exit(1)
+		}
+	}
+	var playlists []parser.Playlist
+	if f.playlists != "" {
+		var err error
+		if playlists, err = parser.ReadPlaylists(f.playlists); err != nil {
+			fmt.Fprintln(os.Stderr, "error reading -playlists:", err)
+			os.Exit(1)
+		}
+	}
+	var categories *aggregate.ChannelCategories
+	if f.categories != "" {
+		var err error
+		if categories, err = aggregate.LoadChannelCategories(f.categories); err != nil {
+			fmt.Fprintln(os.Stderr, "error loading -categories:", err)
+			os.Exit(1)
+		}
+	}
+	var blocklist *aggregate.ChannelFilter
+	if f.whatIfBlock != "" {
+		var err error
+		if blocklist, err = aggregate.LoadChannelFilter(f.whatIfBlock); err != nil {
+			fmt.Fprintln(os.Stderr, "error loading -what-if-block:", err)
+			os.Exit(1)
+		}
+	}
+	var durations map[string]youtube.Video
+	if f.durations != "" {
+		var err error
+		if durations, err = youtube.ReadDurations(f.durations); err != nil {
+			fmt.Fprintln(os.Stderr, "error reading -durations:", err)
+			os.Exit(1)
+		}
+	}
+	var comments []parser.Comment
+	if f.comments != "" {
+		var err error
+		if comments, err = parser.ReadComments(f.comments); err != nil {
+			fmt.Fprintln(os.Stderr, "error reading -comments:", err)
+			os.Exit(1)
+		}
+	}
+
+	return output.Writer{
+		Formats:           formats,
+		TopN:              f.topN,
+		FullLimit:         f.fullLimit,
+		LongTailThreshold: f.longTailThreshold,
+		AllTimeTop:        f.allTimeTop,
+		Sort:              f.sort,
+		WriteWorkers:      f.writeWorkers,
+		RecapYear:         f.recapYear,
+		Aliases:           f.channelAliases,
+		Report:            f.report,
+		ReportTemplate:    reportTemplate,
+		Subscriptions:     subs,
+		Likes:             likes,
+		Playlists:         playlists,
+		Comments:          comments,
+		Templates:         templates,
+		KeywordsByChannel: f.keywordsByChannel,
+		GapDays:           f.gapDays,
+		VelocityChange:    f.velocityChange,
+		DiscoveryMin:      f.discoveryMin,
+		ICalThreshold:     f.icalThreshold,
+		DailyChannels:     f.dailyChannels,
+		Categories:        categories,
+		Blocklist:         blocklist,
+		MetricsOut:        f.metricsOut,
+		VideoDetails:      durations,
+		DefaultDuration:   int(f.defaultDuration.Seconds()),
+		Plugins:           plugins,
+	}
+}
+
+// apply sets the aggregation options the Writer's outputs depend on and
+// feeds the entries to its plugins.
+func (f *writerFlags) apply(opts *aggregate.Options, w *output.Writer) {
+	opts.Granularity = f.granularity
+	opts.TrackAliases = f.channelAliases
+	opts.SessionGap = f.sessionGap
+	opts.RollingDays = f.rollingDays
+	opts.DayChannels = f.icalThreshold > 0 || f.dailyChannels > 0
+	names := make([]string, 0, len(w.Plugins))
+	for name := range w.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		opts.AddActivitySink(w.Plugins[name].OnActivity)
+	}
+}
+
+// lookupVideos adds to w.VideoDetails the videos missing from -durations,
+// looked up with the YouTube Data API when -yt-api-key is set. A failed
+// lookup (e.g. out of quota) only warns, since the cache keeps whatever was
+// fetched for the next run.
+func (f *writerFlags) lookupVideos(w *output.Writer, agg *aggregate.Aggregator) {
+	if f.ytAPIKey == "" {
+		return
+	}
+	client, err := youtube.NewClient(f.ytAPIKey, f.ytCache)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error reading -yt-cache:", err)
+		os.Exit(1)
+	}
+	client.Interval = time.Duration(float64(time.Second) / f.ytRate)
+
+	var ids []string
+	for vk := range agg.AllTimeVideoCounts {
+		id := parser.VideoIDFromURL(agg.VideoInfo[vk].URL)
+		if _, known := w.VideoDetails[id]; id != "" && !known {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	fetched, err := client.Videos(ids)
+	if w.VideoDetails == nil {
+		w.VideoDetails = make(map[string]youtube.Video, len(fetched))
+	}
+	for id, v := range fetched {
+		w.VideoDetails[id] = v
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: YouTube Data API lookup stopped early, estimates only cover the videos fetched so far:", err)
+	}
+}
+
+// aggregateAccounts counts each -in label=path account on its own with opts,
+// for the per-account breakdowns and outputs of a household run, naming
+// channels as household does. It exits on error.
+func aggregateAccounts(opts aggregate.Options, accounts []account, progress bool, household *aggregate.Aggregator) []output.Account {
+	opts.OnWatch, opts.OnActivity = nil, nil
+	out := make([]output.Account, 0, len(accounts))
+	for _, a := range accounts {
+		inputs, err := parser.ExpandInputs(a.paths)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error opening input:", err)
+			os.Exit(1)
+		}
+		aopts := opts
+		aopts.Dedupe = len(inputs) > 1
+		// Topics are merged the way household merged them, by
+		// ResolveChannelsLike.
+		aopts.MergeTopics = false
+		agg, merged, processing := aggregateInputs(aopts, inputs, progress, "")
+		agg.ResolveChannelsLike(household)
+		out = append(out, output.Account{Label: a.label, Agg: agg, Inputs: merged, Processing: processing})
+	}
+	return out
+}
+
+// aggregateInputs consumes every input into one Aggregator, exiting on
+// error, and reports per-input entry and duplicate counts. With progress set
+// it also reports how far into each input it is. With statePath set it only
+// counts the entries newer than the state saved there by the last run, adds
+// the state's counts and saves the result for the next run.
+func aggregateInputs(opts aggregate.Options, inputs []string, progress bool, statePath string) (*aggregate.Aggregator, []output.MergeInput, output.ProcessingStats) {
+	var pp *progressPrinter
+	if progress {
+		pp = newProgressPrinter()
+		opts.OnProgress = pp.update
+	}
+	var state *aggregate.Aggregator
+	if statePath != "" {
+		var err error
+		state, err = aggregate.LoadState(statePath, opts)
+		switch {
+		case err == nil:
+			opts.After, opts.AfterKeys = state.LatestTime, state.LatestKeys
+		case errors.Is(err, fs.ErrNotExist):
+		case errors.Is(err, aggregate.ErrStateMismatch):
+			fmt.Fprintf(os.Stderr, "warning: %s was saved by another version or with different counting flags; counting every entry again\n", statePath)
+		default:
+			fmt.Fprintln(os.Stderr, "error loading -state:", err)
+			os.Exit(1)
+		}
+	}
+	agg := aggregate.New(opts)
+
+	started := time.Now()
+	var merged []output.MergeInput
+	for _, p := range inputs {
+		entries, dups, read := agg.EntriesDecoded, agg.Duplicates, agg.BytesRead
+		if pp != nil {
+			size, _ := parser.InputSize(p)
+			pp.start(p, size)
+		}
+		if err := agg.ConsumeFile(p); err != nil {
+			if pp != nil {
+				fmt.Fprintln(os.Stderr)
+			}
+			fmt.Fprintf(os.Stderr, "error parsing input %s: %v\n", p, err)
+			os.Exit(1)
+		}
+		if pp != nil {
+			pp.done(agg.BytesRead-read, agg.EntriesDecoded)
+		}
+		merged = append(merged, output.MergeInput{
+			Path:              p,
+			Entries:           agg.EntriesDecoded - entries,
+			DuplicatesDropped: agg.Duplicates - dups,
+		})
+	}
+	if state != nil {
+		agg.MergeState(state)
+		fmt.Fprintf(os.Stderr, "resumed from %s: %d entries up to %s were already counted\n",
+			statePath, agg.AlreadyCounted, opts.After.Format(time.RFC3339))
+	}
+	if statePath != "" {
+		if err := agg.SaveState(statePath); err != nil {
+			fmt.Fprintln(os.Stderr, "error saving -state:", err)
+			os.Exit(1)
+		}
+	}
+	agg.ResolveChannels()
+	if agg.Skipped > 0 {
+		pe := agg.ParseErrors[0]
+		fmt.Fprintf(os.Stderr, "warning: skipped %d entries that could not be decoded, the first being entry %d of %s (byte %d): %s; pass -strict to stop at it instead\n",
+			agg.Skipped, pe.Entry, pe.Input, pe.Offset, pe.Error)
+	}
+	if agg.NotWatched > 0 && agg.NotWatched == agg.EntriesDecoded-agg.Duplicates {
+		fmt.Fprintf(os.Stderr, "warning: no entry title starts with a known watched prefix (e.g. %q); if the export is in another language, pass its prefix with -title-prefix\n", agg.NotWatchedSample)
+	}
+	return agg, merged, output.NewProcessingStats(agg, time.Since(started))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"example.com/hello/takeout/synth"
+)
+
+// runGenerate writes a synthetic watch history of any size, to measure
+// analyze (or anything else) on more history than a real export has.
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	def := synth.DefaultConfig(0)
+	n := fs.Int("n", 100000, "Number of entries to generate")
+	channels := fs.Int("channels", 0, "Distinct channels (default: one per 50 entries)")
+	videos := fs.Int("videos", 0, "Distinct videos (default: one per 3 entries)")
+	from := fs.String("from", def.Start.Format(time.DateOnly), "First day of the history, YYYY-MM-DD in UTC")
+	to := fs.String("to", def.End.AddDate(0, 0, -1).Format(time.DateOnly), "Last day of the history (inclusive), YYYY-MM-DD in UTC")
+	seed := fs.Uint64("seed", def.Seed, "Seed of the history; the same flags give the same history")
+	outPath := fs.String("o", "synthetic-watch-history.json", "Path of the JSON export to write; - writes it to stdout")
+	parseFlags(fs, args)
+
+	if *n < 0 || *channels < 0 || *videos < 0 {
+		fmt.Fprintln(os.Stderr, "error: -n, -channels and -videos must not be negative")
+		os.Exit(2)
+	}
+	cfg := synth.DefaultConfig(*n)
+	cfg.Seed = *seed
+	if *channels > 0 {
+		cfg.Channels = *channels
+	}
+	if *videos > 0 {
+		cfg.Videos = *videos
+	}
+	start, err := time.Parse(time.DateOnly, *from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: -from must be a date like 2019-01-01")
+		os.Exit(2)
+	}
+	end, err := time.Parse(time.DateOnly, *to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error: -to must be a date like 2024-12-31")
+		os.Exit(2)
+	}
+	if end.Before(start) {
+		fmt.Fprintln(os.Stderr, "error: -from must not be after -to")
+		os.Exit(2)
+	}
+	cfg.Start, cfg.End = start, end.AddDate(0, 0, 1)
+
+	w := createActivityOutput(*outPath)
+	if err := synth.Generate(cfg, w.Write); err != nil {
+		fmt.Fprintln(os.Stderr, "error generating history:", err)
+		os.Exit(1)
+	}
+	closeActivityOutput(w)
+	if *outPath != "-" {
+		fmt.Printf("Wrote %d entries to: %s\n", w.Count(), *outPath)
+	}
+}
@@ -0,0 +1,89 @@
+// Command takeout summarizes a Google Takeout YouTube watch history into
+// per-year and all-time top channel and video files.
+//
+// Usage:
+//
+//	takeout analyze -in watch-history.json [flags]   write JSON/CSV outputs
+//	takeout merge -in a.json -in b.zip -o merged.json
+//	takeout extract -in watch-history.json -channel X -year 2022
+//	takeout sample -in watch-history.json -n 1000
+//	takeout generate -n 1000000 -o synthetic.json
+//	takeout diff old.json new.json
+//	takeout compare a.json b.json
+//	takeout serve -in watch-history.json -addr :8080
+//	takeout tui -in watch-history.json
+//	takeout verify out
+//	takeout validate -in watch-history.json
+//
+// Without a subcommand, the flags are those of analyze.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	_ "time/tzdata" // so -tz works on systems without a zoneinfo database
+)
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+var commands = []struct {
+	name    string
+	summary string
+	run     func(args []string)
+}{
+	{"analyze", "aggregate watch history into JSON/CSV, Parquet or SQLite outputs", runAnalyze},
+	{"merge", "combine several exports into one deduplicated watch-history.json", runMerge},
+	{"extract", "write the counted entries of a channel or year as raw JSON activities", runExtract},
+	{"sample", "write a random sample of the counted entries as raw JSON activities", runSample},
+	{"generate", "write a synthetic watch history of any size, for benchmarking", runGenerate},
+	{"diff", "compare channels, videos and totals between two exports", runDiff},
+	{"compare", "compare the channels two people watch: overlap and what is unique to each", runCompare},
+	{"serve", "analyze and serve an interactive dashboard over HTTP", runServe},
+	{"tui", "analyze and browse years and channels in the terminal", runTUI},
+	{"verify", "check an analyze output directory against its manifest.json", runVerify},
+	{"validate", "sanity-check an export before analyzing it: entries, time range, actions, locale", runValidate},
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		switch arg := os.Args[1]; arg {
+		case "help", "-h", "-help", "--help":
+			usage()
+			return
+		default:
+			for _, c := range commands {
+				if c.name == arg {
+					c.run(os.Args[2:])
+					return
+				}
+			}
+			if !strings.HasPrefix(arg, "-") {
+				fmt.Fprintf(os.Stderr, "takeout: unknown command %q\n", arg)
+				usage()
+				os.Exit(2)
+			}
+		}
+	}
+	// Backwards compatible: plain flags mean analyze.
+	runAnalyze(os.Args[1:])
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: takeout <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Without a command, flags are those of analyze. Run 'takeout <command> -h' for a command's flags.")
+}
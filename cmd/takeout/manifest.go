@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"io"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"example.com/hello/takeout/output"
+	"example.com/hello/takeout/parser"
+)
+
+// secretFlags are hidden in the manifest's flags.
+var secretFlags = map[string]bool{"redact": true, "yt-api-key": true}
+
+// toolVersion is the module version and, for builds from a checkout, the
+// VCS revision the binary was built from.
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	v := info.Main.Version
+	var rev, dirty string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				dirty = "-dirty"
+			}
+		}
+	}
+	if rev != "" {
+		v += " " + rev + dirty
+	}
+	return v
+}
+
+// runManifest describes this run for manifest.json: the flags that were set
+// on fs (secrets hidden) and every input with its size and, with hash (for
+// -bundle), its SHA-256. Hashing is left out otherwise, since it reads every
+// input a second time.
+func runManifest(fs *flag.FlagSet, inputs []string, location *time.Location, hash bool) (*output.Manifest, error) {
+	m := &output.Manifest{
+		Tool:      "takeout analyze",
+		Version:   toolVersion(),
+		Generated: time.Now().In(location).Format(time.RFC3339),
+		Inputs:    []output.ManifestInput{},
+		Flags:     make(map[string]string),
+	}
+	fs.Visit(func(f *flag.Flag) {
+		v := f.Value.String()
+		if secretFlags[f.Name] {
+			v = "(hidden)"
+		}
+		m.Flags[f.Name] = v
+	})
+	for _, p := range inputs {
+		in, err := describeInput(p, hash)
+		if err != nil {
+			return nil, err
+		}
+		m.Inputs = append(m.Inputs, in)
+	}
+	return m, nil
+}
+
+func describeInput(p string, hash bool) (output.ManifestInput, error) {
+	if p == parser.Stdin {
+		return output.ManifestInput{Path: p}, nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return output.ManifestInput{}, err
+	}
+	defer f.Close()
+	if !hash {
+		info, err := f.Stat()
+		if err != nil {
+			return output.ManifestInput{}, err
+		}
+		return output.ManifestInput{Path: p, Size: info.Size()}, nil
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return output.ManifestInput{}, err
+	}
+	return output.ManifestInput{Path: p, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
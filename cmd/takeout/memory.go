@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/output"
+)
+
+// parseSize parses a byte count like "512MB", "2GiB" or "1500000". Decimal
+// (KB, MB, GB) and binary (KiB, MiB, GiB) units are accepted.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		mult   int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"B", 1},
+	}
+	num, mult := strings.TrimSpace(s), int64(1)
+	for _, u := range units {
+		if strings.HasSuffix(strings.ToUpper(num), strings.ToUpper(u.suffix)) {
+			num, mult = strings.TrimSpace(num[:len(num)-len(u.suffix)]), u.mult
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || !(v >= 0) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid size %q (want e.g. 512MB or 2GiB)", s)
+	}
+	return int64(v * float64(mult)), nil
+}
+
+// guardMemory makes the garbage collector work to keep the process under
+// limit bytes and, if the live heap still grows past it, stops the run with
+// the memory statistics rather than letting the machine start swapping.
+func guardMemory(limit int64) {
+	debug.SetMemoryLimit(limit)
+	sample := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	go func() {
+		for range time.Tick(250 * time.Millisecond) {
+			metrics.Read(sample)
+			if live := sample[0].Value.Uint64(); live > uint64(limit) {
+				output.CleanupAndExit(1, fmt.Sprintf("error: live heap of %s is over -max-mem %s (%s); counting fewer years (-start/-end), coarser -granularity or -session-gap 0 needs less",
+					formatMB(live), formatMB(uint64(limit)), memoryStats()))
+			}
+		}
+	}()
+}
+
+// memoryStats summarizes the Go runtime's memory use for -stats and
+// -max-mem.
+func memoryStats() string {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return fmt.Sprintf("%s heap in use, %s obtained from the OS, %d GC cycles",
+		formatMB(ms.HeapAlloc), formatMB(ms.Sys), ms.NumGC)
+}
+
+func formatMB(b uint64) string { return fmt.Sprintf("%.1f MB", float64(b)/1e6) }
+
+// formatBytes is formatMB in kB below a megabyte, for output file sizes.
+func formatBytes(b int64) string {
+	if b < 1e6 {
+		return fmt.Sprintf("%.1f kB", float64(b)/1e3)
+	}
+	return formatMB(uint64(b))
+}
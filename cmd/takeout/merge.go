@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"example.com/hello/takeout/output"
+	"example.com/hello/takeout/parser"
+)
+
+// runMerge writes every activity from the inputs, minus duplicates, into a
+// single watch-history.json that analyze (or anything else) can read.
+func runMerge(args []string) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	var inPaths stringList
+	fs.Var(&inPaths, "in", "Export to merge: watch-history.json/.html, a Takeout .zip, or a directory of them (repeat for each)")
+	outPath := fs.String("o", "merged-watch-history.json", "Path of the merged JSON export to write")
+	parseFlags(fs, args)
+
+	output.InstallInterruptCleanup()
+
+	if len(inPaths) == 0 {
+		fmt.Fprintln(os.Stderr, "error: -in is required")
+		os.Exit(2)
+	}
+	inputs, err := parser.ExpandInputs(inPaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error opening input:", err)
+		os.Exit(1)
+	}
+
+	w, err := output.NewActivityWriter(*outPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error creating merged output:", err)
+		os.Exit(1)
+	}
+	seen := make(map[string]struct{})
+	for _, p := range inputs {
+		entries, dups, err := mergeInput(p, w, seen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error parsing input %s: %v\n", p, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d entries, %d duplicates dropped\n", p, entries, dups)
+	}
+	if err := w.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "error writing merged output:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %d entries to: %s\n", w.Count(), *outPath)
+}
+
+// mergeInput copies the activities in path to w, skipping any whose Key is
+// already in seen.
+func mergeInput(path string, w *output.ActivityWriter, seen map[string]struct{}) (entries, dups int, err error) {
+	f, err := parser.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	dec, err := parser.NewDecoder(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	for {
+		a, err := dec.Next()
+		if err == io.EOF {
+			return entries, dups, nil
+		}
+		if err != nil {
+			return entries, dups, err
+		}
+		entries++
+		if _, dup := seen[a.Key()]; dup {
+			dups++
+			continue
+		}
+		seen[a.Key()] = struct{}{}
+		if err := w.Write(a); err != nil {
+			return entries, dups, err
+		}
+	}
+}
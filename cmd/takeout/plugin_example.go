@@ -0,0 +1,74 @@
+//go:build exampleplugin
+
+// An example custom aggregator, compiled in only with
+//
+//	go build -tags exampleplugin ./cmd/takeout
+//
+// and enabled with -plugin weekend. Your own plugins can follow the same
+// pattern: a file like this one, or a package imported for its init.
+package main
+
+import (
+	"math"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+func init() {
+	aggregate.RegisterPlugin("weekend", func() aggregate.Plugin { return &weekendPlugin{} })
+}
+
+type weekendYear struct {
+	Year           int     `json:"year"`
+	Weekday        int     `json:"weekday_watches"`
+	Weekend        int     `json:"weekend_watches"`
+	WeekendPercent float64 `json:"weekend_percent"`
+}
+
+// weekendPlugin splits each year's counted watches into weekdays and
+// weekends, writing weekend_watches.json.
+type weekendPlugin struct {
+	counts map[int]*weekendYear
+	years  []weekendYear
+}
+
+func (p *weekendPlugin) OnActivity(r aggregate.ActivityRecord) error {
+	if !r.Counted || r.Time == nil {
+		return nil
+	}
+	if p.counts == nil {
+		p.counts = make(map[int]*weekendYear)
+	}
+	y := p.counts[r.Year]
+	if y == nil {
+		y = &weekendYear{Year: r.Year}
+		p.counts[r.Year] = y
+	}
+	switch r.Time.Weekday() {
+	case time.Saturday, time.Sunday:
+		y.Weekend++
+	default:
+		y.Weekday++
+	}
+	return nil
+}
+
+func (p *weekendPlugin) Finalize(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	for year := opts.StartYear; year <= opts.EndYear; year++ {
+		y := weekendYear{Year: year}
+		if c := p.counts[year]; c != nil {
+			y = *c
+		}
+		if total := y.Weekday + y.Weekend; total > 0 {
+			y.WeekendPercent = math.Round(float64(y.Weekend)/float64(total)*1000) / 10
+		}
+		p.years = append(p.years, y)
+	}
+	return nil
+}
+
+func (p *weekendPlugin) Outputs() map[string]any {
+	return map[string]any{"weekend_watches": map[string]any{"years": p.years}}
+}
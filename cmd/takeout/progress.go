@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// progressPrinter reports -progress on stderr: a line redrawn in place on a
+// terminal, or a new line every few seconds when stderr is redirected.
+type progressPrinter struct {
+	name     string
+	total    int64
+	tty      bool
+	interval time.Duration
+	last     time.Time
+}
+
+func newProgressPrinter() *progressPrinter {
+	p := &progressPrinter{interval: 5 * time.Second}
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		p.tty = true
+		p.interval = 200 * time.Millisecond
+	}
+	return p
+}
+
+// start begins reporting on the input at path, total bytes long (0 when
+// unknown).
+func (p *progressPrinter) start(path string, total int64) {
+	p.name = filepath.Base(path)
+	p.total = total
+	p.last = time.Time{}
+}
+
+// update is an aggregate.Options.OnProgress callback.
+func (p *progressPrinter) update(bytesRead int64, entries int) {
+	if time.Since(p.last) < p.interval {
+		return
+	}
+	p.last = time.Now()
+	p.print(bytesRead, entries)
+}
+
+// done prints the final state of the current input.
+func (p *progressPrinter) done(bytesRead int64, entries int) {
+	p.print(bytesRead, entries)
+	if p.tty {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func (p *progressPrinter) print(bytesRead int64, entries int) {
+	line := fmt.Sprintf("%s: %.1f MB", p.name, float64(bytesRead)/1e6)
+	if p.total > 0 {
+		line += fmt.Sprintf(" / %.1f MB (%3.0f%%)", float64(p.total)/1e6, 100*float64(bytesRead)/float64(p.total))
+	}
+	line += fmt.Sprintf(", %d entries", entries)
+	if p.tty {
+		fmt.Fprintf(os.Stderr, "\r\033[K%s", line)
+		return
+	}
+	fmt.Fprintln(os.Stderr, line)
+}
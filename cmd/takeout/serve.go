@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"example.com/hello/takeout/output"
+)
+
+// runServe runs the analysis once and serves an interactive dashboard over
+// the results from memory until interrupted. With -outdir it also writes the
+// usual output files.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	in := addInputFlags(fs)
+	wf := addWriterFlags(fs)
+	outDir := fs.String("outdir", "", "Also write the output files to this directory (default: write nothing)")
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	parseFlags(fs, args)
+
+	output.InstallInterruptCleanup()
+
+	location := in.validate()
+	w := wf.writer(in)
+	if w.Plugins != nil && *outDir == "" {
+		fmt.Fprintln(os.Stderr, "error: -plugin writes its outputs to -outdir, which serve only writes when set")
+		os.Exit(2)
+	}
+	opts, inputs := in.options(location)
+	wf.apply(&opts, &w)
+	// /api/channel/{id}/timeline breaks each year down by month.
+	opts.DayChannels = true
+
+	agg, merged, processing := aggregateInputs(opts, inputs, in.progress, "")
+	if *outDir != "" {
+		wf.lookupVideos(&w, agg)
+		w.Dir = *outDir
+		w.Inputs = merged
+		w.Processing = processing
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			fmt.Fprintln(os.Stderr, "error creating outdir:", err)
+			os.Exit(1)
+		}
+		if err := w.Write(agg); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing outputs:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote JSON outputs to: %s\n", *outDir)
+	}
+
+	fmt.Printf("Serving dashboard on http://%s/\n", *addr)
+	if err := http.ListenAndServe(*addr, output.NewDashboard(agg, w.Metrics(agg))); err != nil {
+		fmt.Fprintln(os.Stderr, "error serving:", err)
+		os.Exit(1)
+	}
+}
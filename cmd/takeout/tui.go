@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"example.com/hello/takeout/output"
+	"example.com/hello/takeout/parser"
+)
+
+// runTUI runs the analysis once and browses the results in the terminal:
+// years, channel sorting and search, and a channel's per-year counts. It
+// writes no files.
+func runTUI(args []string) {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	in := addInputFlags(fs)
+	rows := fs.Int("rows", 20, "Channels per page")
+	parseFlags(fs, args)
+	if *rows < 1 {
+		fmt.Fprintln(os.Stderr, "error: -rows must be at least 1")
+		os.Exit(2)
+	}
+
+	location := in.validate()
+	opts, inputs := in.options(location)
+	for _, p := range inputs {
+		if p == parser.Stdin {
+			fmt.Fprintln(os.Stderr, "error: tui reads its commands from stdin, so -in - cannot be used")
+			os.Exit(2)
+		}
+	}
+	agg, _, _ := aggregateInputs(opts, inputs, in.progress, "")
+
+	e := output.NewExplorer(agg)
+	e.Rows = *rows
+	if fi, err := os.Stdout.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb" {
+		e.Clear = true
+	}
+	if err := e.Run(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "error reading commands:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"example.com/hello/takeout/parser"
+)
+
+// runValidate reads each export through without aggregating it and prints
+// what it holds: its entries, time range, actions, likely language and how
+// many entries lack a channel, exiting with status 1 if any export cannot be
+// analyzed.
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	var inPaths stringList
+	fs.Var(&inPaths, "in", "Export to check: watch-history.json/.html, a Takeout .zip, or a directory of them (repeatable; - reads stdin)")
+	asJSON := fs.Bool("json", false, "Print the checks as JSON, keyed by input path")
+	parseFlags(fs, args)
+
+	if len(inPaths) == 0 {
+		fmt.Fprintln(os.Stderr, "error: -in is required")
+		os.Exit(2)
+	}
+	inputs, err := parser.ExpandInputs(inPaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error opening input:", err)
+		os.Exit(1)
+	}
+
+	results := make(map[string]*parser.Validation, len(inputs))
+	failed := false
+	for _, p := range inputs {
+		v, err := validateInput(p)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading input %s: %v\n", p, err)
+			os.Exit(1)
+		}
+		results[p] = v
+		failed = failed || len(v.Problems) > 0
+		if !*asJSON {
+			printValidation(p, v)
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintln(os.Stderr, "error writing checks:", err)
+			os.Exit(1)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// validateInput validates the export at path.
+func validateInput(path string) (*parser.Validation, error) {
+	f, err := parser.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parser.Validate(f)
+}
+
+// printValidation prints v as a short report on path.
+func printValidation(path string, v *parser.Validation) {
+	fmt.Printf("%s: %s export, %d entries\n", path, strings.ToUpper(v.Format), v.Entries)
+	if v.FirstTime != "" {
+		fmt.Printf("  time range:      %s to %s\n", v.FirstTime, v.LastTime)
+	}
+
+	actions := make([]string, 0, len(v.Actions))
+	for a := range v.Actions {
+		actions = append(actions, a)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		if v.Actions[actions[i]] != v.Actions[actions[j]] {
+			return v.Actions[actions[i]] > v.Actions[actions[j]]
+		}
+		return actions[i] < actions[j]
+	})
+	for i, a := range actions {
+		actions[i] = fmt.Sprintf("%s %d", a, v.Actions[a])
+	}
+	if len(actions) > 0 {
+		fmt.Printf("  actions:         %s\n", strings.Join(actions, ", "))
+	}
+
+	if v.Locale != "" {
+		fmt.Printf("  locale:          %s (%q on %.1f%% of entries)\n", v.Locale, parser.DefaultWatchedPrefixes[v.Locale], v.LocaleShare)
+	} else {
+		fmt.Println("  locale:          unknown (no built-in watched prefix matches)")
+	}
+	fmt.Printf("  missing channel: %.1f%% (%d entries: %d removed videos, %d ads)\n", v.MissingChannelPercent, v.MissingChannel, v.RemovedVideos, v.Ads)
+	if v.MissingTitle > 0 || v.MissingURL > 0 {
+		fmt.Printf("  missing title:   %d entries; missing URL: %d entries\n", v.MissingTitle, v.MissingURL)
+	}
+	for _, w := range v.Warnings {
+		fmt.Println("  warning:", w)
+	}
+	for _, p := range v.Problems {
+		fmt.Println("  problem:", p)
+	}
+	if len(v.Problems) == 0 {
+		fmt.Println("  OK: ready to analyze")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"example.com/hello/takeout/output"
+)
+
+// runVerify checks an analyze output directory against its manifest.json,
+// printing every missing, changed, unlisted or partially written file and
+// exiting with status 1 if there are any.
+func runVerify(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	dir := fs.String("outdir", "", "Output directory to check (or give it as the argument: verify out)")
+	parseFlags(fs, args)
+
+	if args := fs.Args(); len(args) > 0 {
+		if len(args) != 1 || *dir != "" {
+			fmt.Fprintln(os.Stderr, "error: give the output directory either as the argument (verify out) or with -outdir")
+			os.Exit(2)
+		}
+		*dir = args[0]
+	}
+	if *dir == "" {
+		fmt.Fprintln(os.Stderr, "error: the output directory is required")
+		os.Exit(2)
+	}
+
+	m, problems, err := output.VerifyManifest(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error verifying outputs:", err)
+		if errors.Is(err, output.ErrNoManifest) {
+			fmt.Fprintln(os.Stderr, "manifest.json is written by analyze; directories from before it was added cannot be verified")
+		}
+		os.Exit(1)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%s: %d problems with the %d files in the manifest (written by %s %s at %s)\n", *dir, len(problems), len(m.Files), m.Tool, m.Version, m.Generated)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: all %d files match the manifest (written by %s %s at %s)\n", *dir, len(m.Files), m.Tool, m.Version, m.Generated)
+}
@@ -0,0 +1,841 @@
+// Package aggregate turns parsed watch history activities into per-year,
+// per-period, per-channel and per-video counts.
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/parser"
+)
+
+// ChannelKey identifies a channel by its trimmed name and URL.
+type ChannelKey struct {
+	Name string
+	URL  string
+}
+
+// unknownChannel is the name watches without a channel are counted under.
+const unknownChannel = "(unknown channel)"
+
+// Options controls which activities are counted and how they are bucketed.
+type Options struct {
+	StartYear int
+	EndYear   int
+	// AllYears is set when StartYear and EndYear were not given but cover
+	// every watch of the input (see DetectYears). A state saved with it is
+	// resumed by a run over a wider range, and MergeState then lowers
+	// EndYear to the last year with a watch.
+	AllYears bool
+	// From and Until, if set, narrow the year range to watches at or after
+	// From and before Until.
+	From  time.Time
+	Until time.Time
+	// StrictTimes makes Add fail on a counted entry whose time is not
+	// exactly RFC3339, for auditing an export, instead of reading it with
+	// parser.ParseTime or counting it in TimeParseFails.
+	StrictTimes bool
+	// Strict makes Consume fail on the first entry that cannot be decoded
+	// instead of skipping it into ParseErrors.
+	Strict bool
+	// SkipRemoved leaves watches of videos that are no longer available out
+	// of the channel and video counts instead of counting them under
+	// "(unknown channel)"; they are tallied in YearRemoved either way.
+	SkipRemoved bool
+	// ExcludeAds leaves ad views (see parser.Activity.IsAd) out of the
+	// channel and video counts; they are tallied in the Ad* fields either way.
+	ExcludeAds bool
+	// ExcludeMusic leaves YouTube Music plays (see parser.Activity.IsMusic)
+	// out of the channel and video counts; they are tallied in the Music*
+	// fields either way.
+	ExcludeMusic bool
+	// ExcludeChannels and OnlyChannels, if set, leave out watches of the
+	// channels that match, or that do not match, respectively.
+	ExcludeChannels *ChannelFilter
+	OnlyChannels    *ChannelFilter
+	// Actions are the actions (see parser.Action) counted like watches,
+	// in the order of parser.Actions; nil counts only parser.ActionWatched.
+	Actions []string
+	// WatchedPrefixes are lowercased title prefixes that mark a watch event
+	// (see parser.LoadWatchedPrefixes).
+	WatchedPrefixes []string
+	TrackAliases    bool
+	// GroupBy is "url" to count a channel by its URL (or the channel ID in
+	// it) across renames, under its most recent name; see ResolveChannels.
+	// "" or "name" keeps every distinct name/URL pair apart.
+	GroupBy string
+	// MergeTopics makes ResolveChannels fold YouTube Music's " - Topic"
+	// channels into the artist channel of the same name.
+	MergeTopics bool
+	// NormalizeNames is "nfc" to put channel names in Unicode NFC before
+	// keying them, so names typed with combining marks match their
+	// precomposed look-alikes, or "strip" to also drop emoji and invisible
+	// characters (see parser.NormalizeName). "" or "none" keeps names as
+	// the export has them.
+	NormalizeNames string
+	// Location is the time zone used to assign watches to years, days, hours
+	// and other buckets. Nil means UTC.
+	Location *time.Location
+	// Dedupe drops entries whose parser.Activity.Key (the video and the time
+	// to the second) was already seen, for merging overlapping exports.
+	Dedupe bool
+	// Granularity adds month/week/day buckets on top of years ("" or "year"
+	// means years only).
+	Granularity string
+	// SessionGap, if positive, keeps every counted watch time so Sessions
+	// can group watches less than SessionGap apart.
+	SessionGap time.Duration
+	// RollingDays, if positive, fills DayChannelCounts for the rolling
+	// RollingDays-day windows of rolling_top_channels.json.
+	RollingDays int
+	// DayChannels fills DayChannelCounts even without RollingDays.
+	DayChannels bool
+	// After, if set, skips entries from before its second as already
+	// counted, for adding a newer export to a loaded state (see
+	// MergeState). Exports time watches to the second at best, so of the
+	// entries within that second only those whose Activity.Key is in
+	// AfterKeys are skipped.
+	After     time.Time
+	AfterKeys map[string]bool
+	// Workers, if more than 1, makes Consume decode and count entries on
+	// that many goroutines (see consumeParallel). The results are the same
+	// as with one. ConsumeFile still counts an input smaller than
+	// minParallelBytes on one.
+	Workers int
+	// OnProgress, if set, is called by Consume every progressEvery entries
+	// with the bytes of the current input read so far and the entries
+	// decoded across all inputs.
+	OnProgress func(bytesRead int64, entries int)
+	// OnWatch, if set, is called for every counted watch event, in input
+	// order and never concurrently.
+	OnWatch func(WatchEvent) error
+	// OnActivity, if set, is called like OnWatch but for every entry that is
+	// not a dropped duplicate, counted or not.
+	OnActivity func(ActivityRecord) error
+}
+
+// WatchEvent is a single counted watch, after filtering and normalization.
+type WatchEvent struct {
+	Time        time.Time
+	ChannelName string
+	ChannelURL  string
+	VideoID     string
+	VideoTitle  string
+	VideoURL    string
+}
+
+// InRange reports whether t falls in the year range and within From and
+// Until.
+func (opts Options) InRange(t time.Time) bool {
+	if y := t.Year(); y < opts.StartYear || y > opts.EndYear {
+		return false
+	}
+	return (opts.From.IsZero() || !t.Before(opts.From)) && (opts.Until.IsZero() || t.Before(opts.Until))
+}
+
+// ChannelName normalizes a channel name as NormalizeNames asks.
+func (opts Options) ChannelName(name string) string {
+	switch opts.NormalizeNames {
+	case "nfc":
+		return parser.NormalizeName(name, false)
+	case "strip":
+		return parser.NormalizeName(name, true)
+	}
+	return name
+}
+
+// CountsAction reports whether entries of action are counted like watches.
+func (opts Options) CountsAction(action string) bool {
+	if opts.Actions == nil {
+		return action == parser.ActionWatched
+	}
+	return action != parser.ActionOther && slices.Contains(opts.Actions, action)
+}
+
+// Window returns the first and last day counted: From and the day before
+// Until where set, otherwise the first and last day of the year range.
+func (opts Options) Window() (first, last time.Time) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	first = time.Date(opts.StartYear, 1, 1, 0, 0, 0, 0, loc)
+	last = time.Date(opts.EndYear, 12, 31, 0, 0, 0, 0, loc)
+	if opts.From.After(first) {
+		first = opts.From
+	}
+	if !opts.Until.IsZero() && opts.Until.AddDate(0, 0, -1).Before(last) {
+		last = opts.Until.AddDate(0, 0, -1)
+	}
+	return first, last
+}
+
+// AddWatchSink chains fn after any OnWatch callback already set on opts.
+func (opts *Options) AddWatchSink(fn func(WatchEvent) error) {
+	prev := opts.OnWatch
+	if prev == nil {
+		opts.OnWatch = fn
+		return
+	}
+	opts.OnWatch = func(e WatchEvent) error {
+		if err := prev(e); err != nil {
+			return err
+		}
+		return fn(e)
+	}
+}
+
+// AddActivitySink chains fn after any OnActivity callback already set on
+// opts, like AddWatchSink.
+func (opts *Options) AddActivitySink(fn func(ActivityRecord) error) {
+	prev := opts.OnActivity
+	if prev == nil {
+		opts.OnActivity = fn
+		return
+	}
+	opts.OnActivity = func(r ActivityRecord) error {
+		if err := prev(r); err != nil {
+			return err
+		}
+		return fn(r)
+	}
+}
+
+// Aggregator holds the counters filled in by Consume. Its maps may be read
+// directly once all input has been consumed.
+type Aggregator struct {
+	opts Options
+
+	YearCounts     map[int]map[ChannelKey]int
+	YearTotals     map[int]int
+	YearParseFails map[int]int
+	// YearRemoved counts watches of videos that are no longer available:
+	// removed-video placeholders and untitled videos (see
+	// parser.IsUntitledVideo). YearUntitled counts the latter alone. Both
+	// are counted whether or not SkipRemoved leaves them out.
+	YearRemoved   map[int]int
+	YearUntitled  map[int]int
+	YearAds       map[int]int
+	AllTimeCounts map[ChannelKey]int
+	// AllTimeHours tallies every channel's watches by hour of day, for the
+	// typical hour of the top channels. The top is only known once every
+	// entry is counted, and -state and standard input cannot be read again,
+	// so no channel can be left out.
+	AllTimeHours   map[ChannelKey]*[24]int
+	TotalAllYears  int
+	TotalRemoved   int
+	TotalAds       int
+	EntriesDecoded int
+	BytesRead      int64
+	Duplicates     int
+	// ChannelFiltered counts watches left out by ExcludeChannels or
+	// OnlyChannels.
+	ChannelFiltered int
+	// LatestTime is the time of the newest entry added and LatestKeys the
+	// Activity.Key of the entries within its second; AlreadyCounted counts
+	// the entries skipped as counted before Options.After.
+	LatestTime     time.Time
+	LatestKeys     map[string]bool
+	AlreadyCounted int
+	// YearMusic counts YouTube Music plays per year, MusicArtistCounts per
+	// year and artist (the track's channel) and MusicTrackCounts per track,
+	// keyed like the per-video maps. They are counted whether or not
+	// ExcludeMusic leaves them out.
+	YearMusic         map[int]int
+	TotalMusic        int
+	MusicArtistCounts map[int]map[ChannelKey]int
+	MusicTrackCounts  map[string]int
+	// Skipped counts the entries Consume could not decode and skipped;
+	// ParseErrors describes the first maxParseErrors of them.
+	Skipped     int
+	ParseErrors []ParseError
+	// TimeParseFails counts the watch entries whose time could not be
+	// parsed, and YearParseFails those of them whose time starts with a
+	// year in range.
+	TimeParseFails int
+	// input is the path ConsumeFile is reading, for ParseErrors.
+	input string
+	// YearUnknownReasons counts the watches counted under "(unknown
+	// channel)" per year by why they have no channel (see unknownReason).
+	YearUnknownReasons map[int]map[string]int
+	// ActionCounts counts the entries of each action (see parser.Action),
+	// counted or not.
+	ActionCounts map[string]int
+	// NotWatched counts entries skipped because their title has no watched
+	// prefix, or that of an action not in Options.Actions; NotWatchedSample
+	// is one such title, preferably of a video.
+	NotWatched       int
+	NotWatchedSample string
+	sampleIsVideo    bool
+	seen             seenSet
+	strs             interner
+	// Aliases maps each counted channel to the raw name/URL variants that
+	// were folded into it, with counts. Only filled when TrackAliases is set.
+	Aliases map[ChannelKey]map[ChannelKey]int
+	// DayCounts counts watches per calendar day ("2006-01-02").
+	DayCounts map[string]int
+	// DayChannelCounts counts watches per day and channel; only filled when
+	// RollingDays or DayChannels is set.
+	DayChannelCounts map[string]map[ChannelKey]int
+	// WeekdayHours counts watches by day of week (Sunday first) and hour.
+	WeekdayHours [7][24]int
+	// YearWeekendCounts and YearWeekendTotals count the watches on Saturdays
+	// and Sundays per year and channel; the rest of YearCounts and
+	// YearTotals were on weekdays.
+	YearWeekendCounts map[int]map[ChannelKey]int
+	YearWeekendTotals map[int]int
+	// ChannelSpans holds each counted channel's first and last watch.
+	ChannelSpans map[ChannelKey]WatchSpan
+	// HistorySpans is ChannelSpans over every watch in the input, including
+	// those outside the year range or window, so it tells when a channel was
+	// first watched at all. Like ChannelSpans, it leaves out the channels
+	// ExcludeChannels and OnlyChannels filter and, with ExcludeAds, ads.
+	HistorySpans map[ChannelKey]WatchSpan
+	// MonthChannelCounts counts watches per calendar month (1 to 12,
+	// across all years) and channel.
+	MonthChannelCounts map[int]map[ChannelKey]int
+	// PeriodCounts/PeriodTotals bucket watches by PeriodLabel when a
+	// granularity finer than a year is requested.
+	PeriodCounts map[string]map[ChannelKey]int
+	PeriodTotals map[string]int
+	// Per-video counts are keyed by video ID (see videoKeyFor); VideoInfo
+	// keeps the title, URL and channel of the most recent watch.
+	YearVideoCounts    map[int]map[string]int
+	AllTimeVideoCounts map[string]int
+	VideoInfo          map[string]VideoInfo
+	// AdVideoCounts counts ad views per video, keyed like the per-video maps.
+	AdVideoCounts map[string]int
+	// RemovedVideoCounts counts watches of untitled videos by canonical watch
+	// URL; the removed-video placeholders have no URL to count by.
+	RemovedVideoCounts map[string]int
+	// latest is the most recently watched name/URL of each channel group,
+	// only tracked when GroupBy is "url".
+	latest map[ChannelKey]channelSighting
+	// topics maps each " - Topic" channel ResolveChannels merged to its
+	// artist channel (see Options.MergeTopics).
+	topics map[ChannelKey]ChannelKey
+	// watchTimes holds every counted watch time when SessionGap is set.
+	watchTimes []time.Time
+	// With Workers > 1, seq is the input position of the entry being added,
+	// and infoSeq and sampleSeq record where VideoInfo entries and
+	// NotWatchedSample were first seen, so merging the workers' counts keeps
+	// the same ones a single pass would.
+	seq       int
+	infoSeq   map[string]int
+	sampleSeq int
+	// lastYear is the last year of a watch with a valid time, in range or
+	// not, for MergeState to trim an AllYears range to.
+	lastYear int
+	// sequential makes Consume ignore Workers, for an input too small to
+	// gain from them; workersUsed is the most Consume has counted on.
+	sequential  bool
+	workersUsed int
+	// redacted is set by Redact.
+	redacted bool
+}
+
+// WatchSpan is the time of the first and the last of some watches.
+type WatchSpan struct {
+	First time.Time
+	Last  time.Time
+}
+
+// add widens s to include o; a zero s is empty.
+func (s WatchSpan) add(o WatchSpan) WatchSpan {
+	if s.First.IsZero() || o.First.Before(s.First) {
+		s.First = o.First
+	}
+	if o.Last.After(s.Last) {
+		s.Last = o.Last
+	}
+	return s
+}
+
+// VideoInfo describes a video counted in the per-video maps.
+type VideoInfo struct {
+	Title   string
+	URL     string
+	Channel ChannelKey
+}
+
+// New returns an empty Aggregator with a bucket for every year in
+// opts.StartYear..opts.EndYear.
+func New(opts Options) *Aggregator {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	agg := &Aggregator{
+		opts:           opts,
+		YearCounts:     make(map[int]map[ChannelKey]int),
+		YearTotals:     make(map[int]int),
+		YearParseFails: make(map[int]int),
+		YearRemoved:    make(map[int]int),
+		YearUntitled:   make(map[int]int),
+		YearAds:        make(map[int]int),
+		AllTimeCounts:  make(map[ChannelKey]int),
+		AllTimeHours:   make(map[ChannelKey]*[24]int),
+		ChannelSpans:   make(map[ChannelKey]WatchSpan),
+		HistorySpans:   make(map[ChannelKey]WatchSpan),
+		LatestKeys:     make(map[string]bool),
+		Aliases:        make(map[ChannelKey]map[ChannelKey]int),
+		seen:           make(seenSet),
+		strs:           make(interner),
+		DayCounts:      make(map[string]int),
+		ActionCounts:   make(map[string]int),
+		PeriodCounts:   make(map[string]map[ChannelKey]int),
+		PeriodTotals:   make(map[string]int),
+
+		YearUnknownReasons: make(map[int]map[string]int),
+		YearMusic:          make(map[int]int),
+		MusicArtistCounts:  make(map[int]map[ChannelKey]int),
+		MusicTrackCounts:   make(map[string]int),
+		YearVideoCounts:    make(map[int]map[string]int),
+		AllTimeVideoCounts: make(map[string]int),
+		VideoInfo:          make(map[string]VideoInfo),
+		AdVideoCounts:      make(map[string]int),
+		RemovedVideoCounts: make(map[string]int),
+		DayChannelCounts:   make(map[string]map[ChannelKey]int),
+		MonthChannelCounts: make(map[int]map[ChannelKey]int),
+		YearWeekendCounts:  make(map[int]map[ChannelKey]int),
+		YearWeekendTotals:  make(map[int]int),
+		latest:             make(map[ChannelKey]channelSighting),
+	}
+	for m := 1; m <= 12; m++ {
+		agg.MonthChannelCounts[m] = make(map[ChannelKey]int)
+	}
+
+	// init year buckets
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		agg.YearCounts[y] = make(map[ChannelKey]int)
+		agg.YearTotals[y] = 0
+		agg.YearWeekendCounts[y] = make(map[ChannelKey]int)
+		agg.YearParseFails[y] = 0
+		agg.YearRemoved[y] = 0
+		agg.YearUntitled[y] = 0
+		agg.YearAds[y] = 0
+		agg.YearUnknownReasons[y] = make(map[string]int)
+		agg.YearMusic[y] = 0
+		agg.MusicArtistCounts[y] = make(map[ChannelKey]int)
+		agg.YearVideoCounts[y] = make(map[string]int)
+	}
+	return agg
+}
+
+// Options returns the options the Aggregator was created with.
+func (agg *Aggregator) Options() Options { return agg.opts }
+
+// WorkersUsed returns the most goroutines Consume has counted an input on.
+func (agg *Aggregator) WorkersUsed() int { return max(agg.workersUsed, 1) }
+
+// minParallelBytes is the size below which ConsumeFile counts an input on
+// one goroutine whatever Workers says. Handing out batches and merging the
+// workers' maps has a cost of its own, which only a large export earns back
+// (see BenchmarkConsume).
+const minParallelBytes = 32 << 20
+
+// ConsumeFile opens path with parser.Open and consumes it.
+func (agg *Aggregator) ConsumeFile(path string) error {
+	f, err := parser.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	agg.input = path
+	defer func() { agg.input = "" }()
+	if size, err := parser.InputSize(path); err == nil && size > 0 && size < minParallelBytes {
+		agg.sequential = true
+		defer func() { agg.sequential = false }()
+	}
+	return agg.Consume(f)
+}
+
+// progressEvery is how many entries Consume decodes between OnProgress calls.
+const progressEvery = 1000
+
+// Consume decodes a whole export from r and adds every activity in it.
+func (agg *Aggregator) Consume(r io.Reader) error {
+	src, err := parser.NewDecoder(r)
+	if err != nil {
+		return err
+	}
+
+	if agg.opts.Workers > 1 && !agg.sequential {
+		agg.workersUsed = max(agg.workersUsed, agg.opts.Workers)
+		return agg.consumeParallel(src)
+	}
+
+	for idx := 0; ; idx++ {
+		a, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			ee, ok := agg.skip(idx, err)
+			if !ok {
+				return err
+			}
+			if ee.Stop {
+				break
+			}
+			continue
+		}
+		if err := agg.Add(a); err != nil {
+			return fmt.Errorf("entry %d: %w", idx, err)
+		}
+		if agg.opts.OnProgress != nil && (idx+1)%progressEvery == 0 {
+			agg.opts.OnProgress(src.InputOffset(), agg.EntriesDecoded)
+		}
+	}
+
+	agg.BytesRead += src.InputOffset()
+	return nil
+}
+
+// maxParseErrors is how many skipped entries ParseErrors describes.
+const maxParseErrors = 1000
+
+// ParseError describes an entry Consume skipped because it could not be
+// decoded.
+type ParseError struct {
+	Input string `json:"input,omitempty"`
+	// Entry is the entry's position in the input, counting from 0.
+	Entry   int    `json:"entry"`
+	Offset  int64  `json:"byte_offset"`
+	Error   string `json:"error"`
+	Snippet string `json:"snippet"`
+	// RestSkipped is set when the input could not be read past the entry.
+	RestSkipped bool `json:"rest_of_input_skipped"`
+}
+
+// skip records err, from decoding entry idx, in ParseErrors and returns it
+// as an EntryError. ok is false, and Consume has to stop with err, with
+// Strict set or when err is not a *parser.EntryError.
+func (agg *Aggregator) skip(idx int, err error) (ee *parser.EntryError, ok bool) {
+	if agg.opts.Strict || !errors.As(err, &ee) {
+		return nil, false
+	}
+	agg.Skipped++
+	if len(agg.ParseErrors) < maxParseErrors {
+		agg.ParseErrors = append(agg.ParseErrors, ParseError{
+			Input:       agg.input,
+			Entry:       idx,
+			Offset:      ee.Offset,
+			Error:       ee.Err.Error(),
+			Snippet:     ee.Snippet,
+			RestSkipped: ee.Stop,
+		})
+	}
+	return ee, true
+}
+
+// Add counts a single activity. Activities that are not watch events, fall
+// outside the year range or are filtered by the options are skipped; the
+// only error without callbacks is a bad time with StrictTimes set.
+func (agg *Aggregator) Add(a parser.Activity) (err error) {
+	opts := &agg.opts
+	agg.EntriesDecoded++
+
+	if opts.Dedupe {
+		if !agg.seen.add(a) {
+			agg.Duplicates++
+			return nil
+		}
+	}
+
+	t, terr := parser.ParseTime(a.Time)
+	if terr == nil && opts.StrictTimes {
+		_, terr = time.Parse(time.RFC3339, strings.TrimSpace(a.Time))
+	}
+	if terr == nil {
+		if !opts.After.IsZero() {
+			sec, after := t.Truncate(time.Second), opts.After.Truncate(time.Second)
+			if sec.Before(after) || sec.Equal(after) && opts.AfterKeys[a.Key()] {
+				agg.AlreadyCounted++
+				return nil
+			}
+		}
+		agg.noteLatest(t, a)
+	}
+
+	var rec *ActivityRecord
+	if opts.OnActivity != nil {
+		r := agg.activityRecord(a)
+		rec = &r
+		defer func() {
+			if err == nil {
+				err = opts.OnActivity(*rec)
+			}
+		}()
+	}
+
+	// Only keep watch events, and those of the other counted actions
+	title := strings.TrimSpace(a.Title)
+	action, videoTitle := parser.Action(title, opts.WatchedPrefixes)
+	agg.ActionCounts[action]++
+	if !opts.CountsAction(action) {
+		if agg.NotWatched == 0 || (!agg.sampleIsVideo && parser.VideoIDFromURL(a.TitleURL) != "") {
+			agg.NotWatchedSample = title
+			agg.sampleIsVideo = parser.VideoIDFromURL(a.TitleURL) != ""
+			agg.sampleSeq = agg.seq
+		}
+		agg.NotWatched++
+		return nil
+	}
+
+	if terr != nil {
+		if opts.StrictTimes {
+			return fmt.Errorf("invalid time %q: %w", a.Time, terr)
+		}
+		// Without a time the watch cannot be bucketed; count the failure
+		// under the year the time starts with, if any.
+		agg.TimeParseFails++
+		if y := parser.TimeYear(a.Time); y >= opts.StartYear && y <= opts.EndYear {
+			agg.YearParseFails[y]++
+		}
+		return nil
+	}
+
+	t = t.In(opts.Location)
+	y := t.Year()
+	if y > agg.lastYear {
+		agg.lastYear = y
+	}
+
+	chName, chURL := a.Channel()
+	chName = opts.ChannelName(chName)
+	unknown := chName == ""
+	if unknown {
+		chName = unknownChannel
+	}
+	k := ChannelKey{Name: agg.strs.intern(chName), URL: agg.strs.intern(chURL)}
+	filtered := (opts.ExcludeChannels != nil && opts.ExcludeChannels.Match(k)) ||
+		(opts.OnlyChannels != nil && !opts.OnlyChannels.Match(k))
+	if !filtered && !(opts.ExcludeAds && a.IsAd()) {
+		agg.HistorySpans[k] = agg.HistorySpans[k].add(WatchSpan{First: t, Last: t})
+	}
+
+	if !opts.InRange(t) {
+		return nil
+	}
+
+	if filtered {
+		agg.ChannelFiltered++
+		return nil
+	}
+
+	if a.IsAd() {
+		vk := videoKeyFor(videoTitle, a.TitleURL)
+		agg.YearAds[y]++
+		agg.TotalAds++
+		agg.AdVideoCounts[vk]++
+		agg.noteVideo(vk, videoTitle, a.TitleURL, k)
+		if opts.ExcludeAds {
+			return nil
+		}
+	}
+
+	if a.IsMusic() {
+		vk := videoKeyFor(videoTitle, a.TitleURL)
+		agg.YearMusic[y]++
+		agg.TotalMusic++
+		agg.MusicArtistCounts[y][k]++
+		agg.MusicTrackCounts[vk]++
+		agg.noteVideo(vk, videoTitle, a.TitleURL, k)
+		if opts.ExcludeMusic {
+			return nil
+		}
+	}
+
+	untitled := parser.IsUntitledVideo(videoTitle)
+	if untitled || parser.IsRemovedVideoTitle(title) {
+		agg.YearRemoved[y]++
+		agg.TotalRemoved++
+		if untitled {
+			agg.YearUntitled[y]++
+			u := videoURL(a.TitleURL)
+			if u == "" {
+				u = videoTitle
+			}
+			agg.RemovedVideoCounts[u]++
+		}
+		if opts.SkipRemoved {
+			return nil
+		}
+	}
+
+	if rec != nil {
+		rec.Counted = true
+	}
+	agg.YearCounts[y][k]++
+	agg.YearTotals[y]++
+	if unknown {
+		agg.YearUnknownReasons[y][unknownReason(a, title, untitled)]++
+	}
+	agg.AllTimeCounts[k]++
+	if agg.AllTimeHours[k] == nil {
+		agg.AllTimeHours[k] = new([24]int)
+	}
+	agg.AllTimeHours[k][t.Hour()]++
+	agg.ChannelSpans[k] = agg.ChannelSpans[k].add(WatchSpan{First: t, Last: t})
+	agg.MonthChannelCounts[int(t.Month())][k]++
+	day := t.Format(time.DateOnly)
+	agg.DayCounts[day]++
+	if opts.RollingDays > 0 || opts.DayChannels {
+		if agg.DayChannelCounts[day] == nil {
+			agg.DayChannelCounts[day] = make(map[ChannelKey]int)
+		}
+		agg.DayChannelCounts[day][k]++
+	}
+	agg.WeekdayHours[t.Weekday()][t.Hour()]++
+	if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		agg.YearWeekendCounts[y][k]++
+		agg.YearWeekendTotals[y]++
+	}
+	agg.TotalAllYears++
+	if opts.SessionGap > 0 {
+		agg.watchTimes = append(agg.watchTimes, t)
+	}
+	if opts.GroupBy == "url" {
+		g := opts.ChannelGroup(k)
+		if l, ok := agg.latest[g]; !ok || t.After(l.time) {
+			agg.latest[g] = channelSighting{key: k, time: t, seq: agg.seq}
+		}
+	}
+
+	// Removed videos all share one title, so they aren't counted per video.
+	if !parser.IsRemovedVideoTitle(title) {
+		vk := videoKeyFor(videoTitle, a.TitleURL)
+		agg.YearVideoCounts[y][vk]++
+		agg.AllTimeVideoCounts[vk]++
+		agg.noteVideo(vk, videoTitle, a.TitleURL, k)
+	}
+
+	if p := PeriodLabel(t, opts.Granularity); p != "" {
+		if agg.PeriodCounts[p] == nil {
+			agg.PeriodCounts[p] = make(map[ChannelKey]int)
+		}
+		agg.PeriodCounts[p][k]++
+		agg.PeriodTotals[p]++
+	}
+
+	if opts.TrackAliases {
+		var raw ChannelKey
+		if len(a.Subtitles) > 0 {
+			raw = ChannelKey{Name: a.Subtitles[0].Name, URL: a.Subtitles[0].URL}
+		}
+		if agg.Aliases[k] == nil {
+			agg.Aliases[k] = make(map[ChannelKey]int)
+		}
+		agg.Aliases[k][raw]++
+	}
+
+	if opts.OnWatch != nil {
+		ev := WatchEvent{
+			Time:        t,
+			ChannelName: chName,
+			ChannelURL:  chURL,
+			VideoID:     parser.VideoIDFromURL(a.TitleURL),
+			VideoTitle:  videoTitle,
+			VideoURL:    strings.TrimSpace(a.TitleURL),
+		}
+		if err := opts.OnWatch(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reasons a counted watch has no channel, most specific first.
+const (
+	UnknownRemoved     = "removed_video"
+	UnknownAd          = "ad"
+	UnknownMusic       = "music_track"
+	UnknownNoSubtitles = "no_subtitles"
+	UnknownBlankName   = "blank_channel_name"
+)
+
+// unknownReason tells why an activity without a channel name has none:
+// removed videos and most ads carry no subtitles at all, YouTube Music
+// tracks often lack them too, and a few entries have a channel link with an
+// empty name.
+func unknownReason(a parser.Activity, title string, untitled bool) string {
+	switch {
+	case untitled || parser.IsRemovedVideoTitle(title):
+		return UnknownRemoved
+	case a.IsAd():
+		return UnknownAd
+	case a.IsMusic():
+		return UnknownMusic
+	case len(a.Subtitles) == 0:
+		return UnknownNoSubtitles
+	}
+	return UnknownBlankName
+}
+
+// noteLatest keeps LatestTime and the keys of the entries within its second
+// up to date with an entry a at t.
+func (agg *Aggregator) noteLatest(t time.Time, a parser.Activity) {
+	switch sec, latest := t.Truncate(time.Second), agg.LatestTime.Truncate(time.Second); {
+	case agg.LatestTime.IsZero() || sec.After(latest):
+		agg.LatestKeys = map[string]bool{a.Key(): true}
+	case sec.Equal(latest):
+		agg.LatestKeys[a.Key()] = true
+	}
+	if t.After(agg.LatestTime) {
+		agg.LatestTime = t
+	}
+}
+
+// noteVideo records the video watched at rawURL under vk unless it was
+// already seen.
+func (agg *Aggregator) noteVideo(vk, title, rawURL string, k ChannelKey) {
+	if _, seen := agg.VideoInfo[vk]; seen {
+		return
+	}
+	agg.VideoInfo[vk] = VideoInfo{Title: title, URL: videoURL(rawURL), Channel: k}
+	if agg.infoSeq != nil {
+		agg.infoSeq[vk] = agg.seq
+	}
+}
+
+// videoKeyFor keys per-video counts by video ID, as its canonical watch URL,
+// so short links, mobile links and links with a timestamp count as one video.
+// URLs without a video ID are used as is, and entries without a URL (e.g.
+// removed videos) fall back to the title.
+func videoKeyFor(title, rawURL string) string {
+	if u := parser.WatchURL(rawURL); u != "" {
+		return u
+	}
+	if u := strings.TrimSpace(rawURL); u != "" {
+		return u
+	}
+	return "title:" + title
+}
+
+// videoURL is the URL recorded in VideoInfo: the canonical form of a watch
+// URL, keeping Shorts links recognizable, or rawURL as is.
+func videoURL(rawURL string) string {
+	if u := parser.CanonicalVideoURL(rawURL); u != "" {
+		return u
+	}
+	return strings.TrimSpace(rawURL)
+}
+
+// PeriodLabel names the month ("2006-01"), ISO week ("2006-W01") or day
+// ("2006-01-02") bucket t falls in, or "" for year granularity.
+func PeriodLabel(t time.Time, granularity string) string {
+	switch granularity {
+	case "month":
+		return t.Format("2006-01")
+	case "week":
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", y, w)
+	case "day":
+		return t.Format(time.DateOnly)
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package aggregate_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/output"
+	"example.com/hello/takeout/parser"
+	"example.com/hello/takeout/synth"
+)
+
+var benchEntries = flag.Int("entries", 10000, "Entries in the synthetic history the benchmarks count")
+
+// benchOptions are analyze's default options over the synthetic history's
+// years.
+func benchOptions(b *testing.B) aggregate.Options {
+	prefixes, err := parser.LoadWatchedPrefixes("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return aggregate.Options{
+		StartYear:       2019,
+		EndYear:         2024,
+		SkipRemoved:     true,
+		ExcludeAds:      true,
+		WatchedPrefixes: prefixes,
+		Actions:         []string{parser.ActionWatched},
+		GroupBy:         "name",
+		Location:        time.UTC,
+		Workers:         1,
+	}
+}
+
+// BenchmarkAdd counts already decoded activities, without the decoding.
+func BenchmarkAdd(b *testing.B) {
+	acts, err := synth.Activities(synth.DefaultConfig(*benchEntries))
+	if err != nil {
+		b.Fatal(err)
+	}
+	opts := benchOptions(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		agg := aggregate.New(opts)
+		for _, a := range acts {
+			if err := agg.Add(a); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	reportPerEntry(b)
+}
+
+// BenchmarkConsume decodes and counts a whole export per op, with one worker
+// and with several.
+func BenchmarkConsume(b *testing.B) {
+	var buf bytes.Buffer
+	w, err := output.NewActivityStream(&buf)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := synth.Generate(synth.DefaultConfig(*benchEntries), w.Write); err != nil {
+		b.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		b.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			opts := benchOptions(b)
+			opts.Workers = workers
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				agg := aggregate.New(opts)
+				if err := agg.Consume(bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			reportPerEntry(b)
+		})
+	}
+}
+
+// reportPerEntry reports the time per entry of a benchmark that handles the
+// whole synthetic history per op, which compares across -entries.
+func reportPerEntry(b *testing.B) {
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(*benchEntries), "ns/entry")
+}
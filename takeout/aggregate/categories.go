@@ -0,0 +1,110 @@
+package aggregate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Uncategorized is the category of channels no rule matches.
+const Uncategorized = "(uncategorized)"
+
+// ChannelCategories assigns channels to user-defined categories, such as
+// "education" or "gaming".
+type ChannelCategories struct {
+	names   []string
+	filters []*ChannelFilter
+}
+
+// LoadChannelCategories reads a categories file, a small subset of YAML
+// mapping each category to a list of channels:
+//
+//	education:
+//	  - 3Blue1Brown
+//	  - https://www.youtube.com/@veritasium
+//	  - /(?i)lecture/
+//	gaming:
+//	  - "Some Channel: Let's Plays"
+//
+// Entries are matched like the lines of LoadChannelFilter: names and URLs
+// exactly, ignoring case, and /regexp/ anywhere in the name or URL. They may
+// be quoted. Lines starting with # are comments. A channel matching several
+// categories gets the first in the file.
+func LoadChannelCategories(path string) (*ChannelCategories, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cc := &ChannelCategories{}
+	index := make(map[string]int)
+	cur := -1
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		raw := strings.TrimRight(sc.Text(), " \t\r")
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if item, ok := strings.CutPrefix(line, "- "); ok || line == "-" {
+			if cur < 0 {
+				return nil, fmt.Errorf("%s:%d: list entry before any category", path, n)
+			}
+			entry := unquoteYAML(strings.TrimSpace(item))
+			if entry == "" {
+				continue
+			}
+			if err := cc.filters[cur].add(entry); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			continue
+		}
+		name, ok := strings.CutSuffix(line, ":")
+		if !ok || raw != line {
+			return nil, fmt.Errorf("%s:%d: want a category like \"education:\" or a \"- channel\" entry", path, n)
+		}
+		name = unquoteYAML(strings.TrimSpace(name))
+		if name == "" || name == Uncategorized {
+			return nil, fmt.Errorf("%s:%d: invalid category name %q", path, n, name)
+		}
+		i, seen := index[name]
+		if !seen {
+			i = len(cc.names)
+			index[name] = i
+			cc.names = append(cc.names, name)
+			cc.filters = append(cc.filters, newChannelFilter())
+		}
+		cur = i
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// unquoteYAML strips the quotes of a single- or double-quoted YAML scalar.
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		inner := s[1 : len(s)-1]
+		if s[0] == '\'' {
+			return strings.ReplaceAll(inner, "''", "'")
+		}
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(inner)
+	}
+	return s
+}
+
+// Names returns the categories in file order.
+func (cc *ChannelCategories) Names() []string { return cc.names }
+
+// Category returns the channel's category, or Uncategorized.
+func (cc *ChannelCategories) Category(k ChannelKey) string {
+	for i, cf := range cc.filters {
+		if cf.Match(k) {
+			return cc.names[i]
+		}
+	}
+	return Uncategorized
+}
@@ -0,0 +1,199 @@
+package aggregate
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/parser"
+)
+
+type channelSighting struct {
+	key  ChannelKey
+	time time.Time
+	seq  int
+}
+
+// ChannelGroup returns the identity k is counted under: k itself, or with
+// GroupBy "url" the channel ID from its URL (the URL itself if it has no
+// ID). Channels without a URL are never merged.
+func (opts Options) ChannelGroup(k ChannelKey) ChannelKey {
+	if opts.GroupBy != "url" || k.URL == "" {
+		return k
+	}
+	id := parser.ChannelIDFromURL(k.URL)
+	if id == "" {
+		id = k.URL
+	}
+	return ChannelKey{URL: id}
+}
+
+// CanonicalChannel returns the name/URL k is reported under once
+// ResolveChannels has run: with GroupBy "url", the most recently watched
+// name/URL of its group, and with MergeTopics, the artist channel a
+// " - Topic" channel was folded into.
+func (agg *Aggregator) CanonicalChannel(k ChannelKey) ChannelKey {
+	if l, ok := agg.latest[agg.opts.ChannelGroup(k)]; ok {
+		k = l.key
+	}
+	if t, ok := agg.topics[k]; ok {
+		return t
+	}
+	return k
+}
+
+// ResolveChannels merges the per-channel counts of renamed channels once all
+// input has been consumed, if GroupBy is "url", and with MergeTopics folds
+// YouTube Music's auto-generated " - Topic" channels into the artist
+// channel of the same name.
+func (agg *Aggregator) ResolveChannels() {
+	if agg.opts.GroupBy == "url" {
+		agg.remapChannels(agg.CanonicalChannel)
+	}
+	if agg.opts.MergeTopics {
+		agg.topics = topicTargets(agg.HistorySpans, agg.AllTimeCounts)
+		if len(agg.topics) > 0 {
+			agg.remapChannels(agg.CanonicalChannel)
+		}
+	}
+}
+
+// ResolveChannelsLike rekeys agg's per-channel counts, after
+// ResolveChannels, to the name/URL other reports each channel under, so an
+// Aggregator of part of other's input (such as one account of a household)
+// names renamed channels and merges topics the same way. agg should not
+// merge topics itself.
+func (agg *Aggregator) ResolveChannelsLike(other *Aggregator) {
+	if agg.opts.GroupBy != "url" && len(other.topics) == 0 {
+		return
+	}
+	agg.remapChannels(other.CanonicalChannel)
+}
+
+// TopicSuffix ends the names of the channels YouTube Music generates for an
+// artist's tracks.
+const TopicSuffix = " - Topic"
+
+// topicTargets maps each " - Topic" channel among channels to the channel
+// named like it without the suffix (ignoring case) that was watched most,
+// if there is one. Ties go to the name, then URL, that sorts first.
+func topicTargets(channels map[ChannelKey]WatchSpan, counts map[ChannelKey]int) map[ChannelKey]ChannelKey {
+	artists := make(map[string]ChannelKey)
+	for k := range channels {
+		if strings.HasSuffix(k.Name, TopicSuffix) {
+			continue
+		}
+		name := strings.ToLower(k.Name)
+		best, ok := artists[name]
+		if !ok || counts[k] > counts[best] ||
+			(counts[k] == counts[best] && (k.Name < best.Name || (k.Name == best.Name && k.URL < best.URL))) {
+			artists[name] = k
+		}
+	}
+	targets := make(map[ChannelKey]ChannelKey)
+	for k := range channels {
+		base, ok := strings.CutSuffix(k.Name, TopicSuffix)
+		if !ok {
+			continue
+		}
+		if a, ok := artists[strings.ToLower(strings.TrimSpace(base))]; ok {
+			targets[k] = a
+		}
+	}
+	return targets
+}
+
+// TopicMerge is a " - Topic" channel MergeTopics folded into an artist
+// channel.
+type TopicMerge struct {
+	Topic  ChannelKey
+	Artist ChannelKey
+}
+
+// TopicMerges lists the " - Topic" channels ResolveChannels folded into
+// artist channels, sorted by topic name.
+func (agg *Aggregator) TopicMerges() []TopicMerge {
+	out := make([]TopicMerge, 0, len(agg.topics))
+	for t, a := range agg.topics {
+		out = append(out, TopicMerge{Topic: t, Artist: a})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Topic.Name != out[j].Topic.Name {
+			return out[i].Topic.Name < out[j].Topic.Name
+		}
+		return out[i].Topic.URL < out[j].Topic.URL
+	})
+	return out
+}
+
+// remapChannels rekeys every per-channel count by to(k), adding up the
+// counts of channels that end up under the same key.
+func (agg *Aggregator) remapChannels(to func(ChannelKey) ChannelKey) {
+	remap := func(m map[ChannelKey]int) map[ChannelKey]int {
+		out := make(map[ChannelKey]int, len(m))
+		for k, c := range m {
+			out[to(k)] += c
+		}
+		return out
+	}
+
+	for y, m := range agg.YearCounts {
+		agg.YearCounts[y] = remap(m)
+	}
+	for y, m := range agg.YearWeekendCounts {
+		agg.YearWeekendCounts[y] = remap(m)
+	}
+	for p, m := range agg.PeriodCounts {
+		agg.PeriodCounts[p] = remap(m)
+	}
+	for d, m := range agg.DayChannelCounts {
+		agg.DayChannelCounts[d] = remap(m)
+	}
+	for y, m := range agg.MusicArtistCounts {
+		agg.MusicArtistCounts[y] = remap(m)
+	}
+	for m, c := range agg.MonthChannelCounts {
+		agg.MonthChannelCounts[m] = remap(c)
+	}
+	agg.AllTimeCounts = remap(agg.AllTimeCounts)
+
+	hours := make(map[ChannelKey]*[24]int, len(agg.AllTimeHours))
+	for k, h := range agg.AllTimeHours {
+		c := to(k)
+		if hours[c] == nil {
+			hours[c] = new([24]int)
+		}
+		for i, n := range h {
+			hours[c][i] += n
+		}
+	}
+	agg.AllTimeHours = hours
+
+	remapSpans := func(m map[ChannelKey]WatchSpan) map[ChannelKey]WatchSpan {
+		out := make(map[ChannelKey]WatchSpan, len(m))
+		for k, sp := range m {
+			c := to(k)
+			out[c] = out[c].add(sp)
+		}
+		return out
+	}
+	agg.ChannelSpans = remapSpans(agg.ChannelSpans)
+	agg.HistorySpans = remapSpans(agg.HistorySpans)
+
+	aliases := make(map[ChannelKey]map[ChannelKey]int, len(agg.Aliases))
+	for k, raw := range agg.Aliases {
+		c := to(k)
+		if aliases[c] == nil {
+			aliases[c] = make(map[ChannelKey]int)
+		}
+		for r, n := range raw {
+			aliases[c][r] += n
+		}
+	}
+	agg.Aliases = aliases
+
+	for vk, vi := range agg.VideoInfo {
+		vi.Channel = to(vi.Channel)
+		agg.VideoInfo[vk] = vi
+	}
+}
@@ -0,0 +1,75 @@
+package aggregate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ChannelFilter matches channels against a list of names, URLs and regular
+// expressions.
+type ChannelFilter struct {
+	exact    map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// LoadChannelFilter reads a channel list: one entry per line, matched
+// against the channel name or URL. Plain lines match exactly, ignoring case;
+// lines written as /regexp/ match if the expression matches anywhere in the
+// name or URL. Blank lines and lines starting with # are skipped.
+func LoadChannelFilter(path string) (*ChannelFilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cf := newChannelFilter()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := cf.add(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return cf, nil
+}
+
+func newChannelFilter() *ChannelFilter {
+	return &ChannelFilter{exact: make(map[string]bool)}
+}
+
+// add adds one entry: a name or URL, or a /regexp/.
+func (cf *ChannelFilter) add(entry string) error {
+	if len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+		re, err := regexp.Compile(entry[1 : len(entry)-1])
+		if err != nil {
+			return err
+		}
+		cf.patterns = append(cf.patterns, re)
+		return nil
+	}
+	cf.exact[strings.ToLower(entry)] = true
+	return nil
+}
+
+// Match reports whether the channel's name or URL is on the list.
+func (cf *ChannelFilter) Match(k ChannelKey) bool {
+	if cf.exact[strings.ToLower(k.Name)] || (k.URL != "" && cf.exact[strings.ToLower(k.URL)]) {
+		return true
+	}
+	for _, re := range cf.patterns {
+		if re.MatchString(k.Name) || (k.URL != "" && re.MatchString(k.URL)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package aggregate
+
+import (
+	"strings"
+	"unicode"
+)
+
+// titleStopWords are searchStopWords plus words common in video titles that
+// say nothing about their topic.
+var titleStopWords = map[string]bool{
+	"all": true, "about": true, "after": true, "but": true, "can": true,
+	"ep": true, "episode": true, "ft": true, "feat": true, "full": true,
+	"get": true, "has": true, "have": true, "he": true, "his": true,
+	"if": true, "into": true, "its": true, "just": true, "me": true,
+	"my": true, "new": true, "no": true, "not": true, "official": true,
+	"one": true, "our": true, "out": true, "part": true, "she": true,
+	"so": true, "that": true, "this": true, "up": true, "video": true,
+	"was": true, "we": true, "when": true, "who": true, "will": true,
+	"you": true, "your": true,
+}
+
+func isTitleStopWord(w string) bool { return searchStopWords[w] || titleStopWords[w] }
+
+// TitleTerms splits a video title into its distinct lowercased keywords,
+// skipping single characters and stop words, and the distinct bigrams of
+// keywords that follow each other in the title with only spaces between
+// them.
+func TitleTerms(title string) (words, bigrams []string) {
+	seen := make(map[string]bool)
+	prev := ""
+	var word []rune
+	flush := func() {
+		w := strings.Trim(string(word), "'")
+		word = word[:0]
+		if w == "" {
+			return
+		}
+		if len([]rune(w)) < 2 || isTitleStopWord(w) {
+			prev = ""
+			return
+		}
+		if !seen[w] {
+			seen[w] = true
+			words = append(words, w)
+		}
+		if prev != "" {
+			if b := prev + " " + w; !seen[b] {
+				seen[b] = true
+				bigrams = append(bigrams, b)
+			}
+		}
+		prev = w
+	}
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '\'':
+			word = append(word, r)
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			prev = ""
+		}
+	}
+	flush()
+	return words, bigrams
+}
@@ -0,0 +1,39 @@
+package aggregate
+
+import (
+	"hash/fnv"
+	"io"
+
+	"example.com/hello/takeout/parser"
+)
+
+// interner hands out one shared copy of each channel name and URL. Every
+// decoded entry brings its own copies, and without interning the per-year,
+// per-period and per-video maps each keep whichever copy they saw first.
+type interner map[string]string
+
+func (in interner) intern(s string) string {
+	if v, ok := in[s]; ok {
+		return v
+	}
+	in[s] = s
+	return s
+}
+
+// seenSet remembers the activities Dedupe has already counted. It grows by
+// one element per entry, so it keeps a 128-bit hash of Activity.Key rather
+// than the key itself.
+type seenSet map[[16]byte]struct{}
+
+// add marks a as seen, reporting false if it already was.
+func (s seenSet) add(a parser.Activity) bool {
+	h := fnv.New128a()
+	_, _ = io.WriteString(h, a.Key())
+	var k [16]byte
+	h.Sum(k[:0])
+	if _, dup := s[k]; dup {
+		return false
+	}
+	s[k] = struct{}{}
+	return true
+}
@@ -0,0 +1,409 @@
+package aggregate
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"example.com/hello/takeout/parser"
+)
+
+// batchSize is how many entries the reader hands a worker at a time.
+const batchSize = 512
+
+type batchEntry struct {
+	idx int // position in the input, as Consume numbers entries
+	off int64
+	raw []byte
+	act parser.Activity
+}
+
+type entryBatch struct {
+	seq     int
+	entries []batchEntry
+}
+
+type batchEvent struct {
+	idx int
+	ev  WatchEvent
+}
+
+type batchRecord struct {
+	idx int
+	rec ActivityRecord
+}
+
+type batchResult struct {
+	seq     int
+	events  []batchEvent
+	records []batchRecord
+	err     error
+}
+
+// consumeParallel is Consume as a pipeline over opts.Workers goroutines: one
+// goroutine reads entries in batches, each worker decodes its batches (when
+// src is a parser.RawDecoder) and counts them into its own Aggregator, and
+// the workers' counts are merged into agg at the end. Watch events and
+// activity records are passed to OnWatch and OnActivity on the calling
+// goroutine in input order.
+//
+// With Dedupe set, duplicates have to be dropped in input order before the
+// entries are handed out, so the reader decodes them itself and only the
+// counting is spread out.
+func (agg *Aggregator) consumeParallel(src parser.Decoder) error {
+	workers := agg.opts.Workers
+	if agg.infoSeq == nil {
+		agg.infoSeq = make(map[string]int)
+	}
+	raw, _ := src.(parser.RawDecoder)
+	if agg.opts.Dedupe {
+		raw = nil
+	}
+
+	batches := make(chan entryBatch, 2*workers)
+	results := make(chan batchResult, 2*workers)
+	quit := make(chan struct{})
+
+	base := agg.seq
+	errs := len(agg.ParseErrors)
+	var read int
+	var readErr error
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		defer close(batches)
+		read, readErr = agg.readBatches(src, raw, batches, quit)
+	}()
+
+	shards := make([]*Aggregator, workers)
+	var wg sync.WaitGroup
+	for i := range shards {
+		shards[i] = agg.newShard()
+		wg.Add(1)
+		go func(s *Aggregator) {
+			defer wg.Done()
+			s.countBatches(raw, base, batches, results, quit)
+		}(shards[i])
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results arrive in any order; pass their events on in batch order and
+	// stop at the first error, as a single pass would.
+	var err error
+	pending := make(map[int]batchResult)
+	next := 0
+	for r := range results {
+		if err != nil {
+			continue
+		}
+		pending[r.seq] = r
+		for {
+			r, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if err = agg.emit(r); err != nil {
+				close(quit)
+				break
+			}
+		}
+	}
+	<-readDone
+	if err == nil {
+		err = readErr
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, s := range shards {
+		agg.merge(s)
+	}
+	// The reader and the workers each skip entries in their own order.
+	sort.SliceStable(agg.ParseErrors[errs:], func(i, j int) bool {
+		return agg.ParseErrors[errs+i].Entry < agg.ParseErrors[errs+j].Entry
+	})
+	if len(agg.ParseErrors) > maxParseErrors {
+		agg.ParseErrors = agg.ParseErrors[:maxParseErrors]
+	}
+	agg.seq = base + read
+	agg.BytesRead += src.InputOffset()
+	return nil
+}
+
+// readBatches reads src into batches until EOF, an error or quit, dropping
+// duplicates when Dedupe is set. It returns how many entries it read.
+func (agg *Aggregator) readBatches(src parser.Decoder, raw parser.RawDecoder, batches chan<- entryBatch, quit <-chan struct{}) (int, error) {
+	entries := agg.EntriesDecoded
+	var b entryBatch
+	send := func() bool {
+		if len(b.entries) == 0 {
+			return true
+		}
+		select {
+		case batches <- b:
+			b = entryBatch{seq: b.seq + 1}
+			return true
+		case <-quit:
+			return false
+		}
+	}
+
+	for idx := 0; ; idx++ {
+		e := batchEntry{idx: idx}
+		var err error
+		if raw != nil {
+			e.raw, err = raw.NextRaw()
+			e.off = src.InputOffset() - int64(len(e.raw))
+		} else {
+			e.act, err = src.Next()
+		}
+		if err == io.EOF {
+			send()
+			return idx, nil
+		}
+		if err != nil {
+			ee, ok := agg.skip(idx, err)
+			if !ok || ee.Stop {
+				send()
+				if ok {
+					err = nil
+				}
+				return idx, err
+			}
+			continue
+		}
+		if agg.opts.OnProgress != nil && (idx+1)%progressEvery == 0 {
+			agg.opts.OnProgress(src.InputOffset(), entries+idx+1)
+		}
+
+		if agg.opts.Dedupe {
+			if !agg.seen.add(e.act) {
+				agg.EntriesDecoded++
+				agg.Duplicates++
+				continue
+			}
+		}
+		b.entries = append(b.entries, e)
+		if len(b.entries) == batchSize && !send() {
+			return idx + 1, nil
+		}
+	}
+}
+
+// newShard returns an empty Aggregator for one worker.
+func (agg *Aggregator) newShard() *Aggregator {
+	opts := agg.opts
+	opts.Workers = 0
+	opts.Dedupe = false
+	opts.OnProgress = nil
+	s := New(opts)
+	s.infoSeq = make(map[string]int)
+	s.input = agg.input
+	return s
+}
+
+// countBatches adds every entry of the batches it receives to s. Watch
+// events and activity records are collected into the batch's result instead
+// of calling OnWatch and OnActivity.
+func (s *Aggregator) countBatches(raw parser.RawDecoder, base int, batches <-chan entryBatch, results chan<- batchResult, quit <-chan struct{}) {
+	var r batchResult
+	var idx int
+	if s.opts.OnWatch != nil {
+		s.opts.OnWatch = func(e WatchEvent) error {
+			r.events = append(r.events, batchEvent{idx: idx, ev: e})
+			return nil
+		}
+	}
+	if s.opts.OnActivity != nil {
+		s.opts.OnActivity = func(rec ActivityRecord) error {
+			r.records = append(r.records, batchRecord{idx: idx, rec: rec})
+			return nil
+		}
+	}
+
+	for b := range batches {
+		select {
+		case <-quit:
+			continue
+		default:
+		}
+		r = batchResult{seq: b.seq}
+		for _, e := range b.entries {
+			idx = e.idx
+			a := e.act
+			if raw != nil {
+				var ok bool
+				var err error
+				if a, ok, err = raw.DecodeRaw(e.raw); err != nil {
+					ee := &parser.EntryError{Offset: e.off, Snippet: parser.Snippet(e.raw), Err: err}
+					if _, skipped := s.skip(idx, ee); skipped {
+						continue
+					}
+					r.err = ee
+					break
+				} else if !ok {
+					continue
+				}
+			}
+			s.seq = base + idx
+			if err := s.Add(a); err != nil {
+				r.err = fmt.Errorf("entry %d: %w", idx, err)
+				break
+			}
+		}
+		results <- r
+	}
+}
+
+// emit passes a batch's watch events and activity records on and returns
+// the batch's error, if any.
+func (agg *Aggregator) emit(r batchResult) error {
+	for _, e := range r.events {
+		if err := agg.opts.OnWatch(e.ev); err != nil {
+			return fmt.Errorf("entry %d: %w", e.idx, err)
+		}
+	}
+	for _, e := range r.records {
+		if err := agg.opts.OnActivity(e.rec); err != nil {
+			return fmt.Errorf("entry %d: %w", e.idx, err)
+		}
+	}
+	return r.err
+}
+
+// merge adds a worker's counts to agg. Where a single pass keeps the first
+// or latest of something (VideoInfo, NotWatchedSample, the latest name of a
+// channel), the input positions recorded by Add pick the same one.
+func (agg *Aggregator) merge(s *Aggregator) {
+	for y, m := range s.YearCounts {
+		addCounts(agg.YearCounts[y], m)
+	}
+	addCounts(agg.YearTotals, s.YearTotals)
+	for y, m := range s.YearWeekendCounts {
+		addCounts(agg.YearWeekendCounts[y], m)
+	}
+	addCounts(agg.YearWeekendTotals, s.YearWeekendTotals)
+	agg.TimeParseFails += s.TimeParseFails
+	addCounts(agg.YearParseFails, s.YearParseFails)
+	addCounts(agg.YearRemoved, s.YearRemoved)
+	addCounts(agg.YearUntitled, s.YearUntitled)
+	addCounts(agg.YearAds, s.YearAds)
+	addCounts(agg.YearMusic, s.YearMusic)
+	agg.TotalMusic += s.TotalMusic
+	for y, m := range s.MusicArtistCounts {
+		addCounts(agg.MusicArtistCounts[y], m)
+	}
+	addCounts(agg.MusicTrackCounts, s.MusicTrackCounts)
+	for y, m := range s.YearUnknownReasons {
+		addCounts(agg.YearUnknownReasons[y], m)
+	}
+	addCounts(agg.AllTimeCounts, s.AllTimeCounts)
+	for k, h := range s.AllTimeHours {
+		if agg.AllTimeHours[k] == nil {
+			agg.AllTimeHours[k] = new([24]int)
+		}
+		for i, n := range h {
+			agg.AllTimeHours[k][i] += n
+		}
+	}
+	for k, sp := range s.ChannelSpans {
+		agg.ChannelSpans[k] = agg.ChannelSpans[k].add(sp)
+	}
+	for k, sp := range s.HistorySpans {
+		agg.HistorySpans[k] = agg.HistorySpans[k].add(sp)
+	}
+	for m, c := range s.MonthChannelCounts {
+		addCounts(agg.MonthChannelCounts[m], c)
+	}
+	agg.TotalAllYears += s.TotalAllYears
+	agg.TotalRemoved += s.TotalRemoved
+	agg.TotalAds += s.TotalAds
+	agg.EntriesDecoded += s.EntriesDecoded
+	agg.Duplicates += s.Duplicates
+	agg.ChannelFiltered += s.ChannelFiltered
+	agg.AlreadyCounted += s.AlreadyCounted
+	switch sec, latest := s.LatestTime.Truncate(time.Second), agg.LatestTime.Truncate(time.Second); {
+	case sec.After(latest):
+		agg.LatestKeys = s.LatestKeys
+	case sec.Equal(latest):
+		maps.Copy(agg.LatestKeys, s.LatestKeys)
+	}
+	if s.LatestTime.After(agg.LatestTime) {
+		agg.LatestTime = s.LatestTime
+	}
+	agg.lastYear = max(agg.lastYear, s.lastYear)
+	agg.Skipped += s.Skipped
+	agg.ParseErrors = append(agg.ParseErrors, s.ParseErrors...)
+
+	if s.NotWatched > 0 && (agg.NotWatched == 0 ||
+		(s.sampleIsVideo && !agg.sampleIsVideo) ||
+		(s.sampleIsVideo == agg.sampleIsVideo && s.sampleSeq < agg.sampleSeq)) {
+		agg.NotWatchedSample = s.NotWatchedSample
+		agg.sampleIsVideo = s.sampleIsVideo
+		agg.sampleSeq = s.sampleSeq
+	}
+	agg.NotWatched += s.NotWatched
+	addCounts(agg.ActionCounts, s.ActionCounts)
+
+	for k, raw := range s.Aliases {
+		if agg.Aliases[k] == nil {
+			agg.Aliases[k] = make(map[ChannelKey]int)
+		}
+		addCounts(agg.Aliases[k], raw)
+	}
+	addCounts(agg.DayCounts, s.DayCounts)
+	for d, m := range s.DayChannelCounts {
+		if agg.DayChannelCounts[d] == nil {
+			agg.DayChannelCounts[d] = make(map[ChannelKey]int)
+		}
+		addCounts(agg.DayChannelCounts[d], m)
+	}
+	for d := range s.WeekdayHours {
+		for h, n := range s.WeekdayHours[d] {
+			agg.WeekdayHours[d][h] += n
+		}
+	}
+	for p, m := range s.PeriodCounts {
+		if agg.PeriodCounts[p] == nil {
+			agg.PeriodCounts[p] = make(map[ChannelKey]int)
+		}
+		addCounts(agg.PeriodCounts[p], m)
+	}
+	addCounts(agg.PeriodTotals, s.PeriodTotals)
+
+	for y, m := range s.YearVideoCounts {
+		addCounts(agg.YearVideoCounts[y], m)
+	}
+	addCounts(agg.AllTimeVideoCounts, s.AllTimeVideoCounts)
+	for vk, vi := range s.VideoInfo {
+		if _, ok := agg.VideoInfo[vk]; !ok || s.infoSeq[vk] < agg.infoSeq[vk] {
+			agg.VideoInfo[vk] = vi
+			agg.infoSeq[vk] = s.infoSeq[vk]
+		}
+	}
+	addCounts(agg.AdVideoCounts, s.AdVideoCounts)
+	addCounts(agg.RemovedVideoCounts, s.RemovedVideoCounts)
+
+	for g, l := range s.latest {
+		cur, ok := agg.latest[g]
+		if !ok || l.time.After(cur.time) || (l.time.Equal(cur.time) && l.seq < cur.seq) {
+			agg.latest[g] = l
+		}
+	}
+	agg.watchTimes = append(agg.watchTimes, s.watchTimes...)
+}
+
+func addCounts[K comparable](dst, src map[K]int) {
+	for k, n := range src {
+		dst[k] += n
+	}
+}
@@ -0,0 +1,67 @@
+package aggregate
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Plugin is a custom aggregator fed the same entries as the built-in
+// counters, for metrics of your own without changing Add. Plugins are
+// compiled in: a package registers one with RegisterPlugin from an init
+// function, and the takeout command enables it by name with -plugin.
+type Plugin interface {
+	// OnActivity is called like Options.OnActivity: for every entry that
+	// is not a dropped duplicate, counted or not, in input order and never
+	// concurrently.
+	OnActivity(ActivityRecord) error
+	// Finalize is called once all input has been consumed, with the
+	// built-in counts to build on.
+	Finalize(agg *Aggregator) error
+	// Outputs returns the files to write, keyed by file name without .json,
+	// each written as JSON.
+	Outputs() map[string]any
+}
+
+var plugins = struct {
+	sync.Mutex
+	byName map[string]func() Plugin
+}{byName: make(map[string]func() Plugin)}
+
+// RegisterPlugin makes a plugin available under name, with newPlugin
+// returning a fresh one for every run. Like database/sql.Register, it
+// panics if newPlugin is nil or name is already taken.
+func RegisterPlugin(name string, newPlugin func() Plugin) {
+	plugins.Lock()
+	defer plugins.Unlock()
+	if newPlugin == nil {
+		panic("aggregate: RegisterPlugin " + name + " with a nil constructor")
+	}
+	if _, dup := plugins.byName[name]; dup {
+		panic("aggregate: RegisterPlugin called twice for " + name)
+	}
+	plugins.byName[name] = newPlugin
+}
+
+// PluginNames returns the names of the registered plugins, sorted.
+func PluginNames() []string {
+	plugins.Lock()
+	defer plugins.Unlock()
+	names := make([]string, 0, len(plugins.byName))
+	for name := range plugins.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewPlugin returns a new instance of the plugin registered under name.
+func NewPlugin(name string) (Plugin, error) {
+	plugins.Lock()
+	newPlugin, ok := plugins.byName[name]
+	plugins.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin %q (registered: %v)", name, PluginNames())
+	}
+	return newPlugin(), nil
+}
@@ -0,0 +1,60 @@
+package aggregate
+
+import (
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/parser"
+)
+
+// ActivityRecord is one entry as Add interprets it, for Options.OnActivity:
+// the Takeout quirks (localized watched prefixes, removed and untitled
+// videos, ad views, YouTube Music plays, Shorts) already resolved into plain fields.
+type ActivityRecord struct {
+	Title       string `json:"title"`
+	VideoTitle  string `json:"video_title,omitempty"`
+	VideoURL    string `json:"video_url,omitempty"`
+	VideoID     string `json:"video_id,omitempty"`
+	ChannelName string `json:"channel_name,omitempty"`
+	ChannelURL  string `json:"channel_url,omitempty"`
+	// Time is in Options.Location, or nil if the entry's time is invalid.
+	Time      *time.Time `json:"time"`
+	Year      int        `json:"year,omitempty"`
+	IsWatch   bool       `json:"is_watch"`
+	IsAd      bool       `json:"is_ad"`
+	IsMusic   bool       `json:"is_music"`
+	IsShort   bool       `json:"is_short"`
+	IsRemoved bool       `json:"is_removed"`
+	// Action is the entry's action (see parser.Action); IsWatch is whether
+	// it is parser.ActionWatched.
+	Action string `json:"action"`
+	// Counted is whether the entry made it into the channel counts.
+	Counted bool `json:"counted"`
+}
+
+func (agg *Aggregator) activityRecord(a parser.Activity) ActivityRecord {
+	title := strings.TrimSpace(a.Title)
+	action, videoTitle := parser.Action(title, agg.opts.WatchedPrefixes)
+	r := ActivityRecord{
+		Title:    title,
+		Action:   action,
+		VideoURL: strings.TrimSpace(a.TitleURL),
+		VideoID:  parser.VideoIDFromURL(a.TitleURL),
+		IsWatch:  action == parser.ActionWatched,
+		IsAd:     a.IsAd(),
+		IsMusic:  a.IsMusic(),
+		IsShort:  parser.IsShortsURL(a.TitleURL),
+	}
+	r.ChannelName, r.ChannelURL = a.Channel()
+	r.ChannelName = agg.opts.ChannelName(r.ChannelName)
+	if action != parser.ActionOther {
+		r.VideoTitle = videoTitle
+		r.IsRemoved = parser.IsUntitledVideo(videoTitle) || parser.IsRemovedVideoTitle(title)
+	}
+	if t, err := parser.ParseTime(a.Time); err == nil {
+		t = t.In(agg.opts.Location)
+		r.Time = &t
+		r.Year = t.Year()
+	}
+	return r
+}
@@ -0,0 +1,70 @@
+package aggregate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// pseudonym derives a stable, hard to reverse name for s from key: the
+// first 48 bits of HMAC-SHA256(key, s) in hex after prefix.
+func pseudonym(key []byte, prefix, s string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return prefix + hex.EncodeToString(mac.Sum(nil)[:6])
+}
+
+// Redact replaces every channel with a pseudonym like "channel-1a2b3c4d5e6f"
+// and every video with one like "video-…", derived from key so the same key
+// gives the same pseudonyms in every run, and without it they cannot be
+// matched to names by hashing known ones. Channel and video URLs, the
+// snippets of ParseErrors and NotWatchedSample are dropped. Counts, and so
+// the rankings, are unchanged. Call it after ResolveChannels.
+func (agg *Aggregator) Redact(key string) {
+	k := []byte(key)
+	channel := func(c ChannelKey) ChannelKey {
+		if c.Name == unknownChannel && c.URL == "" {
+			return c
+		}
+		return ChannelKey{Name: pseudonym(k, "channel-", c.Name+"\x00"+c.URL)}
+	}
+	agg.remapChannels(channel)
+	for c, raw := range agg.Aliases {
+		out := make(map[ChannelKey]int, len(raw))
+		for r, n := range raw {
+			out[channel(r)] += n
+		}
+		agg.Aliases[c] = out
+	}
+
+	video := func(vk string) string { return pseudonym(k, "video-", vk) }
+	remap := func(m map[string]int) map[string]int {
+		out := make(map[string]int, len(m))
+		for vk, c := range m {
+			out[video(vk)] += c
+		}
+		return out
+	}
+	for y, m := range agg.YearVideoCounts {
+		agg.YearVideoCounts[y] = remap(m)
+	}
+	agg.AllTimeVideoCounts = remap(agg.AllTimeVideoCounts)
+	agg.AdVideoCounts = remap(agg.AdVideoCounts)
+	agg.MusicTrackCounts = remap(agg.MusicTrackCounts)
+	agg.RemovedVideoCounts = remap(agg.RemovedVideoCounts)
+	info := make(map[string]VideoInfo, len(agg.VideoInfo))
+	for vk, vi := range agg.VideoInfo {
+		p := video(vk)
+		info[p] = VideoInfo{Title: p, Channel: vi.Channel}
+	}
+	agg.VideoInfo = info
+
+	for i := range agg.ParseErrors {
+		agg.ParseErrors[i].Snippet = ""
+	}
+	agg.NotWatchedSample = ""
+	agg.redacted = true
+}
+
+// Redacted reports whether Redact has run.
+func (agg *Aggregator) Redacted() bool { return agg.redacted }
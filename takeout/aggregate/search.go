@@ -0,0 +1,172 @@
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"example.com/hello/takeout/parser"
+)
+
+// Searches holds the counters for search-history.json, filled in by Consume.
+// Only StartYear, EndYear, From, Until, StrictTimes, Strict, Location and
+// Dedupe of the options apply.
+type Searches struct {
+	opts Options
+
+	// YearQueries counts normalized queries (lowercased, spaces collapsed)
+	// per year.
+	YearQueries map[int]map[string]int
+	YearTotals  map[int]int
+	// Words counts the words of every counted query, minus stop words.
+	Words map[string]int
+	// MonthCounts counts searches per month ("2006-01").
+	MonthCounts    map[string]int
+	Total          int
+	EntriesDecoded int
+	Duplicates     int
+	// Skipped counts entries that could not be decoded (see
+	// Aggregator.Skipped).
+	Skipped int
+	seen    seenSet
+}
+
+func NewSearches(opts Options) *Searches {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+	s := &Searches{
+		opts:        opts,
+		YearQueries: make(map[int]map[string]int),
+		YearTotals:  make(map[int]int),
+		Words:       make(map[string]int),
+		MonthCounts: make(map[string]int),
+		seen:        make(seenSet),
+	}
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		s.YearQueries[y] = make(map[string]int)
+		s.YearTotals[y] = 0
+	}
+	return s
+}
+
+// Options returns the options the Searches were created with.
+func (s *Searches) Options() Options { return s.opts }
+
+// ConsumeFile opens the search history at path and consumes it.
+func (s *Searches) ConsumeFile(path string) error {
+	f, err := parser.OpenHistory(path, parser.SearchHistory)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Consume(f)
+}
+
+// Consume decodes a whole search history export from r.
+func (s *Searches) Consume(r io.Reader) error {
+	src, err := parser.NewDecoder(r)
+	if err != nil {
+		return err
+	}
+	for idx := 0; ; idx++ {
+		a, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var ee *parser.EntryError
+			if s.opts.Strict || !errors.As(err, &ee) {
+				return err
+			}
+			s.Skipped++
+			if ee.Stop {
+				return nil
+			}
+			continue
+		}
+		if err := s.Add(a); err != nil {
+			return fmt.Errorf("entry %d: %w", idx, err)
+		}
+	}
+}
+
+// Add counts a single search-history activity.
+func (s *Searches) Add(a parser.Activity) error {
+	opts := &s.opts
+	s.EntriesDecoded++
+
+	if opts.Dedupe {
+		if !s.seen.add(a) {
+			s.Duplicates++
+			return nil
+		}
+	}
+
+	q, ok := a.SearchQuery()
+	if !ok {
+		return nil
+	}
+
+	t, err := parser.ParseTime(a.Time)
+	if err == nil && opts.StrictTimes {
+		_, err = time.Parse(time.RFC3339, strings.TrimSpace(a.Time))
+	}
+	if err != nil {
+		if opts.StrictTimes {
+			return fmt.Errorf("invalid time %q: %w", a.Time, err)
+		}
+		return nil
+	}
+	t = t.In(opts.Location)
+	y := t.Year()
+	if !opts.InRange(t) {
+		return nil
+	}
+
+	q = strings.Join(strings.Fields(strings.ToLower(q)), " ")
+	s.YearQueries[y][q]++
+	s.YearTotals[y]++
+	s.MonthCounts[t.Format("2006-01")]++
+	s.Total++
+	for _, w := range strings.FieldsFunc(q, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) }) {
+		if len([]rune(w)) < 2 || searchStopWords[w] {
+			continue
+		}
+		s.Words[w]++
+	}
+	return nil
+}
+
+// searchStopWords are common English words left out of Searches.Words.
+var searchStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "do": true, "for": true, "from": true, "how": true,
+	"in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"the": true, "to": true, "vs": true, "what": true, "why": true, "with": true,
+}
+
+// TermCount is a search query or word with its count.
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// TermCounts turns m into counts sorted by count desc, then term.
+func TermCounts(m map[string]int) []TermCount {
+	out := make([]TermCount, 0, len(m))
+	for t, c := range m {
+		out = append(out, TermCount{Term: t, Count: c})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count == out[j].Count {
+			return out[i].Term < out[j].Term
+		}
+		return out[i].Count > out[j].Count
+	})
+	return out
+}
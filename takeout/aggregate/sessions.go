@@ -0,0 +1,46 @@
+package aggregate
+
+import (
+	"sort"
+	"time"
+)
+
+// Session is a run of watches with no gap of Options.SessionGap or more
+// between two consecutive ones.
+type Session struct {
+	Start  time.Time
+	End    time.Time
+	Videos int
+}
+
+// Sessions groups the counted watches into sessions, keyed by the year the
+// session started in. It returns nil unless Options.SessionGap is set.
+func (agg *Aggregator) Sessions() map[int][]Session {
+	gap := agg.opts.SessionGap
+	if gap <= 0 {
+		return nil
+	}
+	times := make([]time.Time, len(agg.watchTimes))
+	copy(times, agg.watchTimes)
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	out := make(map[int][]Session)
+	var cur *Session
+	flush := func() {
+		if cur != nil {
+			y := cur.Start.Year()
+			out[y] = append(out[y], *cur)
+		}
+	}
+	for _, t := range times {
+		if cur != nil && t.Sub(cur.End) < gap {
+			cur.End = t
+			cur.Videos++
+			continue
+		}
+		flush()
+		cur = &Session{Start: t, End: t, Videos: 1}
+	}
+	flush()
+	return out
+}
@@ -0,0 +1,255 @@
+package aggregate
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stateVersion changes whenever the state file's layout does, so a state
+// written by another version is recounted rather than misread.
+const stateVersion = 8
+
+// ErrStateMismatch is returned by LoadState for a state file written by
+// another version or with options that count watches differently.
+var ErrStateMismatch = errors.New("state was saved by another version or with different counting options")
+
+// stateHeader comes first in a state file. The year range is kept apart
+// from Key, as a state counted over every year of its input (AllYears) can
+// be resumed over a wider range once the history reaches a new year.
+type stateHeader struct {
+	Version   int
+	Key       string
+	StartYear int
+	EndYear   int
+	AllYears  bool
+}
+
+// stateBody is what SaveState keeps of an Aggregator besides its exported
+// counts.
+type stateBody struct {
+	Counts        *Aggregator
+	Latest        map[ChannelKey]stateSighting
+	WatchTimes    []time.Time
+	SampleIsVideo bool
+	LastYear      int
+}
+
+type stateSighting struct {
+	Key  ChannelKey
+	Time time.Time
+}
+
+// stateKey describes the options besides the year range that decide what is
+// counted and how, so a state is only reused by runs that would have counted
+// it the same way; stateYearsFit checks the range.
+func stateKey(opts Options) string {
+	loc := "UTC"
+	if opts.Location != nil {
+		loc = opts.Location.String()
+	}
+	return fmt.Sprintf("from=%s until=%s removed=%t ads=%t music=%t exclude=%s only=%s prefixes=%q actions=%q aliases=%t group=%s names=%s tz=%s granularity=%s sessions=%t daychannels=%t",
+		opts.From.Format(time.RFC3339), opts.Until.Format(time.RFC3339), opts.SkipRemoved, opts.ExcludeAds, opts.ExcludeMusic,
+		opts.ExcludeChannels.key(), opts.OnlyChannels.key(), opts.WatchedPrefixes, opts.Actions, opts.TrackAliases,
+		opts.GroupBy, opts.NormalizeNames, loc, opts.Granularity, opts.SessionGap > 0, opts.RollingDays > 0 || opts.DayChannels)
+}
+
+// SaveState writes agg's counts to path, replacing it only once the whole
+// state is written. Save before ResolveChannels, which the loading run
+// applies to the merged counts itself.
+func (agg *Aggregator) SaveState(path string) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	body := stateBody{
+		Counts:        agg,
+		Latest:        make(map[ChannelKey]stateSighting, len(agg.latest)),
+		WatchTimes:    agg.watchTimes,
+		SampleIsVideo: agg.sampleIsVideo,
+		LastYear:      agg.lastYear,
+	}
+	for g, l := range agg.latest {
+		body.Latest[g] = stateSighting{Key: l.key, Time: l.time}
+	}
+	bw := bufio.NewWriter(f)
+	enc := gob.NewEncoder(bw)
+	err = enc.Encode(stateHeader{
+		Version:   stateVersion,
+		Key:       stateKey(agg.opts),
+		StartYear: agg.opts.StartYear,
+		EndYear:   agg.opts.EndYear,
+		AllYears:  agg.opts.AllYears,
+	})
+	if err == nil {
+		err = enc.Encode(body)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadState reads a state written by SaveState into a new Aggregator with
+// opts, for MergeState. It returns ErrStateMismatch if the state was saved
+// with options that count differently.
+func LoadState(path string, opts Options) (*Aggregator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := gob.NewDecoder(bufio.NewReader(f))
+	h, err := readStateHeader(dec, path)
+	if err != nil {
+		return nil, err
+	}
+	if h.Key != stateKey(opts) || !stateYearsFit(h, opts) {
+		return nil, ErrStateMismatch
+	}
+	opts.Workers = 0
+	opts.Dedupe = false
+	opts.OnProgress, opts.OnWatch, opts.OnActivity = nil, nil, nil
+	body := stateBody{Counts: New(opts)}
+	if err := dec.Decode(&body); err != nil {
+		return nil, fmt.Errorf("reading state %s: %w", path, err)
+	}
+	agg := body.Counts
+	for g, l := range body.Latest {
+		agg.latest[g] = channelSighting{key: l.Key, time: l.Time}
+	}
+	agg.watchTimes = body.WatchTimes
+	agg.sampleIsVideo = body.SampleIsVideo
+	agg.lastYear = body.LastYear
+	return agg, nil
+}
+
+// StateYears returns the year range of the state at path if it can be
+// resumed with opts over every year of its input (see Options.AllYears), so
+// the run can take that range instead of detecting one: it was saved by
+// this version with options that count the same way but for the years, and
+// with AllYears. ok is false otherwise, or if the state cannot be read.
+func StateYears(path string, opts Options) (start, end int, ok bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+	h, err := readStateHeader(gob.NewDecoder(bufio.NewReader(f)), path)
+	if err != nil || h.Key != stateKey(opts) || !h.AllYears {
+		return 0, 0, false
+	}
+	return h.StartYear, h.EndYear, true
+}
+
+// readStateHeader decodes the header of the state at path from dec,
+// returning ErrStateMismatch for another version's.
+func readStateHeader(dec *gob.Decoder, path string) (stateHeader, error) {
+	var h stateHeader
+	if err := dec.Decode(&h); err != nil {
+		return h, fmt.Errorf("reading state %s: %w", path, err)
+	}
+	if h.Version != stateVersion {
+		return h, ErrStateMismatch
+	}
+	return h, nil
+}
+
+// stateYearsFit reports whether a state with header h has counted every
+// watch of opts' year range: it was saved with the same range, or with
+// every year of its input and opts' range takes them all in.
+func stateYearsFit(h stateHeader, opts Options) bool {
+	if h.StartYear == opts.StartYear && h.EndYear == opts.EndYear {
+		return true
+	}
+	return h.AllYears && opts.StartYear <= h.StartYear && h.EndYear <= opts.EndYear
+}
+
+// MergeState adds the counts of a loaded state to agg, which should have
+// counted only the entries after the state's LatestTime (see
+// Options.After). Where a single pass would keep the newest of something,
+// agg's entries win over the state's. Counters that describe this run's
+// reading (EntriesDecoded, BytesRead, Duplicates, AlreadyCounted, Skipped
+// and ParseErrors) are left as they are. With AllYears set, EndYear is then
+// lowered to the last year with a watch, as detecting the range would have
+// made it.
+func (agg *Aggregator) MergeState(old *Aggregator) {
+	decoded, read, dups, already := agg.EntriesDecoded, agg.BytesRead, agg.Duplicates, agg.AlreadyCounted
+	skipped, errs := agg.Skipped, agg.ParseErrors
+	// Entries with a bad time are read again on every run, never skipped as
+	// already counted.
+	timeFails, yearFails := agg.TimeParseFails, maps.Clone(agg.YearParseFails)
+
+	if agg.infoSeq == nil {
+		agg.infoSeq = make(map[string]int)
+	}
+	old.infoSeq = make(map[string]int, len(old.VideoInfo))
+	for vk := range old.VideoInfo {
+		old.infoSeq[vk] = math.MaxInt
+	}
+	old.sampleSeq = math.MaxInt
+	for g, l := range old.latest {
+		l.seq = math.MaxInt
+		old.latest[g] = l
+	}
+	agg.merge(old)
+
+	agg.EntriesDecoded, agg.BytesRead, agg.Duplicates, agg.AlreadyCounted = decoded, read, dups, already
+	agg.Skipped, agg.ParseErrors = skipped, errs
+	agg.TimeParseFails, agg.YearParseFails = timeFails, yearFails
+	if agg.opts.AllYears {
+		agg.trimYears(max(agg.lastYear, agg.opts.StartYear))
+	}
+}
+
+// trimYears lowers EndYear to end, dropping the buckets of the years after
+// it.
+func (agg *Aggregator) trimYears(end int) {
+	for y := end + 1; y <= agg.opts.EndYear; y++ {
+		delete(agg.YearCounts, y)
+		delete(agg.YearTotals, y)
+		delete(agg.YearWeekendCounts, y)
+		delete(agg.YearWeekendTotals, y)
+		delete(agg.YearParseFails, y)
+		delete(agg.YearRemoved, y)
+		delete(agg.YearUntitled, y)
+		delete(agg.YearAds, y)
+		delete(agg.YearUnknownReasons, y)
+		delete(agg.YearMusic, y)
+		delete(agg.MusicArtistCounts, y)
+		delete(agg.YearVideoCounts, y)
+	}
+	agg.opts.EndYear = min(agg.opts.EndYear, end)
+}
+
+// key lists the filter's entries in a fixed order, for stateKey.
+func (f *ChannelFilter) key() string {
+	if f == nil {
+		return ""
+	}
+	entries := make([]string, 0, len(f.exact)+len(f.patterns))
+	for e := range f.exact {
+		entries = append(entries, e)
+	}
+	sort.Strings(entries)
+	for _, re := range f.patterns {
+		entries = append(entries, "/"+re.String()+"/")
+	}
+	return fmt.Sprintf("%q", strings.Join(entries, "\n"))
+}
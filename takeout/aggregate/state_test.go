@@ -0,0 +1,158 @@
+package aggregate_test
+
+import (
+	"errors"
+	"maps"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+	"example.com/hello/takeout/synth"
+)
+
+// TestStateResumeNewYear saves a state counted over every year of a history
+// ending in 2024 and resumes it, as analyze does without -start and -end,
+// with the history grown into 2025: the saved entries must not be counted
+// again, and the counts must match a single pass over the grown history.
+func TestStateResumeNewYear(t *testing.T) {
+	prefixes, err := parser.LoadWatchedPrefixes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := synth.DefaultConfig(3000)
+	cfg.Start = time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg.End = time.Date(2025, 6, 30, 0, 0, 0, 0, time.UTC)
+	acts, err := synth.Activities(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cut := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var older []parser.Activity
+	for _, a := range acts {
+		if tm, err := parser.ParseTime(a.Time); err == nil && tm.Before(cut) {
+			older = append(older, a)
+		}
+	}
+
+	opts := aggregate.Options{
+		StartYear:       2023,
+		EndYear:         2024,
+		AllYears:        true,
+		WatchedPrefixes: prefixes,
+		Location:        time.UTC,
+	}
+	path := filepath.Join(t.TempDir(), "state.gob")
+	saved := aggregate.New(opts)
+	addAll(t, saved, older)
+	if err := saved.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+
+	start, end, ok := aggregate.StateYears(path, opts)
+	if !ok || start != 2023 || end != 2024 {
+		t.Fatalf("StateYears = %d, %d, %t; want 2023, 2024, true", start, end, ok)
+	}
+	// analyze widens the range through the current year.
+	resumed := opts
+	resumed.EndYear = 2027
+	state, err := aggregate.LoadState(path, resumed)
+	if err != nil {
+		t.Fatalf("LoadState over a wider range: %v", err)
+	}
+	resumed.After, resumed.AfterKeys = state.LatestTime, state.LatestKeys
+	agg := aggregate.New(resumed)
+	addAll(t, agg, acts)
+	agg.MergeState(state)
+
+	if agg.AlreadyCounted != len(older) {
+		t.Errorf("AlreadyCounted = %d, want the %d saved entries", agg.AlreadyCounted, len(older))
+	}
+	if got := agg.Options().EndYear; got != 2025 {
+		t.Errorf("EndYear = %d after MergeState, want 2025", got)
+	}
+	if _, ok := agg.YearCounts[2026]; ok {
+		t.Error("YearCounts keeps 2026, after the last watch")
+	}
+
+	single := opts
+	single.EndYear = 2025
+	full := aggregate.New(single)
+	addAll(t, full, acts)
+	if !maps.Equal(agg.YearTotals, full.YearTotals) {
+		t.Errorf("YearTotals = %v, want %v as in a single pass", agg.YearTotals, full.YearTotals)
+	}
+	if !maps.Equal(agg.AllTimeCounts, full.AllTimeCounts) {
+		t.Error("AllTimeCounts differ from a single pass")
+	}
+
+	narrower := opts
+	narrower.StartYear = 2024
+	if _, err := aggregate.LoadState(path, narrower); !errors.Is(err, aggregate.ErrStateMismatch) {
+		t.Errorf("LoadState over a range leaving out saved years: %v, want ErrStateMismatch", err)
+	}
+}
+
+// TestStateResumeSameSecond resumes a state whose newest watch shares its
+// second with a watch only the newer export has, as HTML exports with their
+// whole-second times do: that watch must be counted, the saved one not again.
+func TestStateResumeSameSecond(t *testing.T) {
+	prefixes, err := parser.LoadWatchedPrefixes("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	watch := func(id, at string) parser.Activity {
+		return parser.Activity{
+			Header:    "YouTube",
+			Title:     "Watched Video " + id,
+			TitleURL:  "https://www.youtube.com/watch?v=" + id,
+			Time:      at,
+			Subtitles: []parser.Subtitle{{Name: "Channel", URL: "https://www.youtube.com/channel/UC1"}},
+		}
+	}
+	older := []parser.Activity{
+		watch("aaaaaaaaaaa", "2024-03-01T10:00:00Z"),
+		watch("bbbbbbbbbbb", "2024-03-01T10:00:05Z"),
+	}
+	newer := []parser.Activity{
+		watch("ccccccccccc", "2024-03-01T10:00:05Z"),
+		watch("bbbbbbbbbbb", "2024-03-01T10:00:05.300Z"),
+		watch("aaaaaaaaaaa", "2024-03-01T10:00:00Z"),
+		watch("ddddddddddd", "2024-03-01T10:00:06Z"),
+	}
+
+	opts := aggregate.Options{StartYear: 2024, EndYear: 2024, WatchedPrefixes: prefixes, Location: time.UTC}
+	path := filepath.Join(t.TempDir(), "state.gob")
+	saved := aggregate.New(opts)
+	addAll(t, saved, older)
+	if err := saved.SaveState(path); err != nil {
+		t.Fatal(err)
+	}
+	state, err := aggregate.LoadState(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := opts
+	resumed.After, resumed.AfterKeys = state.LatestTime, state.LatestKeys
+	agg := aggregate.New(resumed)
+	addAll(t, agg, append(append([]parser.Activity{}, newer...), older...))
+	agg.MergeState(state)
+
+	if agg.AlreadyCounted != 4 {
+		t.Errorf("AlreadyCounted = %d, want the 4 entries of the saved watches", agg.AlreadyCounted)
+	}
+	if got := agg.YearTotals[2024]; got != 4 {
+		t.Errorf("YearTotals[2024] = %d, want 4 watches", got)
+	}
+}
+
+// addAll adds acts to agg, failing the test on an error.
+func addAll(t *testing.T, agg *aggregate.Aggregator, acts []parser.Activity) {
+	t.Helper()
+	for _, a := range acts {
+		if err := agg.Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package aggregate
+
+import (
+	"sort"
+	"strings"
+)
+
+type ChannelStat struct {
+	ChannelName string `json:"channel_name"`
+	ChannelURL  string `json:"channel_url,omitempty"`
+	WatchCount  int    `json:"watch_count"`
+	TypicalHour *int   `json:"typical_hour,omitempty"`
+	// ChannelCount is only set on the synthetic "(long tail)" entry and
+	// holds how many channels were folded into it.
+	ChannelCount int `json:"channel_count,omitempty"`
+	// RankDelta is the change in rank versus the prior year (positive means
+	// the channel moved up), or "new" if it was not watched that year.
+	RankDelta any `json:"rank_delta,omitempty"`
+	// Subscribed is whether the channel is in the subscriptions given with
+	// -subscriptions, or nil without them.
+	Subscribed *bool `json:"subscribed,omitempty"`
+	// Accounts breaks WatchCount down by account in a household run of
+	// labeled inputs, keyed by label, or is nil without labels.
+	Accounts map[string]int `json:"accounts,omitempty"`
+	// FirstWatched and LastWatched are the channel's first and last counted
+	// watch in any year, and SpanDays the calendar days from one to the
+	// other; only the full channel lists set them.
+	FirstWatched string `json:"first_watched,omitempty"`
+	LastWatched  string `json:"last_watched,omitempty"`
+	SpanDays     *int   `json:"span_days,omitempty"`
+}
+
+func (s ChannelStat) Key() ChannelKey {
+	return ChannelKey{Name: s.ChannelName, URL: s.ChannelURL}
+}
+
+type VideoStat struct {
+	VideoTitle  string `json:"video_title"`
+	VideoURL    string `json:"video_url,omitempty"`
+	ChannelName string `json:"channel_name"`
+	WatchCount  int    `json:"watch_count"`
+}
+
+// StatsFromMap turns channel counts into unsorted stats.
+func StatsFromMap(m map[ChannelKey]int) []ChannelStat {
+	out := make([]ChannelStat, 0, len(m))
+	for k, c := range m {
+		out = append(out, ChannelStat{
+			ChannelName: k.Name,
+			ChannelURL:  k.URL,
+			WatchCount:  c,
+		})
+	}
+	return out
+}
+
+func SortStatsByCountThenName(stats []ChannelStat) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].WatchCount != stats[j].WatchCount {
+			return stats[i].WatchCount > stats[j].WatchCount
+		}
+		return lessByName(stats[i], stats[j])
+	})
+}
+
+// lessByName orders channels by name ignoring case, then by the exact name
+// and URL, so no two channels tie.
+func lessByName(a, b ChannelStat) bool {
+	if an, bn := strings.ToLower(a.ChannelName), strings.ToLower(b.ChannelName); an != bn {
+		return an < bn
+	}
+	if a.ChannelName != b.ChannelName {
+		return a.ChannelName < b.ChannelName
+	}
+	return a.ChannelURL < b.ChannelURL
+}
+
+// ChannelSorts are the orders SortStats can put channel lists in.
+var ChannelSorts = []string{"count_desc", "count_asc", "name", "first_seen", "last_seen"}
+
+// SortStats sorts stats in one of the ChannelSorts, looking up the first
+// and last watch of each channel in spans for first_seen and last_seen.
+// Every order ends with the channel name and URL, so the result does not
+// depend on the input order; see SortDescription.
+func SortStats(stats []ChannelStat, order string, spans map[ChannelKey]WatchSpan) {
+	switch order {
+	case "count_asc":
+		sort.Slice(stats, func(i, j int) bool {
+			if stats[i].WatchCount != stats[j].WatchCount {
+				return stats[i].WatchCount < stats[j].WatchCount
+			}
+			return lessByName(stats[i], stats[j])
+		})
+	case "name":
+		sort.Slice(stats, func(i, j int) bool { return lessByName(stats[i], stats[j]) })
+	case "first_seen", "last_seen":
+		sort.Slice(stats, func(i, j int) bool {
+			a, b := spans[stats[i].Key()], spans[stats[j].Key()]
+			if order == "first_seen" && !a.First.Equal(b.First) {
+				return a.First.Before(b.First)
+			}
+			if order == "last_seen" && !a.Last.Equal(b.Last) {
+				return a.Last.After(b.Last)
+			}
+			if stats[i].WatchCount != stats[j].WatchCount {
+				return stats[i].WatchCount > stats[j].WatchCount
+			}
+			return lessByName(stats[i], stats[j])
+		})
+	default:
+		SortStatsByCountThenName(stats)
+	}
+}
+
+// SortDescription describes a SortStats order, with its tie-breaks, for the
+// sort field of the outputs.
+func SortDescription(order string) string {
+	const byName = "channel_name asc ignoring case, channel_name asc, channel_url asc"
+	switch order {
+	case "count_asc":
+		return "watch_count asc, " + byName
+	case "name":
+		return byName
+	case "first_seen":
+		return "first_watched asc, watch_count desc, " + byName
+	case "last_seen":
+		return "last_watched desc, watch_count desc, " + byName
+	}
+	return "watch_count desc, " + byName
+}
+
+// VideoStatsFromMap turns per-video counts into stats sorted by count, then
+// title, then URL.
+func VideoStatsFromMap(m map[string]int, info map[string]VideoInfo) []VideoStat {
+	out := make([]VideoStat, 0, len(m))
+	for k, c := range m {
+		vi := info[k]
+		out = append(out, VideoStat{
+			VideoTitle:  vi.Title,
+			VideoURL:    vi.URL,
+			ChannelName: vi.Channel.Name,
+			WatchCount:  c,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].WatchCount == out[j].WatchCount {
+			ti, tj := strings.ToLower(out[i].VideoTitle), strings.ToLower(out[j].VideoTitle)
+			if ti == tj {
+				return out[i].VideoURL < out[j].VideoURL
+			}
+			return ti < tj
+		}
+		return out[i].WatchCount > out[j].WatchCount
+	})
+	return out
+}
+
+// SplitLongTail splits sorted stats into channels with at least threshold
+// watches and a single "(long tail)" entry summarizing the rest. tail is nil
+// when threshold <= 0 or nothing falls below it.
+func SplitLongTail(stats []ChannelStat, threshold int) (head []ChannelStat, tail *ChannelStat) {
+	if threshold <= 0 {
+		return stats, nil
+	}
+	i := sort.Search(len(stats), func(i int) bool { return stats[i].WatchCount < threshold })
+	if i == len(stats) {
+		return stats, nil
+	}
+	t := ChannelStat{ChannelName: "(long tail)"}
+	for _, st := range stats[i:] {
+		t.WatchCount += st.WatchCount
+		t.ChannelCount++
+	}
+	return stats[:i], &t
+}
+
+// AnnotateRankDeltas sets RankDelta on sorted stats relative to prevRanks
+// (nil when there is no prior year) and returns this year's ranks.
+func AnnotateRankDeltas(stats []ChannelStat, prevRanks map[ChannelKey]int) map[ChannelKey]int {
+	ranks := make(map[ChannelKey]int, len(stats))
+	for i := range stats {
+		k := stats[i].Key()
+		ranks[k] = i + 1
+		if prevRanks == nil {
+			continue
+		}
+		if prev, ok := prevRanks[k]; ok {
+			stats[i].RankDelta = prev - (i + 1)
+		} else {
+			stats[i].RankDelta = "new"
+		}
+	}
+	return ranks
+}
+
+// ModeHour returns the most frequent hour of day in hours, preferring the
+// earliest hour on ties.
+func ModeHour(hours *[24]int) int {
+	best := 0
+	for h := 1; h < 24; h++ {
+		if hours[h] > hours[best] {
+			best = h
+		}
+	}
+	return best
+}
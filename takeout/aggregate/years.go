@@ -0,0 +1,84 @@
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/parser"
+)
+
+// FirstYouTubeYear is the year YouTube launched; DetectYears ignores watch
+// times before it, which can only be bogus.
+const FirstYouTubeYear = 2005
+
+// DetectYears reads the exports in paths and returns the first and last
+// year, in opts.Location, of a watch (or other entry of opts.Actions) with a
+// valid time, so the year range can cover the whole history. ok is false if
+// there is none. Entries that cannot be decoded are skipped, whatever
+// opts.Strict says; the counting pass reports them.
+func DetectYears(paths []string, opts Options) (first, last int, ok bool, err error) {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, p := range paths {
+		err := detectYears(p, func(a parser.Activity) {
+			action, _ := parser.Action(strings.TrimSpace(a.Title), opts.WatchedPrefixes)
+			if !opts.CountsAction(action) {
+				return
+			}
+			t, err := parser.ParseTime(a.Time)
+			if err != nil {
+				return
+			}
+			y := t.In(loc).Year()
+			if y < FirstYouTubeYear {
+				return
+			}
+			if !ok || y < first {
+				first = y
+			}
+			if !ok || y > last {
+				last = y
+			}
+			ok = true
+		})
+		if err != nil {
+			return 0, 0, false, fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return first, last, ok, nil
+}
+
+// detectYears passes every activity of the export at path to fn.
+func detectYears(path string, fn func(parser.Activity)) error {
+	f, err := parser.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	src, err := parser.NewDecoder(f)
+	if err != nil {
+		return err
+	}
+	for {
+		a, err := src.Next()
+		if err == io.EOF {
+			return nil
+		}
+		var ee *parser.EntryError
+		if errors.As(err, &ee) {
+			if ee.Stop {
+				return nil
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fn(a)
+	}
+}
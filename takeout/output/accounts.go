@@ -0,0 +1,110 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// Account is one labeled account of a household run (-in label=path),
+// counted on its own: it adds its share to every channel of the combined
+// lists and gets its own outputs under AccountDir.
+type Account struct {
+	Label      string
+	Agg        *aggregate.Aggregator
+	Inputs     []MergeInput
+	Processing ProcessingStats
+}
+
+// AccountSummary is an account's part of a household run in summary.json.
+type AccountSummary struct {
+	Label string `json:"label"`
+	// Dir is where the account's own outputs are, relative to the
+	// combined ones.
+	Dir                 string      `json:"dir"`
+	TotalVideosAllYears int         `json:"total_videos_all_years"`
+	UniqueChannels      int         `json:"unique_channels"`
+	Years               map[int]int `json:"years"`
+}
+
+// AccountDir is the directory the outputs of the account label are written
+// to within dir.
+func AccountDir(dir, label string) string {
+	return filepath.Join(dir, "accounts", label)
+}
+
+// accountSummaries describes the Accounts for summary.json, or nil without
+// them.
+func (w *Writer) accountSummaries() []AccountSummary {
+	if len(w.Accounts) == 0 {
+		return nil
+	}
+	out := make([]AccountSummary, 0, len(w.Accounts))
+	for _, a := range w.Accounts {
+		out = append(out, AccountSummary{
+			Label:               a.Label,
+			Dir:                 filepath.ToSlash(AccountDir("", a.Label)),
+			TotalVideosAllYears: a.Agg.TotalAllYears,
+			UniqueChannels:      len(a.Agg.AllTimeCounts),
+			Years:               a.Agg.YearTotals,
+		})
+	}
+	return out
+}
+
+// markAccounts sets Accounts on stats when there are Accounts: each
+// account's watches of the channel in the counts that counts picks out of
+// its Aggregator, such as one year's. Channels are matched by
+// Options.ChannelGroup, so with -group-by url an account that knows a
+// channel under an older name still adds to it. The long tail entry is left
+// out.
+func (w *Writer) markAccounts(stats []ChannelStat, counts func(*aggregate.Aggregator) map[aggregate.ChannelKey]int) {
+	if len(w.Accounts) == 0 {
+		return
+	}
+	groups := make([]map[aggregate.ChannelKey]int, len(w.Accounts))
+	for i, a := range w.Accounts {
+		opts := a.Agg.Options()
+		groups[i] = make(map[aggregate.ChannelKey]int)
+		for k, n := range counts(a.Agg) {
+			groups[i][opts.ChannelGroup(k)] += n
+		}
+	}
+	for i := range stats {
+		if stats[i].ChannelCount > 0 {
+			continue
+		}
+		stats[i].Accounts = make(map[string]int, len(w.Accounts))
+		for j, a := range w.Accounts {
+			stats[i].Accounts[a.Label] = groups[j][a.Agg.Options().ChannelGroup(stats[i].Key())]
+		}
+	}
+}
+
+// writeAccounts writes each account's own outputs into its AccountDir, with
+// the same settings as the combined ones. Metrics, plugins and the channel
+// report describe the whole run and are only written once.
+func (w *Writer) writeAccounts() error {
+	for _, a := range w.Accounts {
+		aw := *w
+		aw.Dir = AccountDir(w.Dir, a.Label)
+		aw.Accounts = nil
+		aw.Inputs = a.Inputs
+		aw.Processing = a.Processing
+		aw.MetricsOut = ""
+		aw.Plugins = nil
+		aw.ChannelReport = nil
+		aw.schemas = nil
+		if err := os.MkdirAll(aw.Dir, 0o755); err != nil {
+			return err
+		}
+		if err := aw.Write(a.Agg); err != nil {
+			return err
+		}
+		for name, t := range aw.schemas {
+			w.noteSchema(filepath.Join(aw.Dir, name), t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+
+	"example.com/hello/takeout/parser"
+)
+
+// ActivityWriter streams activities to a watch-history.json style file (a
+// JSON array, one activity per line) via a .tmp file renamed on Close.
+type ActivityWriter struct {
+	path string
+	f    *os.File
+	w    *bufio.Writer
+	n    int
+}
+
+func NewActivityWriter(path string) (*ActivityWriter, error) {
+	tmp := path + ".tmp"
+	trackTemp(tmp)
+	f, err := os.Create(tmp)
+	if err != nil {
+		untrackTemp(tmp)
+		return nil, err
+	}
+	aw := &ActivityWriter{path: path, f: f, w: bufio.NewWriterSize(f, 1024*1024)}
+	if _, err := aw.w.WriteString("["); err != nil {
+		aw.abort()
+		return nil, err
+	}
+	return aw, nil
+}
+
+// NewActivityStream streams activities to w in the same format, for
+// standard output; Close flushes it but leaves w open.
+func NewActivityStream(w io.Writer) (*ActivityWriter, error) {
+	aw := &ActivityWriter{w: bufio.NewWriterSize(w, 1024*1024)}
+	if _, err := aw.w.WriteString("["); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+func (aw *ActivityWriter) Write(a parser.Activity) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if aw.n == 0 {
+		sep = "\n"
+	}
+	aw.n++
+	if _, err := aw.w.WriteString(sep); err != nil {
+		return err
+	}
+	_, err = aw.w.Write(data)
+	return err
+}
+
+// Count reports how many activities have been written.
+func (aw *ActivityWriter) Count() int { return aw.n }
+
+func (aw *ActivityWriter) Close() error {
+	if _, err := aw.w.WriteString("\n]\n"); err != nil {
+		aw.abort()
+		return err
+	}
+	if err := aw.w.Flush(); err != nil {
+		aw.abort()
+		return err
+	}
+	if aw.f == nil {
+		return nil
+	}
+	tmp := aw.path + ".tmp"
+	defer untrackTemp(tmp)
+	if err := aw.f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, aw.path)
+}
+
+func (aw *ActivityWriter) abort() {
+	if aw.f == nil {
+		return
+	}
+	_ = aw.f.Close()
+	_ = os.Remove(aw.path + ".tmp")
+	untrackTemp(aw.path + ".tmp")
+}
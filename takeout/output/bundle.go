@@ -0,0 +1,114 @@
+package output
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsBundlePath reports whether path names an archive WriteBundle can write:
+// a .zip, .tar.gz or .tgz file.
+func IsBundlePath(path string) bool {
+	p := strings.ToLower(path)
+	return strings.HasSuffix(p, ".zip") || strings.HasSuffix(p, ".tar.gz") || strings.HasSuffix(p, ".tgz")
+}
+
+// WriteBundle archives every file below dir into path, a .zip, .tar.gz or
+// .tgz, with dir's manifest.json (see WriteManifest) first. The archive is
+// written atomically, like WriteJSON, and left out if it is inside dir
+// itself.
+func WriteBundle(path, dir string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	files, err := listFiles(dir, func(p string) bool {
+		a, err := filepath.Abs(p)
+		return err == nil && a == abs
+	})
+	if err != nil {
+		return err
+	}
+	files = append([]string{ManifestName}, files...)
+
+	tmp := path + ".tmp"
+	trackTemp(tmp)
+	defer untrackTemp(tmp)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		err = writeZip(f, dir, files)
+	} else {
+		err = writeTarGz(f, dir, files)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func writeZip(w io.Writer, dir string, files []string) error {
+	zw := zip.NewWriter(w)
+	for _, name := range files {
+		if err := addFile(dir, name, func(info fs.FileInfo, r io.Reader) error {
+			fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: info.ModTime()})
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(fw, r)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, dir string, files []string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, name := range files {
+		if err := addFile(dir, name, func(info fs.FileInfo, r io.Reader) error {
+			if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: info.Size(), ModTime: info.ModTime()}); err != nil {
+				return err
+			}
+			_, err := io.Copy(tw, r)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// addFile opens dir/name and passes it to add.
+func addFile(dir, name string, add func(fs.FileInfo, io.Reader) error) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err := add(info, f); err != nil {
+		return fmt.Errorf("adding %s: %w", name, err)
+	}
+	return nil
+}
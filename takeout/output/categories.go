@@ -0,0 +1,78 @@
+package output
+
+import (
+	"math"
+	"path/filepath"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// CategoryShare is a channel category's watches in a year or all time.
+type CategoryShare struct {
+	Category       string        `json:"category"`
+	WatchCount     int           `json:"watch_count"`
+	SharePercent   float64       `json:"share_percent"`
+	UniqueChannels int           `json:"unique_channels"`
+	TopChannels    []ChannelStat `json:"top_channels"`
+}
+
+type CategoryYear struct {
+	Year        int             `json:"year,omitempty"`
+	TotalVideos int             `json:"total_videos_watched"`
+	Categories  []CategoryShare `json:"categories"`
+}
+
+// categoryYear splits channel counts into w.Categories, in file order with
+// the uncategorized channels last.
+func (w *Writer) categoryYear(counts map[aggregate.ChannelKey]int, total int) CategoryYear {
+	names := append(append([]string{}, w.Categories.Names()...), aggregate.Uncategorized)
+	byCategory := make(map[string]map[aggregate.ChannelKey]int, len(names))
+	for k, n := range counts {
+		c := w.Categories.Category(k)
+		if byCategory[c] == nil {
+			byCategory[c] = make(map[aggregate.ChannelKey]int)
+		}
+		byCategory[c][k] = n
+	}
+	cy := CategoryYear{TotalVideos: total, Categories: make([]CategoryShare, 0, len(names))}
+	for _, name := range names {
+		stats := aggregate.StatsFromMap(byCategory[name])
+		aggregate.SortStatsByCountThenName(stats)
+		cs := CategoryShare{Category: name, UniqueChannels: len(stats), TopChannels: limitList(stats, w.TopN)}
+		for _, st := range stats {
+			cs.WatchCount += st.WatchCount
+		}
+		if total > 0 {
+			cs.SharePercent = math.Round(float64(cs.WatchCount)/float64(total)*1000) / 10
+		}
+		cy.Categories = append(cy.Categories, cs)
+	}
+	return cy
+}
+
+// writeCategories writes categories.json: the watches of each channel
+// category per year and all time, and their share of the total.
+func (w *Writer) writeCategories(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	years := make([]CategoryYear, 0, opts.EndYear-opts.StartYear+1)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		cy := w.categoryYear(agg.YearCounts[y], agg.YearTotals[y])
+		cy.Year = y
+		years = append(years, cy)
+	}
+
+	payload := struct {
+		Years   []CategoryYear `json:"years"`
+		AllTime CategoryYear   `json:"all_time"`
+		TopN    int            `json:"top_n"`
+		Sort    string         `json:"sort"`
+		Notes   string         `json:"notes"`
+	}{
+		Years:   years,
+		AllTime: w.categoryYear(agg.AllTimeCounts, agg.TotalAllYears),
+		TopN:    w.TopN,
+		Sort:    "categories in -categories file order, then (uncategorized); top_channels by watch_count desc, channel_name asc",
+		Notes:   "Each channel belongs to the first category in the -categories file with an entry matching its name or URL, or to (uncategorized). share_percent is the category's share of total_videos_watched, including \"(unknown channel)\" watches, which are uncategorized unless an entry matches them.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "categories.json"), payload)
+}
@@ -0,0 +1,168 @@
+package output
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// ChannelReport collects the watches of one channel, passed to Add as a
+// watch sink, for channel_report.json.
+type ChannelReport struct {
+	// Name, if set, matches the channel name as watched, ignoring case.
+	Name string
+	// URL, if set, matches the channel URL; with Group it matches every
+	// channel counted under the same identity, following renames.
+	URL string
+	// Group, if set, maps a channel to the identity it is counted under, as
+	// Options.ChannelGroup does.
+	Group   func(aggregate.ChannelKey) aggregate.ChannelKey
+	watches []aggregate.WatchEvent
+}
+
+type ChannelReportYear struct {
+	Year    int  `json:"year"`
+	Watches int  `json:"watches"`
+	Rank    *int `json:"rank,omitempty"`
+	// Channels is how many channels were watched that year.
+	Channels int `json:"channels"`
+}
+
+type ChannelWatch struct {
+	Time        string `json:"time"`
+	VideoTitle  string `json:"video_title"`
+	VideoURL    string `json:"video_url,omitempty"`
+	ChannelName string `json:"channel_name"`
+}
+
+type RewatchedVideo struct {
+	VideoTitle   string `json:"video_title"`
+	VideoURL     string `json:"video_url,omitempty"`
+	WatchCount   int    `json:"watch_count"`
+	FirstWatched string `json:"first_watched"`
+	LastWatched  string `json:"last_watched"`
+}
+
+// Add records e if it is a watch of the reported channel.
+func (r *ChannelReport) Add(e aggregate.WatchEvent) error {
+	if r.Name != "" && !strings.EqualFold(e.ChannelName, r.Name) {
+		return nil
+	}
+	if r.URL != "" && e.ChannelURL != r.URL {
+		if r.Group == nil || e.ChannelURL == "" ||
+			r.Group(aggregate.ChannelKey{URL: e.ChannelURL}) != r.Group(aggregate.ChannelKey{URL: r.URL}) {
+			return nil
+		}
+	}
+	r.watches = append(r.watches, e)
+	return nil
+}
+
+// Matched is how many watches Add has recorded.
+func (r *ChannelReport) Matched() int { return len(r.watches) }
+
+// writeChannelReport writes channel_report.json.
+func (w *Writer) writeChannelReport(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	r := w.ChannelReport
+	watches := append([]aggregate.WatchEvent(nil), r.watches...)
+	sort.SliceStable(watches, func(i, j int) bool { return watches[i].Time.Before(watches[j].Time) })
+
+	// The channels matched, as agg reports them, most watched first.
+	counts := make(map[aggregate.ChannelKey]int)
+	yearCounts := make(map[int]int)
+	monthCounts := make(map[string]int)
+	list := make([]ChannelWatch, 0, len(watches))
+	rewatches := make(map[string]*RewatchedVideo)
+	var order []string
+	for _, e := range watches {
+		k := agg.CanonicalChannel(aggregate.ChannelKey{Name: e.ChannelName, URL: e.ChannelURL})
+		counts[k]++
+		yearCounts[e.Time.Year()]++
+		monthCounts[e.Time.Format("2006-01")]++
+		ts := e.Time.Format(time.RFC3339)
+		list = append(list, ChannelWatch{Time: ts, VideoTitle: e.VideoTitle, VideoURL: e.VideoURL, ChannelName: k.Name})
+
+		id := e.VideoID
+		if id == "" {
+			id = e.VideoURL
+		}
+		if id == "" {
+			id = e.VideoTitle
+		}
+		v, ok := rewatches[id]
+		if !ok {
+			v = &RewatchedVideo{VideoURL: e.VideoURL, FirstWatched: ts}
+			rewatches[id] = v
+			order = append(order, id)
+		}
+		v.VideoTitle = e.VideoTitle
+		v.WatchCount++
+		v.LastWatched = ts
+	}
+	channels := aggregate.StatsFromMap(counts)
+	aggregate.SortStatsByCountThenName(channels)
+
+	years := make([]ChannelReportYear, 0, opts.EndYear-opts.StartYear+1)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		stats := aggregate.StatsFromMap(agg.YearCounts[y])
+		aggregate.SortStatsByCountThenName(stats)
+		year := ChannelReportYear{Year: y, Watches: yearCounts[y], Channels: len(stats)}
+		for i, s := range stats {
+			if _, ok := counts[s.Key()]; ok {
+				rank := i + 1
+				year.Rank = &rank
+				break
+			}
+		}
+		years = append(years, year)
+	}
+
+	months := make([]MonthCount, 0, len(monthCounts))
+	for m, n := range monthCounts {
+		months = append(months, MonthCount{Month: m, Count: n})
+	}
+	sort.Slice(months, func(i, j int) bool { return months[i].Month < months[j].Month })
+
+	rewatched := []RewatchedVideo{}
+	for _, id := range order {
+		if v := rewatches[id]; v.WatchCount > 1 {
+			rewatched = append(rewatched, *v)
+		}
+	}
+	sort.SliceStable(rewatched, func(i, j int) bool { return rewatched[i].WatchCount > rewatched[j].WatchCount })
+
+	payload := struct {
+		QueryName    string              `json:"query_name,omitempty"`
+		QueryURL     string              `json:"query_url,omitempty"`
+		Channels     []ChannelStat       `json:"channels"`
+		TotalWatches int                 `json:"total_watches"`
+		FirstWatched string              `json:"first_watched,omitempty"`
+		LastWatched  string              `json:"last_watched,omitempty"`
+		Years        []ChannelReportYear `json:"years"`
+		Months       []MonthCount        `json:"months"`
+		Rewatched    []RewatchedVideo    `json:"rewatched_videos"`
+		Watches      []ChannelWatch      `json:"watches"`
+		Sort         string              `json:"sort"`
+		Notes        string              `json:"notes"`
+	}{
+		QueryName:    r.Name,
+		QueryURL:     r.URL,
+		Channels:     channels,
+		TotalWatches: len(list),
+		Years:        years,
+		Months:       months,
+		Rewatched:    rewatched,
+		Watches:      list,
+		Sort:         "watches and months by time asc; rewatched_videos by watch_count desc, then first watch",
+		Notes:        "Covers the counted watches of every channel matching the query (the name ignoring case, the URL exactly or, with -group-by url, by channel ID). rank is the channel's place among all channels that year by watch count, for the best-ranked match; times are in the " + opts.Location.String() + " time zone.",
+	}
+	if len(list) > 0 {
+		payload.FirstWatched = list[0].Time
+		payload.LastWatched = list[len(list)-1].Time
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "channel_report.json"), payload)
+}
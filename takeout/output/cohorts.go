@@ -0,0 +1,101 @@
+package output
+
+import (
+	"math"
+	"path/filepath"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// Cohort is the channels first watched in one year and how many of them
+// were still watched in each year after.
+type Cohort struct {
+	Year     int `json:"year"`
+	Channels int `json:"channels"`
+	// Retained counts the cohort's channels watched at least once in Year,
+	// Year+1 and so on up to the last year of the range, and
+	// RetainedPercent is the same as a share of Channels.
+	Retained        []int     `json:"retained"`
+	RetainedPercent []float64 `json:"retained_percent"`
+}
+
+// CohortAverage is the share of channels still watched a number of years
+// after the year they were first watched, over every cohort old enough.
+type CohortAverage struct {
+	YearsLater      int     `json:"years_later"`
+	Cohorts         int     `json:"cohorts"`
+	Channels        int     `json:"channels"`
+	Retained        int     `json:"retained"`
+	RetainedPercent float64 `json:"retained_percent"`
+}
+
+// writeCohorts writes channel_cohorts.json: for the channels first watched
+// in each year of the range, the share still watched in every later year,
+// like the retention table of a subscription business.
+func (w *Writer) writeCohorts(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	cohorts := make(map[int][]aggregate.ChannelKey)
+	for k := range agg.AllTimeCounts {
+		sp, ok := agg.HistorySpans[k]
+		if !ok || k.Name == "(unknown channel)" {
+			continue
+		}
+		if y := sp.First.In(opts.Location).Year(); y >= opts.StartYear && y <= opts.EndYear {
+			cohorts[y] = append(cohorts[y], k)
+		}
+	}
+
+	list := make([]Cohort, 0, opts.EndYear-opts.StartYear+1)
+	averages := make([]CohortAverage, opts.EndYear-opts.StartYear+1)
+	for i := range averages {
+		averages[i].YearsLater = i
+	}
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		c := Cohort{Year: y, Channels: len(cohorts[y]), Retained: []int{}, RetainedPercent: []float64{}}
+		for later := y; later <= opts.EndYear; later++ {
+			n := 0
+			for _, k := range cohorts[y] {
+				if agg.YearCounts[later][k] > 0 {
+					n++
+				}
+			}
+			c.Retained = append(c.Retained, n)
+			c.RetainedPercent = append(c.RetainedPercent, sharePercent(n, c.Channels))
+			if c.Channels > 0 {
+				a := &averages[later-y]
+				a.Cohorts++
+				a.Channels += c.Channels
+				a.Retained += n
+			}
+		}
+		list = append(list, c)
+	}
+	for i := range averages {
+		averages[i].RetainedPercent = sharePercent(averages[i].Retained, averages[i].Channels)
+	}
+
+	payload := struct {
+		StartYear int             `json:"start_year"`
+		EndYear   int             `json:"end_year"`
+		Cohorts   []Cohort        `json:"cohorts"`
+		Average   []CohortAverage `json:"average"`
+		Notes     string          `json:"notes"`
+	}{
+		StartYear: opts.StartYear,
+		EndYear:   opts.EndYear,
+		Cohorts:   list,
+		Average:   averages,
+		Notes:     "A channel's cohort is the year of its first watch anywhere in the export, so channels first watched before the range belong to no cohort. retained[n] counts the cohort's channels watched at least once n years after the cohort year (retained[0] is the whole cohort), and average pools every cohort with a year n years later in the range. The last year of the export is usually partial, which lowers its retention.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "channel_cohorts.json"), payload)
+}
+
+// sharePercent is n of total in percent, to one decimal, or 0 without a
+// total.
+func sharePercent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(total)*1000) / 10
+}
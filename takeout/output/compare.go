@@ -0,0 +1,184 @@
+package output
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// CompareSide describes one of the two compared exports.
+type CompareSide struct {
+	Label          string `json:"label"`
+	TotalVideos    int    `json:"total_videos_watched"`
+	UniqueChannels int    `json:"unique_channels"`
+}
+
+// CompareChannel is a channel's watches and all-time rank (0 when absent)
+// in each export.
+type CompareChannel struct {
+	ChannelName string `json:"channel_name"`
+	ChannelURL  string `json:"channel_url,omitempty"`
+	ACount      int    `json:"a_count"`
+	BCount      int    `json:"b_count"`
+	ARank       int    `json:"a_rank,omitempty"`
+	BRank       int    `json:"b_rank,omitempty"`
+}
+
+// CompareYear is the channel overlap of one year. Jaccard is shared channels
+// over channels watched by either; WeightedJaccard weighs each channel by its
+// share of each person's watches, so channels both watch a lot count most.
+type CompareYear struct {
+	Year            int     `json:"year"`
+	AChannels       int     `json:"a_channels"`
+	BChannels       int     `json:"b_channels"`
+	SharedChannels  int     `json:"shared_channels"`
+	Jaccard         float64 `json:"jaccard"`
+	WeightedJaccard float64 `json:"weighted_jaccard"`
+}
+
+type Comparison struct {
+	A               CompareSide      `json:"a"`
+	B               CompareSide      `json:"b"`
+	SharedChannels  int              `json:"shared_channels"`
+	Jaccard         float64          `json:"jaccard"`
+	WeightedJaccard float64          `json:"weighted_jaccard"`
+	Years           []CompareYear    `json:"years"`
+	SharedTop       []CompareChannel `json:"shared_top_channels"`
+	OnlyA           []CompareChannel `json:"only_a"`
+	OnlyB           []CompareChannel `json:"only_b"`
+	Limit           int              `json:"limit"`
+	Sort            string           `json:"sort"`
+	Notes           string           `json:"notes"`
+}
+
+// overlap returns the shared channels, Jaccard and weighted Jaccard of two
+// channel counts, both keyed by channel group. The weighted one compares
+// each channel's share of the watches, so it does not depend on how much
+// either person watches.
+func overlap(a, b map[aggregate.ChannelKey]int) (shared int, jaccard, weighted float64) {
+	var totalA, totalB int
+	for _, n := range a {
+		totalA += n
+	}
+	for _, m := range b {
+		totalB += m
+	}
+	var same float64
+	for k, n := range a {
+		m, ok := b[k]
+		if !ok {
+			continue
+		}
+		shared++
+		same += min(float64(n)/float64(totalA), float64(m)/float64(totalB))
+	}
+	if union := len(a) + len(b) - shared; union > 0 {
+		jaccard = math.Round(float64(shared)/float64(union)*1000) / 1000
+	}
+	// With shares summing to 1 on each side, sum(min) / sum(max) is
+	// same / (2 - same).
+	if shared > 0 {
+		weighted = math.Round(same/(2-same)*1000) / 1000
+	}
+	return shared, jaccard, weighted
+}
+
+// CompareAccounts compares the channels of two people's exports: their
+// overlap all time and per year, the channels both watch most and those
+// only one of them watches. Channels are matched by name and URL, or with
+// GroupBy "url" by channel ID. Each list is cut to limit entries (0 = all).
+func CompareAccounts(a, b *aggregate.Aggregator, labelA, labelB string, limit int) Comparison {
+	opts := a.Options()
+	// group rekeys counts by channel group, remembering each group's
+	// name/URL as the export reports it.
+	names := make(map[aggregate.ChannelKey]aggregate.ChannelKey)
+	group := func(counts map[aggregate.ChannelKey]int) map[aggregate.ChannelKey]int {
+		out := make(map[aggregate.ChannelKey]int, len(counts))
+		for k, n := range counts {
+			if k.Name == "(unknown channel)" {
+				continue
+			}
+			g := opts.ChannelGroup(k)
+			out[g] += n
+			if _, ok := names[g]; !ok {
+				names[g] = k
+			}
+		}
+		return out
+	}
+
+	allA, allB := group(a.AllTimeCounts), group(b.AllTimeCounts)
+	c := Comparison{
+		A:     CompareSide{Label: labelA, TotalVideos: a.TotalAllYears, UniqueChannels: len(allA)},
+		B:     CompareSide{Label: labelB, TotalVideos: b.TotalAllYears, UniqueChannels: len(allB)},
+		Limit: limit,
+		Sort:  "shared_top_channels by the smaller of a_count and b_count desc, then a_count + b_count desc; only_a and only_b by count desc; ties by channel_name asc",
+		Notes: "Channels are matched by name and URL, or by channel ID with -group-by url, and \"(unknown channel)\" is left out. jaccard is shared channels over channels watched by either; weighted_jaccard compares each channel's share of each person's watches (the sum of the smaller shares over the sum of the larger), so channels watched a lot weigh most, 1 means the same mix of channels whoever watches more, and 0 no channel in common. Ranks are all-time positions in each export.",
+	}
+	c.SharedChannels, c.Jaccard, c.WeightedJaccard = overlap(allA, allB)
+
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		ya, yb := group(a.YearCounts[y]), group(b.YearCounts[y])
+		cy := CompareYear{Year: y, AChannels: len(ya), BChannels: len(yb)}
+		cy.SharedChannels, cy.Jaccard, cy.WeightedJaccard = overlap(ya, yb)
+		c.Years = append(c.Years, cy)
+	}
+
+	// Rank by the reported names, so channels with the same count keep the
+	// order of top_channels_all_time.json.
+	named := func(counts map[aggregate.ChannelKey]int) map[aggregate.ChannelKey]int {
+		out := make(map[aggregate.ChannelKey]int, len(counts))
+		for g, n := range counts {
+			out[names[g]] = n
+		}
+		return out
+	}
+	ranksA, ranksB := channelRanks(named(allA)), channelRanks(named(allB))
+	shared, onlyA, onlyB := []CompareChannel{}, []CompareChannel{}, []CompareChannel{}
+	entry := func(g aggregate.ChannelKey) CompareChannel {
+		k := names[g]
+		return CompareChannel{ChannelName: k.Name, ChannelURL: k.URL, ACount: allA[g], BCount: allB[g], ARank: ranksA[k], BRank: ranksB[k]}
+	}
+	for g := range allA {
+		if _, ok := allB[g]; ok {
+			shared = append(shared, entry(g))
+		} else {
+			onlyA = append(onlyA, entry(g))
+		}
+	}
+	for g := range allB {
+		if _, ok := allA[g]; !ok {
+			onlyB = append(onlyB, entry(g))
+		}
+	}
+	byName := func(x, y CompareChannel) bool {
+		return strings.ToLower(x.ChannelName) < strings.ToLower(y.ChannelName)
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if x, y := min(shared[i].ACount, shared[i].BCount), min(shared[j].ACount, shared[j].BCount); x != y {
+			return x > y
+		}
+		if x, y := shared[i].ACount+shared[i].BCount, shared[j].ACount+shared[j].BCount; x != y {
+			return x > y
+		}
+		return byName(shared[i], shared[j])
+	})
+	sort.Slice(onlyA, func(i, j int) bool {
+		if onlyA[i].ACount != onlyA[j].ACount {
+			return onlyA[i].ACount > onlyA[j].ACount
+		}
+		return byName(onlyA[i], onlyA[j])
+	})
+	sort.Slice(onlyB, func(i, j int) bool {
+		if onlyB[i].BCount != onlyB[j].BCount {
+			return onlyB[i].BCount > onlyB[j].BCount
+		}
+		return byName(onlyB[i], onlyB[j])
+	})
+	c.SharedTop = limitList(shared, limit)
+	c.OnlyA = limitList(onlyA, limit)
+	c.OnlyB = limitList(onlyB, limit)
+	return c
+}
@@ -0,0 +1,55 @@
+package output
+
+import (
+	"math"
+	"sort"
+)
+
+// Concentration describes how a year's watches are spread over channels.
+// The top shares are percentages of all channel-attributed watches.
+type Concentration struct {
+	Top1Percent             float64 `json:"top_1_percent"`
+	Top5Percent             float64 `json:"top_5_percent"`
+	Top10Percent            float64 `json:"top_10_percent"`
+	Top50Percent            float64 `json:"top_50_percent"`
+	Gini                    float64 `json:"gini"`
+	MedianWatchesPerChannel float64 `json:"median_watches_per_channel"`
+}
+
+// concentration computes Concentration from stats, which must be sorted by
+// watch count descending.
+func concentration(stats []ChannelStat) Concentration {
+	var c Concentration
+	if len(stats) == 0 {
+		return c
+	}
+	counts := make([]int, len(stats))
+	total := 0
+	for i, s := range stats {
+		counts[i] = s.WatchCount
+		total += s.WatchCount
+	}
+	if total == 0 {
+		return c
+	}
+	share := func(n int) float64 {
+		sum := 0
+		for _, v := range counts[:min(n, len(counts))] {
+			sum += v
+		}
+		return math.Round(float64(sum)/float64(total)*1000) / 10
+	}
+	c.Top1Percent, c.Top5Percent, c.Top10Percent, c.Top50Percent = share(1), share(5), share(10), share(50)
+
+	// Gini over the counts in ascending order: 0 when every channel has
+	// the same count, approaching 1 when one channel has them all.
+	sort.Ints(counts)
+	var weighted float64
+	for i, v := range counts {
+		weighted += float64(i+1) * float64(v)
+	}
+	n := float64(len(counts))
+	c.Gini = math.Round((2*weighted/(n*float64(total))-(n+1)/n)*1000) / 1000
+	c.MedianWatchesPerChannel = median(counts)
+	return c
+}
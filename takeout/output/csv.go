@@ -0,0 +1,135 @@
+package output
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats selects which files are written for channel and video list
+// outputs.
+type Formats struct {
+	JSON    bool
+	CSV     bool
+	Parquet bool
+}
+
+// ParseFormats parses a comma-separated list like "json,csv,parquet".
+func ParseFormats(s string) (Formats, error) {
+	var f Formats
+	for _, name := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "json":
+			f.JSON = true
+		case "csv":
+			f.CSV = true
+		case "parquet":
+			f.Parquet = true
+		case "":
+		default:
+			return f, fmt.Errorf("unknown format %q (want json, csv or parquet)", name)
+		}
+	}
+	if !f.JSON && !f.CSV && !f.Parquet {
+		return f, fmt.Errorf("no output format selected")
+	}
+	return f, nil
+}
+
+// WriteChannelList writes payload to <base>.json and/or stats to <base>.csv
+// and <base>.parquet, depending on formats.
+func WriteChannelList(base string, formats Formats, payload any, stats []ChannelStat) error {
+	return writeTable(nil, base, formats, payload,
+		func() [][]string { return channelStatsRecords(stats) },
+		func() ([]ParquetColumn, [][]any) { return channelStatsRows(stats) })
+}
+
+// WriteVideoList is WriteChannelList for video stats.
+func WriteVideoList(base string, formats Formats, payload any, stats []VideoStat) error {
+	return writeTable(nil, base, formats, payload,
+		func() [][]string { return videoStatsRecords(stats) },
+		func() ([]ParquetColumn, [][]any) { return videoStatsRows(stats) })
+}
+
+// writeTable writes the files of a channel or video list with files. The
+// contents are built before the writes are handed over, so the payload and
+// stats may change once it returns.
+func writeTable(files *fileWriter, base string, formats Formats, payload any, records func() [][]string, rows func() ([]ParquetColumn, [][]any)) error {
+	if formats.JSON {
+		b, err := encodeJSON(payload)
+		if err != nil {
+			return err
+		}
+		if err := files.do(func() error { return writeEncoded(base+".json", b) }); err != nil {
+			return err
+		}
+	}
+	if formats.CSV {
+		recs := records()
+		if err := files.do(func() error { return writeCSV(base+".csv", recs) }); err != nil {
+			return err
+		}
+	}
+	if formats.Parquet {
+		columns, values := rows()
+		if err := files.do(func() error { return writeParquet(base+".parquet", columns, values) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func channelStatsRecords(stats []ChannelStat) [][]string {
+	records := [][]string{{"rank", "channel_name", "channel_url", "watch_count", "rank_delta", "typical_hour", "channel_count", "first_watched", "last_watched", "span_days"}}
+	for i, st := range stats {
+		var rankDelta, typicalHour, channelCount, spanDays string
+		if st.RankDelta != nil {
+			rankDelta = fmt.Sprint(st.RankDelta)
+		}
+		if st.TypicalHour != nil {
+			typicalHour = strconv.Itoa(*st.TypicalHour)
+		}
+		if st.ChannelCount > 0 {
+			channelCount = strconv.Itoa(st.ChannelCount)
+		}
+		if st.SpanDays != nil {
+			spanDays = strconv.Itoa(*st.SpanDays)
+		}
+		records = append(records, []string{
+			strconv.Itoa(i + 1),
+			st.ChannelName,
+			st.ChannelURL,
+			strconv.Itoa(st.WatchCount),
+			rankDelta,
+			typicalHour,
+			channelCount,
+			st.FirstWatched,
+			st.LastWatched,
+			spanDays,
+		})
+	}
+	return records
+}
+
+func videoStatsRecords(stats []VideoStat) [][]string {
+	records := [][]string{{"rank", "video_title", "video_url", "channel_name", "watch_count"}}
+	for i, st := range stats {
+		records = append(records, []string{
+			strconv.Itoa(i + 1),
+			st.VideoTitle,
+			st.VideoURL,
+			st.ChannelName,
+			strconv.Itoa(st.WatchCount),
+		})
+	}
+	return records
+}
+
+// writeCSV writes records to path atomically, like WriteJSON.
+func writeCSV(path string, records [][]string) error {
+	return writeAtomic(path, func(f io.Writer) error {
+		return csv.NewWriter(f).WriteAll(records)
+	})
+}
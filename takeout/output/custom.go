@@ -0,0 +1,93 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// TemplateData is the data -template files are executed with.
+type TemplateData struct {
+	Generated string
+	// Summary is the content of summary.json.
+	Summary Summary
+	// Years lists every year in range, with all of its channels and videos.
+	Years []TemplateYear
+	// Channels and Videos are every channel and video, all time.
+	Channels []ChannelStat
+	Videos   []VideoStat
+}
+
+// TemplateYear is a year's top_channels_<YEAR>.json result plus its full
+// channel and video lists.
+type TemplateYear struct {
+	YearResult
+	Channels []ChannelStat
+	Videos   []VideoStat
+}
+
+// customFuncs are available to -template files: those of REPORT.md
+// templates, plus csv, which quotes a CSV field, and json, which encodes any
+// value as JSON.
+var customFuncs = template.FuncMap{
+	"md":    markdownFuncs["md"],
+	"inc":   markdownFuncs["inc"],
+	"delta": markdownFuncs["delta"],
+	"csv": func(s string) string {
+		var b strings.Builder
+		cw := csv.NewWriter(&b)
+		_ = cw.Write([]string{s})
+		cw.Flush()
+		return strings.TrimSuffix(b.String(), "\n")
+	},
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// ParseCustomTemplate parses a -template file. The output is written to
+// the file's base name without a .tmpl extension.
+func ParseCustomTemplate(path, text string) (*template.Template, error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+	return template.New(name).Funcs(customFuncs).Parse(text)
+}
+
+// writeCustom renders the Templates into Dir.
+func (w *Writer) writeCustom(agg *aggregate.Aggregator, summary Summary) error {
+	opts := agg.Options()
+	data := TemplateData{
+		Generated: time.Now().In(opts.Location).Format(time.RFC3339),
+		Summary:   summary,
+	}
+	var prevRanks map[aggregate.ChannelKey]int
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		ty := TemplateYear{
+			YearResult: summary.Years[y],
+			Channels:   aggregate.StatsFromMap(agg.YearCounts[y]),
+			Videos:     aggregate.VideoStatsFromMap(agg.YearVideoCounts[y], agg.VideoInfo),
+		}
+		aggregate.SortStatsByCountThenName(ty.Channels)
+		prevRanks = aggregate.AnnotateRankDeltas(ty.Channels, prevRanks)
+		w.markSubscribed(ty.Channels)
+		w.markAccounts(ty.Channels, func(a *aggregate.Aggregator) map[aggregate.ChannelKey]int { return a.YearCounts[y] })
+		data.Years = append(data.Years, ty)
+	}
+	data.Channels = aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(data.Channels)
+	w.markSubscribed(data.Channels)
+	w.markAccounts(data.Channels, func(a *aggregate.Aggregator) map[aggregate.ChannelKey]int { return a.AllTimeCounts })
+	data.Videos = aggregate.VideoStatsFromMap(agg.AllTimeVideoCounts, agg.VideoInfo)
+
+	for _, t := range w.Templates {
+		if err := writeTemplate(filepath.Join(w.Dir, t.Name()), t, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
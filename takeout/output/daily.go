@@ -0,0 +1,38 @@
+package output
+
+import (
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// writeDaily writes daily_counts.csv: the watch count of every day of the
+// counted window, days without watches included, for plotting. With
+// w.DailyChannels it adds a column for each of that many most watched
+// channels, which needs agg's DayChannelCounts.
+func (w *Writer) writeDaily(agg *aggregate.Aggregator) error {
+	var top []ChannelStat
+	if w.DailyChannels > 0 {
+		stats := aggregate.StatsFromMap(agg.AllTimeCounts)
+		aggregate.SortStatsByCountThenName(stats)
+		top = limitList(stats, w.DailyChannels)
+	}
+
+	header := []string{"date", "count"}
+	for _, st := range top {
+		header = append(header, st.ChannelName)
+	}
+	records := [][]string{header}
+	first, last := agg.Options().Window()
+	eachDay(first, last, func(d time.Time) {
+		day := d.Format(time.DateOnly)
+		rec := []string{day, strconv.Itoa(agg.DayCounts[day])}
+		for _, st := range top {
+			rec = append(rec, strconv.Itoa(agg.DayChannelCounts[day][st.Key()]))
+		}
+		records = append(records, rec)
+	})
+	return w.files.do(func() error { return writeCSV(filepath.Join(w.Dir, "daily_counts.csv"), records) })
+}
@@ -0,0 +1,550 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// Dashboard serves an interactive page over an Aggregator, computing every
+// view from memory. The Aggregator must not change while it is served.
+type Dashboard struct {
+	agg     *aggregate.Aggregator
+	years   map[int][]ChannelStat
+	allTime []ChannelStat
+	ids     map[string][]aggregate.ChannelKey // by watch count
+	metrics []byte
+	mux     *http.ServeMux
+}
+
+// DashboardYear is one year in the /api/summary response.
+type DashboardYear struct {
+	Year           int          `json:"year"`
+	TotalVideos    int          `json:"total_videos_watched"`
+	UniqueChannels int          `json:"unique_channels"`
+	Months         []MonthCount `json:"months"`
+}
+
+// DashboardChannel is a channel in the /api/channels response, with the ID
+// to look it up at /api/channel/{id}/timeline and its rank by watch count.
+// The names a channel was watched under, which share its ID, are one row,
+// named after the most watched of all time.
+type DashboardChannel struct {
+	ID   string `json:"id"`
+	Rank int    `json:"rank"`
+	ChannelStat
+}
+
+// DashboardTimelineYear is one year in the /api/channel/{id}/timeline
+// response; Rank is the channel's in /api/channels. Months is only set when
+// the Aggregator has DayChannelCounts.
+type DashboardTimelineYear struct {
+	Year    int          `json:"year"`
+	Watches int          `json:"watches"`
+	Rank    *int         `json:"rank,omitempty"`
+	Months  []MonthCount `json:"months,omitempty"`
+}
+
+// NewDashboard precomputes the ranked channel lists of agg and returns the
+// dashboard handler. It serves metrics, as rendered by Writer.Metrics, at
+// /metrics, and the JSON API the page is drawn from under /api, which
+// scripts can query as well.
+func NewDashboard(agg *aggregate.Aggregator, metrics []byte) *Dashboard {
+	opts := agg.Options()
+	d := &Dashboard{agg: agg, years: make(map[int][]ChannelStat), ids: make(map[string][]aggregate.ChannelKey), metrics: metrics, mux: http.NewServeMux()}
+
+	all := aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(all)
+	for _, s := range all {
+		id := channelID(s.Key())
+		d.ids[id] = append(d.ids[id], s.Key())
+	}
+	var prevRanks map[aggregate.ChannelKey]int
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		stats := d.groupByID(aggregate.StatsFromMap(agg.YearCounts[y]))
+		prevRanks = aggregate.AnnotateRankDeltas(stats, prevRanks)
+		d.years[y] = stats
+	}
+	d.allTime = d.groupByID(all)
+	for i := range d.allTime {
+		var hours *[24]int
+		for _, k := range d.ids[channelID(d.allTime[i].Key())] {
+			if h := agg.AllTimeHours[k]; h != nil {
+				if hours == nil {
+					hours = new([24]int)
+				}
+				for j, n := range h {
+					hours[j] += n
+				}
+			}
+		}
+		if hours != nil {
+			h := aggregate.ModeHour(hours)
+			d.allTime[i].TypicalHour = &h
+		}
+	}
+
+	d.mux.HandleFunc("GET /{$}", d.page)
+	d.mux.HandleFunc("GET /api/summary", d.summary)
+	d.mux.HandleFunc("GET /api/years", d.serveYears)
+	d.mux.HandleFunc("GET /api/channels", d.channels)
+	d.mux.HandleFunc("GET /api/channel/{id}/timeline", d.timeline)
+	d.mux.HandleFunc("GET /api/videos", d.videos)
+	d.mux.HandleFunc("GET /metrics", d.serveMetrics)
+	return d
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) { d.mux.ServeHTTP(w, r) }
+
+func (d *Dashboard) page(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, dashboardPage)
+}
+
+func (d *Dashboard) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", MetricsContentType)
+	w.Write(d.metrics)
+}
+
+// yearSummaries is every year's totals and watches per month.
+func (d *Dashboard) yearSummaries() []DashboardYear {
+	opts := d.agg.Options()
+	years := make([]DashboardYear, 0, opts.EndYear-opts.StartYear+1)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		dy := DashboardYear{
+			Year:           y,
+			TotalVideos:    d.agg.YearTotals[y],
+			UniqueChannels: len(d.agg.YearCounts[y]),
+			Months:         dashboardMonths(),
+		}
+		prefix := fmt.Sprintf("%04d-", y)
+		for day, n := range d.agg.DayCounts {
+			if !strings.HasPrefix(day, prefix) {
+				continue
+			}
+			if t, err := time.Parse(time.DateOnly, day); err == nil {
+				dy.Months[t.Month()-1].Count += n
+			}
+		}
+		years = append(years, dy)
+	}
+	return years
+}
+
+func (d *Dashboard) summary(w http.ResponseWriter, r *http.Request) {
+	opts := d.agg.Options()
+	years := d.yearSummaries()
+	writeJSONResponse(w, struct {
+		TimeZone       string          `json:"time_zone"`
+		TotalVideos    int             `json:"total_videos_all_years"`
+		UniqueChannels int             `json:"unique_channels"`
+		UniqueVideos   int             `json:"unique_videos"`
+		Years          []DashboardYear `json:"years"`
+		WeekdayHours   [7][24]int      `json:"weekday_hours"`
+	}{
+		TimeZone:       opts.Location.String(),
+		TotalVideos:    d.agg.TotalAllYears,
+		UniqueChannels: len(d.agg.AllTimeCounts),
+		UniqueVideos:   len(d.agg.AllTimeVideoCounts),
+		Years:          years,
+		WeekdayHours:   d.agg.WeekdayHours,
+	})
+}
+
+// yearParam reads ?year=, returning 0 for all time (missing or "all").
+func (d *Dashboard) yearParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	s := r.URL.Query().Get("year")
+	if s == "" || s == "all" {
+		return 0, true
+	}
+	y, err := strconv.Atoi(s)
+	opts := d.agg.Options()
+	if err != nil || y < opts.StartYear || y > opts.EndYear {
+		http.Error(w, fmt.Sprintf("year must be all or %d..%d", opts.StartYear, opts.EndYear), http.StatusBadRequest)
+		return 0, false
+	}
+	return y, true
+}
+
+func (d *Dashboard) serveYears(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, d.yearSummaries())
+}
+
+// channels serves ?year='s channels, ranked by watch count and ordered by
+// ?sort=count (the default), name or change in rank.
+func (d *Dashboard) channels(w http.ResponseWriter, r *http.Request) {
+	y, ok := d.yearParam(w, r)
+	if !ok {
+		return
+	}
+	by := r.URL.Query().Get("sort")
+	switch by {
+	case "", "count", "name", "change":
+	default:
+		http.Error(w, "sort must be count, name or change", http.StatusBadRequest)
+		return
+	}
+	stats := d.allTime
+	if y != 0 {
+		stats = d.years[y]
+	}
+	rows := make([]rankedChannel, len(stats))
+	for i, s := range stats {
+		rows[i] = rankedChannel{s, i + 1}
+	}
+	sortRankedChannels(rows, by)
+	out := make([]DashboardChannel, len(rows))
+	for i, row := range rows {
+		out[i] = DashboardChannel{ID: channelID(row.Key()), Rank: row.rank, ChannelStat: row.ChannelStat}
+	}
+	writeJSONResponse(w, out)
+}
+
+// timeline serves a channel's watches and rank in every year, and per month
+// when the Aggregator has DayChannelCounts. It covers every name the channel
+// was watched under, which share its ID, and is named after the most watched.
+func (d *Dashboard) timeline(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	keys, ok := d.ids[id]
+	if !ok {
+		http.Error(w, fmt.Sprintf("no channel with id %q", id), http.StatusNotFound)
+		return
+	}
+	opts := d.agg.Options()
+	var months map[int][]MonthCount
+	if d.agg.DayChannelCounts != nil {
+		months = make(map[int][]MonthCount)
+		for day, counts := range d.agg.DayChannelCounts {
+			n := 0
+			for _, k := range keys {
+				n += counts[k]
+			}
+			if n == 0 {
+				continue
+			}
+			t, err := time.Parse(time.DateOnly, day)
+			if err != nil {
+				continue
+			}
+			if months[t.Year()] == nil {
+				months[t.Year()] = dashboardMonths()
+			}
+			months[t.Year()][t.Month()-1].Count += n
+		}
+	}
+	years := make([]DashboardTimelineYear, 0, opts.EndYear-opts.StartYear+1)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		ty := DashboardTimelineYear{Year: y, Months: months[y]}
+		for _, k := range keys {
+			ty.Watches += d.agg.YearCounts[y][k]
+		}
+		for i, s := range d.years[y] {
+			if channelID(s.Key()) == id {
+				rank := i + 1
+				ty.Rank = &rank
+				break
+			}
+		}
+		if months != nil && ty.Months == nil {
+			ty.Months = dashboardMonths()
+		}
+		years = append(years, ty)
+	}
+
+	payload := struct {
+		ID           string                  `json:"id"`
+		ChannelName  string                  `json:"channel_name"`
+		ChannelURL   string                  `json:"channel_url,omitempty"`
+		WatchCount   int                     `json:"watch_count"`
+		FirstWatched string                  `json:"first_watched,omitempty"`
+		LastWatched  string                  `json:"last_watched,omitempty"`
+		Years        []DashboardTimelineYear `json:"years"`
+	}{
+		ID:          id,
+		ChannelName: keys[0].Name,
+		ChannelURL:  keys[0].URL,
+		Years:       years,
+	}
+	var span aggregate.WatchSpan
+	for _, k := range keys {
+		payload.WatchCount += d.agg.AllTimeCounts[k]
+		sp, ok := d.agg.ChannelSpans[k]
+		if !ok {
+			continue
+		}
+		if span.First.IsZero() || sp.First.Before(span.First) {
+			span.First = sp.First
+		}
+		if sp.Last.After(span.Last) {
+			span.Last = sp.Last
+		}
+	}
+	if !span.First.IsZero() {
+		payload.FirstWatched = span.First.Format(time.RFC3339)
+		payload.LastWatched = span.Last.Format(time.RFC3339)
+	}
+	writeJSONResponse(w, payload)
+}
+
+// dashboardMonths is a zero count for every month, Jan to Dec.
+func dashboardMonths() []MonthCount {
+	months := make([]MonthCount, 0, 12)
+	for m := time.January; m <= time.December; m++ {
+		months = append(months, MonthCount{Month: m.String()[:3]})
+	}
+	return months
+}
+
+// groupByID merges the stats of names that share a channel ID into one row
+// under the ID's most watched name of all time, sorted by watch count.
+func (d *Dashboard) groupByID(stats []ChannelStat) []ChannelStat {
+	rows := make(map[string]int, len(stats))
+	out := make([]ChannelStat, 0, len(stats))
+	for _, s := range stats {
+		id := channelID(s.Key())
+		if i, ok := rows[id]; ok {
+			out[i].WatchCount += s.WatchCount
+			continue
+		}
+		k := d.ids[id][0]
+		rows[id] = len(out)
+		out = append(out, ChannelStat{ChannelName: k.Name, ChannelURL: k.URL, WatchCount: s.WatchCount})
+	}
+	aggregate.SortStatsByCountThenName(out)
+	return out
+}
+
+// channelID identifies k in API paths: the UC... ID or @handle of its URL,
+// or its name when the URL has neither.
+func channelID(k aggregate.ChannelKey) string {
+	if id := parser.ChannelIDFromURL(k.URL); id != "" {
+		return id
+	}
+	return k.Name
+}
+
+func (d *Dashboard) videos(w http.ResponseWriter, r *http.Request) {
+	y, ok := d.yearParam(w, r)
+	if !ok {
+		return
+	}
+	counts := d.agg.AllTimeVideoCounts
+	if y != 0 {
+		counts = d.agg.YearVideoCounts[y]
+	}
+	writeJSONResponse(w, aggregate.VideoStatsFromMap(counts, d.agg.VideoInfo))
+}
+
+func writeJSONResponse(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// dashboardPage is the whole dashboard UI; it draws everything client-side
+// from the /api endpoints.
+const dashboardPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>YouTube watch history</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+.sub { color: #666; margin-top: 0; }
+.stats { display: flex; gap: 2em; margin: 1.5em 0; }
+.stats div { font-size: 0.9em; color: #666; }
+.stats b { display: block; font-size: 2em; color: #c00; }
+.years button { border: 1px solid #ccc; background: #fff; padding: 0.3em 0.8em; margin: 0 0.2em 0.4em 0; cursor: pointer; border-radius: 3px; }
+.years button.on { background: #c00; border-color: #c00; color: #fff; }
+svg text { font-size: 12px; fill: #333; }
+.bar { fill: #c00; cursor: pointer; }
+.bar.dim { fill: #e99; }
+.cell { fill: #c00; }
+input[type=search] { width: 100%; padding: 0.4em; margin: 0.5em 0; box-sizing: border-box; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.3em 0.5em; border-bottom: 1px solid #eee; }
+th { cursor: pointer; user-select: none; white-space: nowrap; }
+th.num, td.num { text-align: right; }
+.tables { display: grid; grid-template-columns: 1fr 1fr; gap: 2em; }
+@media (max-width: 800px) { .tables { grid-template-columns: 1fr; } }
+</style>
+</head>
+<body>
+<h1>Your YouTube</h1>
+<p class="sub" id="sub"></p>
+<div class="stats" id="stats"></div>
+<div class="years" id="years"></div>
+<h2 id="chart-title">Videos per year</h2>
+<svg id="chart" width="100%" height="220"></svg>
+<h2>When you watch</h2>
+<svg id="heatmap" width="580" height="180"></svg>
+<input type="search" id="search" placeholder="Search channels and videos">
+<div class="tables">
+<div><h2>Channels</h2><table id="channels"></table></div>
+<div><h2>Videos</h2><table id="videos"></table></div>
+</div>
+<script>
+"use strict";
+const svgNS = "http://www.w3.org/2000/svg";
+let summary, year = "all", channels = [], videos = [];
+const sorts = {channels: {key: "watch_count", desc: true}, videos: {key: "watch_count", desc: true}};
+
+function el(tag, attrs, text) {
+  const e = tag === "svg" || ["rect", "text", "title", "g"].includes(tag) ? document.createElementNS(svgNS, tag) : document.createElement(tag);
+  for (const k in attrs || {}) e.setAttribute(k, attrs[k]);
+  if (text !== undefined) e.textContent = text;
+  return e;
+}
+
+async function getJSON(url) {
+  const r = await fetch(url);
+  if (!r.ok) throw new Error(await r.text());
+  return r.json();
+}
+
+function drawBars(svg, items, onClick) {
+  svg.replaceChildren();
+  const w = svg.clientWidth || 900, h = 220, pad = 30;
+  const max = Math.max(1, ...items.map(i => i.count));
+  const bw = (w - pad) / items.length;
+  items.forEach((it, i) => {
+    const bh = (h - 2 * pad) * it.count / max;
+    const x = pad / 2 + i * bw;
+    const r = el("rect", {class: "bar" + (it.dim ? " dim" : ""), x: x + 4, y: h - pad - bh, width: Math.max(1, bw - 8), height: bh});
+    r.append(el("title", {}, it.label + ": " + it.count));
+    if (onClick) r.addEventListener("click", () => onClick(it));
+    svg.append(r, el("text", {x: x + bw / 2, y: h - pad + 16, "text-anchor": "middle"}, it.label),
+      el("text", {x: x + bw / 2, y: h - pad - bh - 4, "text-anchor": "middle"}, it.count));
+  });
+}
+
+function drawChart() {
+  const svg = document.getElementById("chart");
+  if (year === "all") {
+    document.getElementById("chart-title").textContent = "Videos per year";
+    drawBars(svg, summary.years.map(y => ({label: String(y.year), count: y.total_videos_watched, year: y.year})), it => selectYear(it.year));
+  } else {
+    const y = summary.years.find(y => y.year === year);
+    document.getElementById("chart-title").textContent = "Videos per month in " + year;
+    drawBars(svg, y.months.map(m => ({label: m.month, count: m.count})));
+  }
+}
+
+function drawHeatmap() {
+  const svg = document.getElementById("heatmap"), size = 20, left = 40;
+  const days = ["Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"];
+  const max = Math.max(1, ...summary.weekday_hours.flat());
+  summary.weekday_hours.forEach((hours, d) => {
+    svg.append(el("text", {x: 0, y: d * size + 14}, days[d]));
+    hours.forEach((n, h) => {
+      const r = el("rect", {class: "cell", x: left + h * size, y: d * size, width: size - 2, height: size - 2, "fill-opacity": (0.05 + 0.95 * n / max).toFixed(2)});
+      r.append(el("title", {}, days[d] + " " + String(h).padStart(2, "0") + ":00 - " + n + " watches"));
+      svg.append(r);
+    });
+  });
+  for (let h = 0; h < 24; h += 3) svg.append(el("text", {x: left + h * size, y: 7 * size + 14}, String(h).padStart(2, "0")));
+}
+
+function drawStats() {
+  const s = year === "all"
+    ? {videos: summary.total_videos_all_years, channels: summary.unique_channels}
+    : (y => ({videos: y.total_videos_watched, channels: y.unique_channels}))(summary.years.find(y => y.year === year));
+  const box = document.getElementById("stats");
+  box.replaceChildren();
+  for (const [n, label] of [[s.videos, "videos watched"], [s.channels, "channels"]]) {
+    const d = el("div");
+    d.append(el("b", {}, n), label);
+    box.append(d);
+  }
+}
+
+function drawYears() {
+  const box = document.getElementById("years");
+  box.replaceChildren();
+  for (const y of ["all", ...summary.years.filter(y => y.total_videos_watched > 0).map(y => y.year)]) {
+    const b = el("button", {class: y === year ? "on" : ""}, y === "all" ? "All time" : y);
+    b.addEventListener("click", () => selectYear(y));
+    box.append(b);
+  }
+}
+
+function drawTable(id, rows, cols) {
+  const q = document.getElementById("search").value.trim().toLowerCase();
+  const s = sorts[id];
+  const shown = rows.filter(r => !q || cols.some(c => c.search && String(r[c.key] || "").toLowerCase().includes(q)));
+  shown.sort((a, b) => {
+    const x = a[s.key] ?? "", y = b[s.key] ?? "";
+    const c = typeof x === "number" && typeof y === "number" ? x - y : String(x).localeCompare(String(y));
+    return s.desc ? -c : c;
+  });
+  const t = document.getElementById(id);
+  t.replaceChildren();
+  const head = el("tr");
+  for (const c of cols) {
+    const th = el("th", {class: c.num ? "num" : ""}, c.label + (s.key === c.key ? (s.desc ? " ▼" : " ▲") : ""));
+    th.addEventListener("click", () => {
+      sorts[id] = {key: c.key, desc: s.key === c.key ? !s.desc : !!c.num};
+      render();
+    });
+    head.append(th);
+  }
+  t.append(head);
+  for (const r of shown.slice(0, 200)) {
+    const tr = el("tr");
+    for (const c of cols) {
+      const td = el("td", {class: c.num ? "num" : ""});
+      if (c.link && r[c.link]) {
+        const a = el("a", {href: r[c.link], target: "_blank", rel: "noopener"}, r[c.key]);
+        td.append(a);
+      } else {
+        td.textContent = c.fmt ? c.fmt(r[c.key], r) : (r[c.key] ?? "");
+      }
+      tr.append(td);
+    }
+    t.append(tr);
+  }
+}
+
+function render() {
+  drawTable("channels", channels, [
+    {key: "rank", label: "#", num: true},
+    {key: "channel_name", label: "Channel", search: true, link: "channel_url"},
+    {key: "watch_count", label: "Videos", num: true},
+    {key: "rank_delta", label: "Δ rank", fmt: d => d === undefined ? "" : typeof d === "number" ? (d > 0 ? "▲" + d : d < 0 ? "▼" + -d : "=") : d},
+  ]);
+  drawTable("videos", videos, [
+    {key: "video_title", label: "Video", search: true, link: "video_url"},
+    {key: "channel_name", label: "Channel", search: true},
+    {key: "watch_count", label: "Watches", num: true},
+  ]);
+}
+
+async function selectYear(y) {
+  year = y;
+  [channels, videos] = await Promise.all([getJSON("api/channels?year=" + y), getJSON("api/videos?year=" + y)]);
+  channels.forEach((c, i) => c.rank = i + 1);
+  drawYears();
+  drawStats();
+  drawChart();
+  render();
+}
+
+(async () => {
+  summary = await getJSON("api/summary");
+  document.getElementById("sub").textContent = "Times in " + summary.time_zone + ".";
+  drawHeatmap();
+  document.getElementById("search").addEventListener("input", render);
+  await selectYear("all");
+})().catch(e => document.body.append(el("pre", {}, String(e))));
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,321 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// HistoryDataset writes the -out parquet:<dir> dataset: every entry as
+// Add interprets it, in Hive-style year partitions
+// (activities/year=2024/data.parquet), and the aggregates as one Parquet
+// table each with the year as a column, so DuckDB (or Spark, Polars, ...)
+// can query across years without joining per-year files. Entries are
+// streamed in as they are read; the aggregates are added at the end.
+type HistoryDataset struct {
+	dir   string
+	parts map[int]*ParquetWriter
+}
+
+// RawActivityColumns is the schema of the activities partitions;
+// RawActivityRow builds a matching row. The year is in the partition path.
+var RawActivityColumns = []ParquetColumn{
+	{Name: "time", Type: ParquetInt64, Converted: ParquetTimestampMillis, Optional: true},
+	{Name: "date", Type: ParquetInt32, Converted: ParquetDate, Optional: true},
+	{Name: "month", Type: ParquetInt32, Converted: ParquetNoConversion, Optional: true},
+	{Name: "hour", Type: ParquetInt32, Converted: ParquetNoConversion, Optional: true},
+	{Name: "title", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "action", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "video_title", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+	{Name: "video_url", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+	{Name: "video_id", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+	{Name: "channel_name", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+	{Name: "channel_url", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+	{Name: "channel_id", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+	{Name: "is_ad", Type: ParquetBoolean, Converted: ParquetNoConversion},
+	{Name: "is_music", Type: ParquetBoolean, Converted: ParquetNoConversion},
+	{Name: "is_short", Type: ParquetBoolean, Converted: ParquetNoConversion},
+	{Name: "is_removed", Type: ParquetBoolean, Converted: ParquetNoConversion},
+	{Name: "counted", Type: ParquetBoolean, Converted: ParquetNoConversion},
+}
+
+func RawActivityRow(r aggregate.ActivityRecord) []any {
+	var t, date, month, hour any
+	if r.Time != nil {
+		t = r.Time.UnixMilli()
+		date = parquetDate(*r.Time)
+		month = int32(r.Time.Month())
+		hour = int32(r.Time.Hour())
+	}
+	return []any{
+		t, date, month, hour,
+		r.Title,
+		r.Action,
+		nullIfEmpty(r.VideoTitle),
+		nullIfEmpty(r.VideoURL),
+		nullIfEmpty(r.VideoID),
+		nullIfEmpty(r.ChannelName),
+		nullIfEmpty(r.ChannelURL),
+		nullIfEmpty(parser.ChannelIDFromURL(r.ChannelURL)),
+		r.IsAd, r.IsMusic, r.IsShort, r.IsRemoved, r.Counted,
+	}
+}
+
+// nullIfEmpty makes an empty string a null in an Optional column.
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// parquetDate is the DATE value of t's calendar day: days since 1970-01-01.
+func parquetDate(t time.Time) int32 {
+	return int32(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+func NewHistoryDataset(dir string) (*HistoryDataset, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &HistoryDataset{dir: dir, parts: make(map[int]*ParquetWriter)}, nil
+}
+
+// partitionDir is the directory of year's activities; entries without a
+// valid time are under year=0.
+func (d *HistoryDataset) partitionDir(year int) string {
+	return filepath.Join(d.dir, "activities", "year="+strconv.Itoa(year))
+}
+
+func (d *HistoryDataset) AddActivity(r aggregate.ActivityRecord) error {
+	pw, ok := d.parts[r.Year]
+	if !ok {
+		dir := d.partitionDir(r.Year)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		var err error
+		if pw, err = NewParquetWriter(filepath.Join(dir, "data.parquet"), RawActivityColumns); err != nil {
+			return err
+		}
+		d.parts[r.Year] = pw
+	}
+	return pw.WriteRow(RawActivityRow(r)...)
+}
+
+// abort drops the partitions being written.
+func (d *HistoryDataset) abort() {
+	for _, pw := range d.parts {
+		pw.abort()
+	}
+}
+
+// Finish closes the activity partitions, removes those a previous run left
+// for years this one has no entries in, and writes the aggregate tables and
+// views.sql.
+func (d *HistoryDataset) Finish(agg *aggregate.Aggregator) error {
+	for _, pw := range d.parts {
+		if err := pw.Close(); err != nil {
+			d.abort()
+			return err
+		}
+	}
+	stale, err := filepath.Glob(filepath.Join(d.dir, "activities", "year=*", "data.parquet"))
+	if err != nil {
+		return err
+	}
+	for _, p := range stale {
+		y, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(filepath.Dir(p)), "year="))
+		if _, ok := d.parts[y]; ok && err == nil {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		_ = os.Remove(filepath.Dir(p)) // only if nothing else is in it
+	}
+
+	opts := agg.Options()
+	years := make([]int, 0, opts.EndYear-opts.StartYear+1)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		years = append(years, y)
+	}
+	tables := []struct {
+		name string
+		rows func() ([]ParquetColumn, [][]any)
+	}{
+		{"years", func() ([]ParquetColumn, [][]any) { return datasetYears(agg, years) }},
+		{"channel_years", func() ([]ParquetColumn, [][]any) { return datasetChannelYears(agg, years) }},
+		{"video_years", func() ([]ParquetColumn, [][]any) { return datasetVideoYears(agg, years) }},
+		{"channels", func() ([]ParquetColumn, [][]any) { return datasetChannels(agg) }},
+		{"daily_counts", func() ([]ParquetColumn, [][]any) { return datasetDays(agg) }},
+		{"hours", func() ([]ParquetColumn, [][]any) { return datasetHours(agg) }},
+	}
+	names := make([]string, 0, len(tables))
+	for _, t := range tables {
+		columns, rows := t.rows()
+		if err := writeParquet(filepath.Join(d.dir, t.name+".parquet"), columns, rows); err != nil {
+			return err
+		}
+		names = append(names, t.name)
+	}
+	return writeTemplate(filepath.Join(d.dir, "views.sql"), datasetViews, names)
+}
+
+// datasetViews is views.sql, which defines a DuckDB view per table of the
+// dataset; the paths are relative to its directory.
+var datasetViews = template.Must(template.New("views.sql").Parse(`-- DuckDB views over this dataset: run duckdb -init views.sql in this directory.
+CREATE OR REPLACE VIEW activities AS SELECT * FROM read_parquet('activities/*/*.parquet', hive_partitioning = true);
+{{- range .}}
+CREATE OR REPLACE VIEW {{.}} AS SELECT * FROM read_parquet('{{.}}.parquet');
+{{- end}}
+`))
+
+func datasetYears(agg *aggregate.Aggregator, years []int) ([]ParquetColumn, [][]any) {
+	columns := []ParquetColumn{
+		{Name: "year", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "unique_channels", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "unique_videos", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "weekend_watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "removed_videos", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "ad_views", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "music_plays", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "time_parse_failures", Type: ParquetInt32, Converted: ParquetNoConversion},
+	}
+	rows := make([][]any, 0, len(years))
+	for _, y := range years {
+		rows = append(rows, []any{
+			int32(y),
+			int32(agg.YearTotals[y]),
+			int32(len(agg.YearCounts[y])),
+			int32(len(agg.YearVideoCounts[y])),
+			int32(agg.YearWeekendTotals[y]),
+			int32(agg.YearRemoved[y]),
+			int32(agg.YearAds[y]),
+			int32(agg.YearMusic[y]),
+			int32(agg.YearParseFails[y]),
+		})
+	}
+	return columns, rows
+}
+
+func datasetChannelYears(agg *aggregate.Aggregator, years []int) ([]ParquetColumn, [][]any) {
+	columns := []ParquetColumn{
+		{Name: "year", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "rank", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "channel_name", Type: ParquetByteArray, Converted: ParquetUTF8},
+		{Name: "channel_url", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+		{Name: "channel_id", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+		{Name: "watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "weekend_watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+	}
+	var rows [][]any
+	for _, y := range years {
+		stats := aggregate.StatsFromMap(agg.YearCounts[y])
+		aggregate.SortStatsByCountThenName(stats)
+		for i, st := range stats {
+			rows = append(rows, []any{
+				int32(y),
+				int32(i + 1),
+				st.ChannelName,
+				nullIfEmpty(st.ChannelURL),
+				nullIfEmpty(parser.ChannelIDFromURL(st.ChannelURL)),
+				int32(st.WatchCount),
+				int32(agg.YearWeekendCounts[y][st.Key()]),
+			})
+		}
+	}
+	return columns, rows
+}
+
+func datasetVideoYears(agg *aggregate.Aggregator, years []int) ([]ParquetColumn, [][]any) {
+	columns := []ParquetColumn{
+		{Name: "year", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "rank", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "video_title", Type: ParquetByteArray, Converted: ParquetUTF8},
+		{Name: "video_url", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+		{Name: "video_id", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+		{Name: "channel_name", Type: ParquetByteArray, Converted: ParquetUTF8},
+		{Name: "watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+	}
+	var rows [][]any
+	for _, y := range years {
+		for i, v := range aggregate.VideoStatsFromMap(agg.YearVideoCounts[y], agg.VideoInfo) {
+			rows = append(rows, []any{
+				int32(y),
+				int32(i + 1),
+				v.VideoTitle,
+				nullIfEmpty(v.VideoURL),
+				nullIfEmpty(parser.VideoIDFromURL(v.VideoURL)),
+				v.ChannelName,
+				int32(v.WatchCount),
+			})
+		}
+	}
+	return columns, rows
+}
+
+func datasetChannels(agg *aggregate.Aggregator) ([]ParquetColumn, [][]any) {
+	columns := []ParquetColumn{
+		{Name: "rank", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "channel_name", Type: ParquetByteArray, Converted: ParquetUTF8},
+		{Name: "channel_url", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+		{Name: "channel_id", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+		{Name: "watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "first_watched", Type: ParquetInt64, Converted: ParquetTimestampMillis},
+		{Name: "last_watched", Type: ParquetInt64, Converted: ParquetTimestampMillis},
+	}
+	stats := aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(stats)
+	rows := make([][]any, 0, len(stats))
+	for i, st := range stats {
+		span := agg.ChannelSpans[st.Key()]
+		rows = append(rows, []any{
+			int32(i + 1),
+			st.ChannelName,
+			nullIfEmpty(st.ChannelURL),
+			nullIfEmpty(parser.ChannelIDFromURL(st.ChannelURL)),
+			int32(st.WatchCount),
+			span.First.UnixMilli(),
+			span.Last.UnixMilli(),
+		})
+	}
+	return columns, rows
+}
+
+// datasetDays lists every day of the counted window, days without watches
+// included, like daily_counts.csv.
+func datasetDays(agg *aggregate.Aggregator) ([]ParquetColumn, [][]any) {
+	columns := []ParquetColumn{
+		{Name: "date", Type: ParquetInt32, Converted: ParquetDate},
+		{Name: "watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+	}
+	var rows [][]any
+	first, last := agg.Options().Window()
+	eachDay(first, last, func(d time.Time) {
+		rows = append(rows, []any{parquetDate(d), int32(agg.DayCounts[d.Format(time.DateOnly)])})
+	})
+	return columns, rows
+}
+
+func datasetHours(agg *aggregate.Aggregator) ([]ParquetColumn, [][]any) {
+	columns := []ParquetColumn{
+		{Name: "weekday", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "hour", Type: ParquetInt32, Converted: ParquetNoConversion},
+		{Name: "watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+	}
+	rows := make([][]any, 0, 7*24)
+	for wd, hours := range agg.WeekdayHours {
+		for h, n := range hours {
+			rows = append(rows, []any{int32(wd), int32(h), int32(n)})
+		}
+	}
+	return columns, rows
+}
@@ -0,0 +1,236 @@
+package output
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// ChannelDiff compares one channel between two exports. Ranks are all-time
+// positions (0 when absent); RankDelta is positive when it moved up.
+type ChannelDiff struct {
+	ChannelName string `json:"channel_name"`
+	ChannelURL  string `json:"channel_url,omitempty"`
+	OldCount    int    `json:"old_count"`
+	NewCount    int    `json:"new_count"`
+	Delta       int    `json:"delta"`
+	OldRank     int    `json:"old_rank,omitempty"`
+	NewRank     int    `json:"new_rank,omitempty"`
+	RankDelta   int    `json:"rank_delta,omitempty"`
+}
+
+// VideoDiff is ChannelDiff for a video.
+type VideoDiff struct {
+	VideoTitle  string `json:"video_title"`
+	VideoURL    string `json:"video_url,omitempty"`
+	ChannelName string `json:"channel_name"`
+	OldCount    int    `json:"old_count"`
+	NewCount    int    `json:"new_count"`
+	Delta       int    `json:"delta"`
+	OldRank     int    `json:"old_rank,omitempty"`
+	NewRank     int    `json:"new_rank,omitempty"`
+	RankDelta   int    `json:"rank_delta,omitempty"`
+}
+
+type VideoDiffs struct {
+	OldUnique   int         `json:"old_unique_videos"`
+	NewUnique   int         `json:"new_unique_videos"`
+	Added       []VideoDiff `json:"added"`
+	Dropped     []VideoDiff `json:"dropped"`
+	Changed     []VideoDiff `json:"changed"`
+	RankChanges []VideoDiff `json:"rank_changes"`
+}
+
+type Diff struct {
+	OldTotal       int           `json:"old_total_videos"`
+	NewTotal       int           `json:"new_total_videos"`
+	Growth         int           `json:"growth"`
+	GrowthPercent  *float64      `json:"growth_percent"`
+	OldChannels    int           `json:"old_unique_channels"`
+	NewChannels    int           `json:"new_unique_channels"`
+	Added          []ChannelDiff `json:"added"`
+	Dropped        []ChannelDiff `json:"dropped"`
+	Changed        []ChannelDiff `json:"changed"`
+	RankChanges    []ChannelDiff `json:"rank_changes"`
+	Videos         VideoDiffs    `json:"videos"`
+	Limit          int           `json:"limit"`
+	Sort           string        `json:"sort"`
+	RankChangeSort string        `json:"rank_changes_sort"`
+}
+
+// DiffChannels compares all-time channel and video counts. Added entries only
+// appear in newAgg, dropped ones only in oldAgg and changed ones in both with
+// a different count; rank changes are entries in both whose all-time rank
+// moved. Each list is cut to limit entries (0 = all).
+func DiffChannels(oldAgg, newAgg *aggregate.Aggregator, limit int) Diff {
+	d := Diff{
+		OldTotal:       oldAgg.TotalAllYears,
+		NewTotal:       newAgg.TotalAllYears,
+		Growth:         newAgg.TotalAllYears - oldAgg.TotalAllYears,
+		OldChannels:    len(oldAgg.AllTimeCounts),
+		NewChannels:    len(newAgg.AllTimeCounts),
+		Added:          []ChannelDiff{},
+		Dropped:        []ChannelDiff{},
+		Changed:        []ChannelDiff{},
+		RankChanges:    []ChannelDiff{},
+		Limit:          limit,
+		Sort:           "abs(delta) desc, channel_name (video_title for videos) asc",
+		RankChangeSort: "abs(rank_delta) desc, new_rank asc",
+	}
+	if oldAgg.TotalAllYears > 0 {
+		p := math.Round(float64(d.Growth)/float64(oldAgg.TotalAllYears)*1000) / 10
+		d.GrowthPercent = &p
+	}
+
+	oldRanks := channelRanks(oldAgg.AllTimeCounts)
+	newRanks := channelRanks(newAgg.AllTimeCounts)
+	for k, n := range newAgg.AllTimeCounts {
+		o := oldAgg.AllTimeCounts[k]
+		cd := ChannelDiff{ChannelName: k.Name, ChannelURL: k.URL, OldCount: o, NewCount: n, Delta: n - o, NewRank: newRanks[k]}
+		if o == 0 {
+			d.Added = append(d.Added, cd)
+			continue
+		}
+		cd.OldRank = oldRanks[k]
+		cd.RankDelta = cd.OldRank - cd.NewRank
+		if n != o {
+			d.Changed = append(d.Changed, cd)
+		}
+		if cd.RankDelta != 0 {
+			d.RankChanges = append(d.RankChanges, cd)
+		}
+	}
+	for k, o := range oldAgg.AllTimeCounts {
+		if _, ok := newAgg.AllTimeCounts[k]; !ok {
+			d.Dropped = append(d.Dropped, ChannelDiff{ChannelName: k.Name, ChannelURL: k.URL, OldCount: o, Delta: -o, OldRank: oldRanks[k]})
+		}
+	}
+	for _, list := range []*[]ChannelDiff{&d.Added, &d.Dropped, &d.Changed} {
+		sortChannelDiffs(*list)
+		*list = limitList(*list, limit)
+	}
+	sort.Slice(d.RankChanges, func(i, j int) bool {
+		return rankChangeLess(d.RankChanges[i].RankDelta, d.RankChanges[i].NewRank, d.RankChanges[j].RankDelta, d.RankChanges[j].NewRank)
+	})
+	d.RankChanges = limitList(d.RankChanges, limit)
+
+	d.Videos = diffVideos(oldAgg, newAgg, limit)
+	return d
+}
+
+func diffVideos(oldAgg, newAgg *aggregate.Aggregator, limit int) VideoDiffs {
+	vd := VideoDiffs{
+		OldUnique:   len(oldAgg.AllTimeVideoCounts),
+		NewUnique:   len(newAgg.AllTimeVideoCounts),
+		Added:       []VideoDiff{},
+		Dropped:     []VideoDiff{},
+		Changed:     []VideoDiff{},
+		RankChanges: []VideoDiff{},
+	}
+	oldRanks := videoRanks(oldAgg)
+	newRanks := videoRanks(newAgg)
+	for vk, n := range newAgg.AllTimeVideoCounts {
+		vi := newAgg.VideoInfo[vk]
+		o := oldAgg.AllTimeVideoCounts[vk]
+		v := VideoDiff{VideoTitle: vi.Title, VideoURL: vi.URL, ChannelName: vi.Channel.Name, OldCount: o, NewCount: n, Delta: n - o, NewRank: newRanks[vk]}
+		if o == 0 {
+			vd.Added = append(vd.Added, v)
+			continue
+		}
+		v.OldRank = oldRanks[vk]
+		v.RankDelta = v.OldRank - v.NewRank
+		if n != o {
+			vd.Changed = append(vd.Changed, v)
+		}
+		if v.RankDelta != 0 {
+			vd.RankChanges = append(vd.RankChanges, v)
+		}
+	}
+	for vk, o := range oldAgg.AllTimeVideoCounts {
+		if _, ok := newAgg.AllTimeVideoCounts[vk]; !ok {
+			vi := oldAgg.VideoInfo[vk]
+			vd.Dropped = append(vd.Dropped, VideoDiff{VideoTitle: vi.Title, VideoURL: vi.URL, ChannelName: vi.Channel.Name, OldCount: o, Delta: -o, OldRank: oldRanks[vk]})
+		}
+	}
+	for _, list := range []*[]VideoDiff{&vd.Added, &vd.Dropped, &vd.Changed} {
+		l := *list
+		sort.Slice(l, func(i, j int) bool {
+			if a, b := abs(l[i].Delta), abs(l[j].Delta); a != b {
+				return a > b
+			}
+			return strings.ToLower(l[i].VideoTitle) < strings.ToLower(l[j].VideoTitle)
+		})
+		*list = limitList(l, limit)
+	}
+	sort.Slice(vd.RankChanges, func(i, j int) bool {
+		return rankChangeLess(vd.RankChanges[i].RankDelta, vd.RankChanges[i].NewRank, vd.RankChanges[j].RankDelta, vd.RankChanges[j].NewRank)
+	})
+	vd.RankChanges = limitList(vd.RankChanges, limit)
+	return vd
+}
+
+// channelRanks numbers channels the way top_channels_all_time.json orders
+// them.
+func channelRanks(counts map[aggregate.ChannelKey]int) map[aggregate.ChannelKey]int {
+	stats := aggregate.StatsFromMap(counts)
+	aggregate.SortStatsByCountThenName(stats)
+	return aggregate.AnnotateRankDeltas(stats, nil)
+}
+
+// videoRanks is channelRanks for top_videos_all_time.json, keyed like
+// AllTimeVideoCounts.
+func videoRanks(agg *aggregate.Aggregator) map[string]int {
+	keys := make([]string, 0, len(agg.AllTimeVideoCounts))
+	for vk := range agg.AllTimeVideoCounts {
+		keys = append(keys, vk)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := agg.AllTimeVideoCounts[keys[i]], agg.AllTimeVideoCounts[keys[j]]
+		if a == b {
+			ta := strings.ToLower(agg.VideoInfo[keys[i]].Title)
+			tb := strings.ToLower(agg.VideoInfo[keys[j]].Title)
+			if ta == tb {
+				return keys[i] < keys[j]
+			}
+			return ta < tb
+		}
+		return a > b
+	})
+	ranks := make(map[string]int, len(keys))
+	for i, vk := range keys {
+		ranks[vk] = i + 1
+	}
+	return ranks
+}
+
+func rankChangeLess(deltaA, rankA, deltaB, rankB int) bool {
+	if a, b := abs(deltaA), abs(deltaB); a != b {
+		return a > b
+	}
+	return rankA < rankB
+}
+
+func limitList[T any](list []T, limit int) []T {
+	if limit > 0 && len(list) > limit {
+		return list[:limit]
+	}
+	return list
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func sortChannelDiffs(diffs []ChannelDiff) {
+	sort.Slice(diffs, func(i, j int) bool {
+		if a, b := abs(diffs[i].Delta), abs(diffs[j].Delta); a != b {
+			return a > b
+		}
+		return strings.ToLower(diffs[i].ChannelName) < strings.ToLower(diffs[j].ChannelName)
+	})
+}
@@ -0,0 +1,85 @@
+package output
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// Discovery is a channel watched for the first time in a year.
+type Discovery struct {
+	ChannelName  string `json:"channel_name"`
+	ChannelURL   string `json:"channel_url,omitempty"`
+	FirstWatched string `json:"first_watched"`
+	// WatchCount counts every watch of the channel in the range, and
+	// FirstYearWatches those in the year it was discovered.
+	WatchCount       int `json:"watch_count"`
+	FirstYearWatches int `json:"first_year_watches"`
+}
+
+type DiscoveryYear struct {
+	Year int `json:"year"`
+	// NewChannels counts every channel first watched in the year, however
+	// often it was watched after.
+	NewChannels int         `json:"new_channels"`
+	Discoveries []Discovery `json:"discoveries"`
+}
+
+// writeDiscoveries writes discoveries.json: per year, the channels first
+// watched that year that reached w.DiscoveryMin watches.
+func (w *Writer) writeDiscoveries(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	byYear := make(map[int][]Discovery)
+	newChannels := make(map[int]int)
+	for k, n := range agg.AllTimeCounts {
+		sp, ok := agg.HistorySpans[k]
+		if !ok || k.Name == "(unknown channel)" {
+			continue
+		}
+		first := sp.First.In(opts.Location)
+		y := first.Year()
+		newChannels[y]++
+		if n < w.DiscoveryMin {
+			continue
+		}
+		byYear[y] = append(byYear[y], Discovery{
+			ChannelName:      k.Name,
+			ChannelURL:       k.URL,
+			FirstWatched:     first.Format(time.RFC3339),
+			WatchCount:       n,
+			FirstYearWatches: agg.YearCounts[y][k],
+		})
+	}
+
+	years := make([]DiscoveryYear, 0, opts.EndYear-opts.StartYear+1)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		list := byYear[y]
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].WatchCount != list[j].WatchCount {
+				return list[i].WatchCount > list[j].WatchCount
+			}
+			return strings.ToLower(list[i].ChannelName) < strings.ToLower(list[j].ChannelName)
+		})
+		if list == nil {
+			list = []Discovery{}
+		}
+		years = append(years, DiscoveryYear{Year: y, NewChannels: newChannels[y], Discoveries: list})
+	}
+
+	payload := struct {
+		MinWatches int             `json:"min_watches"`
+		Years      []DiscoveryYear `json:"years"`
+		Sort       string          `json:"sort"`
+		Notes      string          `json:"notes"`
+	}{
+		MinWatches: w.DiscoveryMin,
+		Years:      years,
+		Sort:       "watch_count desc, channel_name asc",
+		Notes:      "A channel is discovered in the year of its first watch anywhere in the export, even before -start or the window, so a channel first watched before the range is never listed. watch_count counts its watches in the range, and only channels with at least min_watches are listed; new_channels counts all the range's channels discovered that year.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "discoveries.json"), payload)
+}
@@ -0,0 +1,131 @@
+package output
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// Engagement is the summary.json section on the comments and live chat
+// messages given with -comments.
+type Engagement struct {
+	Comments         int                    `json:"comments"`
+	LiveChatMessages int                    `json:"live_chat_messages"`
+	Years            map[int]EngagementYear `json:"years"`
+	TopChannels      []CommentedChannel     `json:"top_channels"`
+	// UnknownChannel counts those on videos that are not in the watch
+	// history (or on posts), whose channel is unknown.
+	UnknownChannel int            `json:"unknown_channel"`
+	CommentLength  *CommentLength `json:"comment_length,omitempty"`
+	LiveChatLength *CommentLength `json:"live_chat_length,omitempty"`
+	Notes          string         `json:"notes"`
+}
+
+type EngagementYear struct {
+	Comments         int `json:"comments"`
+	LiveChatMessages int `json:"live_chat_messages"`
+}
+
+type CommentedChannel struct {
+	ChannelName      string `json:"channel_name"`
+	ChannelURL       string `json:"channel_url,omitempty"`
+	Comments         int    `json:"comments"`
+	LiveChatMessages int    `json:"live_chat_messages"`
+}
+
+// CommentLength describes text lengths in characters.
+type CommentLength struct {
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	Max    int     `json:"max"`
+}
+
+func commentLength(lengths []int) *CommentLength {
+	if len(lengths) == 0 {
+		return nil
+	}
+	l := &CommentLength{}
+	sum := 0
+	for _, n := range lengths {
+		sum += n
+		l.Max = max(l.Max, n)
+	}
+	l.Mean = math.Round(float64(sum)/float64(len(lengths))*10) / 10
+	l.Median = median(lengths)
+	return l
+}
+
+// engagement counts w.Comments in the window of agg, finding each one's
+// channel from the watch history by video ID.
+func (w *Writer) engagement(agg *aggregate.Aggregator) *Engagement {
+	opts := agg.Options()
+	channelOf := make(map[string]aggregate.ChannelKey)
+	for key := range agg.AllTimeVideoCounts {
+		info := agg.VideoInfo[key]
+		if id := parser.VideoIDFromURL(info.URL); id != "" {
+			channelOf[id] = info.Channel
+		}
+	}
+
+	e := &Engagement{Years: make(map[int]EngagementYear)}
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		e.Years[y] = EngagementYear{}
+	}
+	channels := make(map[aggregate.ChannelKey]*CommentedChannel)
+	var commentLengths, chatLengths []int
+	for _, c := range w.Comments {
+		t := c.Time.In(opts.Location)
+		if c.Time.IsZero() || !opts.InRange(t) {
+			continue
+		}
+		year := e.Years[t.Year()]
+		n := utf8.RuneCountInString(c.Text)
+		if c.LiveChat {
+			e.LiveChatMessages++
+			year.LiveChatMessages++
+			chatLengths = append(chatLengths, n)
+		} else {
+			e.Comments++
+			year.Comments++
+			commentLengths = append(commentLengths, n)
+		}
+		e.Years[t.Year()] = year
+
+		k, ok := channelOf[c.VideoID]
+		if !ok || k.Name == "(unknown channel)" {
+			e.UnknownChannel++
+			continue
+		}
+		ch, ok := channels[k]
+		if !ok {
+			ch = &CommentedChannel{ChannelName: k.Name, ChannelURL: k.URL}
+			channels[k] = ch
+		}
+		if c.LiveChat {
+			ch.LiveChatMessages++
+		} else {
+			ch.Comments++
+		}
+	}
+
+	top := make([]CommentedChannel, 0, len(channels))
+	for _, ch := range channels {
+		top = append(top, *ch)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		ni, nj := top[i].Comments+top[i].LiveChatMessages, top[j].Comments+top[j].LiveChatMessages
+		if ni != nj {
+			return ni > nj
+		}
+		return strings.ToLower(top[i].ChannelName) < strings.ToLower(top[j].ChannelName)
+	})
+	e.TopChannels = limitList(top, w.TopN)
+	e.CommentLength = commentLength(commentLengths)
+	e.LiveChatLength = commentLength(chatLengths)
+	e.Notes = "Comments and live chat messages written in the counted window. Their channel is that of the video in the watch history, so those on videos not watched in the window (and on posts) count in unknown_channel. top_channels is sorted by comments plus live chat messages desc, then name, and has the top -top channels; lengths are in characters."
+	return e
+}
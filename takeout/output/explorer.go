@@ -0,0 +1,321 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// explorerHelp lists the commands Explorer understands.
+const explorerHelp = `n/p next/previous year  y YEAR jump  a all time  j/k page down/up (Enter: down)
+s count|name|change sort  /TEXT filter names (/ clears)  NUMBER open channel  b back  q quit`
+
+// Explorer is a line-driven terminal browser over an Aggregator for the tui
+// command. It redraws a page of the selected year's channels after every
+// command read from its input, so it works on any terminal without a raw
+// mode, and commands can be piped in. It is written on the standard library
+// rather than bubbletea or tview, which would be the module's first
+// third-party dependencies and need a terminal in raw mode. The Aggregator
+// must not change while it is explored.
+type Explorer struct {
+	// Rows is how many channels a page shows.
+	Rows int
+	// Clear redraws each page from the top of the screen rather than
+	// appending it to the output.
+	Clear bool
+
+	agg     *aggregate.Aggregator
+	years   map[int][]ChannelStat
+	allTime []ChannelStat
+
+	year    int // 0 is all time
+	sortBy  string
+	filter  string
+	offset  int
+	channel *ChannelStat // the opened channel, or nil for the list
+	status  string
+}
+
+// NewExplorer precomputes the ranked channel lists of agg, like NewDashboard,
+// and starts on the last year with watches.
+func NewExplorer(agg *aggregate.Aggregator) *Explorer {
+	opts := agg.Options()
+	e := &Explorer{Rows: 20, agg: agg, years: make(map[int][]ChannelStat), year: opts.EndYear, sortBy: "count"}
+	var prevRanks map[aggregate.ChannelKey]int
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		stats := aggregate.StatsFromMap(agg.YearCounts[y])
+		aggregate.SortStatsByCountThenName(stats)
+		prevRanks = aggregate.AnnotateRankDeltas(stats, prevRanks)
+		e.years[y] = stats
+		if len(stats) > 0 {
+			e.year = y
+		}
+	}
+	e.allTime = aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(e.allTime)
+	return e
+}
+
+// Run draws the first page and then handles one command per line of in
+// until q or the end of in.
+func (e *Explorer) Run(in io.Reader, out io.Writer) error {
+	bw := bufio.NewWriter(out)
+	sc := bufio.NewScanner(in)
+	for {
+		e.draw(bw)
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if !sc.Scan() {
+			fmt.Fprintln(bw)
+			bw.Flush()
+			return sc.Err()
+		}
+		if !e.handle(strings.TrimSpace(sc.Text())) {
+			return bw.Flush()
+		}
+	}
+}
+
+// handle applies one command, reporting false when the explorer should quit.
+func (e *Explorer) handle(cmd string) bool {
+	opts := e.agg.Options()
+	e.status = ""
+	if e.channel != nil && (cmd == "b" || cmd == "") {
+		e.channel = nil
+		return true
+	}
+	switch {
+	case cmd == "q":
+		return false
+	case cmd == "" || cmd == "j":
+		if e.offset+e.Rows < len(e.list()) {
+			e.offset += e.Rows
+		}
+	case cmd == "k":
+		e.offset = max(e.offset-e.Rows, 0)
+	case cmd == "n":
+		if e.year == 0 {
+			e.status = "all time is the last view"
+		} else if e.year < opts.EndYear {
+			e.setYear(e.year + 1)
+		} else {
+			e.setYear(0)
+		}
+	case cmd == "p":
+		if e.year == 0 {
+			e.setYear(opts.EndYear)
+		} else if e.year > opts.StartYear {
+			e.setYear(e.year - 1)
+		} else {
+			e.status = fmt.Sprintf("%d is the first year", opts.StartYear)
+		}
+	case cmd == "a":
+		e.setYear(0)
+	case strings.HasPrefix(cmd, "y"):
+		y, err := strconv.Atoi(strings.TrimSpace(cmd[1:]))
+		if err != nil || y < opts.StartYear || y > opts.EndYear {
+			e.status = fmt.Sprintf("year must be %d..%d", opts.StartYear, opts.EndYear)
+			break
+		}
+		e.setYear(y)
+	case strings.HasPrefix(cmd, "s"):
+		switch by := strings.TrimSpace(cmd[1:]); by {
+		case "count", "name", "change":
+			e.sortBy = by
+			e.offset = 0
+		default:
+			e.status = "sort by count, name or change"
+		}
+	case strings.HasPrefix(cmd, "/"):
+		e.filter = strings.ToLower(strings.TrimSpace(cmd[1:]))
+		e.offset = 0
+	default:
+		n, err := strconv.Atoi(cmd)
+		list := e.list()
+		if err != nil {
+			e.status = fmt.Sprintf("unknown command %q", cmd)
+		} else if n < 1 || n > len(list) {
+			e.status = fmt.Sprintf("no channel %d", n)
+		} else {
+			s := list[n-1].ChannelStat
+			e.channel = &s
+		}
+	}
+	return true
+}
+
+func (e *Explorer) setYear(y int) {
+	e.year = y
+	e.offset = 0
+	e.channel = nil
+}
+
+// rankedChannel is a channel in a list with its watch-count rank.
+type rankedChannel struct {
+	ChannelStat
+	rank int
+}
+
+// list is the selected year's channels matching the filter, in the selected
+// order.
+func (e *Explorer) list() []rankedChannel {
+	stats := e.allTime
+	if e.year != 0 {
+		stats = e.years[e.year]
+	}
+	var rows []rankedChannel
+	for i, s := range stats {
+		if e.filter == "" || strings.Contains(strings.ToLower(s.ChannelName), e.filter) {
+			rows = append(rows, rankedChannel{s, i + 1})
+		}
+	}
+	sortRankedChannels(rows, e.sortBy)
+	return rows
+}
+
+// sortRankedChannels reorders rows, ranked by count, by "name" or by
+// "change" in rank; any other order leaves them by count.
+func sortRankedChannels(rows []rankedChannel, by string) {
+	switch by {
+	case "name":
+		sort.SliceStable(rows, func(i, j int) bool {
+			return strings.ToLower(rows[i].ChannelName) < strings.ToLower(rows[j].ChannelName)
+		})
+	case "change":
+		sort.SliceStable(rows, func(i, j int) bool { return rankChange(rows[i].RankDelta) > rankChange(rows[j].RankDelta) })
+	}
+}
+
+// rankChange orders rank deltas for sorting, with new channels first and
+// all-time entries (no delta) last.
+func rankChange(delta any) int {
+	switch d := delta.(type) {
+	case int:
+		return d
+	case string:
+		return 1 << 30
+	}
+	return -1 << 30
+}
+
+func formatRankDelta(delta any) string {
+	switch d := delta.(type) {
+	case int:
+		if d > 0 {
+			return fmt.Sprintf("+%d", d)
+		} else if d < 0 {
+			return strconv.Itoa(d)
+		}
+		return "="
+	case string:
+		return d
+	}
+	return ""
+}
+
+func (e *Explorer) yearLabel() string {
+	if e.year == 0 {
+		return "all time"
+	}
+	return strconv.Itoa(e.year)
+}
+
+func (e *Explorer) draw(w io.Writer) {
+	if e.Clear {
+		fmt.Fprint(w, "\x1b[H\x1b[2J")
+	} else {
+		fmt.Fprintln(w)
+	}
+	if e.channel != nil {
+		e.drawChannel(w)
+	} else {
+		e.drawList(w)
+	}
+	fmt.Fprintln(w)
+	if e.status != "" {
+		fmt.Fprintln(w, e.status)
+	}
+	fmt.Fprintln(w, explorerHelp)
+	fmt.Fprint(w, "> ")
+}
+
+func (e *Explorer) drawList(w io.Writer) {
+	total, unique := e.agg.TotalAllYears, len(e.agg.AllTimeCounts)
+	if e.year != 0 {
+		total, unique = e.agg.YearTotals[e.year], len(e.agg.YearCounts[e.year])
+	}
+	fmt.Fprintf(w, "%s: %d watches, %d channels (sorted by %s", e.yearLabel(), total, unique, e.sortBy)
+	if e.filter != "" {
+		fmt.Fprintf(w, ", names containing %q", e.filter)
+	}
+	fmt.Fprintln(w, ")")
+	fmt.Fprintln(w)
+
+	rows := e.list()
+	if len(rows) == 0 {
+		fmt.Fprintln(w, "  no channels")
+		return
+	}
+	end := min(e.offset+e.Rows, len(rows))
+	fmt.Fprintf(w, "%5s %5s %8s %6s  %s\n", "#", "rank", "watches", "change", "channel")
+	for i := e.offset; i < end; i++ {
+		r := rows[i]
+		fmt.Fprintf(w, "%5d %5d %8d %6s  %s\n", i+1, r.rank, r.WatchCount, formatRankDelta(r.RankDelta), r.ChannelName)
+	}
+	fmt.Fprintf(w, "\nshowing %d-%d of %d\n", e.offset+1, end, len(rows))
+}
+
+func (e *Explorer) drawChannel(w io.Writer) {
+	opts := e.agg.Options()
+	k := e.channel.Key()
+	fmt.Fprintln(w, k.Name)
+	if k.URL != "" {
+		fmt.Fprintln(w, k.URL)
+	}
+	if sp, ok := e.agg.ChannelSpans[k]; ok {
+		fmt.Fprintf(w, "first watched %s, last watched %s\n", sp.First.Format(time.DateOnly), sp.Last.Format(time.DateOnly))
+	}
+	fmt.Fprintf(w, "%d watches in all\n\n", e.agg.AllTimeCounts[k])
+
+	most := 0
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		most = max(most, e.agg.YearCounts[y][k])
+	}
+	fmt.Fprintf(w, "%4s %8s %5s\n", "year", "watches", "rank")
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		n := e.agg.YearCounts[y][k]
+		rank := "-"
+		for i, s := range e.years[y] {
+			if s.Key() == k {
+				rank = strconv.Itoa(i + 1)
+				break
+			}
+		}
+		bar := ""
+		if most > 0 {
+			bar = strings.Repeat("#", (n*40+most-1)/most)
+		}
+		fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("%4d %8d %5s  %s", y, n, rank, bar), " "))
+	}
+
+	counts := make(map[string]int)
+	for url, n := range e.agg.AllTimeVideoCounts {
+		if e.agg.VideoInfo[url].Channel == k {
+			counts[url] = n
+		}
+	}
+	videos := limitList(aggregate.VideoStatsFromMap(counts, e.agg.VideoInfo), 10)
+	if len(videos) > 0 {
+		fmt.Fprintln(w, "\ntop videos:")
+		for _, v := range videos {
+			fmt.Fprintf(w, "%8d  %s\n", v.WatchCount, v.VideoTitle)
+		}
+	}
+}
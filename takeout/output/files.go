@@ -0,0 +1,238 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// tempFiles tracks the .tmp files currently being written so they can be
+// removed if the run is interrupted.
+var tempFiles = struct {
+	sync.Mutex
+	paths map[string]struct{}
+}{paths: make(map[string]struct{})}
+
+func trackTemp(path string) {
+	tempFiles.Lock()
+	tempFiles.paths[path] = struct{}{}
+	tempFiles.Unlock()
+}
+
+func untrackTemp(path string) {
+	tempFiles.Lock()
+	delete(tempFiles.paths, path)
+	tempFiles.Unlock()
+}
+
+// InstallInterruptCleanup removes in-flight .tmp files and exits nonzero on
+// SIGINT/SIGTERM.
+func InstallInterruptCleanup() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		CleanupAndExit(1, fmt.Sprint("interrupted: ", sig))
+	}()
+}
+
+// CleanupAndExit removes in-flight .tmp files, prints msg to stderr and
+// exits with code, for stopping a run from outside the goroutine writing the
+// outputs. The lock is held until exit so no new temp files appear.
+func CleanupAndExit(code int, msg string) {
+	tempFiles.Lock()
+	for p := range tempFiles.paths {
+		_ = os.Remove(p)
+	}
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(code)
+}
+
+// CombineJSON writes the .json files in dir to out as one indented JSON
+// object, each under its file name without .json.
+func CombineJSON(out io.Writer, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	doc := make(map[string]json.RawMessage)
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return err
+		}
+		doc[name] = b
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// WriteJSON writes v as indented JSON to path, via a .tmp file renamed into
+// place so readers never see a partial file.
+func WriteJSON(path string, v any) error {
+	b, err := encodeJSON(v)
+	if err != nil {
+		return err
+	}
+	return writeEncoded(path, b)
+}
+
+// writeEncoded writes the already encoded contents b to path atomically.
+func writeEncoded(path string, b []byte) error {
+	return writeAtomic(path, func(f io.Writer) error {
+		_, err := f.Write(b)
+		return err
+	})
+}
+
+// encodeJSON encodes v as WriteJSON writes it.
+func encodeJSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeAtomic creates path's directory and writes path with write, via a
+// .tmp file renamed into place so readers never see a partial file.
+func writeAtomic(path string, write func(io.Writer) error) error {
+	tmp := path + ".tmp"
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	trackTemp(tmp)
+	defer untrackTemp(tmp)
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// fileWriter writes output files on up to a fixed number of goroutines at
+// once, which speeds up writing many small files to slow or network drives.
+// A nil fileWriter writes every file at once on the calling goroutine.
+type fileWriter struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	errs  []error
+}
+
+// newFileWriter returns a fileWriter for workers goroutines, or nil for one.
+func newFileWriter(workers int) *fileWriter {
+	if workers <= 1 {
+		return nil
+	}
+	return &fileWriter{slots: make(chan struct{}, workers)}
+}
+
+// do runs write on a goroutine of its own, waiting for a free slot first.
+// Its error is kept for wait; write must only use data that no longer
+// changes, such as already encoded contents. On a nil fileWriter do returns
+// write's error.
+func (fw *fileWriter) do(write func() error) error {
+	if fw == nil {
+		return write()
+	}
+	fw.slots <- struct{}{}
+	fw.wg.Add(1)
+	go func() {
+		defer func() {
+			<-fw.slots
+			fw.wg.Done()
+		}()
+		if err := write(); err != nil {
+			fw.mu.Lock()
+			fw.errs = append(fw.errs, err)
+			fw.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// wait waits for every write do started and returns their errors joined.
+func (fw *fileWriter) wait() error {
+	if fw == nil {
+		return nil
+	}
+	fw.wg.Wait()
+	// The writes finish in any order; sort their errors for a stable message.
+	sort.Slice(fw.errs, func(i, j int) bool { return fw.errs[i].Error() < fw.errs[j].Error() })
+	return errors.Join(fw.errs...)
+}
+
+// LatestLink is the symlink in a -out-versioned directory that points to the
+// newest run.
+const LatestLink = "latest"
+
+// NewVersionedDir creates and returns a new subdirectory of base named after
+// t, like base/2025-01-07T18-30, adding -2, -3 and so on for runs within the
+// same minute.
+func NewVersionedDir(base string, t time.Time) (string, error) {
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		return "", err
+	}
+	name := t.Format("2006-01-02T15-04")
+	for i := 1; ; i++ {
+		dir := filepath.Join(base, name)
+		if i > 1 {
+			dir += fmt.Sprintf("-%d", i)
+		}
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			return dir, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+	}
+}
+
+// LinkLatest points base/latest at dir, a subdirectory of base, replacing
+// the previous link in one rename so it never dangles.
+func LinkLatest(base, dir string) error {
+	link := filepath.Join(base, LatestLink)
+	tmp := link + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(dir), tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package output
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// Gap is a run of consecutive days without a watch between two days with
+// one; Start and End are its first and last empty day.
+type Gap struct {
+	Days  int    `json:"days"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+type GapYear struct {
+	Year int `json:"year"`
+	// Gaps counts the breaks of at least -gap-days starting in the year,
+	// and DaysInGaps their days.
+	Gaps       int   `json:"gaps"`
+	DaysInGaps int   `json:"days_in_gaps"`
+	Longest    []Gap `json:"longest_gaps"`
+}
+
+// writeGaps writes gaps.json: every break of w.GapDays or more days without
+// a watch, and the longest of each year.
+func (w *Writer) writeGaps(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	days := make([]time.Time, 0, len(agg.DayCounts))
+	for day := range agg.DayCounts {
+		if d, err := time.Parse(time.DateOnly, day); err == nil {
+			days = append(days, d)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	var all []Gap
+	byYear := make(map[int][]Gap)
+	for i := 1; i < len(days); i++ {
+		n := int(days[i].Sub(days[i-1]).Hours()/24) - 1
+		if n < w.GapDays {
+			continue
+		}
+		start := days[i-1].AddDate(0, 0, 1)
+		g := Gap{Days: n, Start: start.Format(time.DateOnly), End: days[i].AddDate(0, 0, -1).Format(time.DateOnly)}
+		all = append(all, g)
+		byYear[start.Year()] = append(byYear[start.Year()], g)
+	}
+	if all == nil {
+		all = []Gap{}
+	}
+
+	years := make([]GapYear, 0, opts.EndYear-opts.StartYear+1)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		gy := GapYear{Year: y, Gaps: len(byYear[y])}
+		longest := append([]Gap{}, byYear[y]...)
+		for _, g := range longest {
+			gy.DaysInGaps += g.Days
+		}
+		// Stable, so ties keep the earlier gap first.
+		sort.SliceStable(longest, func(i, j int) bool { return longest[i].Days > longest[j].Days })
+		gy.Longest = limitList(longest, w.TopN)
+		years = append(years, gy)
+	}
+
+	var longest Gap
+	for _, g := range all {
+		if g.Days > longest.Days {
+			longest = g
+		}
+	}
+
+	payload := struct {
+		MinDays    int       `json:"min_gap_days"`
+		TotalGaps  int       `json:"total_gaps"`
+		LongestGap *Gap      `json:"longest_gap,omitempty"`
+		Years      []GapYear `json:"years"`
+		Gaps       []Gap     `json:"gaps"`
+		TopN       int       `json:"top_n"`
+		Sort       string    `json:"sort"`
+		Notes      string    `json:"notes"`
+	}{
+		MinDays:   w.GapDays,
+		TotalGaps: len(all),
+		Years:     years,
+		Gaps:      all,
+		TopN:      w.TopN,
+		Sort:      "gaps by start asc; longest_gaps by days desc, then start asc",
+		Notes:     "Days are calendar days in the " + opts.Location.String() + " time zone. A gap is a run of days without a counted watch between two days with one, so the time before the first and after the last watch in the export is never a gap. Gaps belong to the year they start in; longest_gaps has the top -top of them.",
+	}
+	if longest.Days > 0 {
+		payload.LongestGap = &longest
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "gaps.json"), payload)
+}
@@ -0,0 +1,132 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// Streak is a run of consecutive days with at least one watch.
+type Streak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type HabitsResult struct {
+	Year               int     `json:"year"`
+	TotalVideos        int     `json:"total_videos_watched"`
+	DaysCovered        int     `json:"days_covered"`
+	ActiveDays         int     `json:"active_days"`
+	ZeroWatchDays      int     `json:"zero_watch_days"`
+	MedianPerActiveDay float64 `json:"median_videos_per_active_day"`
+	LongestStreak      Streak  `json:"longest_streak"`
+	LongestStreakAll   Streak  `json:"longest_streak_all_time"`
+	CurrentStreak      Streak  `json:"current_streak"`
+	LastWatchDay       string  `json:"last_watch_day,omitempty"`
+	Notes              string  `json:"notes"`
+}
+
+// longestStreak returns the longest run of consecutive days in days, which
+// must be sorted; ties go to the earliest run.
+func longestStreak(days []time.Time) Streak {
+	var best, cur Streak
+	for i, d := range days {
+		if i > 0 && d.Equal(days[i-1].AddDate(0, 0, 1)) {
+			cur.Days++
+		} else {
+			cur = Streak{Days: 1, Start: d.Format(time.DateOnly)}
+		}
+		cur.End = d.Format(time.DateOnly)
+		if cur.Days > best.Days {
+			best = cur
+		}
+	}
+	return best
+}
+
+// median returns the median of counts, which it sorts.
+func median(counts []int) float64 {
+	if len(counts) == 0 {
+		return 0
+	}
+	sort.Ints(counts)
+	m := len(counts) / 2
+	if len(counts)%2 == 1 {
+		return float64(counts[m])
+	}
+	return float64(counts[m-1]+counts[m]) / 2
+}
+
+// writeHabits writes habits_<YEAR>.json for every year in range.
+func (w *Writer) writeHabits(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	days := make([]time.Time, 0, len(agg.DayCounts))
+	for day := range agg.DayCounts {
+		if d, err := time.Parse(time.DateOnly, day); err == nil {
+			days = append(days, d)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	longestAll := longestStreak(days)
+	var current Streak
+	var first, last time.Time
+	if len(days) > 0 {
+		first, last = days[0], days[len(days)-1]
+		i := len(days) - 1
+		for i > 0 && days[i-1].Equal(days[i].AddDate(0, 0, -1)) {
+			i--
+		}
+		current = longestStreak(days[i:])
+	}
+
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		res := HabitsResult{
+			Year:             y,
+			TotalVideos:      agg.YearTotals[y],
+			LongestStreakAll: longestAll,
+			CurrentStreak:    current,
+			Notes:            "Days are calendar days in the " + opts.Location.String() + " time zone. days_covered counts the days of the year between the first and the last watch in the export, and zero_watch_days those of them without a watch. current_streak is the run of days ending on last_watch_day, the latest watch in the export, which stands in for the export date. longest_streak only counts the year's own days, so a streak over New Year is split between the two years.",
+		}
+		if len(days) > 0 {
+			res.LastWatchDay = last.Format(time.DateOnly)
+		}
+
+		var yearDays []time.Time
+		var counts []int
+		for _, d := range days {
+			if d.Year() == y {
+				yearDays = append(yearDays, d)
+				counts = append(counts, agg.DayCounts[d.Format(time.DateOnly)])
+			}
+		}
+		res.ActiveDays = len(yearDays)
+		res.MedianPerActiveDay = median(counts)
+		res.LongestStreak = longestStreak(yearDays)
+
+		from := time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(y, 12, 31, 0, 0, 0, 0, time.UTC)
+		if len(days) > 0 {
+			if first.After(from) {
+				from = first
+			}
+			if last.Before(to) {
+				to = last
+			}
+			if !to.Before(from) {
+				res.DaysCovered = int(to.Sub(from).Hours()/24) + 1
+			}
+		}
+		res.ZeroWatchDays = res.DaysCovered - res.ActiveDays
+
+		if err := w.writeJSON(filepath.Join(w.Dir, fmt.Sprintf("habits_%d.json", y)), res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
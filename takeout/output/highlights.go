@@ -0,0 +1,150 @@
+package output
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// HighlightChannel is a channel picked out by highlights.json.
+type HighlightChannel struct {
+	ChannelName string `json:"channel_name"`
+	ChannelURL  string `json:"channel_url,omitempty"`
+	WatchCount  int    `json:"watch_count"`
+	// Rank and PreviousRank are the channel's rank in the year and the one
+	// before, for the biggest climber.
+	Rank         int `json:"rank,omitempty"`
+	PreviousRank int `json:"previous_rank,omitempty"`
+	// FirstWatched is when a new channel was first watched.
+	FirstWatched string `json:"first_watched,omitempty"`
+}
+
+// HighlightsYear is a year's headline facts, each left out when the year has
+// nothing to report for it. Headlines puts them into sentences.
+type HighlightsYear struct {
+	Year        int `json:"year"`
+	TotalVideos int `json:"total_videos_watched"`
+	// ChangePercent compares TotalVideos with the year before, when that
+	// year has watches.
+	ChangePercent     *float64          `json:"change_percent,omitempty"`
+	TopChannel        *HighlightChannel `json:"top_channel,omitempty"`
+	BiggestNewChannel *HighlightChannel `json:"biggest_new_channel,omitempty"`
+	BiggestClimber    *HighlightChannel `json:"biggest_climber,omitempty"`
+	MostRewatched     *VideoStat        `json:"most_rewatched_video,omitempty"`
+	BusiestDay        *DayCount         `json:"busiest_day,omitempty"`
+	LongestBinge      *SessionStat      `json:"longest_binge,omitempty"`
+	Headlines         []string          `json:"headlines"`
+}
+
+// writeHighlights writes highlights.json: per year, its top, biggest new and
+// fastest climbing channel, most rewatched video, busiest day, longest binge
+// and change from the year before, with a sentence for each.
+func (w *Writer) writeHighlights(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	sessions := agg.Sessions()
+
+	// firstWatched holds when each channel was first watched anywhere in
+	// the export, as in discoveries.json.
+	firstWatched := make(map[aggregate.ChannelKey]time.Time, len(agg.HistorySpans))
+	for k, sp := range agg.HistorySpans {
+		firstWatched[k] = sp.First.In(opts.Location)
+	}
+
+	years := make([]HighlightsYear, 0, opts.EndYear-opts.StartYear+1)
+	prevRanks := channelRanks(agg.YearCounts[opts.StartYear-1])
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		h := HighlightsYear{Year: y, TotalVideos: agg.YearTotals[y], Headlines: []string{}}
+		if prev := agg.YearTotals[y-1]; prev > 0 {
+			pct := math.Round(float64(h.TotalVideos-prev)/float64(prev)*1000) / 10
+			h.ChangePercent = &pct
+		}
+
+		stats := aggregate.StatsFromMap(agg.YearCounts[y])
+		aggregate.SortStatsByCountThenName(stats)
+		ranks := make(map[aggregate.ChannelKey]int, len(stats))
+		for i, st := range stats {
+			k := st.Key()
+			ranks[k] = i + 1
+			if k.Name == "(unknown channel)" {
+				continue
+			}
+			if h.TopChannel == nil {
+				h.TopChannel = &HighlightChannel{ChannelName: k.Name, ChannelURL: k.URL, WatchCount: st.WatchCount, Rank: i + 1}
+			}
+			if first, ok := firstWatched[k]; ok && first.Year() == y && h.BiggestNewChannel == nil {
+				h.BiggestNewChannel = &HighlightChannel{ChannelName: k.Name, ChannelURL: k.URL, WatchCount: st.WatchCount, FirstWatched: first.Format(time.RFC3339)}
+			}
+			// Only climbs into the year's top channels count, so a move
+			// far down the list is never the headline.
+			prev, ok := prevRanks[k]
+			if !ok || i >= max(w.TopN, 1) || prev <= i+1 {
+				continue
+			}
+			if h.BiggestClimber == nil || prev-(i+1) > h.BiggestClimber.PreviousRank-h.BiggestClimber.Rank {
+				h.BiggestClimber = &HighlightChannel{ChannelName: k.Name, ChannelURL: k.URL, WatchCount: st.WatchCount, Rank: i + 1, PreviousRank: prev}
+			}
+		}
+		prevRanks = ranks
+
+		if videos := aggregate.VideoStatsFromMap(agg.YearVideoCounts[y], agg.VideoInfo); len(videos) > 0 && videos[0].WatchCount > 1 {
+			h.MostRewatched = &videos[0]
+		}
+		h.BusiestDay = busiestDay(y, agg)
+		if s := longestSession(sessions[y]); s.Videos > 1 {
+			h.LongestBinge = newSessionStat(s)
+		}
+		h.Headlines = highlightHeadlines(h)
+		years = append(years, h)
+	}
+
+	payload := struct {
+		Years []HighlightsYear `json:"years"`
+		Notes string           `json:"notes"`
+	}{
+		Years: years,
+		Notes: "Days are in the " + opts.Location.String() + " time zone. biggest_new_channel is the year's most watched channel first watched that year anywhere in the export (see discoveries.json); biggest_climber the channel that moved up the most ranks into the year's -top channels; most_rewatched_video the video watched most often that year, if more than once; longest_binge the session with the most videos, if more than one (see sessions_<YEAR>.json, off with -session-gap 0). \"(unknown channel)\" is never a highlight.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "highlights.json"), payload)
+}
+
+// highlightHeadlines puts a year's highlights into sentences.
+func highlightHeadlines(h HighlightsYear) []string {
+	lines := []string{}
+	switch {
+	case h.ChangePercent == nil:
+		lines = append(lines, fmt.Sprintf("You watched %s in %d.", videoCount(h.TotalVideos), h.Year))
+	case *h.ChangePercent >= 0:
+		lines = append(lines, fmt.Sprintf("You watched %s in %d, up %.1f%% from %d.", videoCount(h.TotalVideos), h.Year, *h.ChangePercent, h.Year-1))
+	default:
+		lines = append(lines, fmt.Sprintf("You watched %s in %d, down %.1f%% from %d.", videoCount(h.TotalVideos), h.Year, -*h.ChangePercent, h.Year-1))
+	}
+	if c := h.TopChannel; c != nil {
+		lines = append(lines, fmt.Sprintf("Your top channel was %s, with %s.", c.ChannelName, videoCount(c.WatchCount)))
+	}
+	if c := h.BiggestNewChannel; c != nil {
+		lines = append(lines, fmt.Sprintf("Your biggest discovery was %s: %s after your first one on %s.", c.ChannelName, videoCount(c.WatchCount), c.FirstWatched[:len(time.DateOnly)]))
+	}
+	if c := h.BiggestClimber; c != nil {
+		lines = append(lines, fmt.Sprintf("%s climbed from #%d to #%d.", c.ChannelName, c.PreviousRank, c.Rank))
+	}
+	if v := h.MostRewatched; v != nil {
+		lines = append(lines, fmt.Sprintf("You watched %q %d times.", v.VideoTitle, v.WatchCount))
+	}
+	if d := h.BusiestDay; d != nil {
+		lines = append(lines, fmt.Sprintf("Your busiest day was %s, with %s.", d.Date, videoCount(d.Count)))
+	}
+	if b := h.LongestBinge; b != nil {
+		lines = append(lines, fmt.Sprintf("Your longest binge was %d videos in %.0f minutes, starting %s.", b.Videos, b.DurationMinutes, b.Start[:len(time.DateOnly)]))
+	}
+	return lines
+}
+
+func videoCount(n int) string {
+	if n == 1 {
+		return "1 video"
+	}
+	return fmt.Sprintf("%d videos", n)
+}
@@ -0,0 +1,100 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// icalTopChannels is how many of a day's channels its event describes.
+const icalTopChannels = 3
+
+// writeICal writes heavy_days.ics: an all-day event for every day with more
+// than w.ICalThreshold watches, with the day's count and top channels, to
+// overlay on a calendar. It needs agg's DayChannelCounts.
+func (w *Writer) writeICal(agg *aggregate.Aggregator) error {
+	days := make([]string, 0, len(agg.DayCounts))
+	for day, n := range agg.DayCounts {
+		if n > w.ICalThreshold {
+			days = append(days, day)
+		}
+	}
+	sort.Strings(days)
+
+	// DTSTAMP is required; the last watch in the export keeps the file the
+	// same across runs over the same export.
+	stamp := agg.LatestTime.UTC().Format("20060102T150405Z")
+	var b strings.Builder
+	line := func(s string) { icalLine(&b, s) }
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//learning-go//takeout//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:YouTube heavy watch days")
+	for _, day := range days {
+		d, err := time.Parse(time.DateOnly, day)
+		if err != nil {
+			continue
+		}
+		n := agg.DayCounts[day]
+		stats := aggregate.StatsFromMap(agg.DayChannelCounts[day])
+		aggregate.SortStatsByCountThenName(stats)
+		summary := "YouTube: " + videoCount(n)
+		desc := videoCount(n) + " watched."
+		if len(stats) > 0 {
+			summary += " (top: " + stats[0].ChannelName + ")"
+			desc += "\nTop channels:"
+			for _, st := range limitList(stats, icalTopChannels) {
+				desc += fmt.Sprintf("\n%s: %d", st.ChannelName, st.WatchCount)
+			}
+		}
+		line("BEGIN:VEVENT")
+		line("UID:" + day + "@takeout.youtube-history")
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + d.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + d.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + icalText(summary))
+		line("DESCRIPTION:" + icalText(desc))
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	path := filepath.Join(w.Dir, "heavy_days.ics")
+	tmp := path + ".tmp"
+	trackTemp(tmp)
+	defer untrackTemp(tmp)
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// icalText escapes s for a TEXT property value (RFC 5545 section 3.3.11).
+func icalText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// icalLine writes s as a content line ending in CRLF, folded so no line is
+// longer than 75 bytes and without splitting a UTF-8 character.
+func icalLine(b *strings.Builder, s string) {
+	n := 75
+	for len(s) > n {
+		cut := n
+		for s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ") // a continuation line starts with a space
+		s = s[cut:]
+		n = 74
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,110 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+const (
+	// keywordsTop is how many keywords and bigrams keywords_<YEAR>.json
+	// lists for the year, and keywordsChannelTop for each channel.
+	keywordsTop        = 50
+	keywordsChannelTop = 10
+)
+
+// ChannelKeywords are the top title keywords of one channel's videos.
+type ChannelKeywords struct {
+	ChannelName string                `json:"channel_name"`
+	ChannelURL  string                `json:"channel_url,omitempty"`
+	WatchCount  int                   `json:"watch_count"`
+	Keywords    []aggregate.TermCount `json:"keywords"`
+	Bigrams     []aggregate.TermCount `json:"bigrams"`
+}
+
+type KeywordsResult struct {
+	Year           int                   `json:"year"`
+	TotalVideos    int                   `json:"total_videos_watched"`
+	TitledWatches  int                   `json:"titled_watches"`
+	UniqueKeywords int                   `json:"unique_keywords"`
+	Keywords       []aggregate.TermCount `json:"keywords"`
+	Bigrams        []aggregate.TermCount `json:"bigrams"`
+	Channels       []ChannelKeywords     `json:"channels,omitempty"`
+	Sort           string                `json:"sort"`
+	Notes          string                `json:"notes"`
+}
+
+// writeKeywords writes keywords_<YEAR>.json for every year in range. Each
+// watch counts its video's title keywords once, so a keyword's count is how
+// many watches had it in the title.
+func (w *Writer) writeKeywords(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		res := KeywordsResult{
+			Year:        y,
+			TotalVideos: agg.YearTotals[y],
+			Sort:        "count desc, term asc",
+			Notes:       "Keywords are the lowercased words of watched video titles without stop words and single characters; bigrams are two keywords next to each other in a title. A title counts each keyword once per watch. Removed videos and videos listed without a title are left out.",
+		}
+		var channels map[aggregate.ChannelKey]bool
+		if w.KeywordsByChannel {
+			top := aggregate.StatsFromMap(agg.YearCounts[y])
+			aggregate.SortStatsByCountThenName(top)
+			top = limitList(top, w.TopN)
+			channels = make(map[aggregate.ChannelKey]bool, len(top))
+			for _, s := range top {
+				channels[aggregate.ChannelKey{Name: s.ChannelName, URL: s.ChannelURL}] = true
+			}
+			res.Channels = make([]ChannelKeywords, 0, len(top))
+			for _, s := range top {
+				res.Channels = append(res.Channels, ChannelKeywords{ChannelName: s.ChannelName, ChannelURL: s.ChannelURL, WatchCount: s.WatchCount})
+			}
+		}
+
+		words, bigrams := make(map[string]int), make(map[string]int)
+		chWords := make(map[aggregate.ChannelKey]map[string]int)
+		chBigrams := make(map[aggregate.ChannelKey]map[string]int)
+		for vk, c := range agg.YearVideoCounts[y] {
+			vi := agg.VideoInfo[vk]
+			if parser.IsUntitledVideo(vi.Title) || parser.IsRemovedVideoTitle(vi.Title) {
+				continue
+			}
+			res.TitledWatches += c
+			ws, bs := aggregate.TitleTerms(vi.Title)
+			for _, t := range ws {
+				words[t] += c
+			}
+			for _, t := range bs {
+				bigrams[t] += c
+			}
+			if !channels[vi.Channel] {
+				continue
+			}
+			if chWords[vi.Channel] == nil {
+				chWords[vi.Channel] = make(map[string]int)
+				chBigrams[vi.Channel] = make(map[string]int)
+			}
+			for _, t := range ws {
+				chWords[vi.Channel][t] += c
+			}
+			for _, t := range bs {
+				chBigrams[vi.Channel][t] += c
+			}
+		}
+		res.UniqueKeywords = len(words)
+		res.Keywords = limitList(aggregate.TermCounts(words), keywordsTop)
+		res.Bigrams = limitList(aggregate.TermCounts(bigrams), keywordsTop)
+		for i := range res.Channels {
+			k := aggregate.ChannelKey{Name: res.Channels[i].ChannelName, URL: res.Channels[i].ChannelURL}
+			res.Channels[i].Keywords = limitList(aggregate.TermCounts(chWords[k]), keywordsChannelTop)
+			res.Channels[i].Bigrams = limitList(aggregate.TermCounts(chBigrams[k]), keywordsChannelTop)
+		}
+
+		if err := w.writeJSON(filepath.Join(w.Dir, fmt.Sprintf("keywords_%d.json", y)), res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
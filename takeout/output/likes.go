@@ -0,0 +1,140 @@
+package output
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// likesMinWatched is how many videos of a channel must have been watched
+// for it to be ranked by like rate, so one liked video out of one watched
+// does not top the ranking.
+const likesMinWatched = 5
+
+type ChannelLikes struct {
+	ChannelName   string `json:"channel_name"`
+	ChannelURL    string `json:"channel_url,omitempty"`
+	WatchedVideos int    `json:"watched_videos"`
+	LikedVideos   int    `json:"liked_videos"`
+	// LikedWatched is how many of the watched videos were liked, and
+	// LikeRate their share of WatchedVideos.
+	LikedWatched int     `json:"liked_watched_videos"`
+	LikeRate     float64 `json:"like_rate"`
+}
+
+// writeLikes writes likes.json: liked videos joined to the watch history on
+// video ID, per channel.
+func (w *Writer) writeLikes(agg *aggregate.Aggregator) error {
+	// Watched videos by ID, with the channel of their most recent watch.
+	watched := make(map[string]aggregate.ChannelKey)
+	for key := range agg.AllTimeVideoCounts {
+		info := agg.VideoInfo[key]
+		if id := parser.VideoIDFromURL(info.URL); id != "" {
+			watched[id] = info.Channel
+		}
+	}
+
+	channels := make(map[aggregate.ChannelKey]*ChannelLikes)
+	get := func(k aggregate.ChannelKey) *ChannelLikes {
+		c, ok := channels[k]
+		if !ok {
+			c = &ChannelLikes{ChannelName: k.Name, ChannelURL: k.URL}
+			channels[k] = c
+		}
+		return c
+	}
+	for _, k := range watched {
+		get(k).WatchedVideos++
+	}
+
+	liked := make(map[string]bool, len(w.Likes))
+	var likedWatched, unattributed int
+	for _, l := range w.Likes {
+		if liked[l.VideoID] {
+			continue
+		}
+		liked[l.VideoID] = true
+		if k, ok := watched[l.VideoID]; ok {
+			c := get(k)
+			c.LikedVideos++
+			c.LikedWatched++
+			likedWatched++
+		} else if l.ChannelName != "" || l.ChannelURL != "" {
+			get(agg.CanonicalChannel(aggregate.ChannelKey{Name: l.ChannelName, URL: l.ChannelURL})).LikedVideos++
+		} else {
+			unattributed++
+		}
+	}
+
+	var all, ranked []ChannelLikes
+	for k, c := range channels {
+		if k.Name == "(unknown channel)" {
+			continue
+		}
+		if c.WatchedVideos > 0 {
+			c.LikeRate = math.Round(float64(c.LikedWatched)/float64(c.WatchedVideos)*1000) / 1000
+		}
+		if c.LikedVideos > 0 {
+			all = append(all, *c)
+		}
+		if c.WatchedVideos >= likesMinWatched {
+			ranked = append(ranked, *c)
+		}
+	}
+	byName := func(a, b ChannelLikes) bool {
+		return strings.ToLower(a.ChannelName) < strings.ToLower(b.ChannelName)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].LikedVideos != all[j].LikedVideos {
+			return all[i].LikedVideos > all[j].LikedVideos
+		}
+		if all[i].WatchedVideos != all[j].WatchedVideos {
+			return all[i].WatchedVideos > all[j].WatchedVideos
+		}
+		return byName(all[i], all[j])
+	})
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].LikeRate != ranked[j].LikeRate {
+			return ranked[i].LikeRate > ranked[j].LikeRate
+		}
+		if ranked[i].LikedWatched != ranked[j].LikedWatched {
+			return ranked[i].LikedWatched > ranked[j].LikedWatched
+		}
+		return byName(ranked[i], ranked[j])
+	})
+	if all == nil {
+		all = []ChannelLikes{}
+	}
+	if ranked == nil {
+		ranked = []ChannelLikes{}
+	}
+
+	payload := struct {
+		Likes           int            `json:"liked_videos"`
+		LikedWatched    int            `json:"liked_watched_videos"`
+		LikedNotWatched int            `json:"liked_not_watched_videos"`
+		Unattributed    int            `json:"liked_unknown_channel"`
+		Channels        []ChannelLikes `json:"channels"`
+		LikeRateRanking []ChannelLikes `json:"like_rate_ranking"`
+		MinWatched      int            `json:"like_rate_min_watched_videos"`
+		TopN            int            `json:"top_n"`
+		Sort            string         `json:"sort"`
+		Notes           string         `json:"notes"`
+	}{
+		Likes:           len(liked),
+		LikedWatched:    likedWatched,
+		LikedNotWatched: len(liked) - likedWatched,
+		Unattributed:    unattributed,
+		Channels:        limitList(all, w.AllTimeTop),
+		LikeRateRanking: limitList(ranked, w.AllTimeTop),
+		MinWatched:      likesMinWatched,
+		TopN:            w.AllTimeTop,
+		Sort:            "channels by liked_videos desc, watched_videos desc, channel_name asc; like_rate_ranking by like_rate desc, liked_watched_videos desc, channel_name asc",
+		Notes:           "Likes are joined to the counted watches in the -start..-end range by video ID. watched_videos counts distinct videos, and like_rate is liked_watched_videos / watched_videos. A liked video that was not watched is attributed to its channel only when the likes come from My Activity, which names it; the Liked videos playlist does not, so those count in liked_unknown_channel.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "likes.json"), payload)
+}
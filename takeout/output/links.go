@@ -0,0 +1,53 @@
+package output
+
+import (
+	"net/url"
+	"strings"
+)
+
+// youtubeOrigin is what relative links in an export are resolved against.
+const youtubeOrigin = "https://www.youtube.com"
+
+// resolveURL makes a channel or video URL from an export absolute: Takeout
+// usually has full https links, but paths like /channel/UC... and links
+// without a scheme turn up too. It returns "" for anything else.
+func resolveURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case raw == "":
+		return ""
+	case strings.HasPrefix(raw, "/") && !strings.HasPrefix(raw, "//"):
+		raw = youtubeOrigin + raw
+	case strings.HasPrefix(raw, "//"):
+		raw = "https:" + raw
+	case !strings.Contains(raw, "://"):
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+	return u.String()
+}
+
+// reportLinks resolves the links of the HTML and Markdown reports.
+type reportLinks struct {
+	// search links channels without a URL to a YouTube search for their
+	// name; it is off for redacted names.
+	search bool
+}
+
+// channel links a channel to its page, or to a search for its name if the
+// export has no URL for it. The unknown channel is not linked.
+func (l reportLinks) channel(name, rawURL string) string {
+	if u := resolveURL(rawURL); u != "" {
+		return u
+	}
+	if !l.search || name == "" || name == "(unknown channel)" || name == "(long tail)" {
+		return ""
+	}
+	return youtubeOrigin + "/results?" + url.Values{"search_query": {name}}.Encode()
+}
+
+// video links a video to its watch page, if the export has its URL.
+func (l reportLinks) video(rawURL string) string { return resolveURL(rawURL) }
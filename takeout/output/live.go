@@ -0,0 +1,53 @@
+package output
+
+import (
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// LiveSplit compares live streams and premieres with regular videos (VODs)
+// in one year.
+type LiveSplit struct {
+	Live        int     `json:"live"`
+	VOD         int     `json:"vod"`
+	LivePercent float64 `json:"live_percent"`
+	// UniqueStreams counts the distinct live videos watched.
+	UniqueStreams   int           `json:"unique_streams"`
+	TopLiveChannels []ChannelStat `json:"top_live_channels"`
+	// UsesLookups is set when -yt-api-key lookups mark streams too, not
+	// only their titles.
+	UsesLookups bool `json:"uses_video_lookups"`
+}
+
+// isLive reports whether a counted video was a live stream or premiere: the
+// API lookup in VideoDetails says so, or its title does (see
+// parser.IsLiveTitle).
+func (w *Writer) isLive(vi aggregate.VideoInfo) bool {
+	if v, ok := w.VideoDetails[parser.VideoIDFromURL(vi.URL)]; ok && v.Live {
+		return true
+	}
+	return parser.IsLiveTitle(vi.Title)
+}
+
+// liveSplit splits a year's watches into live and VOD using the per-video
+// counts, which attribute each video to its channel in VideoInfo.
+func (w *Writer) liveSplit(videos map[string]int, total int, info map[string]aggregate.VideoInfo) *LiveSplit {
+	sp := &LiveSplit{UsesLookups: w.VideoDetails != nil}
+	channels := make(map[aggregate.ChannelKey]int)
+	for vk, c := range videos {
+		vi := info[vk]
+		if !w.isLive(vi) {
+			continue
+		}
+		channels[vi.Channel] += c
+		sp.Live += c
+		sp.UniqueStreams++
+	}
+	sp.VOD = total - sp.Live
+	sp.LivePercent = sharePercent(sp.Live, total)
+
+	top := aggregate.StatsFromMap(channels)
+	aggregate.SortStatsByCountThenName(top)
+	sp.TopLiveChannels = limitList(top, w.TopN)
+	return sp
+}
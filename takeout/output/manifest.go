@@ -0,0 +1,251 @@
+package output
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestName is the manifest's file name in an output directory and at the
+// top of a -bundle archive.
+const ManifestName = "manifest.json"
+
+// Manifest describes a run and the files it wrote.
+type Manifest struct {
+	Tool      string            `json:"tool"`
+	Version   string            `json:"version"`
+	Generated string            `json:"generated"`
+	Inputs    []ManifestInput   `json:"inputs"`
+	Flags     map[string]string `json:"flags"`
+	Files     []ManifestFile    `json:"files"`
+	// Excluded lists files below the directory that are left out on
+	// purpose, such as a -bundle archive written into it.
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+// ManifestInput identifies an input file by its size and, when hashed, its
+// SHA-256; standard input has neither.
+type ManifestInput struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// ManifestFile is a written file, by its slash-separated path below the
+// output directory. Records, when known, counts the rows of a CSV file
+// (without the header), the entries of a JSON array or, for a JSON object,
+// those of its longest top-level list.
+type ManifestFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+	Records *int   `json:"records,omitempty"`
+}
+
+// WriteManifest lists every file below dir in m.Files and writes m to
+// dir/manifest.json, atomically like WriteJSON. Files whose paths are in
+// skip, such as an archive being written into dir, are left out and, if
+// below dir, listed in m.Excluded.
+func WriteManifest(dir string, m *Manifest, skip ...string) error {
+	skipped := make(map[string]bool, len(skip))
+	for _, p := range skip {
+		if p == "" {
+			continue
+		}
+		if a, err := filepath.Abs(p); err == nil {
+			skipped[a] = true
+		}
+	}
+	m.Excluded = nil
+	files, err := listFiles(dir, func(p string) bool {
+		a, err := filepath.Abs(p)
+		return err == nil && skipped[a]
+	})
+	if err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	for a := range skipped {
+		if rel, err := filepath.Rel(absDir, a); err == nil && filepath.IsLocal(rel) {
+			m.Excluded = append(m.Excluded, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(m.Excluded)
+	m.Files = make([]ManifestFile, 0, len(files))
+	for _, name := range files {
+		f, err := describeFile(dir, name)
+		if err != nil {
+			return err
+		}
+		m.Files = append(m.Files, f)
+	}
+	return WriteJSON(filepath.Join(dir, ManifestName), m)
+}
+
+// listFiles returns the slash-separated paths of the files below dir, sorted,
+// leaving out the manifest, leftover .tmp files and those skip reports.
+func listFiles(dir string, skip func(string) bool) ([]string, error) {
+	var files []string
+	root := walkRoot(dir)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if skip(filepath.Join(dir, rel)) {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == ManifestName || strings.HasSuffix(rel, ".tmp") {
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// walkRoot resolves dir if it is a symlink, such as the latest link of
+// -out-versioned, which filepath.WalkDir would not follow.
+func walkRoot(dir string) string {
+	if fi, err := os.Lstat(dir); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return real
+		}
+	}
+	return dir
+}
+
+func describeFile(dir, name string) (ManifestFile, error) {
+	b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return ManifestFile{}, err
+	}
+	sum := sha256.Sum256(b)
+	f := ManifestFile{Path: name, Size: int64(len(b)), SHA256: hex.EncodeToString(sum[:])}
+	if n, ok := countRecords(name, b); ok {
+		f.Records = &n
+	}
+	return f, nil
+}
+
+// countRecords counts the records of a .csv or .json file, as described on
+// ManifestFile.
+func countRecords(name string, b []byte) (int, bool) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		r := csv.NewReader(bytes.NewReader(b))
+		r.FieldsPerRecord = -1
+		n := 0
+		for {
+			_, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return 0, false
+			}
+			n++
+		}
+		return max(n-1, 0), true
+	case ".json":
+		var list []json.RawMessage
+		if err := json.Unmarshal(b, &list); err == nil {
+			return len(list), true
+		}
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(b, &obj); err != nil {
+			return 0, false
+		}
+		n, ok := 0, false
+		for _, v := range obj {
+			if err := json.Unmarshal(v, &list); err == nil {
+				n, ok = max(n, len(list)), true
+			}
+		}
+		return n, ok
+	}
+	return 0, false
+}
+
+// ErrNoManifest is returned by VerifyManifest for a directory without a
+// manifest.json.
+var ErrNoManifest = errors.New("no " + ManifestName + " found")
+
+// VerifyManifest checks the files below dir against its manifest.json and
+// returns a line for every problem: a listed file that is missing or whose
+// size or SHA-256 differs, a file that is neither listed nor excluded, and a
+// leftover .tmp file from an interrupted write. It also returns the manifest,
+// to report on.
+func VerifyManifest(dir string) (*Manifest, []string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, fmt.Errorf("%s: %w", dir, ErrNoManifest)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, nil, fmt.Errorf("reading %s: %w", ManifestName, err)
+	}
+
+	var problems []string
+	listed := make(map[string]bool, len(m.Files)+len(m.Excluded))
+	for _, p := range m.Excluded {
+		listed[p] = true
+	}
+	for _, want := range m.Files {
+		listed[want.Path] = true
+		got, err := describeFile(dir, want.Path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			problems = append(problems, want.Path+": missing")
+		case err != nil:
+			return nil, nil, err
+		case got.Size != want.Size:
+			problems = append(problems, fmt.Sprintf("%s: size %d, want %d", want.Path, got.Size, want.Size))
+		case got.SHA256 != want.SHA256:
+			problems = append(problems, want.Path+": SHA-256 differs")
+		}
+	}
+	root := walkRoot(dir)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		switch {
+		case strings.HasSuffix(rel, ".tmp"):
+			problems = append(problems, rel+": partially written file")
+		case rel != ManifestName && !listed[rel]:
+			problems = append(problems, rel+": not in the manifest")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &m, problems, nil
+}
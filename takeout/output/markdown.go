@@ -0,0 +1,171 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// markdownAllTimeTop is how many all-time channels and videos REPORT.md
+// lists.
+const markdownAllTimeTop = 10
+
+// MarkdownReport is the data REPORT.md templates are executed with.
+type MarkdownReport struct {
+	Generated      string
+	TimeZone       string
+	StartYear      int
+	EndYear        int
+	Total          int
+	Channels       int
+	Videos         int
+	EstimatedHours string
+	Years          []YearResult
+	AllTime        []ChannelStat
+	TopVideos      []VideoStat
+}
+
+// markdownFuncs are available to REPORT.md templates: md escapes text for
+// Markdown tables and inline text, link makes escaped text a link to a URL
+// (or leaves it plain without one), channelURL and videoURL resolve the
+// URL of a channel by name and URL or of a video by URL, inc adds one (for
+// 1-based ranks) and delta formats a rank_delta.
+var markdownFuncs = template.FuncMap{
+	"md":         markdownEscape,
+	"link":       markdownLink,
+	"channelURL": reportLinks{search: true}.channel,
+	"videoURL":   reportLinks{search: true}.video,
+	"inc":        func(i int) int { return i + 1 },
+	"delta": func(d any) string {
+		switch v := d.(type) {
+		case int:
+			if v > 0 {
+				return fmt.Sprintf("▲%d", v)
+			}
+			if v < 0 {
+				return fmt.Sprintf("▼%d", -v)
+			}
+			return "="
+		case string:
+			return v
+		}
+		return ""
+	},
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`",
+	"[", `\[`, "]", `\]`, "<", "&lt;", ">", "&gt;", "#", `\#`,
+)
+
+func markdownEscape(s string) string { return markdownEscaper.Replace(s) }
+
+// markdownURLEscaper keeps a URL from ending a Markdown link early.
+var markdownURLEscaper = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "<", "%3C", ">", "%3E")
+
+func markdownLink(text, url string) string {
+	if url == "" {
+		return markdownEscape(text)
+	}
+	return "[" + markdownEscape(text) + "](" + markdownURLEscaper.Replace(url) + ")"
+}
+
+// writeMarkdownReport renders REPORT.md from the per-year results already
+// built by Write.
+func (w *Writer) writeMarkdownReport(agg *aggregate.Aggregator, years map[int]YearResult) error {
+	opts := agg.Options()
+	data := MarkdownReport{
+		Generated: time.Now().In(opts.Location).Format("2006-01-02 15:04 MST"),
+		TimeZone:  opts.Location.String(),
+		StartYear: opts.StartYear,
+		EndYear:   opts.EndYear,
+		Total:     agg.TotalAllYears,
+		Channels:  len(agg.AllTimeCounts),
+		Videos:    len(agg.AllTimeVideoCounts),
+	}
+	if w.estimatesHours() {
+		wt := w.watchTime(agg.AllTimeVideoCounts, agg.TotalAllYears, agg.VideoInfo, 0)
+		data.EstimatedHours = fmt.Sprintf("%.0f", wt.EstimatedHours)
+	}
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		if years[y].TotalVideos > 0 {
+			data.Years = append(data.Years, years[y])
+		}
+	}
+
+	data.AllTime = aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(data.AllTime)
+	if len(data.AllTime) > markdownAllTimeTop {
+		data.AllTime = data.AllTime[:markdownAllTimeTop]
+	}
+	data.TopVideos = aggregate.VideoStatsFromMap(agg.AllTimeVideoCounts, agg.VideoInfo)
+	if len(data.TopVideos) > markdownAllTimeTop {
+		data.TopVideos = data.TopVideos[:markdownAllTimeTop]
+	}
+
+	t := markdownTemplate
+	if w.ReportTemplate != "" {
+		var err error
+		if t, err = template.New("REPORT.md").Funcs(markdownFuncs).Parse(w.ReportTemplate); err != nil {
+			return err
+		}
+	}
+	// Redacted names are no use to search for.
+	if agg.Redacted() {
+		var err error
+		if t, err = t.Clone(); err != nil {
+			return err
+		}
+		t.Funcs(template.FuncMap{"channelURL": reportLinks{}.channel})
+	}
+	return writeTemplate(filepath.Join(w.Dir, "REPORT.md"), t, data)
+}
+
+var markdownTemplate = template.Must(template.New("REPORT.md").Funcs(markdownFuncs).Parse(`# My YouTube, {{.StartYear}}–{{.EndYear}}
+
+{{.Total}} videos from {{.Channels}} channels
+{{- if .EstimatedHours}}, about {{.EstimatedHours}} hours{{end}}.
+Generated {{.Generated}}; times in {{.TimeZone}}.
+
+## Videos per year
+
+| Year | Videos | Channels |
+|-----:|-------:|---------:|
+{{- range .Years}}
+| {{.Year}} | {{.TotalVideos}} | {{.UniqueChannels}} |
+{{- end}}
+
+## All-time top channels
+
+| # | Channel | Videos |
+|--:|---------|-------:|
+{{- range $i, $c := .AllTime}}
+| {{inc $i}} | {{link $c.ChannelName (channelURL $c.ChannelName $c.ChannelURL)}} | {{$c.WatchCount}} |
+{{- end}}
+{{- if .TopVideos}}
+
+## Most rewatched videos
+
+| # | Video | Channel | Watches |
+|--:|-------|---------|--------:|
+{{- range $i, $v := .TopVideos}}
+| {{inc $i}} | {{link $v.VideoTitle (videoURL $v.VideoURL)}} | {{md $v.ChannelName}} | {{$v.WatchCount}} |
+{{- end}}
+{{- end}}
+{{- range .Years}}
+
+## {{.Year}}
+
+{{.TotalVideos}} videos from {{.UniqueChannels}} channels.
+
+| # | Channel | Videos | vs. last year |
+|--:|---------|-------:|:-------------:|
+{{- range $i, $c := .TopChannels}}
+| {{inc $i}} | {{link $c.ChannelName (channelURL $c.ChannelName $c.ChannelURL)}} | {{$c.WatchCount}} | {{delta $c.RankDelta}} |
+{{- end}}
+{{- end}}
+`))
@@ -0,0 +1,90 @@
+package output
+
+import (
+	"path/filepath"
+	"reflect"
+	"strconv"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// MatrixChannel is a channel's row of channel_year_matrix: its watch count
+// in each year of the matrix, in order, and in all of them.
+type MatrixChannel struct {
+	ChannelName string `json:"channel_name"`
+	ChannelURL  string `json:"channel_url,omitempty"`
+	Counts      []int  `json:"counts"`
+	Total       int    `json:"total"`
+}
+
+// writeMatrix writes channel_year_matrix in w.Formats: every channel with
+// its watch count per year, so the per-year files need no joining.
+func (w *Writer) writeMatrix(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	var years []int
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		years = append(years, y)
+	}
+	stats := aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(stats)
+	channels := make([]MatrixChannel, 0, len(stats))
+	for _, st := range stats {
+		counts := make([]int, len(years))
+		for i, y := range years {
+			counts[i] = agg.YearCounts[y][st.Key()]
+		}
+		channels = append(channels, MatrixChannel{ChannelName: st.ChannelName, ChannelURL: st.ChannelURL, Counts: counts, Total: st.WatchCount})
+	}
+
+	payload := struct {
+		Years    []int           `json:"years"`
+		Channels []MatrixChannel `json:"channels"`
+		Sort     string          `json:"sort"`
+		Notes    string          `json:"notes"`
+	}{
+		Years:    years,
+		Channels: channels,
+		Sort:     "total desc, channel_name asc",
+		Notes:    "counts has one entry per year in years, in the same order, and total sums them. Every channel watched in the range is listed, however rarely; the CSV and Parquet files have a column per year instead.",
+	}
+	base := filepath.Join(w.Dir, "channel_year_matrix")
+	if w.Formats.JSON {
+		w.noteSchema(base+".json", reflect.TypeOf(payload))
+	}
+	return writeTable(w.files, base, w.Formats, payload,
+		func() [][]string {
+			header := []string{"channel_name", "channel_url"}
+			for _, y := range years {
+				header = append(header, strconv.Itoa(y))
+			}
+			records := [][]string{append(header, "total")}
+			for _, ch := range channels {
+				rec := []string{ch.ChannelName, ch.ChannelURL}
+				for _, n := range ch.Counts {
+					rec = append(rec, strconv.Itoa(n))
+				}
+				records = append(records, append(rec, strconv.Itoa(ch.Total)))
+			}
+			return records
+		},
+		func() ([]ParquetColumn, [][]any) {
+			columns := []ParquetColumn{
+				{Name: "channel_name", Type: ParquetByteArray, Converted: ParquetUTF8},
+				{Name: "channel_url", Type: ParquetByteArray, Converted: ParquetUTF8},
+			}
+			for _, y := range years {
+				columns = append(columns, ParquetColumn{Name: strconv.Itoa(y), Type: ParquetInt32, Converted: ParquetNoConversion})
+			}
+			columns = append(columns, ParquetColumn{Name: "total", Type: ParquetInt32, Converted: ParquetNoConversion})
+			rows := make([][]any, 0, len(channels))
+			for _, ch := range channels {
+				row := []any{ch.ChannelName, ch.ChannelURL}
+				for _, n := range ch.Counts {
+					row = append(row, int32(n))
+				}
+				rows = append(rows, append(row, int32(ch.Total)))
+			}
+			return columns, rows
+		})
+}
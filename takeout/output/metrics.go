@@ -0,0 +1,114 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// MetricsContentType is the Prometheus text exposition format Metrics
+// writes.
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelEscaper escapes a Prometheus label value.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsWriter writes gauges in the Prometheus text format, each family's
+// HELP and TYPE lines before its first sample.
+type metricsWriter struct {
+	buf  bytes.Buffer
+	seen map[string]bool
+}
+
+// gauge writes one sample of name; labels alternate label names and values.
+func (m *metricsWriter) gauge(name, help string, value float64, labels ...string) {
+	if !m.seen[name] {
+		m.seen[name] = true
+		fmt.Fprintf(&m.buf, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	m.buf.WriteString(name)
+	if len(labels) > 0 {
+		m.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.buf.WriteByte(',')
+			}
+			fmt.Fprintf(&m.buf, `%s="%s"`, labels[i], labelEscaper.Replace(labels[i+1]))
+		}
+		m.buf.WriteByte('}')
+	}
+	m.buf.WriteByte(' ')
+	m.buf.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	m.buf.WriteByte('\n')
+}
+
+// Metrics renders agg's totals, per-year counts and the top TopN channels
+// of each year (AllTimeTop of all time) as Prometheus gauges.
+func (w *Writer) Metrics(agg *aggregate.Aggregator) []byte {
+	opts := agg.Options()
+	m := &metricsWriter{seen: make(map[string]bool)}
+
+	m.gauge("takeout_watches", "Counted watches in the year range.", float64(agg.TotalAllYears))
+	m.gauge("takeout_unique_channels", "Channels with at least one counted watch.", float64(len(agg.AllTimeCounts)))
+	m.gauge("takeout_unique_videos", "Videos with at least one counted watch.", float64(len(agg.AllTimeVideoCounts)))
+	m.gauge("takeout_removed_videos", "Watches of videos that are no longer available.", float64(agg.TotalRemoved))
+	m.gauge("takeout_ad_views", "Ad views.", float64(agg.TotalAds))
+	m.gauge("takeout_music_plays", "YouTube Music plays.", float64(agg.TotalMusic))
+	if !agg.LatestTime.IsZero() {
+		m.gauge("takeout_latest_entry_timestamp_seconds", "Time of the newest entry in the export.", float64(agg.LatestTime.Unix()))
+	}
+
+	// The text format wants every sample of a metric in one group.
+	perYear := []struct {
+		name, help string
+		value      func(y int) int
+	}{
+		{"takeout_year_watches", "Counted watches per year.", func(y int) int { return agg.YearTotals[y] }},
+		{"takeout_year_unique_channels", "Channels watched per year.", func(y int) int { return len(agg.YearCounts[y]) }},
+		{"takeout_year_unique_videos", "Videos watched per year.", func(y int) int { return len(agg.YearVideoCounts[y]) }},
+		{"takeout_year_removed_videos", "Watches of videos no longer available per year.", func(y int) int { return agg.YearRemoved[y] }},
+		{"takeout_year_ad_views", "Ad views per year.", func(y int) int { return agg.YearAds[y] }},
+	}
+	for _, g := range perYear {
+		for y := opts.StartYear; y <= opts.EndYear; y++ {
+			m.gauge(g.name, g.help, float64(g.value(y)), "year", strconv.Itoa(y))
+		}
+	}
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		stats := aggregate.StatsFromMap(agg.YearCounts[y])
+		aggregate.SortStatsByCountThenName(stats)
+		for _, s := range limitList(stats, w.TopN) {
+			m.gauge("takeout_channel_watches", "Counted watches of the top channels of each year.", float64(s.WatchCount),
+				"year", strconv.Itoa(y), "channel", s.ChannelName, "channel_url", s.ChannelURL)
+		}
+	}
+	stats := aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(stats)
+	for _, s := range limitList(stats, w.AllTimeTop) {
+		m.gauge("takeout_channel_watches_all_time", "Counted watches of the top channels of all time.", float64(s.WatchCount),
+			"channel", s.ChannelName, "channel_url", s.ChannelURL)
+	}
+	return m.buf.Bytes()
+}
+
+// writeMetrics writes Metrics to MetricsOut atomically, like WriteJSON.
+func (w *Writer) writeMetrics(agg *aggregate.Aggregator) error {
+	path := w.MetricsOut
+	tmp := path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	trackTemp(tmp)
+	defer untrackTemp(tmp)
+
+	if err := os.WriteFile(tmp, w.Metrics(agg), 0o644); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,81 @@
+package output
+
+import (
+	"path/filepath"
+	"strings"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type MusicYear struct {
+	Plays      int           `json:"total_plays"`
+	TopArtists []ChannelStat `json:"top_artists"`
+}
+
+// musicArtists turns per-artist play counts into sorted stats, dropping the
+// " - Topic" suffix of YouTube's auto-generated artist channels.
+func musicArtists(counts map[aggregate.ChannelKey]int) []ChannelStat {
+	stats := aggregate.StatsFromMap(counts)
+	for i := range stats {
+		stats[i].ChannelName = strings.TrimSuffix(stats[i].ChannelName, " - Topic")
+	}
+	aggregate.SortStatsByCountThenName(stats)
+	return stats
+}
+
+// writeMusic writes music_top_artists.json and music_top_tracks.json, the
+// YouTube Music plays broken out of the watch history.
+func (w *Writer) writeMusic(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	years := make(map[int]MusicYear)
+	allTime := make(map[aggregate.ChannelKey]int)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		for k, n := range agg.MusicArtistCounts[y] {
+			allTime[k] += n
+		}
+		years[y] = MusicYear{
+			Plays:      agg.YearMusic[y],
+			TopArtists: limitList(musicArtists(agg.MusicArtistCounts[y]), w.TopN),
+		}
+	}
+
+	artistsPayload := struct {
+		TotalPlays  int               `json:"total_music_plays"`
+		Excluded    bool              `json:"excluded_from_counts"`
+		Years       map[int]MusicYear `json:"years"`
+		TopN        int               `json:"top_n"`
+		AllTime     []ChannelStat     `json:"top_artists_all_time"`
+		AllTimeTopN int               `json:"all_time_top_n"`
+		Sort        string            `json:"sort"`
+		Notes       string            `json:"notes"`
+	}{
+		TotalPlays:  agg.TotalMusic,
+		Excluded:    opts.ExcludeMusic,
+		Years:       years,
+		TopN:        w.TopN,
+		AllTime:     limitList(musicArtists(allTime), w.AllTimeTop),
+		AllTimeTopN: w.AllTimeTop,
+		Sort:        "watch_count desc, channel_name asc",
+		Notes:       "Music plays are watched entries from YouTube Music (a 'YouTube Music' header or product, or a music.youtube.com URL). An artist is the track's channel, without the ' - Topic' suffix of auto-generated artist channels. When excluded_from_counts is true they are left out of every channel and video count.",
+	}
+	if err := w.writeJSON(filepath.Join(w.Dir, "music_top_artists.json"), artistsPayload); err != nil {
+		return err
+	}
+
+	tracks := aggregate.VideoStatsFromMap(agg.MusicTrackCounts, agg.VideoInfo)
+	tracksPayload := struct {
+		TotalPlays   int         `json:"total_music_plays"`
+		UniqueTracks int         `json:"unique_tracks"`
+		TopN         int         `json:"top_n"`
+		Tracks       []VideoStat `json:"tracks"`
+		Sort         string      `json:"sort"`
+	}{
+		TotalPlays:   agg.TotalMusic,
+		UniqueTracks: len(tracks),
+		TopN:         w.AllTimeTop,
+		Tracks:       limitList(tracks, w.AllTimeTop),
+		Sort:         "watch_count desc, video_title asc",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "music_top_tracks.json"), tracksPayload)
+}
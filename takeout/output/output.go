@@ -0,0 +1,836 @@
+// Package output writes aggregated watch history as JSON/CSV files, and
+// provides the Parquet and SQLite writers used for per-watch exports.
+package output
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+	"example.com/hello/takeout/youtube"
+)
+
+type ChannelStat = aggregate.ChannelStat
+type VideoStat = aggregate.VideoStat
+
+type YearResult struct {
+	Year              int           `json:"year"`
+	TotalVideos       int           `json:"total_videos_watched"`
+	UniqueChannels    int           `json:"unique_channels"`
+	Concentration     Concentration `json:"concentration"`
+	TopChannels       []ChannelStat `json:"top_channels"`
+	TopN              int           `json:"top_n"`
+	Sort              string        `json:"sort"`
+	FilteredAction    string        `json:"filtered_action"`
+	TimeParseFailures int           `json:"time_parse_failures"`
+	RemovedSkipped    int           `json:"removed_videos_skipped"`
+	AdViews           int           `json:"ad_views"`
+	// Live splits the year's watches into live streams and VODs; it is
+	// left out of redacted outputs, which have no titles to tell them by.
+	Live *LiveSplit `json:"live,omitempty"`
+}
+
+type Summary struct {
+	YearRange struct {
+		Start int `json:"start"`
+		End   int `json:"end"`
+	} `json:"year_range"`
+	// Window is the first and last day counted (see -from and -to).
+	Window struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"window"`
+	TimeZone            string             `json:"time_zone"`
+	TotalVideosAllYears int                `json:"total_videos_all_years"`
+	RemovedVideos       int                `json:"removed_videos"`
+	RemovedSkipped      int                `json:"removed_videos_skipped"`
+	AdViews             int                `json:"ad_views"`
+	AdsExcluded         bool               `json:"ads_excluded"`
+	ChannelFiltered     int                `json:"channel_filtered"`
+	MalformedSkipped    int                `json:"malformed_entries_skipped"`
+	TimeParseFailures   int                `json:"time_parse_failures"`
+	Actions             map[string]int     `json:"actions"`
+	ActionsCounted      []string           `json:"actions_counted"`
+	Redacted            bool               `json:"redacted,omitempty"`
+	Engagement          *Engagement        `json:"engagement,omitempty"`
+	Accounts            []AccountSummary   `json:"accounts,omitempty"`
+	Processing          ProcessingStats    `json:"processing"`
+	Years               map[int]YearResult `json:"years"`
+}
+
+type ProcessingStats struct {
+	EntriesDecoded   int     `json:"entries_decoded"`
+	WatchedCounted   int     `json:"watched_counted"`
+	BytesRead        int64   `json:"bytes_read"`
+	ElapsedSeconds   float64 `json:"elapsed_seconds"`
+	MBPerSecond      float64 `json:"mb_per_second"`
+	EntriesPerSecond float64 `json:"entries_per_second"`
+	Workers          int     `json:"workers"`
+	// AlreadyCounted is how many entries -state had counted in an earlier
+	// run.
+	AlreadyCounted int `json:"entries_already_counted,omitempty"`
+}
+
+type MergeInput struct {
+	Path              string `json:"path"`
+	Entries           int    `json:"entries"`
+	DuplicatesDropped int    `json:"duplicates_dropped"`
+}
+
+// Writer writes the per-year, all-time and optional output files for an
+// Aggregator into Dir.
+type Writer struct {
+	Dir               string
+	Formats           Formats
+	TopN              int
+	FullLimit         int
+	LongTailThreshold int
+	AllTimeTop        int
+	// Sort is the order of the channel lists, one of aggregate.ChannelSorts;
+	// "" is count_desc. The channels listed are the most watched either way.
+	Sort string
+	// RecapYear, if nonzero, also writes recap_<YEAR>.json.
+	RecapYear int
+	// Aliases writes aliases.json; the Aggregator must track aliases.
+	Aliases bool
+	// Report, if "html", also writes a self-contained report.html; if
+	// "markdown", a REPORT.md.
+	Report string
+	// ReportTemplate, if set, replaces the built-in template of Report. It
+	// is an html/template for "html" and a text/template for "markdown".
+	ReportTemplate string
+	// VideoDetails, keyed by video ID, also writes watch_time_estimates.json
+	// and adds estimated hours to the report (see youtube.Client.Videos and
+	// youtube.ReadDurations).
+	VideoDetails map[string]youtube.Video
+	// DefaultDuration, if nonzero, is the duration in seconds assumed for
+	// watched videos without a known one; it also writes
+	// watch_time_estimates.json without VideoDetails.
+	DefaultDuration int
+	// Subscriptions, if set, adds subscribed to the channel lists and
+	// writes subscriptions.json.
+	Subscriptions []parser.Subscription
+	subs          *subscriptionIndex
+	// Likes, if set, writes likes.json.
+	Likes []parser.Like
+	// Playlists, if set, writes playlists.json.
+	Playlists []parser.Playlist
+	// Comments, if set, adds the engagement section to summary.json.
+	Comments []parser.Comment
+	// KeywordsByChannel adds the top channels of each year to
+	// keywords_<YEAR>.json with their own title keywords.
+	KeywordsByChannel bool
+	// GapDays, if nonzero, writes gaps.json with the breaks of at least
+	// that many days without a watch.
+	GapDays int
+	// VelocityChange, if nonzero, writes velocity.json, flagging the months
+	// whose videos per day changed by at least that many percent.
+	VelocityChange int
+	// DiscoveryMin, if nonzero, writes discoveries.json with the channels
+	// first watched each year that reached that many watches.
+	DiscoveryMin int
+	// ICalThreshold, if nonzero, writes heavy_days.ics with the days of
+	// more than that many watches; agg needs Options.DayChannels.
+	ICalThreshold int
+	// DailyChannels adds a column to daily_counts.csv for each of that many
+	// most watched channels; agg needs Options.DayChannels.
+	DailyChannels int
+	// Categories, if set, writes categories.json with the watches of each
+	// channel category.
+	Categories *aggregate.ChannelCategories
+	// Blocklist, if set, writes reclaimed_time.json with the watches its
+	// channels took, as if they had been blocked.
+	Blocklist *aggregate.ChannelFilter
+	// ChannelReport, if set, writes channel_report.json from the watches it
+	// collected.
+	ChannelReport *ChannelReport
+	// MetricsOut, if set, is where Metrics is written, e.g. for the
+	// node_exporter textfile collector.
+	MetricsOut string
+	// Plugins, keyed by name, are finalized and their outputs written
+	// before the Templates are rendered.
+	Plugins map[string]aggregate.Plugin
+	// Templates are rendered into Dir last, each to its Name (see
+	// ParseCustomTemplate).
+	Templates []*template.Template
+	// Accounts, if set, are the labeled accounts the Aggregator counted
+	// together: they break the channel lists down by account, are listed in
+	// summary.json and each get their own outputs (see AccountDir).
+	Accounts []Account
+	// Schemas notes the type of every JSON file written, for WriteSchemas.
+	Schemas bool
+	schemas map[string]reflect.Type
+	// WriteWorkers is how many output files are written at once; 0 or 1
+	// writes them one after another.
+	WriteWorkers int
+	files        *fileWriter
+	// Inputs is written to merge_report.json when there is more than one.
+	Inputs     []MergeInput
+	Processing ProcessingStats
+}
+
+// Write writes every output file for agg, WriteWorkers at a time. It
+// returns once every file is written, with the errors of all that failed.
+func (w *Writer) Write(agg *aggregate.Aggregator) error {
+	w.files = newFileWriter(w.WriteWorkers)
+	err := w.write(agg)
+	err = errors.Join(err, w.files.wait())
+	w.files = nil
+	return err
+}
+
+func (w *Writer) write(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	if w.Subscriptions != nil {
+		w.subs = newSubscriptionIndex(w.Subscriptions)
+	}
+
+	// Build per-year results
+	perYearTop := make(map[int]YearResult)
+	var actions []string
+	for _, a := range countedActions(opts) {
+		actions = append(actions, strings.ToUpper(a[:1])+a[1:])
+	}
+	filteredAction := strings.Join(actions, ", ")
+	var prevRanks map[aggregate.ChannelKey]int
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		fullStats := aggregate.StatsFromMap(agg.YearCounts[y])
+		aggregate.SortStatsByCountThenName(fullStats)
+		prevRanks = aggregate.AnnotateRankDeltas(fullStats, prevRanks)
+		w.markSubscribed(fullStats)
+		w.markAccounts(fullStats, func(a *aggregate.Aggregator) map[aggregate.ChannelKey]int { return a.YearCounts[y] })
+
+		top := fullStats
+		if w.TopN > 0 && len(top) > w.TopN {
+			top = top[:w.TopN]
+		}
+		top = w.sortChannels(top, agg)
+
+		var live *LiveSplit
+		if !agg.Redacted() {
+			live = w.liveSplit(agg.YearVideoCounts[y], agg.YearTotals[y], agg.VideoInfo)
+		}
+		perYearTop[y] = YearResult{
+			Year:              y,
+			TotalVideos:       agg.YearTotals[y],
+			UniqueChannels:    len(agg.YearCounts[y]),
+			Concentration:     concentration(fullStats),
+			TopChannels:       top,
+			TopN:              w.TopN,
+			Sort:              aggregate.SortDescription(w.Sort),
+			FilteredAction:    filteredAction,
+			TimeParseFailures: agg.YearParseFails[y],
+			RemovedSkipped:    removedSkipped(opts, agg.YearRemoved[y]),
+			AdViews:           agg.YearAds[y],
+			Live:              live,
+		}
+
+		// Write per-year top file
+		if err := w.writeChannelList(filepath.Join(w.Dir, fmt.Sprintf("top_channels_%d", y)), perYearTop[y], top); err != nil {
+			return err
+		}
+
+		videos := aggregate.VideoStatsFromMap(agg.YearVideoCounts[y], agg.VideoInfo)
+		uniqueVideos := len(videos)
+		if w.TopN > 0 && len(videos) > w.TopN {
+			videos = videos[:w.TopN]
+		}
+		videoPayload := struct {
+			Year         int         `json:"year"`
+			TotalVideos  int         `json:"total_videos_watched"`
+			UniqueVideos int         `json:"unique_videos"`
+			TopVideos    []VideoStat `json:"top_videos"`
+			TopN         int         `json:"top_n"`
+			Sort         string      `json:"sort"`
+		}{
+			Year:         y,
+			TotalVideos:  agg.YearTotals[y],
+			UniqueVideos: uniqueVideos,
+			TopVideos:    videos,
+			TopN:         w.TopN,
+			Sort:         "watch_count desc, video_title asc",
+		}
+		if err := w.writeVideoList(filepath.Join(w.Dir, fmt.Sprintf("top_videos_%d", y)), videoPayload, videos); err != nil {
+			return err
+		}
+
+		if y == w.RecapYear {
+			var wt *WatchTime
+			if w.estimatesHours() {
+				t := w.watchTime(agg.YearVideoCounts[y], agg.YearTotals[y], agg.VideoInfo, w.TopN)
+				wt = &t
+			}
+			recap := BuildRecap(y, fullStats, agg, wt)
+			if err := w.writeJSON(filepath.Join(w.Dir, fmt.Sprintf("recap_%d.json", y)), recap); err != nil {
+				return err
+			}
+		}
+
+		// Write per-year full file
+		fullOut, tail := aggregate.SplitLongTail(fullStats, w.LongTailThreshold)
+		if w.FullLimit > 0 && len(fullOut) > w.FullLimit {
+			fullOut = fullOut[:w.FullLimit]
+		}
+		// Copy so the spans and the tail stay out of the shared top-N
+		// backing array.
+		fullOut = addSpans(append([]ChannelStat(nil), fullOut...), agg.ChannelSpans)
+		aggregate.SortStats(fullOut, w.Sort, agg.ChannelSpans)
+		if tail != nil {
+			fullOut = append(fullOut, *tail)
+		}
+		fullPayload := struct {
+			Year              int           `json:"year"`
+			TotalVideos       int           `json:"total_videos_watched"`
+			Channels          []ChannelStat `json:"channels_sorted"`
+			Limit             int           `json:"limit"`
+			LongTailThreshold int           `json:"long_tail_threshold,omitempty"`
+			Sort              string        `json:"sort"`
+		}{
+			Year:              y,
+			TotalVideos:       agg.YearTotals[y],
+			Channels:          fullOut,
+			Limit:             w.FullLimit,
+			LongTailThreshold: w.LongTailThreshold,
+			Sort:              aggregate.SortDescription(w.Sort),
+		}
+
+		if err := w.writeChannelList(filepath.Join(w.Dir, fmt.Sprintf("channels_full_%d", y)), fullPayload, fullOut); err != nil {
+			return err
+		}
+	}
+
+	if opts.Granularity != "" && opts.Granularity != "year" {
+		if err := w.writePeriodOutputs(agg); err != nil {
+			return err
+		}
+	}
+
+	// Write combined “top by year” file
+	topByYearPayload := struct {
+		StartYear int                `json:"start_year"`
+		EndYear   int                `json:"end_year"`
+		TopN      int                `json:"top_n"`
+		Years     map[int]YearResult `json:"years"`
+	}{
+		StartYear: opts.StartYear,
+		EndYear:   opts.EndYear,
+		TopN:      w.TopN,
+		Years:     perYearTop,
+	}
+	if err := w.writeJSON(filepath.Join(w.Dir, "top_channels_by_year.json"), topByYearPayload); err != nil {
+		return err
+	}
+
+	// Write summary file
+	var summary Summary
+	summary.YearRange.Start = opts.StartYear
+	summary.YearRange.End = opts.EndYear
+	first, last := opts.Window()
+	summary.Window.From = first.Format(time.DateOnly)
+	summary.Window.To = last.Format(time.DateOnly)
+	summary.TimeZone = opts.Location.String()
+	summary.TotalVideosAllYears = agg.TotalAllYears
+	summary.RemovedVideos = agg.TotalRemoved
+	summary.RemovedSkipped = removedSkipped(opts, agg.TotalRemoved)
+	summary.AdViews = agg.TotalAds
+	summary.AdsExcluded = opts.ExcludeAds
+	summary.ChannelFiltered = agg.ChannelFiltered
+	summary.MalformedSkipped = agg.Skipped
+	summary.TimeParseFailures = agg.TimeParseFails
+	summary.Actions = agg.ActionCounts
+	summary.ActionsCounted = countedActions(opts)
+	summary.Redacted = agg.Redacted()
+	if w.Comments != nil {
+		summary.Engagement = w.engagement(agg)
+	}
+	summary.Accounts = w.accountSummaries()
+	summary.Processing = w.Processing
+	summary.Years = perYearTop
+
+	if err := w.writeJSON(filepath.Join(w.Dir, "summary.json"), summary); err != nil {
+		return err
+	}
+
+	// Write all-time top channels
+	allTimeStats := aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(allTimeStats)
+	if w.AllTimeTop > 0 && len(allTimeStats) > w.AllTimeTop {
+		allTimeStats = allTimeStats[:w.AllTimeTop]
+	}
+	aggregate.SortStats(allTimeStats, w.Sort, agg.ChannelSpans)
+	w.markSubscribed(allTimeStats)
+	w.markAccounts(allTimeStats, func(a *aggregate.Aggregator) map[aggregate.ChannelKey]int { return a.AllTimeCounts })
+	for i := range allTimeStats {
+		if hours := agg.AllTimeHours[allTimeStats[i].Key()]; hours != nil {
+			h := aggregate.ModeHour(hours)
+			allTimeStats[i].TypicalHour = &h
+		}
+	}
+	allTimePayload := struct {
+		TopN        int           `json:"top_n"`
+		TotalVideos int           `json:"total_videos_counted"`
+		Channels    []ChannelStat `json:"channels"`
+		Sort        string        `json:"sort"`
+		Notes       string        `json:"notes"`
+	}{
+		TopN:        w.AllTimeTop,
+		TotalVideos: agg.TotalAllYears,
+		Channels:    allTimeStats,
+		Sort:        aggregate.SortDescription(w.Sort),
+		Notes:       "Counts are derived from entries whose title starts with a watched prefix (e.g. 'Watched ') and whose time parses (RFC3339 with or without an offset, or epoch milliseconds); however, entries with missing channel info are grouped under '(unknown channel)'. typical_hour is the channel's most frequent hour of day in the " + opts.Location.String() + " time zone.",
+	}
+	if err := w.writeChannelList(filepath.Join(w.Dir, "top_channels_all_time"), allTimePayload, allTimeStats); err != nil {
+		return err
+	}
+
+	// Write all-time top videos
+	allTimeVideos := aggregate.VideoStatsFromMap(agg.AllTimeVideoCounts, agg.VideoInfo)
+	uniqueVideos := len(allTimeVideos)
+	if w.AllTimeTop > 0 && len(allTimeVideos) > w.AllTimeTop {
+		allTimeVideos = allTimeVideos[:w.AllTimeTop]
+	}
+	allTimeVideoPayload := struct {
+		TopN         int         `json:"top_n"`
+		TotalVideos  int         `json:"total_videos_counted"`
+		UniqueVideos int         `json:"unique_videos"`
+		Videos       []VideoStat `json:"videos"`
+		Sort         string      `json:"sort"`
+		Notes        string      `json:"notes"`
+	}{
+		TopN:         w.AllTimeTop,
+		TotalVideos:  agg.TotalAllYears,
+		UniqueVideos: uniqueVideos,
+		Videos:       allTimeVideos,
+		Sort:         "watch_count desc, video_title asc",
+		Notes:        "Videos are keyed by video ID, so short, mobile and timestamped links to one video count as one (entries without an ID by URL or title), and exclude removed videos; watch_count above 1 means the video was rewatched. Title and channel are from the most recent watch.",
+	}
+	if err := w.writeVideoList(filepath.Join(w.Dir, "top_videos_all_time"), allTimeVideoPayload, allTimeVideos); err != nil {
+		return err
+	}
+
+	if err := w.writeTrends(agg); err != nil {
+		return err
+	}
+
+	if err := w.writeMatrix(agg); err != nil {
+		return err
+	}
+
+	if err := w.writeRemoved(agg); err != nil {
+		return err
+	}
+
+	if err := w.writeUnknown(agg); err != nil {
+		return err
+	}
+
+	if w.ChannelReport != nil {
+		if err := w.writeChannelReport(agg); err != nil {
+			return err
+		}
+	}
+
+	parseErrors := agg.ParseErrors
+	if parseErrors == nil {
+		parseErrors = []aggregate.ParseError{}
+	}
+	parseErrorsPayload := struct {
+		Skipped int                    `json:"skipped_entries"`
+		Errors  []aggregate.ParseError `json:"errors"`
+		Notes   string                 `json:"notes"`
+	}{
+		Skipped: agg.Skipped,
+		Errors:  parseErrors,
+		Notes:   "Entries that could not be decoded are skipped and listed here (the first 1000 of them), with their position in the input, byte offset and the start of their raw text; pass -strict to stop at the first one instead. When rest_of_input_skipped is true the input could not be read past the entry (e.g. it is truncated) and only the entries before it were counted.",
+	}
+	if err := w.writeJSON(filepath.Join(w.Dir, "parse_errors.json"), parseErrorsPayload); err != nil {
+		return err
+	}
+
+	// Write ad summary
+	adVideos := aggregate.VideoStatsFromMap(agg.AdVideoCounts, agg.VideoInfo)
+	if w.AllTimeTop > 0 && len(adVideos) > w.AllTimeTop {
+		adVideos = adVideos[:w.AllTimeTop]
+	}
+	adsPayload := struct {
+		TotalAdViews int         `json:"total_ad_views"`
+		Excluded     bool        `json:"excluded_from_counts"`
+		Years        map[int]int `json:"years"`
+		TopAds       []VideoStat `json:"top_ads"`
+		Sort         string      `json:"sort"`
+		Notes        string      `json:"notes"`
+	}{
+		TotalAdViews: agg.TotalAds,
+		Excluded:     opts.ExcludeAds,
+		Years:        agg.YearAds,
+		TopAds:       adVideos,
+		Sort:         "watch_count desc, video_title asc",
+		Notes:        "Ad views are watched entries Takeout marks with a 'From Google Ads' detail. When excluded_from_counts is true they are left out of every channel and video count.",
+	}
+	if err := w.writeJSON(filepath.Join(w.Dir, "ads_summary.json"), adsPayload); err != nil {
+		return err
+	}
+
+	if w.Subscriptions != nil {
+		if err := w.writeSubscriptions(agg); err != nil {
+			return err
+		}
+	}
+
+	if w.Likes != nil {
+		if err := w.writeLikes(agg); err != nil {
+			return err
+		}
+	}
+
+	if w.Playlists != nil {
+		if err := w.writePlaylists(agg); err != nil {
+			return err
+		}
+	}
+
+	if err := w.writeMusic(agg); err != nil {
+		return err
+	}
+
+	// Shorts are found by their URLs, which Redact drops.
+	if !agg.Redacted() {
+		if err := w.writeShorts(agg); err != nil {
+			return err
+		}
+	}
+
+	if len(w.Inputs) > 1 {
+		mergePayload := struct {
+			Inputs            []MergeInput `json:"inputs"`
+			TotalEntries      int          `json:"total_entries"`
+			DuplicatesDropped int          `json:"duplicates_dropped"`
+			Notes             string       `json:"notes"`
+		}{
+			Inputs:            w.Inputs,
+			TotalEntries:      agg.EntriesDecoded,
+			DuplicatesDropped: agg.Duplicates,
+			Notes:             "Entries with the same titleUrl and time as an entry from an earlier input are dropped as duplicates.",
+		}
+		if err := w.writeJSON(filepath.Join(w.Dir, "merge_report.json"), mergePayload); err != nil {
+			return err
+		}
+	}
+
+	// Pseudonyms do not look alike when the names did.
+	if !agg.Redacted() {
+		if err := w.writeSuggestions(agg); err != nil {
+			return err
+		}
+	}
+
+	if w.Aliases {
+		if err := w.writeJSON(filepath.Join(w.Dir, "aliases.json"), AliasesPayload(agg)); err != nil {
+			return err
+		}
+	}
+
+	if agg.Options().SessionGap > 0 {
+		if err := w.writeSessions(agg); err != nil {
+			return err
+		}
+	}
+
+	if err := w.writeHabits(agg); err != nil {
+		return err
+	}
+
+	if err := w.writeSeasonality(agg); err != nil {
+		return err
+	}
+
+	if err := w.writeWeekend(agg); err != nil {
+		return err
+	}
+
+	if err := w.writeDaily(agg); err != nil {
+		return err
+	}
+
+	if w.GapDays > 0 {
+		if err := w.writeGaps(agg); err != nil {
+			return err
+		}
+	}
+
+	if w.VelocityChange > 0 {
+		if err := w.writeVelocity(agg); err != nil {
+			return err
+		}
+	}
+
+	if w.DiscoveryMin > 0 {
+		if err := w.writeDiscoveries(agg); err != nil {
+			return err
+		}
+	}
+
+	if err := w.writeCohorts(agg); err != nil {
+		return err
+	}
+
+	if err := w.writeHighlights(agg); err != nil {
+		return err
+	}
+
+	if w.Categories != nil {
+		if err := w.writeCategories(agg); err != nil {
+			return err
+		}
+	}
+
+	if w.Blocklist != nil {
+		if err := w.writeReclaimed(agg); err != nil {
+			return err
+		}
+	}
+
+	if w.ICalThreshold > 0 {
+		if err := w.writeICal(agg); err != nil {
+			return err
+		}
+	}
+
+	// Redacted titles have no keywords left to count.
+	if !agg.Redacted() {
+		if err := w.writeKeywords(agg); err != nil {
+			return err
+		}
+	}
+
+	if agg.Options().RollingDays > 0 {
+		if err := w.writeRolling(agg); err != nil {
+			return err
+		}
+	}
+
+	if w.estimatesHours() {
+		if err := w.writeWatchTime(agg); err != nil {
+			return err
+		}
+	}
+
+	switch w.Report {
+	case "html":
+		if err := w.writeReport(agg, perYearTop); err != nil {
+			return err
+		}
+	case "markdown":
+		if err := w.writeMarkdownReport(agg, perYearTop); err != nil {
+			return err
+		}
+	}
+
+	if w.MetricsOut != "" {
+		if err := w.writeMetrics(agg); err != nil {
+			return err
+		}
+	}
+
+	if len(w.Plugins) > 0 {
+		if err := w.writePlugins(agg); err != nil {
+			return err
+		}
+	}
+
+	if len(w.Templates) > 0 {
+		if err := w.writeCustom(agg, summary); err != nil {
+			return err
+		}
+	}
+
+	if len(w.Accounts) > 0 {
+		if err := w.writeAccounts(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type PeriodResult struct {
+	Period         string        `json:"period"`
+	Granularity    string        `json:"granularity"`
+	TotalVideos    int           `json:"total_videos_watched"`
+	UniqueChannels int           `json:"unique_channels"`
+	TopChannels    []ChannelStat `json:"top_channels"`
+	TopN           int           `json:"top_n"`
+	Sort           string        `json:"sort"`
+}
+
+type PeriodTotal struct {
+	Period         string `json:"period"`
+	TotalVideos    int    `json:"total_videos_watched"`
+	UniqueChannels int    `json:"unique_channels"`
+}
+
+// countedActions is the actions opts counts as watches.
+func countedActions(opts aggregate.Options) []string {
+	if opts.Actions == nil {
+		return []string{parser.ActionWatched}
+	}
+	return opts.Actions
+}
+
+// addSpans sets the first and last watch of each channel in stats from
+// spans.
+func addSpans(stats []ChannelStat, spans map[aggregate.ChannelKey]aggregate.WatchSpan) []ChannelStat {
+	for i := range stats {
+		sp, ok := spans[stats[i].Key()]
+		if !ok {
+			continue
+		}
+		first := time.Date(sp.First.Year(), sp.First.Month(), sp.First.Day(), 0, 0, 0, 0, time.UTC)
+		last := time.Date(sp.Last.Year(), sp.Last.Month(), sp.Last.Day(), 0, 0, 0, 0, time.UTC)
+		days := int(last.Sub(first).Hours() / 24)
+		stats[i].FirstWatched = sp.First.Format(time.RFC3339)
+		stats[i].LastWatched = sp.Last.Format(time.RFC3339)
+		stats[i].SpanDays = &days
+	}
+	return stats
+}
+
+// sortChannels returns top, the head of a list sorted by count, in the
+// order of w.Sort, copying it first unless that is the count order so the
+// full list stays sorted by count.
+func (w *Writer) sortChannels(top []ChannelStat, agg *aggregate.Aggregator) []ChannelStat {
+	if w.Sort == "" || w.Sort == "count_desc" {
+		return top
+	}
+	top = append([]ChannelStat(nil), top...)
+	aggregate.SortStats(top, w.Sort, agg.ChannelSpans)
+	return top
+}
+
+// writePeriodOutputs writes top_channels_<PERIOD> files for every period with
+// watches, plus timeseries_<GRANULARITY>.json covering every period in the
+// year range (including empty ones).
+func (w *Writer) writePeriodOutputs(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	granularity := opts.Granularity
+
+	var series []PeriodTotal
+	last := ""
+	end := time.Date(opts.EndYear+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	for d := time.Date(opts.StartYear, 1, 1, 0, 0, 0, 0, time.UTC); d.Before(end); d = d.AddDate(0, 0, 1) {
+		p := aggregate.PeriodLabel(d, granularity)
+		if p == last {
+			continue
+		}
+		last = p
+		series = append(series, PeriodTotal{
+			Period:         p,
+			TotalVideos:    agg.PeriodTotals[p],
+			UniqueChannels: len(agg.PeriodCounts[p]),
+		})
+
+		if agg.PeriodTotals[p] == 0 {
+			continue
+		}
+		stats := aggregate.StatsFromMap(agg.PeriodCounts[p])
+		aggregate.SortStatsByCountThenName(stats)
+		if w.TopN > 0 && len(stats) > w.TopN {
+			stats = stats[:w.TopN]
+		}
+		aggregate.SortStats(stats, w.Sort, agg.ChannelSpans)
+		w.markSubscribed(stats)
+		w.markAccounts(stats, func(a *aggregate.Aggregator) map[aggregate.ChannelKey]int { return a.PeriodCounts[p] })
+		res := PeriodResult{
+			Period:         p,
+			Granularity:    granularity,
+			TotalVideos:    agg.PeriodTotals[p],
+			UniqueChannels: len(agg.PeriodCounts[p]),
+			TopChannels:    stats,
+			TopN:           w.TopN,
+			Sort:           aggregate.SortDescription(w.Sort),
+		}
+		if err := w.writeChannelList(filepath.Join(w.Dir, "top_channels_"+p), res, stats); err != nil {
+			return err
+		}
+	}
+
+	payload := struct {
+		Granularity string        `json:"granularity"`
+		Periods     []PeriodTotal `json:"periods"`
+	}{
+		Granularity: granularity,
+		Periods:     series,
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "timeseries_"+granularity+".json"), payload)
+}
+
+type ChannelAlias struct {
+	ChannelName string         `json:"channel_name"`
+	ChannelURL  string         `json:"channel_url,omitempty"`
+	ChannelID   string         `json:"channel_id,omitempty"`
+	WatchCount  int            `json:"watch_count"`
+	Variants    []AliasVariant `json:"variants"`
+}
+
+type AliasVariant struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Count int    `json:"count"`
+}
+
+// AliasesPayload is the content of aliases.json.
+func AliasesPayload(agg *aggregate.Aggregator) any {
+	stats := aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(stats)
+
+	channels := make([]ChannelAlias, 0, len(stats))
+	for _, st := range stats {
+		ca := ChannelAlias{
+			ChannelName: st.ChannelName,
+			ChannelURL:  st.ChannelURL,
+			ChannelID:   parser.ChannelIDFromURL(st.ChannelURL),
+			WatchCount:  st.WatchCount,
+		}
+		for raw, n := range agg.Aliases[st.Key()] {
+			ca.Variants = append(ca.Variants, AliasVariant{Name: raw.Name, URL: raw.URL, Count: n})
+		}
+		sort.Slice(ca.Variants, func(i, j int) bool {
+			if ca.Variants[i].Count == ca.Variants[j].Count {
+				return ca.Variants[i].Name+ca.Variants[i].URL < ca.Variants[j].Name+ca.Variants[j].URL
+			}
+			return ca.Variants[i].Count > ca.Variants[j].Count
+		})
+		channels = append(channels, ca)
+	}
+
+	return struct {
+		Channels []ChannelAlias `json:"channels"`
+		Notes    string         `json:"notes"`
+	}{
+		Channels: channels,
+		Notes:    "Each channel lists the raw subtitle name/URL pairs (before trimming and merging) that were counted under it.",
+	}
+}
+
+// NewProcessingStats summarizes the work done by agg in elapsed.
+func NewProcessingStats(agg *aggregate.Aggregator, elapsed time.Duration) ProcessingStats {
+	p := ProcessingStats{
+		EntriesDecoded: agg.EntriesDecoded,
+		WatchedCounted: agg.TotalAllYears,
+		BytesRead:      agg.BytesRead,
+		ElapsedSeconds: elapsed.Seconds(),
+		Workers:        agg.WorkersUsed(),
+		AlreadyCounted: agg.AlreadyCounted,
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		p.MBPerSecond = float64(p.BytesRead) / 1e6 / secs
+		p.EntriesPerSecond = float64(p.EntriesDecoded) / secs
+	}
+	return p
+}
@@ -0,0 +1,557 @@
+package output
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// A minimal Parquet writer: flat schemas of BOOLEAN, INT32, INT64 and
+// BYTE_ARRAY columns, required or optional, PLAIN encoding, no compression. Rows are buffered per
+// column and flushed as a row group every parquetRowGroupSize rows, so memory
+// stays bounded no matter how many rows are written.
+
+const parquetRowGroupSize = 64 * 1024
+
+// Parquet physical types.
+const (
+	ParquetBoolean   int32 = 0
+	ParquetInt32     int32 = 1
+	ParquetInt64     int32 = 2
+	ParquetByteArray int32 = 6
+)
+
+// Parquet converted types (logical annotations).
+const (
+	ParquetNoConversion    int32 = -1
+	ParquetUTF8            int32 = 0
+	ParquetDate            int32 = 6
+	ParquetTimestampMillis int32 = 9
+)
+
+type ParquetColumn struct {
+	Name      string
+	Type      int32
+	Converted int32
+	// Optional columns accept nil values, written as nulls.
+	Optional bool
+}
+
+// WatchEventColumns is the schema of the -parquet export; WatchEventRow
+// builds a matching row.
+var WatchEventColumns = []ParquetColumn{
+	{Name: "time", Type: ParquetInt64, Converted: ParquetTimestampMillis},
+	{Name: "year", Type: ParquetInt32, Converted: ParquetNoConversion},
+	{Name: "month", Type: ParquetInt32, Converted: ParquetNoConversion},
+	{Name: "channel_name", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "channel_url", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "video_id", Type: ParquetByteArray, Converted: ParquetUTF8},
+}
+
+func WatchEventRow(e aggregate.WatchEvent) []any {
+	return []any{
+		e.Time.UnixMilli(),
+		int32(e.Time.Year()),
+		int32(e.Time.Month()),
+		e.ChannelName,
+		e.ChannelURL,
+		e.VideoID,
+	}
+}
+
+// ActivityColumns is the schema of activities.parquet, the raw counted
+// watches written by -formats parquet; ActivityRow builds a matching row.
+var ActivityColumns = []ParquetColumn{
+	{Name: "time", Type: ParquetInt64, Converted: ParquetTimestampMillis},
+	{Name: "year", Type: ParquetInt32, Converted: ParquetNoConversion},
+	{Name: "month", Type: ParquetInt32, Converted: ParquetNoConversion},
+	{Name: "video_id", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "video_title", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "video_url", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "channel_name", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "channel_url", Type: ParquetByteArray, Converted: ParquetUTF8},
+}
+
+func ActivityRow(e aggregate.WatchEvent) []any {
+	return []any{
+		e.Time.UnixMilli(),
+		int32(e.Time.Year()),
+		int32(e.Time.Month()),
+		e.VideoID,
+		e.VideoTitle,
+		e.VideoURL,
+		e.ChannelName,
+		e.ChannelURL,
+	}
+}
+
+// ChannelStatColumns and VideoStatColumns are the schemas of the channel and
+// video list tables written by -formats parquet; they match the CSV columns.
+var ChannelStatColumns = []ParquetColumn{
+	{Name: "rank", Type: ParquetInt32, Converted: ParquetNoConversion},
+	{Name: "channel_name", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "channel_url", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+	// "new" or a signed rank change, so kept as a string like in the CSV.
+	{Name: "rank_delta", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+	{Name: "typical_hour", Type: ParquetInt32, Converted: ParquetNoConversion, Optional: true},
+	{Name: "channel_count", Type: ParquetInt32, Converted: ParquetNoConversion, Optional: true},
+	{Name: "first_watched", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+	{Name: "last_watched", Type: ParquetByteArray, Converted: ParquetUTF8, Optional: true},
+	{Name: "span_days", Type: ParquetInt32, Converted: ParquetNoConversion, Optional: true},
+}
+
+var VideoStatColumns = []ParquetColumn{
+	{Name: "rank", Type: ParquetInt32, Converted: ParquetNoConversion},
+	{Name: "video_title", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "video_url", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "channel_name", Type: ParquetByteArray, Converted: ParquetUTF8},
+	{Name: "watch_count", Type: ParquetInt32, Converted: ParquetNoConversion},
+}
+
+func channelStatsRows(stats []ChannelStat) ([]ParquetColumn, [][]any) {
+	rows := make([][]any, 0, len(stats))
+	for i, st := range stats {
+		var rankDelta, typicalHour, channelCount, firstWatched, lastWatched, spanDays any
+		if st.RankDelta != nil {
+			rankDelta = fmt.Sprint(st.RankDelta)
+		}
+		if st.TypicalHour != nil {
+			typicalHour = int32(*st.TypicalHour)
+		}
+		if st.ChannelCount > 0 {
+			channelCount = int32(st.ChannelCount)
+		}
+		if st.SpanDays != nil {
+			firstWatched, lastWatched, spanDays = st.FirstWatched, st.LastWatched, int32(*st.SpanDays)
+		}
+		rows = append(rows, []any{
+			int32(i + 1),
+			st.ChannelName,
+			st.ChannelURL,
+			int32(st.WatchCount),
+			rankDelta,
+			typicalHour,
+			channelCount,
+			firstWatched,
+			lastWatched,
+			spanDays,
+		})
+	}
+	return ChannelStatColumns, rows
+}
+
+func videoStatsRows(stats []VideoStat) ([]ParquetColumn, [][]any) {
+	rows := make([][]any, 0, len(stats))
+	for i, st := range stats {
+		rows = append(rows, []any{
+			int32(i + 1),
+			st.VideoTitle,
+			st.VideoURL,
+			st.ChannelName,
+			int32(st.WatchCount),
+		})
+	}
+	return VideoStatColumns, rows
+}
+
+// writeParquet writes a small table to path in one go.
+func writeParquet(path string, columns []ParquetColumn, rows [][]any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	pw, err := NewParquetWriter(path, columns)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := pw.WriteRow(row...); err != nil {
+			pw.abort()
+			return err
+		}
+	}
+	return pw.Close()
+}
+
+type parquetColumnMeta struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+type parquetRowGroup struct {
+	columns []parquetColumnMeta
+	rows    int64
+}
+
+type ParquetWriter struct {
+	path      string
+	f         *os.File
+	w         *bufio.Writer
+	offset    int64
+	columns   []ParquetColumn
+	buffers   [][]byte
+	defined   [][]bool // per optional column: which buffered rows are not null
+	rows      int64
+	totalRows int64
+	groups    []parquetRowGroup
+}
+
+// NewParquetWriter creates path (via a .tmp file renamed on close) with the
+// given flat schema.
+func NewParquetWriter(path string, columns []ParquetColumn) (*ParquetWriter, error) {
+	trackTemp(path + ".tmp")
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		untrackTemp(path + ".tmp")
+		return nil, err
+	}
+	pw := &ParquetWriter{
+		path:    path,
+		f:       f,
+		w:       bufio.NewWriterSize(f, 1024*1024),
+		columns: columns,
+		buffers: make([][]byte, len(columns)),
+		defined: make([][]bool, len(columns)),
+	}
+	if err := pw.write([]byte("PAR1")); err != nil {
+		pw.abort()
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WriteRow appends one row. Values must match the schema order and types:
+// bool for BOOLEAN, int32 for INT32, int64 for INT64 and string for
+// BYTE_ARRAY columns, or nil for a null in an Optional column.
+func (pw *ParquetWriter) WriteRow(values ...any) error {
+	if len(values) != len(pw.columns) {
+		return fmt.Errorf("parquet: got %d values for %d columns", len(values), len(pw.columns))
+	}
+	for i, v := range values {
+		if pw.columns[i].Optional {
+			pw.defined[i] = append(pw.defined[i], v != nil)
+			if v == nil {
+				continue
+			}
+		} else if v == nil {
+			return fmt.Errorf("parquet: column %s is required, got nil", pw.columns[i].Name)
+		}
+		buf := pw.buffers[i]
+		switch pw.columns[i].Type {
+		case ParquetBoolean:
+			b, ok := v.(bool)
+			if !ok {
+				return fmt.Errorf("parquet: column %s wants bool, got %T", pw.columns[i].Name, v)
+			}
+			// One byte per value until flushRowGroup packs them into bits.
+			var n byte
+			if b {
+				n = 1
+			}
+			buf = append(buf, n)
+		case ParquetInt32:
+			n, ok := v.(int32)
+			if !ok {
+				return fmt.Errorf("parquet: column %s wants int32, got %T", pw.columns[i].Name, v)
+			}
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(n))
+		case ParquetInt64:
+			n, ok := v.(int64)
+			if !ok {
+				return fmt.Errorf("parquet: column %s wants int64, got %T", pw.columns[i].Name, v)
+			}
+			buf = binary.LittleEndian.AppendUint64(buf, uint64(n))
+		case ParquetByteArray:
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("parquet: column %s wants string, got %T", pw.columns[i].Name, v)
+			}
+			buf = binary.LittleEndian.AppendUint32(buf, uint32(len(s)))
+			buf = append(buf, s...)
+		}
+		pw.buffers[i] = buf
+	}
+	pw.rows++
+	if pw.rows >= parquetRowGroupSize {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+// Close flushes any buffered rows, writes the footer and renames the file
+// into place.
+func (pw *ParquetWriter) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		pw.abort()
+		return err
+	}
+	footer := pw.fileMetaData()
+	var trailer [4]byte
+	binary.LittleEndian.PutUint32(trailer[:], uint32(len(footer)))
+	for _, b := range [][]byte{footer, trailer[:], []byte("PAR1")} {
+		if err := pw.write(b); err != nil {
+			pw.abort()
+			return err
+		}
+	}
+	if err := pw.w.Flush(); err != nil {
+		pw.abort()
+		return err
+	}
+	defer untrackTemp(pw.path + ".tmp")
+	if err := pw.f.Close(); err != nil {
+		_ = os.Remove(pw.path + ".tmp")
+		return err
+	}
+	return os.Rename(pw.path+".tmp", pw.path)
+}
+
+func (pw *ParquetWriter) abort() {
+	_ = pw.f.Close()
+	_ = os.Remove(pw.path + ".tmp")
+	untrackTemp(pw.path + ".tmp")
+}
+
+func (pw *ParquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+func (pw *ParquetWriter) flushRowGroup() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	rg := parquetRowGroup{rows: pw.rows}
+	for i, data := range pw.buffers {
+		buffered := data
+		if pw.columns[i].Type == ParquetBoolean {
+			data = packBooleans(data)
+		}
+		var levels []byte
+		if pw.columns[i].Optional {
+			levels = definitionLevels(pw.defined[i])
+			pw.defined[i] = pw.defined[i][:0]
+		}
+		header := pw.pageHeader(len(levels) + len(data))
+		meta := parquetColumnMeta{
+			offset: pw.offset,
+			size:   int64(len(header) + len(levels) + len(data)),
+			values: pw.rows,
+		}
+		if err := pw.write(header); err != nil {
+			return err
+		}
+		if err := pw.write(levels); err != nil {
+			return err
+		}
+		if err := pw.write(data); err != nil {
+			return err
+		}
+		rg.columns = append(rg.columns, meta)
+		pw.buffers[i] = buffered[:0]
+	}
+	pw.groups = append(pw.groups, rg)
+	pw.totalRows += pw.rows
+	pw.rows = 0
+	return nil
+}
+
+// definitionLevels encodes an optional column's levels (1 = value present)
+// as one bit-packed run of the RLE/bit-packing hybrid, length-prefixed as
+// v1 data pages want.
+func definitionLevels(defined []bool) []byte {
+	groups := (len(defined) + 7) / 8
+	run := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(run)+groups))
+	out = append(out, run...)
+	bits := make([]byte, groups)
+	for i, ok := range defined {
+		if ok {
+			bits[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(out, bits...)
+}
+
+// packBooleans bit-packs BOOLEAN values, buffered one byte each, as PLAIN
+// encoding wants them: eight to a byte, least significant bit first.
+func packBooleans(values []byte) []byte {
+	out := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		out[i/8] |= v << (i % 8)
+	}
+	return out
+}
+
+// pageHeader encodes a v1 DATA_PAGE header for a PLAIN, uncompressed page.
+// Columns are flat, so pages carry no repetition levels, and definition
+// levels only for optional columns.
+func (pw *ParquetWriter) pageHeader(size int) []byte {
+	var t thriftWriter
+	t.fieldI32(1, 0) // type = DATA_PAGE
+	t.fieldI32(2, int32(size))
+	t.fieldI32(3, int32(size))
+	t.structBegin(5) // data_page_header
+	t.fieldI32(1, int32(pw.rows))
+	t.fieldI32(2, 0) // encoding = PLAIN
+	t.fieldI32(3, 3) // definition_level_encoding = RLE
+	t.fieldI32(4, 3) // repetition_level_encoding = RLE
+	t.structEnd()
+	t.stop()
+	return t.buf
+}
+
+func (pw *ParquetWriter) fileMetaData() []byte {
+	var t thriftWriter
+	t.fieldI32(1, 1) // version
+
+	t.listBegin(2, thriftStruct, len(pw.columns)+1) // schema
+	t.elemBegin()
+	t.fieldString(4, "schema")
+	t.fieldI32(5, int32(len(pw.columns)))
+	t.elemEnd()
+	for _, c := range pw.columns {
+		t.elemBegin()
+		t.fieldI32(1, c.Type)
+		if c.Optional {
+			t.fieldI32(3, 1) // repetition_type = OPTIONAL
+		} else {
+			t.fieldI32(3, 0) // repetition_type = REQUIRED
+		}
+		t.fieldString(4, c.Name)
+		if c.Converted != ParquetNoConversion {
+			t.fieldI32(6, c.Converted)
+		}
+		t.elemEnd()
+	}
+
+	t.fieldI64(3, pw.totalRows)
+
+	t.listBegin(4, thriftStruct, len(pw.groups)) // row_groups
+	for _, rg := range pw.groups {
+		t.elemBegin()
+		t.listBegin(1, thriftStruct, len(rg.columns))
+		var total int64
+		for i, cm := range rg.columns {
+			c := pw.columns[i]
+			t.elemBegin()
+			t.fieldI64(2, cm.offset) // file_offset
+			t.structBegin(3)         // meta_data
+			t.fieldI32(1, c.Type)
+			t.listBegin(2, thriftI32, 2)
+			t.i32(0) // PLAIN
+			t.i32(3) // RLE
+			t.listBegin(3, thriftBinary, 1)
+			t.binary(c.Name)
+			t.fieldI32(4, 0) // codec = UNCOMPRESSED
+			t.fieldI64(5, cm.values)
+			t.fieldI64(6, cm.size)
+			t.fieldI64(7, cm.size)
+			t.fieldI64(9, cm.offset) // data_page_offset
+			t.structEnd()
+			t.elemEnd()
+			total += cm.size
+		}
+		t.fieldI64(2, total)
+		t.fieldI64(3, rg.rows)
+		t.elemEnd()
+	}
+
+	t.fieldString(6, "learning-go takeout parquet writer")
+	t.stop()
+	return t.buf
+}
+
+// Thrift compact protocol type ids.
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// thriftWriter is just enough of the Thrift compact protocol to encode
+// Parquet page headers and file metadata.
+type thriftWriter struct {
+	buf   []byte
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.buf = append(t.buf, byte(d)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf = binary.AppendUvarint(t.buf, v)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (t *thriftWriter) i32(v int32) { t.varint(zigzag(int64(v))) }
+
+func (t *thriftWriter) binary(s string) {
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *thriftWriter) fieldI32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.i32(v)
+}
+
+func (t *thriftWriter) fieldI64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) fieldString(id int16, s string) {
+	t.fieldHeader(id, thriftBinary)
+	t.binary(s)
+}
+
+func (t *thriftWriter) listBegin(id int16, elem byte, n int) {
+	t.fieldHeader(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+	} else {
+		t.buf = append(t.buf, 0xf0|elem)
+		t.varint(uint64(n))
+	}
+}
+
+// structBegin opens a struct-typed field; elemBegin opens a struct list element.
+func (t *thriftWriter) structBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftWriter) elemBegin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) structEnd() { t.elemEnd() }
+
+func (t *thriftWriter) elemEnd() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+func (t *thriftWriter) stop() { t.buf = append(t.buf, 0) }
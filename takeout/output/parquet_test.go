@@ -0,0 +1,352 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"example.com/hello/takeout/output"
+)
+
+// TestParquetReadBack writes every column type, nulls included, over more
+// than one row group, and reads the file back with a decoder written from
+// the Parquet format specification (Thrift compact protocol footer, data
+// page v1, PLAIN values and RLE/bit-packed definition levels) that shares
+// no code with the writer.
+func TestParquetReadBack(t *testing.T) {
+	columns := []output.ParquetColumn{
+		{Name: "flag", Type: output.ParquetBoolean, Converted: output.ParquetNoConversion},
+		{Name: "n32", Type: output.ParquetInt32, Converted: output.ParquetNoConversion},
+		{Name: "time", Type: output.ParquetInt64, Converted: output.ParquetTimestampMillis},
+		{Name: "text", Type: output.ParquetByteArray, Converted: output.ParquetUTF8},
+		{Name: "maybe_text", Type: output.ParquetByteArray, Converted: output.ParquetUTF8, Optional: true},
+		{Name: "maybe_day", Type: output.ParquetInt32, Converted: output.ParquetDate, Optional: true},
+	}
+	// The writer starts a row group every 65,536 rows.
+	const n = 70000
+	rows := make([][]any, n)
+	for i := range rows {
+		var maybeText, maybeDay any
+		if i%5 != 0 {
+			maybeText = fmt.Sprintf("x%d", i)
+		}
+		if i%7 != 0 {
+			maybeDay = int32(i)
+		}
+		rows[i] = []any{i%3 == 0, int32(i - n/2), int64(i) * 1_000_000, fmt.Sprintf("row %d é", i), maybeText, maybeDay}
+	}
+
+	path := filepath.Join(t.TempDir(), "t.parquet")
+	pw, err := output.NewParquetWriter(path, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := pw.WriteRow(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatal("file does not start and end with PAR1")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerAt := len(data) - 8 - footerLen
+	if footerAt < 4 {
+		t.Fatalf("footer length %d does not fit the file", footerLen)
+	}
+	meta := (&compactReader{t: t, b: data[footerAt : len(data)-8]}).readStruct()
+
+	schema := meta[2].([]any)
+	if got := schema[0].(map[int16]any)[5]; got != int64(len(columns)) {
+		t.Fatalf("root num_children = %v, want %d", got, len(columns))
+	}
+	for i, c := range columns {
+		el := schema[i+1].(map[int16]any)
+		repetition := int64(0)
+		if c.Optional {
+			repetition = 1
+		}
+		if string(el[4].([]byte)) != c.Name || el[1] != int64(c.Type) || el[3] != repetition {
+			t.Errorf("schema element %d = %v, want column %+v", i+1, el, c)
+		}
+		if converted, ok := el[6]; ok != (c.Converted != output.ParquetNoConversion) || ok && converted != int64(c.Converted) {
+			t.Errorf("column %s converted_type = %v, want %d", c.Name, converted, c.Converted)
+		}
+	}
+	if meta[3] != int64(n) {
+		t.Errorf("num_rows = %v, want %d", meta[3], n)
+	}
+
+	got := make([][]any, len(columns))
+	groups := meta[4].([]any)
+	if len(groups) < 2 {
+		t.Errorf("%d row groups, want more than one", len(groups))
+	}
+	for _, g := range groups {
+		rg := g.(map[int16]any)
+		chunks := rg[1].([]any)
+		if len(chunks) != len(columns) {
+			t.Fatalf("row group has %d column chunks, want %d", len(chunks), len(columns))
+		}
+		for i, ch := range chunks {
+			cmd := ch.(map[int16]any)[3].(map[int16]any)
+			if cmd[1] != int64(columns[i].Type) || cmd[4] != int64(0) {
+				t.Fatalf("column chunk %d: type %v, codec %v", i, cmd[1], cmd[4])
+			}
+			if path := cmd[3].([]any); len(path) != 1 || string(path[0].([]byte)) != columns[i].Name {
+				t.Fatalf("column chunk %d path = %q", i, path)
+			}
+			values := readColumnChunk(t, data, int(cmd[9].(int64)), int(cmd[5].(int64)), columns[i])
+			if int64(len(values)) != rg[3] {
+				t.Fatalf("column %s has %d values in a row group of %v rows", columns[i].Name, len(values), rg[3])
+			}
+			got[i] = append(got[i], values...)
+		}
+	}
+
+	for i, c := range columns {
+		want := make([]any, n)
+		for r := range rows {
+			want[r] = rows[r][i]
+		}
+		if !reflect.DeepEqual(got[i], want) {
+			for r := range want {
+				if r >= len(got[i]) || !reflect.DeepEqual(got[i][r], want[r]) {
+					t.Errorf("column %s row %d reads back differently (%d of %d values)", c.Name, r, len(got[i]), n)
+					break
+				}
+			}
+		}
+	}
+}
+
+// readColumnChunk decodes the data pages of a column chunk starting at off
+// into its values, nil for nulls.
+func readColumnChunk(t *testing.T, data []byte, off, numValues int, c output.ParquetColumn) []any {
+	t.Helper()
+	var values []any
+	for len(values) < numValues {
+		r := &compactReader{t: t, b: data[off:]}
+		header := r.readStruct()
+		if header[1] != int64(0) {
+			t.Fatalf("column %s: page type %v, want DATA_PAGE", c.Name, header[1])
+		}
+		size := int(header[3].(int64))
+		dp := header[5].(map[int16]any)
+		count := int(dp[1].(int64))
+		if dp[2] != int64(0) {
+			t.Fatalf("column %s: encoding %v, want PLAIN", c.Name, dp[2])
+		}
+		page := data[off+r.off : off+r.off+size]
+		off += r.off + size
+
+		defined := make([]bool, count)
+		for i := range defined {
+			defined[i] = true
+		}
+		if c.Optional {
+			levelsLen := int(binary.LittleEndian.Uint32(page))
+			levels := decodeHybrid(t, page[4:4+levelsLen], 1, count)
+			for i, l := range levels {
+				defined[i] = l == 1
+			}
+			page = page[4+levelsLen:]
+		}
+		present := 0
+		for _, d := range defined {
+			if d {
+				present++
+			}
+		}
+		plain := decodePlain(t, page, c.Type, present)
+		for _, d := range defined {
+			if d {
+				values = append(values, plain[0])
+				plain = plain[1:]
+			} else {
+				values = append(values, nil)
+			}
+		}
+	}
+	return values
+}
+
+// decodePlain decodes n PLAIN-encoded values of a physical type.
+func decodePlain(t *testing.T, b []byte, typ int32, n int) []any {
+	t.Helper()
+	out := make([]any, 0, n)
+	for i := 0; i < n; i++ {
+		switch typ {
+		case output.ParquetBoolean:
+			out = append(out, b[i/8]>>(i%8)&1 == 1)
+		case output.ParquetInt32:
+			out = append(out, int32(binary.LittleEndian.Uint32(b)))
+			b = b[4:]
+		case output.ParquetInt64:
+			out = append(out, int64(binary.LittleEndian.Uint64(b)))
+			b = b[8:]
+		case output.ParquetByteArray:
+			l := binary.LittleEndian.Uint32(b)
+			out = append(out, string(b[4:4+l]))
+			b = b[4+l:]
+		default:
+			t.Fatalf("physical type %d", typ)
+		}
+	}
+	return out
+}
+
+// decodeHybrid decodes n values of the RLE/bit-packed hybrid encoding.
+func decodeHybrid(t *testing.T, b []byte, width, n int) []int {
+	t.Helper()
+	var out []int
+	for len(out) < n {
+		header, k := binary.Uvarint(b)
+		if k <= 0 {
+			t.Fatal("bad hybrid run header")
+		}
+		b = b[k:]
+		if header&1 == 0 {
+			// RLE run: the value in ceil(width/8) little-endian bytes.
+			vb := (width + 7) / 8
+			v := 0
+			for i := vb - 1; i >= 0; i-- {
+				v = v<<8 | int(b[i])
+			}
+			b = b[vb:]
+			for i := uint64(0); i < header>>1; i++ {
+				out = append(out, v)
+			}
+			continue
+		}
+		// Bit-packed run of groups of 8 values, least significant bit first.
+		count := int(header>>1) * 8
+		for i := 0; i < count; i++ {
+			v := 0
+			for j := 0; j < width; j++ {
+				bit := i*width + j
+				v |= int(b[bit/8]>>(bit%8)&1) << j
+			}
+			out = append(out, v)
+		}
+		b = b[count*width/8:]
+	}
+	return out[:n]
+}
+
+// compactReader decodes the Thrift compact protocol into maps of field ID to
+// value: int64 for integers, []byte for binary, []any for lists and sets and
+// map[int16]any for structs.
+type compactReader struct {
+	t   *testing.T
+	b   []byte
+	off int
+}
+
+func (r *compactReader) byte() byte {
+	if r.off >= len(r.b) {
+		r.t.Fatal("thrift: unexpected end of input")
+	}
+	c := r.b[r.off]
+	r.off++
+	return c
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, k := binary.Uvarint(r.b[r.off:])
+	if k <= 0 {
+		r.t.Fatal("thrift: bad varint")
+	}
+	r.off += k
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) readStruct() map[int16]any {
+	fields := make(map[int16]any)
+	var id int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return fields
+		}
+		if delta := h >> 4; delta != 0 {
+			id += int16(delta)
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(h & 0x0f)
+	}
+}
+
+func (r *compactReader) value(typ byte) any {
+	switch typ {
+	case 1:
+		return true
+	case 2:
+		return false
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.b[r.off:]))
+		r.off += 8
+		return v
+	case 8:
+		n := int(r.uvarint())
+		v := bytes.Clone(r.b[r.off : r.off+n])
+		r.off += n
+		return v
+	case 9, 10:
+		h := r.byte()
+		n, elem := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			if elem == 1 || elem == 2 {
+				list[i] = r.byte() == 1
+				continue
+			}
+			list[i] = r.value(elem)
+		}
+		return list
+	case 11:
+		n := int(r.uvarint())
+		m := make(map[any]any, n)
+		if n > 0 {
+			kv := r.byte()
+			for i := 0; i < n; i++ {
+				k := r.value(kv >> 4)
+				if b, ok := k.([]byte); ok {
+					k = string(b)
+				}
+				m[k] = r.value(kv & 0x0f)
+			}
+		}
+		return m
+	case 12:
+		return r.readStruct()
+	}
+	r.t.Fatalf("thrift: unknown type %d", typ)
+	return nil
+}
@@ -0,0 +1,61 @@
+package output
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// PlannedFile is a file a dry run wrote to a scratch directory, compared with
+// the file of the same path in the real output directory: Status is "new",
+// "changed" or "unchanged".
+type PlannedFile struct {
+	ManifestFile
+	Status string
+}
+
+// PlanOutputs describes the files written to scratch, manifest included, as
+// they would land in dir, and lists the files already in dir that the run
+// would leave in place untouched.
+func PlanOutputs(scratch, dir string) (files []PlannedFile, kept []string, err error) {
+	names, err := listFiles(scratch, func(string) bool { return false })
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := os.Stat(filepath.Join(scratch, ManifestName)); err == nil {
+		names = append([]string{ManifestName}, names...)
+	}
+	planned := make(map[string]bool, len(names))
+	for _, name := range names {
+		planned[name] = true
+		f, err := describeFile(scratch, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		p := PlannedFile{ManifestFile: f, Status: "changed"}
+		switch old, err := describeFile(dir, name); {
+		case errors.Is(err, fs.ErrNotExist):
+			p.Status = "new"
+		case err != nil:
+			return nil, nil, err
+		case old.SHA256 == f.SHA256:
+			p.Status = "unchanged"
+		}
+		files = append(files, p)
+	}
+
+	existing, err := listFiles(dir, func(string) bool { return false })
+	if errors.Is(err, fs.ErrNotExist) {
+		return files, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range existing {
+		if !planned[name] {
+			kept = append(kept, name)
+		}
+	}
+	return files, kept, nil
+}
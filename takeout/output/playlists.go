@@ -0,0 +1,119 @@
+package output
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// PlaylistStats is how many of a playlist's videos were watched.
+type PlaylistStats struct {
+	Name       string `json:"name"`
+	WatchLater bool   `json:"watch_later,omitempty"`
+	// Videos counts distinct videos, and WatchedVideos those watched at
+	// least once.
+	Videos            int     `json:"videos"`
+	WatchedVideos     int     `json:"watched_videos"`
+	CompletionPercent float64 `json:"completion_percent"`
+	FirstAdded        string  `json:"first_added,omitempty"`
+	LastAdded         string  `json:"last_added,omitempty"`
+}
+
+// UnwatchedVideo is a Watch Later video that was never watched.
+type UnwatchedVideo struct {
+	VideoID string `json:"video_id"`
+	URL     string `json:"url"`
+	Added   string `json:"added,omitempty"`
+}
+
+// writePlaylists writes playlists.json: the videos of each playlist joined
+// to the watch history on video ID, with the unwatched Watch Later videos.
+func (w *Writer) writePlaylists(agg *aggregate.Aggregator) error {
+	watched := make(map[string]bool)
+	for key := range agg.AllTimeVideoCounts {
+		if id := parser.VideoIDFromURL(agg.VideoInfo[key].URL); id != "" {
+			watched[id] = true
+		}
+	}
+
+	playlists := make([]PlaylistStats, 0, len(w.Playlists))
+	var watchLater *PlaylistStats
+	type unwatchedVideo struct {
+		UnwatchedVideo
+		added time.Time
+	}
+	var unwatched []unwatchedVideo
+	for _, pl := range w.Playlists {
+		st := PlaylistStats{Name: pl.Name, WatchLater: pl.IsWatchLater()}
+		seen := make(map[string]bool, len(pl.Videos))
+		var first, last time.Time
+		for _, v := range pl.Videos {
+			if seen[v.VideoID] {
+				continue
+			}
+			seen[v.VideoID] = true
+			st.Videos++
+			if !v.Added.IsZero() {
+				if first.IsZero() || v.Added.Before(first) {
+					first = v.Added
+				}
+				if v.Added.After(last) {
+					last = v.Added
+				}
+			}
+			switch {
+			case watched[v.VideoID]:
+				st.WatchedVideos++
+			case st.WatchLater:
+				u := UnwatchedVideo{VideoID: v.VideoID, URL: "https://www.youtube.com/watch?v=" + v.VideoID}
+				if !v.Added.IsZero() {
+					u.Added = v.Added.In(agg.Options().Location).Format(time.RFC3339)
+				}
+				unwatched = append(unwatched, unwatchedVideo{u, v.Added})
+			}
+		}
+		st.CompletionPercent = sharePercent(st.WatchedVideos, st.Videos)
+		if !first.IsZero() {
+			loc := agg.Options().Location
+			st.FirstAdded = first.In(loc).Format(time.RFC3339)
+			st.LastAdded = last.In(loc).Format(time.RFC3339)
+		}
+		playlists = append(playlists, st)
+		if st.WatchLater && watchLater == nil {
+			watchLater = &st
+		}
+	}
+
+	// The oldest unwatched videos first, those without a time last.
+	sort.SliceStable(unwatched, func(i, j int) bool {
+		a, b := unwatched[i].added, unwatched[j].added
+		if a.IsZero() != b.IsZero() {
+			return b.IsZero()
+		}
+		return a.Before(b)
+	})
+	oldest := make([]UnwatchedVideo, 0, len(unwatched))
+	for _, u := range limitList(unwatched, w.AllTimeTop) {
+		oldest = append(oldest, u.UnwatchedVideo)
+	}
+
+	payload := struct {
+		WatchLater          *PlaylistStats   `json:"watch_later"`
+		WatchLaterUnwatched []UnwatchedVideo `json:"watch_later_unwatched"`
+		Playlists           []PlaylistStats  `json:"playlists"`
+		TopN                int              `json:"top_n"`
+		Sort                string           `json:"sort"`
+		Notes               string           `json:"notes"`
+	}{
+		WatchLater:          watchLater,
+		WatchLaterUnwatched: oldest,
+		Playlists:           playlists,
+		TopN:                w.AllTimeTop,
+		Sort:                "playlists by name asc ignoring case; watch_later_unwatched by added asc",
+		Notes:               "Playlist videos are joined to the counted watches in the -start..-end range by video ID, whether watched before or after being added; completion_percent is watched_videos / videos. Watch Later is the playlist named 'Watch later', as English exports name it, and is null without one. Takeout does not export the videos of every playlist, and videos removed from a playlist are not listed.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "playlists.json"), payload)
+}
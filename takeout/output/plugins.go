@@ -0,0 +1,40 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// writePlugins finalizes w.Plugins in name order and writes every output
+// they return to <name>.json in w.Dir.
+func (w *Writer) writePlugins(agg *aggregate.Aggregator) error {
+	names := make([]string, 0, len(w.Plugins))
+	for name := range w.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := w.Plugins[name]
+		if err := p.Finalize(agg); err != nil {
+			return fmt.Errorf("plugin %s: %w", name, err)
+		}
+		outputs := p.Outputs()
+		files := make([]string, 0, len(outputs))
+		for file := range outputs {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			if !filepath.IsLocal(file) {
+				return fmt.Errorf("plugin %s: output name %q is not a file name in the output directory", name, file)
+			}
+			if err := w.writeJSON(filepath.Join(w.Dir, file+".json"), outputs[file]); err != nil {
+				return fmt.Errorf("plugin %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
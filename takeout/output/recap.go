@@ -0,0 +1,118 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+type MonthCount struct {
+	Month string `json:"month"`
+	Count int    `json:"count"`
+}
+
+type Recap struct {
+	Year           int           `json:"year"`
+	TotalVideos    int           `json:"total_videos_watched"`
+	TopChannels    []ChannelStat `json:"top_channels"`
+	BusiestDay     *DayCount     `json:"busiest_day,omitempty"`
+	FavoriteMonth  *MonthCount   `json:"favorite_month,omitempty"`
+	ActiveDays     int           `json:"active_days"`
+	EstimatedHours float64       `json:"estimated_hours"`
+	FunFact        string        `json:"fun_fact"`
+	Notes          string        `json:"notes"`
+}
+
+// recapMinutesPerVideo is the assumed average video length used for the
+// recap's watch-time estimate.
+const recapMinutesPerVideo = 10
+
+// BuildRecap assembles the year-in-review payload from the year's sorted
+// channel stats (already annotated with rank deltas) and the daily counts.
+// wt is the year's watch-time estimate when there are durations to make one
+// (see Writer.watchTime), or nil.
+func BuildRecap(year int, sorted []ChannelStat, agg *aggregate.Aggregator, wt *WatchTime) Recap {
+	r := Recap{
+		Year:        year,
+		TotalVideos: agg.YearTotals[year],
+		TopChannels: sorted,
+	}
+	if len(r.TopChannels) > 5 {
+		r.TopChannels = r.TopChannels[:5]
+	}
+
+	r.BusiestDay = busiestDay(year, agg)
+	var months [12]int
+	prefix := fmt.Sprintf("%04d-", year)
+	for day, n := range agg.DayCounts {
+		if !strings.HasPrefix(day, prefix) {
+			continue
+		}
+		r.ActiveDays++
+		d, err := time.Parse(time.DateOnly, day)
+		if err == nil {
+			months[d.Month()-1] += n
+		}
+	}
+	for m, n := range months {
+		if n > 0 && (r.FavoriteMonth == nil || n > r.FavoriteMonth.Count) {
+			r.FavoriteMonth = &MonthCount{Month: time.Month(m + 1).String(), Count: n}
+		}
+	}
+
+	var basis string
+	r.EstimatedHours, basis = recapHours(r.TotalVideos, wt)
+	r.FunFact = funFact(r.EstimatedHours)
+	r.Notes = "Days and months follow the -tz time zone. " + basis + " rank_delta compares with the prior year's ranks."
+	return r
+}
+
+// recapHours estimates the hours of total watches, from the durations wt
+// was estimated with if there are any and recapMinutesPerVideo for the
+// watches without one, and says which basis it used.
+func recapHours(total int, wt *WatchTime) (float64, string) {
+	if wt == nil {
+		return float64(total*recapMinutesPerVideo) / 60, fmt.Sprintf("estimated_hours assumes %d minutes per video.", recapMinutesPerVideo)
+	}
+	rest := max(total-wt.WatchesWithDuration-wt.WatchesWithDefault, 0)
+	hours := roundHours(wt.EstimatedHours + float64(rest*recapMinutesPerVideo)/60)
+	return hours, fmt.Sprintf("estimated_hours uses the durations of watch_time_estimates.json: %d watches with their video's duration, %d with -default-duration and %d at %d minutes each.", wt.WatchesWithDuration, wt.WatchesWithDefault, rest, recapMinutesPerVideo)
+}
+
+// busiestDay returns the year's day with the most watches, the earliest on
+// a tie, or nil for a year without any.
+func busiestDay(year int, agg *aggregate.Aggregator) *DayCount {
+	var best *DayCount
+	prefix := fmt.Sprintf("%04d-", year)
+	for day, n := range agg.DayCounts {
+		if !strings.HasPrefix(day, prefix) {
+			continue
+		}
+		if best == nil || n > best.Count || (n == best.Count && day < best.Date) {
+			best = &DayCount{Date: day, Count: n}
+		}
+	}
+	return best
+}
+
+func funFact(hours float64) string {
+	const (
+		flightNYCToLondon = 7.0  // hours
+		lotrExtended      = 11.4 // hours, all three extended editions
+	)
+	switch {
+	case hours >= lotrExtended*2:
+		return fmt.Sprintf("You watched enough to sit through the extended Lord of the Rings trilogy %.0f times.", hours/lotrExtended)
+	case hours >= flightNYCToLondon:
+		return fmt.Sprintf("You watched enough to fly from New York to London %.0f times.", hours/flightNYCToLondon)
+	default:
+		return fmt.Sprintf("You watched about %.1f hours of YouTube.", hours)
+	}
+}
@@ -0,0 +1,87 @@
+package output
+
+import (
+	"math"
+	"path/filepath"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// ReclaimedYear is what blocking the -what-if-block channels would have
+// saved in a year or all time. The hours are only set with durations to
+// estimate them with (see watchTime).
+type ReclaimedYear struct {
+	Year             int     `json:"year,omitempty"`
+	TotalVideos      int     `json:"total_videos_watched"`
+	BlockedWatches   int     `json:"blocked_watches"`
+	RemainingWatches int     `json:"remaining_watches"`
+	BlockedPercent   float64 `json:"blocked_percent"`
+	// EstimatedHours is the estimated watch time of every watch, and
+	// ReclaimedHours that of the blocked ones.
+	EstimatedHours  *float64      `json:"estimated_hours,omitempty"`
+	ReclaimedHours  *float64      `json:"estimated_hours_reclaimed,omitempty"`
+	BlockedChannels int           `json:"blocked_channels"`
+	TopChannels     []ChannelStat `json:"top_blocked_channels"`
+}
+
+// reclaimedYear splits a year's or all time's counts into the channels
+// w.Blocklist matches and the rest.
+func (w *Writer) reclaimedYear(counts map[aggregate.ChannelKey]int, videoCounts map[string]int, total int, info map[string]aggregate.VideoInfo) ReclaimedYear {
+	blocked := make(map[aggregate.ChannelKey]int)
+	for k, n := range counts {
+		if w.Blocklist.Match(k) {
+			blocked[k] = n
+		}
+	}
+	stats := aggregate.StatsFromMap(blocked)
+	aggregate.SortStatsByCountThenName(stats)
+	ry := ReclaimedYear{TotalVideos: total, BlockedChannels: len(stats), TopChannels: limitList(stats, w.TopN)}
+	for _, st := range stats {
+		ry.BlockedWatches += st.WatchCount
+	}
+	ry.RemainingWatches = total - ry.BlockedWatches
+	if total > 0 {
+		ry.BlockedPercent = math.Round(float64(ry.BlockedWatches)/float64(total)*1000) / 10
+	}
+
+	if w.estimatesHours() {
+		blockedVideos := make(map[string]int)
+		for vk, n := range videoCounts {
+			if w.Blocklist.Match(info[vk].Channel) {
+				blockedVideos[vk] = n
+			}
+		}
+		all := w.watchTime(videoCounts, total, info, 0).EstimatedHours
+		reclaimed := w.watchTime(blockedVideos, ry.BlockedWatches, info, 0).EstimatedHours
+		ry.EstimatedHours, ry.ReclaimedHours = &all, &reclaimed
+	}
+	return ry
+}
+
+// writeReclaimed writes reclaimed_time.json: the watches, and with
+// durations the hours, that blocking w.Blocklist's channels would have
+// saved per year and all time.
+func (w *Writer) writeReclaimed(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	years := make([]ReclaimedYear, 0, opts.EndYear-opts.StartYear+1)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		ry := w.reclaimedYear(agg.YearCounts[y], agg.YearVideoCounts[y], agg.YearTotals[y], agg.VideoInfo)
+		ry.Year = y
+		years = append(years, ry)
+	}
+
+	payload := struct {
+		Years   []ReclaimedYear `json:"years"`
+		AllTime ReclaimedYear   `json:"all_time"`
+		TopN    int             `json:"top_n"`
+		Sort    string          `json:"sort"`
+		Notes   string          `json:"notes"`
+	}{
+		Years:   years,
+		AllTime: w.reclaimedYear(agg.AllTimeCounts, agg.AllTimeVideoCounts, agg.TotalAllYears, agg.VideoInfo),
+		TopN:    w.TopN,
+		Sort:    "top_blocked_channels by watch_count desc, channel_name asc",
+		Notes:   "A what-if: the watches of the channels matching an entry of the -what-if-block file, by name, URL or /regexp/, as if they had been blocked, and the watches that would remain. Nothing else is assumed to change, such as watching other videos instead. The hours are only given with -durations, -yt-api-key or -default-duration and, like watch_time_estimates.json, assume every watch covers the whole video.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "reclaimed_time.json"), payload)
+}
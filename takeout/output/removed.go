@@ -0,0 +1,75 @@
+package output
+
+import (
+	"path/filepath"
+	"sort"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type RemovedYear struct {
+	Removed  int `json:"removed"`
+	Untitled int `json:"untitled"`
+	Total    int `json:"total"`
+}
+
+type RemovedVideo struct {
+	VideoURL   string `json:"video_url"`
+	WatchCount int    `json:"watch_count"`
+}
+
+// removedSkipped is how many of n removed-video watches were left out of the
+// counts.
+func removedSkipped(opts aggregate.Options, n int) int {
+	if opts.SkipRemoved {
+		return n
+	}
+	return 0
+}
+
+// writeRemoved writes removed_videos.json.
+func (w *Writer) writeRemoved(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	years := make(map[int]RemovedYear)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		years[y] = RemovedYear{
+			Removed:  agg.YearRemoved[y] - agg.YearUntitled[y],
+			Untitled: agg.YearUntitled[y],
+			Total:    agg.YearRemoved[y],
+		}
+	}
+
+	videos := make([]RemovedVideo, 0, len(agg.RemovedVideoCounts))
+	for u, n := range agg.RemovedVideoCounts {
+		videos = append(videos, RemovedVideo{VideoURL: u, WatchCount: n})
+	}
+	sort.Slice(videos, func(i, j int) bool {
+		if videos[i].WatchCount == videos[j].WatchCount {
+			return videos[i].VideoURL < videos[j].VideoURL
+		}
+		return videos[i].WatchCount > videos[j].WatchCount
+	})
+	unique := len(videos)
+	if w.AllTimeTop > 0 && len(videos) > w.AllTimeTop {
+		videos = videos[:w.AllTimeTop]
+	}
+
+	payload := struct {
+		TotalRemoved   int                 `json:"total_removed_watches"`
+		Excluded       bool                `json:"excluded_from_counts"`
+		Years          map[int]RemovedYear `json:"years"`
+		UniqueUntitled int                 `json:"unique_untitled_videos"`
+		Untitled       []RemovedVideo      `json:"untitled_videos"`
+		Sort           string              `json:"sort"`
+		Notes          string              `json:"notes"`
+	}{
+		TotalRemoved:   agg.TotalRemoved,
+		Excluded:       opts.SkipRemoved,
+		Years:          years,
+		UniqueUntitled: unique,
+		Untitled:       videos,
+		Sort:           "watch_count desc, video_url asc",
+		Notes:          "removed counts Takeout's 'Watched a video that has been removed' placeholders, which carry no title, channel or URL. untitled counts watches whose title is only the video URL, which Takeout uses for some private or deleted videos; their URLs are listed in untitled_videos. When excluded_from_counts is false both are counted under '(unknown channel)' or their channel.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "removed_videos.json"), payload)
+}
@@ -0,0 +1,299 @@
+package output
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// The HTML report is a single file with inline CSS and SVG, so it can be
+// opened offline or mailed around. Chart geometry is computed here and the
+// template only places the precomputed shapes.
+
+type reportData struct {
+	Generated string
+	TimeZone  string
+	StartYear int
+	EndYear   int
+	Total     int
+	Channels  int
+	// Hours is the estimated watch time, or "" without durations.
+	Hours   string
+	Totals  reportLine
+	Years   []reportYear
+	Heatmap reportHeatmap
+}
+
+type reportLine struct {
+	Width, Height int
+	AxisY, LabelY int
+	Points        string
+	Dots          []reportDot
+}
+
+type reportDot struct {
+	X, Y  float64
+	Label string
+	Count int
+}
+
+type reportYear struct {
+	Year   int
+	Total  int
+	Height int
+	Bars   []reportBar
+}
+
+type reportBar struct {
+	Y     int
+	Width float64
+	Label string
+	// URL is the channel's page; it is empty for a channel without one.
+	URL   string
+	Count int
+}
+
+type reportHeatmap struct {
+	Width, Height int
+	Days          []reportDay
+	Hours         []reportDot
+	Cells         []reportCell
+}
+
+type reportDay struct {
+	Y    int
+	Name string
+}
+
+type reportCell struct {
+	X, Y    int
+	Opacity float64
+	Title   string
+}
+
+const (
+	reportBarHeight  = 22
+	reportBarMax     = 420.0
+	reportCellSize   = 22
+	reportHeatLeft   = 40
+	reportLineWidth  = 640
+	reportLineHeight = 220
+	reportLinePad    = 40
+)
+
+// writeReport renders report.html from the per-year results already built by
+// Write.
+func (w *Writer) writeReport(agg *aggregate.Aggregator, years map[int]YearResult) error {
+	opts := agg.Options()
+	data := reportData{
+		Generated: time.Now().In(opts.Location).Format("2006-01-02 15:04 MST"),
+		TimeZone:  opts.Location.String(),
+		StartYear: opts.StartYear,
+		EndYear:   opts.EndYear,
+		Total:     agg.TotalAllYears,
+		Channels:  len(agg.AllTimeCounts),
+	}
+	if w.estimatesHours() {
+		wt := w.watchTime(agg.AllTimeVideoCounts, agg.TotalAllYears, agg.VideoInfo, 0)
+		data.Hours = fmt.Sprintf("%.0f", wt.EstimatedHours)
+	}
+
+	// Yearly totals line chart.
+	maxTotal := 1
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		maxTotal = max(maxTotal, agg.YearTotals[y])
+	}
+	data.Totals = reportLine{
+		Width:  reportLineWidth,
+		Height: reportLineHeight,
+		AxisY:  reportLineHeight - reportLinePad,
+		LabelY: reportLineHeight - reportLinePad/2,
+	}
+	n := opts.EndYear - opts.StartYear
+	var points []string
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		x := float64(reportLinePad)
+		if n > 0 {
+			x += float64(y-opts.StartYear) * float64(reportLineWidth-2*reportLinePad) / float64(n)
+		}
+		py := float64(reportLineHeight-reportLinePad) - float64(agg.YearTotals[y])*float64(reportLineHeight-2*reportLinePad)/float64(maxTotal)
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, py))
+		data.Totals.Dots = append(data.Totals.Dots, reportDot{X: x, Y: py, Label: fmt.Sprint(y), Count: agg.YearTotals[y]})
+	}
+	data.Totals.Points = strings.Join(points, " ")
+
+	// Top channels per year, newest first. Redacted names are no use to
+	// search for.
+	links := reportLinks{search: !agg.Redacted()}
+	for y := opts.EndYear; y >= opts.StartYear; y-- {
+		res := years[y]
+		if res.TotalVideos == 0 {
+			continue
+		}
+		ry := reportYear{Year: y, Total: res.TotalVideos}
+		top := 1
+		if len(res.TopChannels) > 0 {
+			top = res.TopChannels[0].WatchCount
+		}
+		for i, st := range res.TopChannels {
+			ry.Bars = append(ry.Bars, reportBar{
+				Y:     i * reportBarHeight,
+				Width: max(1, float64(st.WatchCount)*reportBarMax/float64(top)),
+				Label: st.ChannelName,
+				URL:   links.channel(st.ChannelName, st.ChannelURL),
+				Count: st.WatchCount,
+			})
+		}
+		ry.Height = len(ry.Bars) * reportBarHeight
+		data.Years = append(data.Years, ry)
+	}
+
+	// Day-of-week by hour heatmap.
+	maxCell := 1
+	for d := range agg.WeekdayHours {
+		for h := range agg.WeekdayHours[d] {
+			maxCell = max(maxCell, agg.WeekdayHours[d][h])
+		}
+	}
+	hm := &data.Heatmap
+	hm.Width = reportHeatLeft + 24*reportCellSize
+	hm.Height = 7*reportCellSize + 20
+	for d := range agg.WeekdayHours {
+		name := time.Weekday(d).String()[:3]
+		hm.Days = append(hm.Days, reportDay{Y: d*reportCellSize + reportCellSize*2/3, Name: name})
+		for h, c := range agg.WeekdayHours[d] {
+			hm.Cells = append(hm.Cells, reportCell{
+				X:       reportHeatLeft + h*reportCellSize,
+				Y:       d * reportCellSize,
+				Opacity: 0.05 + 0.95*float64(c)/float64(maxCell),
+				Title:   fmt.Sprintf("%s %02d:00 - %d watches", name, h, c),
+			})
+		}
+	}
+	for h := 0; h < 24; h += 3 {
+		hm.Hours = append(hm.Hours, reportDot{X: float64(reportHeatLeft + h*reportCellSize), Y: float64(7*reportCellSize + 14), Label: fmt.Sprintf("%02d", h)})
+	}
+
+	t := reportTemplate
+	if w.ReportTemplate != "" {
+		var err error
+		if t, err = template.New("report").Parse(w.ReportTemplate); err != nil {
+			return err
+		}
+	}
+	return writeTemplate(filepath.Join(w.Dir, "report.html"), t, data)
+}
+
+// templateExecutor is an html/template or text/template Template.
+type templateExecutor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// writeTemplate renders t to path atomically, like WriteJSON.
+func writeTemplate(path string, t templateExecutor, data any) error {
+	tmp := path + ".tmp"
+	trackTemp(tmp)
+	defer untrackTemp(tmp)
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	if err := t.Execute(bw, data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>YouTube watch history {{.StartYear}}–{{.EndYear}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 760px; margin: 2em auto; padding: 0 1em; color: #222; }
+h1 { margin-bottom: 0.2em; }
+.sub { color: #666; margin-top: 0; }
+.stats { display: flex; gap: 2em; margin: 1.5em 0; }
+.stats div { font-size: 0.9em; color: #666; }
+.stats b { display: block; font-size: 2em; color: #c00; }
+svg text { font-size: 12px; fill: #333; }
+svg a:hover text { text-decoration: underline; }
+.bar { fill: #c00; }
+.line { fill: none; stroke: #c00; stroke-width: 2; }
+.dot { fill: #c00; }
+.cell { fill: #c00; }
+.axis { stroke: #ccc; }
+</style>
+</head>
+<body>
+<h1>Your YouTube, {{.StartYear}}–{{.EndYear}}</h1>
+<p class="sub">Generated {{.Generated}}; times in {{.TimeZone}}.</p>
+
+<div class="stats">
+<div><b>{{.Total}}</b>videos watched</div>
+<div><b>{{.Channels}}</b>channels</div>
+{{- with .Hours}}
+<div><b>{{.}}</b>hours (estimated)</div>
+{{- end}}
+</div>
+
+<h2>Videos per year</h2>
+<svg width="{{.Totals.Width}}" height="{{.Totals.Height}}" viewBox="0 0 {{.Totals.Width}} {{.Totals.Height}}">
+<line class="axis" x1="0" y1="{{.Totals.AxisY}}" x2="{{.Totals.Width}}" y2="{{.Totals.AxisY}}"/>
+<polyline class="line" points="{{.Totals.Points}}"/>
+{{- range .Totals.Dots}}
+<circle class="dot" cx="{{printf "%.1f" .X}}" cy="{{printf "%.1f" .Y}}" r="4"><title>{{.Label}}: {{.Count}}</title></circle>
+<text x="{{printf "%.1f" .X}}" y="{{$.Totals.LabelY}}" text-anchor="middle">{{.Label}}</text>
+<text x="{{printf "%.1f" .X}}" y="{{printf "%.1f" .Y}}" dy="-10" text-anchor="middle">{{.Count}}</text>
+{{- end}}
+</svg>
+
+<h2>When you watch</h2>
+<svg width="{{.Heatmap.Width}}" height="{{.Heatmap.Height}}" viewBox="0 0 {{.Heatmap.Width}} {{.Heatmap.Height}}">
+{{- range .Heatmap.Days}}
+<text x="0" y="{{.Y}}">{{.Name}}</text>
+{{- end}}
+{{- range .Heatmap.Cells}}
+<rect class="cell" x="{{.X}}" y="{{.Y}}" width="20" height="20" fill-opacity="{{printf "%.2f" .Opacity}}"><title>{{.Title}}</title></rect>
+{{- end}}
+{{- range .Heatmap.Hours}}
+<text x="{{printf "%.0f" .X}}" y="{{printf "%.0f" .Y}}">{{.Label}}</text>
+{{- end}}
+</svg>
+
+{{- range .Years}}
+<h2>{{.Year}} <small>({{.Total}} videos)</small></h2>
+<svg width="720" height="{{.Height}}" viewBox="0 0 720 {{.Height}}">
+{{- range .Bars}}
+<rect class="bar" x="0" y="{{.Y}}" width="{{printf "%.1f" .Width}}" height="18"><title>{{.Label}}: {{.Count}}</title></rect>
+{{- if .URL}}
+<a href="{{.URL}}"><text x="{{printf "%.1f" .Width}}" y="{{.Y}}" dx="6" dy="13">{{.Label}} ({{.Count}})</text></a>
+{{- else}}
+<text x="{{printf "%.1f" .Width}}" y="{{.Y}}" dx="6" dy="13">{{.Label}} ({{.Count}})</text>
+{{- end}}
+{{- end}}
+</svg>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,77 @@
+package output
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type RollingWindow struct {
+	Start          string        `json:"start"`
+	End            string        `json:"end"`
+	TotalVideos    int           `json:"total_videos_watched"`
+	UniqueChannels int           `json:"unique_channels"`
+	TopChannels    []ChannelStat `json:"top_channels"`
+}
+
+// writeRolling writes rolling_top_channels.json: the top channels of the
+// RollingDays days ending on the last day of each month, from the first
+// month with a counted watch to the last.
+func (w *Writer) writeRolling(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	days := make([]string, 0, len(agg.DayChannelCounts))
+	for d := range agg.DayChannelCounts {
+		days = append(days, d)
+	}
+	sort.Strings(days)
+
+	windows := []RollingWindow{}
+	if len(days) > 0 {
+		first, _ := time.Parse(time.DateOnly, days[0])
+		last, _ := time.Parse(time.DateOnly, days[len(days)-1])
+		var prevRanks map[aggregate.ChannelKey]int
+		for m := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(last); m = m.AddDate(0, 1, 0) {
+			end := m.AddDate(0, 1, -1)
+			start := end.AddDate(0, 0, 1-opts.RollingDays)
+			counts := make(map[aggregate.ChannelKey]int)
+			total := 0
+			for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+				for k, n := range agg.DayChannelCounts[d.Format(time.DateOnly)] {
+					counts[k] += n
+					total += n
+				}
+			}
+
+			stats := aggregate.StatsFromMap(counts)
+			aggregate.SortStatsByCountThenName(stats)
+			prevRanks = aggregate.AnnotateRankDeltas(stats, prevRanks)
+			windows = append(windows, RollingWindow{
+				Start:          start.Format(time.DateOnly),
+				End:            end.Format(time.DateOnly),
+				TotalVideos:    total,
+				UniqueChannels: len(counts),
+				TopChannels:    limitList(stats, w.TopN),
+			})
+		}
+	}
+
+	payload := struct {
+		WindowDays int             `json:"window_days"`
+		Step       string          `json:"step"`
+		TopN       int             `json:"top_n"`
+		Windows    []RollingWindow `json:"windows"`
+		Sort       string          `json:"sort"`
+		Notes      string          `json:"notes"`
+	}{
+		WindowDays: opts.RollingDays,
+		Step:       "month",
+		TopN:       w.TopN,
+		Windows:    windows,
+		Sort:       "watch_count desc, channel_name asc",
+		Notes:      "Each window covers the window_days days up to and including the last day of a month, in the -tz time zone, so consecutive windows overlap. rank_delta compares with the previous window: positive means the channel moved up, \"new\" that it had no watches in it.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "rolling_top_channels.json"), payload)
+}
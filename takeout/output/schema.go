@@ -0,0 +1,270 @@
+package output
+
+import (
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaDir is the directory within the output directory that -emit-schemas
+// writes the JSON Schemas of the JSON outputs to.
+const SchemaDir = "schemas"
+
+// yearInName matches the years and periods in output file names such as
+// top_channels_2023.json or top_channels_2023-Q1.json.
+var yearInName = regexp.MustCompile(`\d{4}(-[0-9A-Za-z]+)?`)
+
+// writeJSON writes v to path like WriteJSON, with w's file writers, noting
+// its type for WriteSchemas when Schemas is set.
+func (w *Writer) writeJSON(path string, v any) error {
+	b, err := encodeJSON(v)
+	if err != nil {
+		return err
+	}
+	w.noteSchema(path, reflect.TypeOf(v))
+	return w.files.do(func() error { return writeEncoded(path, b) })
+}
+
+// writeChannelList is WriteChannelList in w's formats, with w's file
+// writers, noting the JSON file's type for WriteSchemas.
+func (w *Writer) writeChannelList(base string, payload any, stats []ChannelStat) error {
+	if w.Formats.JSON {
+		w.noteSchema(base+".json", reflect.TypeOf(payload))
+	}
+	return writeTable(w.files, base, w.Formats, payload,
+		func() [][]string { return channelStatsRecords(stats) },
+		func() ([]ParquetColumn, [][]any) { return channelStatsRows(stats) })
+}
+
+// writeVideoList is writeChannelList for video stats.
+func (w *Writer) writeVideoList(base string, payload any, stats []VideoStat) error {
+	if w.Formats.JSON {
+		w.noteSchema(base+".json", reflect.TypeOf(payload))
+	}
+	return writeTable(w.files, base, w.Formats, payload,
+		func() [][]string { return videoStatsRecords(stats) },
+		func() ([]ParquetColumn, [][]any) { return videoStatsRows(stats) })
+}
+
+// noteSchema remembers t as the Go type of the JSON file written to path.
+func (w *Writer) noteSchema(path string, t reflect.Type) {
+	if !w.Schemas {
+		return
+	}
+	rel, err := filepath.Rel(w.Dir, path)
+	if err != nil {
+		return
+	}
+	if w.schemas == nil {
+		w.schemas = make(map[string]reflect.Type)
+	}
+	w.schemas[filepath.ToSlash(rel)] = t
+}
+
+// WriteSchemas writes a JSON Schema (draft 2020-12) for every JSON file
+// Write and WriteSearches wrote, and for manifest.json, into SchemaDir,
+// with an index.json naming the schema of each file. Files that only differ
+// in their year or period, and the files of each account, share a schema
+// such as top_channels_YEAR.schema.json. It does nothing unless Schemas is
+// set.
+func (w *Writer) WriteSchemas() error {
+	if !w.Schemas {
+		return nil
+	}
+	types := make(map[string]reflect.Type, len(w.schemas)+1)
+	for name, t := range w.schemas {
+		types[name] = t
+	}
+	types[ManifestName] = reflect.TypeOf(Manifest{})
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Each schema is named after the files it describes, with their years
+	// replaced by YEAR, unless files of that name have different types.
+	schemaTypes := make(map[string]reflect.Type)
+	index := make(map[string]string, len(names))
+	for _, name := range names {
+		t := types[name]
+		base := strings.TrimSuffix(filepath.Base(name), ".json")
+		schema := yearInName.ReplaceAllString(base, "YEAR") + ".schema.json"
+		if have, ok := schemaTypes[schema]; ok && have != t {
+			schema = strings.ReplaceAll(strings.TrimSuffix(name, ".json"), "/", "_") + ".schema.json"
+		}
+		schemaTypes[schema] = t
+		index[name] = schema
+	}
+
+	dir := filepath.Join(w.Dir, SchemaDir)
+	for schema, t := range schemaTypes {
+		if err := WriteJSON(filepath.Join(dir, schema), JSONSchema(t, schema)); err != nil {
+			return err
+		}
+	}
+	return WriteJSON(filepath.Join(dir, "index.json"), struct {
+		Files map[string]string `json:"files"`
+		Notes string            `json:"notes"`
+	}{
+		Files: index,
+		Notes: "files maps each JSON output, relative to the output directory, to its schema in this directory. Fields that may be left out are not required, and lists, maps and optional values that may be unset can also be null.",
+	})
+}
+
+// JSONSchema describes the JSON encoding/json writes for values of type t
+// as a JSON Schema (draft 2020-12) with the given $id. Named struct types
+// are described once, under $defs.
+func JSONSchema(t reflect.Type, id string) map[string]any {
+	g := &schemaGen{defs: make(map[string]any), names: make(map[reflect.Type]string)}
+	root := g.value(t)
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["$id"] = id
+	if len(g.defs) > 0 {
+		root["$defs"] = g.defs
+	}
+	return root
+}
+
+// schemaGen builds the schema of one type, collecting the named structs it
+// refers to.
+type schemaGen struct {
+	defs  map[string]any
+	names map[reflect.Type]string
+}
+
+// value returns the schema of a value of type t, which is null when t is a
+// nil pointer, slice or map.
+func (g *schemaGen) value(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Map:
+		return nullable(g.schema(t))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return g.schema(t)
+		}
+		return nullable(g.schema(t))
+	}
+	return g.schema(t)
+}
+
+// schema returns the schema of the non-nil values of type t.
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": g.value(t.Elem())}
+	case reflect.Map:
+		s := map[string]any{"type": "object", "additionalProperties": g.value(t.Elem())}
+		switch t.Key().Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s["propertyNames"] = map[string]any{"pattern": "^-?[0-9]+$"}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s["propertyNames"] = map[string]any{"pattern": "^[0-9]+$"}
+		}
+		return s
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = t.Name()
+			for _, other := range g.names {
+				if other == name {
+					name = strings.ReplaceAll(t.PkgPath(), "/", "_") + "_" + t.Name()
+				}
+			}
+			g.names[t] = name
+			g.defs[name] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/$defs/" + name}
+	}
+	// Interfaces hold anything.
+	return map[string]any{}
+}
+
+// object returns the schema of struct type t: its exported fields under
+// their JSON names, with those of embedded structs promoted, and the fields
+// without omitempty required.
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	g.fields(t, props, &required)
+	sort.Strings(required)
+	s := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// fields adds the fields of struct type t to props and required.
+func (g *schemaGen) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		opts = "," + opts + ","
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		omitEmpty := strings.Contains(opts, ",omitempty,")
+		switch {
+		case strings.Contains(opts, ",string,"):
+			props[name] = map[string]any{"type": "string"}
+		case omitEmpty:
+			// Nil pointers, slices and maps are left out, not null.
+			props[name] = g.schema(f.Type)
+		default:
+			props[name] = g.value(f.Type)
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable returns s allowing null as well.
+func nullable(s map[string]any) map[string]any {
+	if typ, ok := s["type"].(string); ok {
+		s["type"] = []string{typ, "null"}
+		return s
+	}
+	return map[string]any{"anyOf": []any{s, map[string]any{"type": "null"}}}
+}
@@ -0,0 +1,103 @@
+package output
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type SearchYear struct {
+	Year          int                   `json:"year"`
+	TotalSearches int                   `json:"total_searches"`
+	UniqueQueries int                   `json:"unique_queries"`
+	TopQueries    []aggregate.TermCount `json:"top_queries"`
+}
+
+type SearchPeriod struct {
+	Period        string `json:"period"`
+	TotalSearches int    `json:"total_searches"`
+}
+
+// WriteSearches writes the search_*.json outputs: top queries per year, word
+// frequencies and searches per month, WriteWorkers at a time like Write.
+func (w *Writer) WriteSearches(s *aggregate.Searches) error {
+	w.files = newFileWriter(w.WriteWorkers)
+	err := w.writeSearches(s)
+	err = errors.Join(err, w.files.wait())
+	w.files = nil
+	return err
+}
+
+func (w *Writer) writeSearches(s *aggregate.Searches) error {
+	opts := s.Options()
+
+	years := make(map[int]SearchYear)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		top := aggregate.TermCounts(s.YearQueries[y])
+		unique := len(top)
+		if w.TopN > 0 && len(top) > w.TopN {
+			top = top[:w.TopN]
+		}
+		years[y] = SearchYear{
+			Year:          y,
+			TotalSearches: s.YearTotals[y],
+			UniqueQueries: unique,
+			TopQueries:    top,
+		}
+	}
+	queriesPayload := struct {
+		TopN  int                `json:"top_n"`
+		Years map[int]SearchYear `json:"years"`
+		Sort  string             `json:"sort"`
+		Notes string             `json:"notes"`
+	}{
+		TopN:  w.TopN,
+		Years: years,
+		Sort:  "count desc, term asc",
+		Notes: "Queries are lowercased with whitespace collapsed, so differently typed searches for the same words count together.",
+	}
+	if err := w.writeJSON(filepath.Join(w.Dir, "search_top_queries.json"), queriesPayload); err != nil {
+		return err
+	}
+
+	words := aggregate.TermCounts(s.Words)
+	unique := len(words)
+	if w.AllTimeTop > 0 && len(words) > w.AllTimeTop {
+		words = words[:w.AllTimeTop]
+	}
+	wordsPayload := struct {
+		TopN          int                   `json:"top_n"`
+		TotalSearches int                   `json:"total_searches"`
+		UniqueWords   int                   `json:"unique_words"`
+		Words         []aggregate.TermCount `json:"words"`
+		Sort          string                `json:"sort"`
+		Notes         string                `json:"notes"`
+	}{
+		TopN:          w.AllTimeTop,
+		TotalSearches: s.Total,
+		UniqueWords:   unique,
+		Words:         words,
+		Sort:          "count desc, term asc",
+		Notes:         "Words are split on anything that is not a letter or digit; single characters and common English stop words are skipped.",
+	}
+	if err := w.writeJSON(filepath.Join(w.Dir, "search_words.json"), wordsPayload); err != nil {
+		return err
+	}
+
+	var series []SearchPeriod
+	end := time.Date(opts.EndYear+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	for m := time.Date(opts.StartYear, 1, 1, 0, 0, 0, 0, time.UTC); m.Before(end); m = m.AddDate(0, 1, 0) {
+		p := m.Format("2006-01")
+		series = append(series, SearchPeriod{Period: p, TotalSearches: s.MonthCounts[p]})
+	}
+	seriesPayload := struct {
+		Granularity string         `json:"granularity"`
+		Periods     []SearchPeriod `json:"periods"`
+	}{
+		Granularity: "month",
+		Periods:     series,
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "search_timeseries_month.json"), seriesPayload)
+}
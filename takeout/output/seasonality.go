@@ -0,0 +1,104 @@
+package output
+
+import (
+	"math"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type SeasonalityMonth struct {
+	Month        string  `json:"month"`
+	TotalVideos  int     `json:"total_videos_watched"`
+	SharePercent float64 `json:"share_percent"`
+	// Years is how many years of the counted window include the month, in
+	// full or in part, and AveragePerYear TotalVideos over them.
+	Years          int     `json:"years"`
+	AveragePerYear float64 `json:"average_per_year"`
+	// Index is AveragePerYear relative to the mean of all months'; above 1
+	// is busier than usual.
+	Index       float64       `json:"index"`
+	TopChannels []ChannelStat `json:"top_channels"`
+}
+
+// writeSeasonality writes seasonality.json: watches per calendar month
+// across every year, with each month's top channels.
+func (w *Writer) writeSeasonality(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	var totals [13]int
+	for day, n := range agg.DayCounts {
+		if m, err := strconv.Atoi(day[5:7]); err == nil && m >= 1 && m <= 12 {
+			totals[m] += n
+		}
+	}
+	var years [13]int
+	first, last := opts.Window()
+	for d := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !d.After(last); d = d.AddDate(0, 1, 0) {
+		years[d.Month()]++
+	}
+
+	months := make([]SeasonalityMonth, 0, 12)
+	sum, counted := 0.0, 0
+	for m := 1; m <= 12; m++ {
+		stats := aggregate.StatsFromMap(agg.MonthChannelCounts[m])
+		aggregate.SortStatsByCountThenName(stats)
+		sm := SeasonalityMonth{
+			Month:       time.Month(m).String(),
+			TotalVideos: totals[m],
+			Years:       years[m],
+			TopChannels: limitList(stats, w.TopN),
+		}
+		if agg.TotalAllYears > 0 {
+			sm.SharePercent = math.Round(float64(totals[m])/float64(agg.TotalAllYears)*1000) / 10
+		}
+		if years[m] > 0 {
+			avg := float64(totals[m]) / float64(years[m])
+			sm.AveragePerYear = math.Round(avg*10) / 10
+			sum += avg
+			counted++
+		}
+		months = append(months, sm)
+	}
+	var peak, low string
+	if counted > 0 && sum > 0 {
+		mean := sum / float64(counted)
+		hi, lo := -1, -1
+		for i := range months {
+			if months[i].Years == 0 {
+				continue
+			}
+			months[i].Index = math.Round(float64(months[i].TotalVideos)/float64(months[i].Years)/mean*100) / 100
+			if hi < 0 || months[i].Index > months[hi].Index {
+				hi = i
+			}
+			if lo < 0 || months[i].Index < months[lo].Index {
+				lo = i
+			}
+		}
+		peak, low = months[hi].Month, months[lo].Month
+	}
+
+	payload := struct {
+		StartYear   int                `json:"start_year"`
+		EndYear     int                `json:"end_year"`
+		TotalVideos int                `json:"total_videos_watched"`
+		PeakMonth   string             `json:"peak_month,omitempty"`
+		LowMonth    string             `json:"low_month,omitempty"`
+		Months      []SeasonalityMonth `json:"months"`
+		TopN        int                `json:"top_n"`
+		Notes       string             `json:"notes"`
+	}{
+		StartYear:   opts.StartYear,
+		EndYear:     opts.EndYear,
+		TotalVideos: agg.TotalAllYears,
+		PeakMonth:   peak,
+		LowMonth:    low,
+		Months:      months,
+		TopN:        w.TopN,
+		Notes:       "Each calendar month combines its watches from every year, in the " + opts.Location.String() + " time zone. Months are compared by average_per_year, since a partly counted window covers some months in fewer years; peak_month and low_month have the highest and lowest index, ties going to the earlier month. top_channels is sorted by watch_count desc, then name.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "seasonality.json"), payload)
+}
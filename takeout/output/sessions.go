@@ -0,0 +1,91 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type SessionStat struct {
+	Start           string  `json:"start"`
+	End             string  `json:"end"`
+	Videos          int     `json:"videos"`
+	DurationMinutes float64 `json:"duration_minutes"`
+}
+
+type SessionDay struct {
+	Date     string `json:"date"`
+	Sessions int    `json:"sessions"`
+	Videos   int    `json:"videos"`
+}
+
+type SessionsResult struct {
+	Year                 int          `json:"year"`
+	GapMinutes           float64      `json:"gap_minutes"`
+	TotalSessions        int          `json:"total_sessions"`
+	TotalVideos          int          `json:"total_videos_watched"`
+	ActiveDays           int          `json:"active_days"`
+	SessionsPerDay       float64      `json:"avg_sessions_per_active_day"`
+	AverageSessionVideos float64      `json:"avg_session_videos"`
+	LongestBinge         *SessionStat `json:"longest_binge,omitempty"`
+	Days                 []SessionDay `json:"days"`
+	Notes                string       `json:"notes"`
+}
+
+func newSessionStat(s aggregate.Session) *SessionStat {
+	return &SessionStat{
+		Start:           s.Start.Format(time.RFC3339),
+		End:             s.End.Format(time.RFC3339),
+		Videos:          s.Videos,
+		DurationMinutes: s.End.Sub(s.Start).Minutes(),
+	}
+}
+
+// longestSession returns the session with the most videos, the longer one on
+// a tie.
+func longestSession(sessions []aggregate.Session) aggregate.Session {
+	var longest aggregate.Session
+	for _, s := range sessions {
+		if s.Videos > longest.Videos || (s.Videos == longest.Videos && s.End.Sub(s.Start) > longest.End.Sub(longest.Start)) {
+			longest = s
+		}
+	}
+	return longest
+}
+
+// writeSessions writes sessions_<YEAR>.json for every year in range.
+func (w *Writer) writeSessions(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	sessions := agg.Sessions()
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		res := SessionsResult{
+			Year:       y,
+			GapMinutes: opts.SessionGap.Minutes(),
+			Days:       []SessionDay{},
+			Notes:      "A session is a run of watches with each less than gap_minutes after the previous one; it belongs to the day and year it started in (" + opts.Location.String() + "). duration_minutes spans the first to the last watch start, so it leaves out the length of the last video.",
+		}
+		for _, s := range sessions[y] {
+			res.TotalSessions++
+			res.TotalVideos += s.Videos
+			day := s.Start.Format(time.DateOnly)
+			if n := len(res.Days); n > 0 && res.Days[n-1].Date == day {
+				res.Days[n-1].Sessions++
+				res.Days[n-1].Videos += s.Videos
+			} else {
+				res.Days = append(res.Days, SessionDay{Date: day, Sessions: 1, Videos: s.Videos})
+			}
+		}
+		res.ActiveDays = len(res.Days)
+		if res.ActiveDays > 0 {
+			res.SessionsPerDay = float64(res.TotalSessions) / float64(res.ActiveDays)
+			res.AverageSessionVideos = float64(res.TotalVideos) / float64(res.TotalSessions)
+			res.LongestBinge = newSessionStat(longestSession(sessions[y]))
+		}
+		if err := w.writeJSON(filepath.Join(w.Dir, fmt.Sprintf("sessions_%d.json", y)), res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
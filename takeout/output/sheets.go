@@ -0,0 +1,47 @@
+package output
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/sheets"
+)
+
+// SheetTabs lays out the summary and each year's top topN channels as tabs
+// of a Google Sheet for -sheets-id: a "Summary" tab with the totals of
+// every year, stamped with the time of the run, and a "Top <YEAR>" tab per
+// year.
+func SheetTabs(agg *aggregate.Aggregator, topN int, now time.Time) []sheets.Tab {
+	opts := agg.Options()
+	summary := [][]any{
+		{"Updated", now.In(opts.Location).Format("2006-01-02 15:04 MST")},
+		{"Time zone", opts.Location.String()},
+		{},
+		{"Year", "Videos watched", "Unique channels", "Ad views", "Removed videos", "Top channel", "Top channel watches"},
+	}
+	tabs := []sheets.Tab{{Title: "Summary"}}
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		stats := aggregate.StatsFromMap(agg.YearCounts[y])
+		aggregate.SortStatsByCountThenName(stats)
+		row := []any{y, agg.YearTotals[y], len(stats), agg.YearAds[y], agg.YearRemoved[y], "", ""}
+		if len(stats) > 0 {
+			row[5], row[6] = stats[0].ChannelName, stats[0].WatchCount
+		}
+		summary = append(summary, row)
+
+		top := [][]any{{"Rank", "Channel", "Channel URL", "Watches", "Share %"}}
+		for i, st := range limitList(stats, topN) {
+			share := 0.0
+			if total := agg.YearTotals[y]; total > 0 {
+				share = math.Round(float64(st.WatchCount)/float64(total)*1000) / 10
+			}
+			top = append(top, []any{i + 1, st.ChannelName, st.ChannelURL, st.WatchCount, share})
+		}
+		tabs = append(tabs, sheets.Tab{Title: fmt.Sprintf("Top %d", y), Rows: top})
+	}
+	summary = append(summary, []any{"All years", agg.TotalAllYears, len(agg.AllTimeCounts), agg.TotalAds, agg.TotalRemoved})
+	tabs[0].Rows = summary
+	return tabs
+}
@@ -0,0 +1,94 @@
+package output
+
+import (
+	"math"
+	"path/filepath"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// shortsMaxSeconds is the longest looked up video counted as a Short.
+const shortsMaxSeconds = 60
+
+// ShortsSplit compares Shorts with regular videos for one year or all time.
+type ShortsSplit struct {
+	TotalWatches   int           `json:"total_videos_watched"`
+	Shorts         int           `json:"shorts"`
+	Regular        int           `json:"regular"`
+	ShortsPercent  float64       `json:"shorts_percent"`
+	TopShorts      []ChannelStat `json:"top_shorts_channels"`
+	TopRegular     []ChannelStat `json:"top_regular_channels"`
+	UniqueShorts   int           `json:"unique_shorts"`
+	ShortsChannels int           `json:"shorts_channels"`
+}
+
+// isShort reports whether a counted video is a Short: its URL is a
+// /shorts/ link or, with VideoDetails, it is at most shortsMaxSeconds long.
+func (w *Writer) isShort(vi aggregate.VideoInfo) bool {
+	if parser.IsShortsURL(vi.URL) {
+		return true
+	}
+	v, ok := w.VideoDetails[parser.VideoIDFromURL(vi.URL)]
+	return ok && v.Found && v.DurationSeconds > 0 && v.DurationSeconds <= shortsMaxSeconds
+}
+
+// shortsSplit splits channel counts into Shorts and regular videos using
+// the per-video counts, which attribute each video to its channel in
+// VideoInfo.
+func (w *Writer) shortsSplit(channels map[aggregate.ChannelKey]int, videos map[string]int, total int, info map[string]aggregate.VideoInfo, topN int) ShortsSplit {
+	sp := ShortsSplit{TotalWatches: total}
+	shorts := make(map[aggregate.ChannelKey]int)
+	for vk, c := range videos {
+		vi := info[vk]
+		if !w.isShort(vi) {
+			continue
+		}
+		shorts[vi.Channel] += c
+		sp.Shorts += c
+		sp.UniqueShorts++
+	}
+	regular := make(map[aggregate.ChannelKey]int, len(channels))
+	for k, c := range channels {
+		if n := c - shorts[k]; n > 0 {
+			regular[k] = n
+		}
+	}
+	sp.Regular = total - sp.Shorts
+	if total > 0 {
+		sp.ShortsPercent = math.Round(float64(sp.Shorts)/float64(total)*1000) / 10
+	}
+	sp.ShortsChannels = len(shorts)
+
+	top := aggregate.StatsFromMap(shorts)
+	aggregate.SortStatsByCountThenName(top)
+	sp.TopShorts = limitList(top, topN)
+	top = aggregate.StatsFromMap(regular)
+	aggregate.SortStatsByCountThenName(top)
+	sp.TopRegular = limitList(top, topN)
+	return sp
+}
+
+// writeShorts writes shorts.json.
+func (w *Writer) writeShorts(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	years := make(map[int]ShortsSplit)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		years[y] = w.shortsSplit(agg.YearCounts[y], agg.YearVideoCounts[y], agg.YearTotals[y], agg.VideoInfo, w.TopN)
+	}
+
+	payload := struct {
+		Years       map[int]ShortsSplit `json:"years"`
+		AllTime     ShortsSplit         `json:"all_time"`
+		UsesLookups bool                `json:"uses_video_lookups"`
+		Sort        string              `json:"sort"`
+		Notes       string              `json:"notes"`
+	}{
+		Years:       years,
+		AllTime:     w.shortsSplit(agg.AllTimeCounts, agg.AllTimeVideoCounts, agg.TotalAllYears, agg.VideoInfo, w.AllTimeTop),
+		UsesLookups: w.VideoDetails != nil,
+		Sort:        "watch_count desc, channel_name asc",
+		Notes:       "A watch is a Short when its URL is a youtube.com/shorts/ link or, when uses_video_lookups is true (-yt-api-key), the video is at most 60 seconds long. Takeout lists most Shorts with a plain watch URL, so without lookups the shorts counts are a lower bound. Removed videos count as regular.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "shorts.json"), payload)
+}
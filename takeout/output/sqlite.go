@@ -0,0 +1,458 @@
+package output
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// A minimal SQLite database writer. Tables are bulk-loaded in rowid order:
+// leaf pages are written as soon as they fill up and interior pages are built
+// on Close, so only one page per table is held in memory. There is no support
+// for indexes, updates or deletes; the result is an ordinary database file
+// that sqlite3 and any driver can open and query.
+
+const sqlitePageSize = 4096
+
+// HistoryDB writes the -out sqlite:<path> database: activities are streamed
+// in as they are counted, channels and per-year counts are added at the end.
+type HistoryDB struct {
+	w          *sqliteWriter
+	activities *sqliteTable
+	channels   *sqliteTable
+	perYear    *sqliteTable
+	channelIDs map[aggregate.ChannelKey]int64
+	order      []aggregate.ChannelKey
+	// Group, if set, maps a channel to the identity it is counted under, as
+	// Options.ChannelGroup does, so renamed channels share one row.
+	Group func(aggregate.ChannelKey) aggregate.ChannelKey
+}
+
+func NewHistoryDB(path string) (*HistoryDB, error) {
+	w, err := newSQLiteWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryDB{
+		w: w,
+		activities: w.createTable("activities", `CREATE TABLE activities(
+  id INTEGER PRIMARY KEY,
+  time TEXT NOT NULL,
+  year INTEGER NOT NULL,
+  month INTEGER NOT NULL,
+  channel_id INTEGER NOT NULL REFERENCES channels(id),
+  video_id TEXT,
+  video_title TEXT,
+  video_url TEXT
+)`),
+		channels: w.createTable("channels", `CREATE TABLE channels(
+  id INTEGER PRIMARY KEY,
+  name TEXT NOT NULL,
+  url TEXT,
+  channel_id TEXT
+)`),
+		perYear: w.createTable("per_year_counts", `CREATE TABLE per_year_counts(
+  year INTEGER NOT NULL,
+  channel_id INTEGER NOT NULL REFERENCES channels(id),
+  watch_count INTEGER NOT NULL
+)`),
+		channelIDs: make(map[aggregate.ChannelKey]int64),
+	}, nil
+}
+
+func (db *HistoryDB) AddActivity(e aggregate.WatchEvent) error {
+	k := aggregate.ChannelKey{Name: e.ChannelName, URL: e.ChannelURL}
+	g := db.group(k)
+	id, ok := db.channelIDs[g]
+	if !ok {
+		id = int64(len(db.order) + 1)
+		db.channelIDs[g] = id
+		db.order = append(db.order, k)
+	}
+	_, err := db.activities.insert(nil, e.Time.Format(time.RFC3339), e.Time.Year(), int(e.Time.Month()), id, e.VideoID, e.VideoTitle, e.VideoURL)
+	return err
+}
+
+func (db *HistoryDB) group(k aggregate.ChannelKey) aggregate.ChannelKey {
+	if db.Group == nil {
+		return k
+	}
+	return db.Group(k)
+}
+
+// Finish writes the channels, named as agg reports them, and the per-year
+// counts, and closes the database.
+func (db *HistoryDB) Finish(agg *aggregate.Aggregator) error {
+	for _, k := range db.order {
+		k = agg.CanonicalChannel(k)
+		if _, err := db.channels.insert(nil, k.Name, k.URL, parser.ChannelIDFromURL(k.URL)); err != nil {
+			db.w.abort()
+			return err
+		}
+	}
+
+	years := make([]int, 0, len(agg.YearCounts))
+	for y := range agg.YearCounts {
+		years = append(years, y)
+	}
+	sort.Ints(years)
+	for _, y := range years {
+		stats := aggregate.StatsFromMap(agg.YearCounts[y])
+		aggregate.SortStatsByCountThenName(stats)
+		for _, st := range stats {
+			if _, err := db.perYear.insert(y, db.channelIDs[db.group(st.Key())], st.WatchCount); err != nil {
+				db.w.abort()
+				return err
+			}
+		}
+	}
+	return db.w.Close()
+}
+
+type sqliteWriter struct {
+	path   string
+	f      *os.File
+	pages  uint32 // pages allocated so far, including page 1
+	tables []*sqliteTable
+}
+
+type sqliteTable struct {
+	w       *sqliteWriter
+	name    string
+	sql     string
+	nextID  int64
+	cells   [][]byte
+	used    int
+	lastID  int64
+	leaves  []sqliteChild
+	written bool
+}
+
+type sqliteChild struct {
+	page   uint32
+	maxKey int64
+}
+
+// newSQLiteWriter creates path (via a .tmp file renamed on close). Page 1 is
+// reserved for the header and schema table, which are written last.
+func newSQLiteWriter(path string) (*sqliteWriter, error) {
+	tmp := path + ".tmp"
+	trackTemp(tmp)
+	f, err := os.Create(tmp)
+	if err != nil {
+		untrackTemp(tmp)
+		return nil, err
+	}
+	return &sqliteWriter{path: path, f: f, pages: 1}, nil
+}
+
+// createTable registers a table; sql is its CREATE TABLE statement.
+func (w *sqliteWriter) createTable(name, sql string) *sqliteTable {
+	t := &sqliteTable{w: w, name: name, sql: sql, nextID: 1, used: 8}
+	w.tables = append(w.tables, t)
+	return t
+}
+
+// insert appends a row and returns its rowid. Values may be nil, int, int64,
+// float64 or string. For a column declared INTEGER PRIMARY KEY pass nil; it
+// aliases the returned rowid.
+func (t *sqliteTable) insert(values ...any) (int64, error) {
+	rowid := t.nextID
+	t.nextID++
+
+	record, err := sqliteRecord(values)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: table %s: %w", t.name, err)
+	}
+	cell, err := t.w.leafCell(rowid, record)
+	if err != nil {
+		return 0, err
+	}
+	if t.used+2+len(cell) > sqlitePageSize {
+		if err := t.flushLeaf(); err != nil {
+			return 0, err
+		}
+	}
+	t.cells = append(t.cells, cell)
+	t.used += 2 + len(cell)
+	t.lastID = rowid
+	return rowid, nil
+}
+
+func (t *sqliteTable) flushLeaf() error {
+	if len(t.cells) == 0 && t.written {
+		return nil
+	}
+	page := t.w.alloc()
+	buf := sqlitePage(0x0d, 0, t.cells, 0)
+	if err := t.w.writePage(page, buf); err != nil {
+		return err
+	}
+	t.leaves = append(t.leaves, sqliteChild{page: page, maxKey: t.lastID})
+	t.cells = t.cells[:0]
+	t.used = 8
+	t.written = true
+	return nil
+}
+
+// finish flushes the last leaf and builds interior levels, returning the
+// table's root page.
+func (t *sqliteTable) finish() (uint32, error) {
+	if len(t.cells) > 0 || !t.written {
+		if err := t.flushLeaf(); err != nil {
+			return 0, err
+		}
+	}
+	level := t.leaves
+	for len(level) > 1 {
+		var next []sqliteChild
+		for len(level) > 0 {
+			var cells [][]byte
+			used := 12
+			n := 0
+			// Every child but the last becomes a cell; the last is the
+			// right-most pointer.
+			for n < len(level)-1 {
+				c := binary.BigEndian.AppendUint32(nil, level[n].page)
+				c = sqliteVarint(c, uint64(level[n].maxKey))
+				if used+2+len(c) > sqlitePageSize {
+					break
+				}
+				cells = append(cells, c)
+				used += 2 + len(c)
+				n++
+			}
+			right := level[n]
+			page := t.w.alloc()
+			if err := t.w.writePage(page, sqlitePage(0x05, 0, cells, right.page)); err != nil {
+				return 0, err
+			}
+			next = append(next, sqliteChild{page: page, maxKey: right.maxKey})
+			level = level[n+1:]
+		}
+		level = next
+	}
+	return level[0].page, nil
+}
+
+// Close finishes every table, writes page 1 (file header plus sqlite_schema)
+// and renames the file into place.
+func (w *sqliteWriter) Close() error {
+	tmp := w.path + ".tmp"
+	defer untrackTemp(tmp)
+
+	var schema [][]byte
+	for i, t := range w.tables {
+		root, err := t.finish()
+		if err != nil {
+			w.abort()
+			return err
+		}
+		record, _ := sqliteRecord([]any{"table", t.name, t.name, int64(root), t.sql})
+		cell, err := w.leafCell(int64(i+1), record)
+		if err != nil {
+			w.abort()
+			return err
+		}
+		schema = append(schema, cell)
+	}
+
+	page1 := sqlitePage(0x0d, 100, schema, 0)
+	copy(page1, sqliteHeader(w.pages))
+	if err := w.writePage(1, page1); err != nil {
+		w.abort()
+		return err
+	}
+	if err := w.f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, w.path)
+}
+
+func (w *sqliteWriter) abort() {
+	_ = w.f.Close()
+	_ = os.Remove(w.path + ".tmp")
+}
+
+func (w *sqliteWriter) alloc() uint32 {
+	w.pages++
+	return w.pages
+}
+
+func (w *sqliteWriter) writePage(n uint32, buf []byte) error {
+	_, err := w.f.WriteAt(buf, int64(n-1)*sqlitePageSize)
+	return err
+}
+
+// leafCell encodes a table-leaf cell, spilling the payload to overflow pages
+// when it does not fit locally.
+func (w *sqliteWriter) leafCell(rowid int64, payload []byte) ([]byte, error) {
+	const (
+		usable   = sqlitePageSize
+		maxLocal = usable - 35
+		minLocal = (usable-12)*32/255 - 23
+	)
+	cell := sqliteVarint(nil, uint64(len(payload)))
+	cell = sqliteVarint(cell, uint64(rowid))
+	if len(payload) <= maxLocal {
+		return append(cell, payload...), nil
+	}
+
+	local := minLocal + (len(payload)-minLocal)%(usable-4)
+	if local > maxLocal {
+		local = minLocal
+	}
+	cell = append(cell, payload[:local]...)
+
+	rest := payload[local:]
+	first := w.alloc()
+	cell = binary.BigEndian.AppendUint32(cell, first)
+	for page := first; len(rest) > 0; {
+		chunk := rest
+		if len(chunk) > usable-4 {
+			chunk = chunk[:usable-4]
+		}
+		rest = rest[len(chunk):]
+		var next uint32
+		if len(rest) > 0 {
+			next = w.alloc()
+		}
+		buf := make([]byte, sqlitePageSize)
+		binary.BigEndian.PutUint32(buf, next)
+		copy(buf[4:], chunk)
+		if err := w.writePage(page, buf); err != nil {
+			return nil, err
+		}
+		page = next
+	}
+	return cell, nil
+}
+
+// sqlitePage lays out a b-tree page: the header at offset hdr, the cell
+// pointer array after it and the cells packed at the end of the page.
+func sqlitePage(kind byte, hdr int, cells [][]byte, rightMost uint32) []byte {
+	buf := make([]byte, sqlitePageSize)
+	buf[hdr] = kind
+	binary.BigEndian.PutUint16(buf[hdr+3:], uint16(len(cells)))
+	ptrs := hdr + 8
+	if kind == 0x05 {
+		binary.BigEndian.PutUint32(buf[hdr+8:], rightMost)
+		ptrs = hdr + 12
+	}
+	end := sqlitePageSize
+	for i, c := range cells {
+		end -= len(c)
+		copy(buf[end:], c)
+		binary.BigEndian.PutUint16(buf[ptrs+2*i:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(buf[hdr+5:], uint16(end))
+	return buf
+}
+
+func sqliteHeader(pages uint32) []byte {
+	h := make([]byte, 100)
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], sqlitePageSize)
+	h[18], h[19] = 1, 1                   // legacy (rollback journal) read/write versions
+	h[21], h[22], h[23] = 64, 32, 32      // payload fractions, fixed by the format
+	binary.BigEndian.PutUint32(h[24:], 1) // file change counter
+	binary.BigEndian.PutUint32(h[28:], pages)
+	binary.BigEndian.PutUint32(h[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(h[44:], 4) // schema format
+	binary.BigEndian.PutUint32(h[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(h[92:], 1) // version-valid-for = change counter
+	binary.BigEndian.PutUint32(h[96:], 3045000)
+	return h
+}
+
+// sqliteRecord encodes values in the SQLite record format.
+func sqliteRecord(values []any) ([]byte, error) {
+	var types, body []byte
+	for _, v := range values {
+		switch x := v.(type) {
+		case nil:
+			types = sqliteVarint(types, 0)
+		case int:
+			types, body = sqliteInt(types, body, int64(x))
+		case int64:
+			types, body = sqliteInt(types, body, x)
+		case float64:
+			types = sqliteVarint(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(x))
+		case string:
+			types = sqliteVarint(types, uint64(2*len(x)+13))
+			body = append(body, x...)
+		default:
+			return nil, fmt.Errorf("unsupported value type %T", v)
+		}
+	}
+	// The header length includes its own varint, which may grow it by a byte.
+	n := len(types) + 1
+	if len(sqliteVarint(nil, uint64(n))) > 1 {
+		n = len(types) + len(sqliteVarint(nil, uint64(n+1)))
+	}
+	rec := sqliteVarint(nil, uint64(n))
+	rec = append(rec, types...)
+	return append(rec, body...), nil
+}
+
+func sqliteInt(types, body []byte, v int64) ([]byte, []byte) {
+	switch {
+	case v == 0:
+		return sqliteVarint(types, 8), body
+	case v == 1:
+		return sqliteVarint(types, 9), body
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return sqliteVarint(types, 1), append(body, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return sqliteVarint(types, 2), binary.BigEndian.AppendUint16(body, uint16(v))
+	case v >= -1<<23 && v < 1<<23:
+		return sqliteVarint(types, 3), append(body, byte(v>>16), byte(v>>8), byte(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return sqliteVarint(types, 4), binary.BigEndian.AppendUint32(body, uint32(v))
+	case v >= -1<<47 && v < 1<<47:
+		return sqliteVarint(types, 5), append(body, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	default:
+		return sqliteVarint(types, 6), binary.BigEndian.AppendUint64(body, uint64(v))
+	}
+}
+
+// sqliteVarint appends v as a SQLite varint: big-endian 7-bit groups, with a
+// full 8-bit ninth byte for values that need it.
+func sqliteVarint(buf []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var b [9]byte
+		b[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			b[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(buf, b[:]...)
+	}
+	var tmp [8]byte
+	n := 0
+	for {
+		tmp[n] = byte(v & 0x7f)
+		n++
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		b := tmp[i]
+		if i > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+	}
+	return buf
+}
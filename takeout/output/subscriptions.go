@@ -0,0 +1,126 @@
+package output
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// subscriptionIndex finds a channel's entry in Writer.Subscriptions.
+type subscriptionIndex struct {
+	byID   map[string]int
+	byName map[string]int
+}
+
+func newSubscriptionIndex(subs []parser.Subscription) *subscriptionIndex {
+	idx := &subscriptionIndex{byID: make(map[string]int), byName: make(map[string]int)}
+	for i, s := range subs {
+		id := s.ChannelID
+		if id == "" {
+			id = parser.ChannelIDFromURL(s.URL)
+		}
+		if id != "" {
+			idx.byID[id] = i
+		}
+		if name := strings.ToLower(s.Title); name != "" {
+			if _, dup := idx.byName[name]; !dup {
+				idx.byName[name] = i
+			}
+		}
+	}
+	return idx
+}
+
+// find returns the subscription matching k. Channels whose URL carries a
+// channel ID are matched by it; the rest (e.g. @handle URLs) by name.
+func (idx *subscriptionIndex) find(k aggregate.ChannelKey) (int, bool) {
+	if id := parser.ChannelIDFromURL(k.URL); strings.HasPrefix(id, "UC") {
+		i, ok := idx.byID[id]
+		return i, ok
+	}
+	i, ok := idx.byName[strings.ToLower(k.Name)]
+	return i, ok
+}
+
+// markSubscribed sets Subscribed on stats when Subscriptions are given,
+// leaving out entries that are not a single channel.
+func (w *Writer) markSubscribed(stats []ChannelStat) {
+	if w.subs == nil {
+		return
+	}
+	for i := range stats {
+		if stats[i].ChannelName == "(unknown channel)" || stats[i].ChannelCount > 0 {
+			continue
+		}
+		_, ok := w.subs.find(stats[i].Key())
+		stats[i].Subscribed = &ok
+	}
+}
+
+type SubscribedChannel struct {
+	ChannelName string `json:"channel_name"`
+	ChannelURL  string `json:"channel_url"`
+	WatchCount  int    `json:"watch_count"`
+}
+
+// writeSubscriptions writes subscriptions.json: the most watched channels
+// that are not subscribed to and the subscriptions never watched.
+func (w *Writer) writeSubscriptions(agg *aggregate.Aggregator) error {
+	watched := make([]int, len(w.Subscriptions))
+	notSubscribed := make(map[aggregate.ChannelKey]int)
+	for k, n := range agg.AllTimeCounts {
+		if k.Name == "(unknown channel)" {
+			continue
+		}
+		if i, ok := w.subs.find(k); ok {
+			watched[i] += n
+		} else {
+			notSubscribed[k] = n
+		}
+	}
+
+	never, subscribed := []SubscribedChannel{}, []SubscribedChannel{}
+	for i, s := range w.Subscriptions {
+		sc := SubscribedChannel{ChannelName: s.Title, ChannelURL: s.URL, WatchCount: watched[i]}
+		if watched[i] == 0 {
+			never = append(never, sc)
+		} else {
+			subscribed = append(subscribed, sc)
+		}
+	}
+	sort.Slice(never, func(i, j int) bool {
+		return strings.ToLower(never[i].ChannelName) < strings.ToLower(never[j].ChannelName)
+	})
+	sort.Slice(subscribed, func(i, j int) bool {
+		if subscribed[i].WatchCount == subscribed[j].WatchCount {
+			return strings.ToLower(subscribed[i].ChannelName) < strings.ToLower(subscribed[j].ChannelName)
+		}
+		return subscribed[i].WatchCount > subscribed[j].WatchCount
+	})
+	top := aggregate.StatsFromMap(notSubscribed)
+	aggregate.SortStatsByCountThenName(top)
+
+	payload := struct {
+		Subscriptions      int                 `json:"subscriptions"`
+		Watched            int                 `json:"subscriptions_watched"`
+		NeverWatched       []SubscribedChannel `json:"never_watched"`
+		WatchedChannels    []SubscribedChannel `json:"watched_subscriptions"`
+		TopNotSubscribed   []ChannelStat       `json:"top_not_subscribed"`
+		NotSubscribedTotal int                 `json:"not_subscribed_channels"`
+		TopN               int                 `json:"top_n"`
+		Notes              string              `json:"notes"`
+	}{
+		Subscriptions:      len(w.Subscriptions),
+		Watched:            len(subscribed),
+		NeverWatched:       never,
+		WatchedChannels:    subscribed,
+		TopNotSubscribed:   limitList(top, w.AllTimeTop),
+		NotSubscribedTotal: len(top),
+		TopN:               w.AllTimeTop,
+		Notes:              "Watches are those counted in the -start..-end range, so never_watched also lists channels only watched outside it. Channels are matched to subscriptions by channel ID, or by name when the watch history only has an @handle URL for them. never_watched is sorted by name, watched_subscriptions and top_not_subscribed by watch_count desc, then name.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "subscriptions.json"), payload)
+}
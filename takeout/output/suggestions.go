@@ -0,0 +1,322 @@
+package output
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+)
+
+// MergeSuggestion is a set of channels that are likely the same channel
+// under names that differ a little.
+type MergeSuggestion struct {
+	// Reason is "same_name" for one name under several URLs, "case" for
+	// names that only differ in case, "unicode" for names that only differ
+	// in accent encoding, emoji or invisible characters, "topic" for a
+	// YouTube Music " - Topic" channel beside the artist's own and
+	// "similar" for names a typo or two apart.
+	Reason string `json:"reason"`
+	// Distance is the edit distance between the names of a "similar" pair.
+	Distance int                     `json:"distance,omitempty"`
+	Channels []aggregate.ChannelStat `json:"channels"`
+	// SuggestedName is the name of the most watched of the channels.
+	SuggestedName      string `json:"suggested_name"`
+	CombinedWatchCount int    `json:"combined_watch_count"`
+}
+
+// MergedTopic is a " - Topic" channel -merge-topics counted under an artist
+// channel.
+type MergedTopic struct {
+	Topic     string `json:"topic"`
+	TopicURL  string `json:"topic_url,omitempty"`
+	Artist    string `json:"artist"`
+	ArtistURL string `json:"artist_url,omitempty"`
+}
+
+// writeSuggestions writes merge_suggestions.json: the channels of the window
+// whose names suggest they are one channel, most watched first, and the
+// " - Topic" channels -merge-topics merged.
+func (w *Writer) writeSuggestions(agg *aggregate.Aggregator) error {
+	merged := []MergedTopic{}
+	for _, m := range agg.TopicMerges() {
+		merged = append(merged, MergedTopic{Topic: m.Topic.Name, TopicURL: m.Topic.URL, Artist: m.Artist.Name, ArtistURL: m.Artist.URL})
+	}
+	payload := struct {
+		Suggestions  []MergeSuggestion `json:"suggestions"`
+		MergedTopics []MergedTopic     `json:"merged_topics"`
+		Notes        string            `json:"notes"`
+	}{
+		Suggestions:  MergeSuggestions(agg.AllTimeCounts),
+		MergedTopics: merged,
+		Notes:        "Names are compared ignoring case, accent encoding, emoji and invisible characters; similar names are at most 1 edit apart (2 from 10 characters on), at least 5 characters long and differ in more than digits, so 'Channel 12' and 'Channel 13' are not suggested. same_name channels have different URLs and may well be different channels. -normalize-names strip merges the unicode suggestions, and -merge-topics counts ' - Topic' channels under the artist's own channel.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "merge_suggestions.json"), payload)
+}
+
+// MergeSuggestions finds the channels among counts whose names are the same
+// but for case, Unicode form or decoration, a " - Topic" suffix, or a typo
+// or two other than in digits, sorted by combined watches.
+func MergeSuggestions(counts map[aggregate.ChannelKey]int) []MergeSuggestion {
+	stats := aggregate.StatsFromMap(counts)
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.WatchCount != b.WatchCount {
+			return a.WatchCount > b.WatchCount
+		}
+		if a.ChannelName != b.ChannelName {
+			return a.ChannelName < b.ChannelName
+		}
+		return a.ChannelURL < b.ChannelURL
+	})
+
+	// Channels are grouped by their folded name, most watched first within
+	// each group.
+	groups := make(map[string][]aggregate.ChannelStat)
+	var folds []string
+	for _, st := range stats {
+		f := foldName(st.ChannelName)
+		if groups[f] == nil {
+			folds = append(folds, f)
+		}
+		groups[f] = append(groups[f], st)
+	}
+
+	out := []MergeSuggestion{}
+	for _, f := range folds {
+		if g := groups[f]; len(g) > 1 {
+			out = append(out, newSuggestion(nameReason(g), 0, g))
+		}
+	}
+	for _, f := range folds {
+		base, ok := strings.CutSuffix(f, strings.ToLower(aggregate.TopicSuffix))
+		if !ok {
+			continue
+		}
+		if artist, ok := groups[strings.TrimSpace(base)]; ok {
+			out = append(out, newSuggestion("topic", 0, append(append([]aggregate.ChannelStat{}, artist...), groups[f]...)))
+		}
+	}
+
+	// Similar names: a name within d edits of another keeps one of its d+1
+	// segments whole, shifted by at most d, so only names that share one
+	// are compared. Names that only differ in digits are one family, and
+	// the names sharing a segment are kept in runs by family so a name
+	// skips its own family's run whole: histories full of numbered
+	// channels would otherwise compare every pair.
+	names := make([][]rune, 0, len(folds))
+	var families []int
+	familyIDs := make(map[string]int)
+	index := make(map[nameSegment][]int)
+	for _, f := range folds {
+		r := []rune(f)
+		if len(r) < 5 {
+			continue
+		}
+		stripped := stripDigits(f)
+		id, ok := familyIDs[stripped]
+		if !ok {
+			id = len(familyIDs)
+			familyIDs[stripped] = id
+		}
+		d := maxNameDistance(len(r))
+		for k := 0; k <= d; k++ {
+			from, to := segment(len(r), d, k)
+			seg := nameSegment{len(r), k, string(r[from:to])}
+			index[seg] = append(index[seg], len(names))
+		}
+		names = append(names, r)
+		families = append(families, id)
+	}
+	runs := make(map[nameSegment][]familyRun, len(index))
+	for seg, ids := range index {
+		sort.SliceStable(ids, func(i, j int) bool { return families[ids[i]] < families[ids[j]] })
+		for len(ids) > 0 {
+			n := 1
+			for n < len(ids) && families[ids[n]] == families[ids[0]] {
+				n++
+			}
+			runs[seg] = append(runs[seg], familyRun{families[ids[0]], ids[:n]})
+			ids = ids[n:]
+		}
+	}
+
+	var rows editRows
+	for j, b := range names {
+		seen := make(map[int]bool)
+		for n := max(5, len(b)-2); n <= len(b); n++ {
+			d := maxNameDistance(n)
+			if len(b)-n > d {
+				continue
+			}
+			for k := 0; k <= d; k++ {
+				from, to := segment(n, d, k)
+				for shift := -d; shift <= d; shift++ {
+					if from+shift < 0 || to+shift > len(b) {
+						continue
+					}
+					for _, run := range runs[nameSegment{n, k, string(b[from+shift : to+shift])}] {
+						if run.family == families[j] {
+							continue
+						}
+						for _, i := range run.names {
+							// Each pair once: from the longer name, or the
+							// later of two as long.
+							if seen[i] || len(names[i]) == len(b) && i >= j {
+								continue
+							}
+							seen[i] = true
+							a, fa, fb := names[i], string(names[i]), string(b)
+							if topicPair(fa, fb) {
+								continue
+							}
+							if dist, ok := rows.distance(a, b, d); ok {
+								out = append(out, newSuggestion("similar", dist, append(append([]aggregate.ChannelStat{}, groups[fa]...), groups[fb]...)))
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].CombinedWatchCount != out[j].CombinedWatchCount {
+			return out[i].CombinedWatchCount > out[j].CombinedWatchCount
+		}
+		return out[i].SuggestedName < out[j].SuggestedName
+	})
+	return out
+}
+
+// stripDigits is name without its digits, for telling numbered channels
+// such as "Channel 12" and "Channel 13" apart from typos.
+func stripDigits(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// nameSegment is the k-th of the segments a name n runes long is split into
+// to find similar names.
+type nameSegment struct {
+	n, k int
+	text string
+}
+
+// familyRun is the names sharing a segment that only differ from each other
+// in digits, by their index.
+type familyRun struct {
+	family int
+	names  []int
+}
+
+// segment returns where the k-th of the d+1 nearly equal segments of a name
+// n runes long starts and ends.
+func segment(n, d, k int) (from, to int) {
+	return k * n / (d + 1), (k + 1) * n / (d + 1)
+}
+
+// foldName is the form names are grouped by: lower case, NFC, without emoji
+// or invisible characters.
+func foldName(name string) string {
+	return strings.ToLower(parser.NormalizeName(name, true))
+}
+
+// nameReason tells why the channels of a group of equal folded names were
+// grouped.
+func nameReason(g []aggregate.ChannelStat) string {
+	reason := "same_name"
+	for _, st := range g[1:] {
+		switch {
+		case st.ChannelName == g[0].ChannelName:
+		case strings.EqualFold(st.ChannelName, g[0].ChannelName):
+			reason = "case"
+		default:
+			return "unicode"
+		}
+	}
+	return reason
+}
+
+// topicPair reports whether one of two folded names is the other's
+// " - Topic" channel, which the topic suggestions already cover.
+func topicPair(a, b string) bool {
+	suffix := strings.ToLower(aggregate.TopicSuffix)
+	return strings.TrimSpace(strings.TrimSuffix(a, suffix)) == strings.TrimSpace(strings.TrimSuffix(b, suffix))
+}
+
+// newSuggestion makes a suggestion of channels, sorted most watched first.
+func newSuggestion(reason string, distance int, channels []aggregate.ChannelStat) MergeSuggestion {
+	sort.SliceStable(channels, func(i, j int) bool { return channels[i].WatchCount > channels[j].WatchCount })
+	s := MergeSuggestion{Reason: reason, Distance: distance, Channels: channels, SuggestedName: channels[0].ChannelName}
+	for _, st := range channels {
+		s.CombinedWatchCount += st.WatchCount
+	}
+	return s
+}
+
+// maxNameDistance is how many edits apart two names, the shorter n runes
+// long, may be to be suggested as similar.
+func maxNameDistance(n int) int {
+	if n >= 10 {
+		return 2
+	}
+	return 1
+}
+
+// editRows are the two rows of the edit distance table, kept between
+// comparisons.
+type editRows struct {
+	prev, cur []int
+}
+
+// distance returns the Levenshtein distance between a and b if it is at
+// most limit. Only the cells within limit of the diagonal are computed, and
+// it gives up as soon as a row has none within limit.
+func (e *editRows) distance(a, b []rune, limit int) (int, bool) {
+	if len(b)-len(a) > limit || len(a)-len(b) > limit {
+		return 0, false
+	}
+	if cap(e.prev) < len(b)+1 {
+		e.prev, e.cur = make([]int, len(b)+1), make([]int, len(b)+1)
+	}
+	prev, cur := e.prev[:len(b)+1], e.cur[:len(b)+1]
+	over := limit + 1
+	for j := range prev {
+		prev[j] = min(j, over)
+	}
+	for i := 1; i <= len(a); i++ {
+		lo, hi := max(1, i-limit), min(len(b), i+limit)
+		cur[lo-1] = over
+		if lo == 1 {
+			cur[0] = min(i, over)
+		}
+		best := cur[lo-1]
+		for j := lo; j <= hi; j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d := min(prev[j-1]+cost, prev[j]+1, cur[j-1]+1, over)
+			cur[j] = d
+			best = min(best, d)
+		}
+		if hi < len(b) {
+			cur[hi+1] = over
+		}
+		if best > limit {
+			return 0, false
+		}
+		prev, cur = cur, prev
+	}
+	if d := prev[len(b)]; d <= limit {
+		return d, true
+	}
+	return 0, false
+}
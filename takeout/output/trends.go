@@ -0,0 +1,131 @@
+package output
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type TrendYear struct {
+	WatchCount int `json:"watch_count"`
+	Rank       int `json:"rank,omitempty"`
+	// RankDelta is as in ChannelStat: positive means the channel moved up,
+	// "new" that it was not watched the year before.
+	RankDelta any `json:"rank_delta,omitempty"`
+}
+
+type ChannelTrend struct {
+	ChannelName string            `json:"channel_name"`
+	ChannelURL  string            `json:"channel_url,omitempty"`
+	TotalCount  int               `json:"total_watch_count"`
+	Years       map[int]TrendYear `json:"years"`
+}
+
+type TrendChange struct {
+	ChannelName string `json:"channel_name"`
+	ChannelURL  string `json:"channel_url,omitempty"`
+	// WatchCount is this year's count for new channels and the prior year's
+	// for dropped ones.
+	WatchCount int `json:"watch_count"`
+}
+
+// writeTrends writes channel_trends.json: per-year counts and ranks of the
+// all-time top channels, and the channels new or dropped each year.
+func (w *Writer) writeTrends(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	ranks := make(map[int]map[aggregate.ChannelKey]int)
+	newByYear := make(map[int][]TrendChange)
+	droppedByYear := make(map[int][]TrendChange)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		stats := aggregate.StatsFromMap(agg.YearCounts[y])
+		aggregate.SortStatsByCountThenName(stats)
+		ranks[y] = make(map[aggregate.ChannelKey]int, len(stats))
+		for i, st := range stats {
+			ranks[y][st.Key()] = i + 1
+		}
+		if y == opts.StartYear {
+			continue
+		}
+
+		added := []TrendChange{}
+		for _, st := range stats {
+			if _, ok := agg.YearCounts[y-1][st.Key()]; !ok {
+				added = append(added, TrendChange{ChannelName: st.ChannelName, ChannelURL: st.ChannelURL, WatchCount: st.WatchCount})
+			}
+		}
+		dropped := []TrendChange{}
+		for k, n := range agg.YearCounts[y-1] {
+			if _, ok := agg.YearCounts[y][k]; !ok {
+				dropped = append(dropped, TrendChange{ChannelName: k.Name, ChannelURL: k.URL, WatchCount: n})
+			}
+		}
+		sortTrendChanges(dropped)
+		if w.AllTimeTop > 0 {
+			added = added[:min(len(added), w.AllTimeTop)]
+			dropped = dropped[:min(len(dropped), w.AllTimeTop)]
+		}
+		newByYear[y] = added
+		droppedByYear[y] = dropped
+	}
+
+	allTime := aggregate.StatsFromMap(agg.AllTimeCounts)
+	aggregate.SortStatsByCountThenName(allTime)
+	if w.AllTimeTop > 0 && len(allTime) > w.AllTimeTop {
+		allTime = allTime[:w.AllTimeTop]
+	}
+	channels := make([]ChannelTrend, 0, len(allTime))
+	for _, st := range allTime {
+		k := st.Key()
+		ct := ChannelTrend{
+			ChannelName: st.ChannelName,
+			ChannelURL:  st.ChannelURL,
+			TotalCount:  st.WatchCount,
+			Years:       make(map[int]TrendYear),
+		}
+		for y := opts.StartYear; y <= opts.EndYear; y++ {
+			ty := TrendYear{WatchCount: agg.YearCounts[y][k], Rank: ranks[y][k]}
+			if ty.Rank > 0 && y > opts.StartYear {
+				if prev, ok := ranks[y-1][k]; ok {
+					ty.RankDelta = prev - ty.Rank
+				} else {
+					ty.RankDelta = "new"
+				}
+			}
+			ct.Years[y] = ty
+		}
+		channels = append(channels, ct)
+	}
+
+	payload := struct {
+		StartYear int                   `json:"start_year"`
+		EndYear   int                   `json:"end_year"`
+		TopN      int                   `json:"top_n"`
+		Channels  []ChannelTrend        `json:"channels"`
+		New       map[int][]TrendChange `json:"new_by_year"`
+		Dropped   map[int][]TrendChange `json:"dropped_by_year"`
+		Sort      string                `json:"sort"`
+		Notes     string                `json:"notes"`
+	}{
+		StartYear: opts.StartYear,
+		EndYear:   opts.EndYear,
+		TopN:      w.AllTimeTop,
+		Channels:  channels,
+		New:       newByYear,
+		Dropped:   droppedByYear,
+		Sort:      "channels: total_watch_count desc; new/dropped lists: watch_count desc, channel_name asc",
+		Notes:     "channels lists the all-time top channels; rank is the channel's rank among all channels that year and is omitted in years it was not watched. A channel is new in a year if it was not watched the year before, and dropped if it was watched the year before but not that year.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "channel_trends.json"), payload)
+}
+
+func sortTrendChanges(list []TrendChange) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].WatchCount == list[j].WatchCount {
+			return strings.ToLower(list[i].ChannelName) < strings.ToLower(list[j].ChannelName)
+		}
+		return list[i].WatchCount > list[j].WatchCount
+	})
+}
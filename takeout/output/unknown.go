@@ -0,0 +1,53 @@
+package output
+
+import (
+	"path/filepath"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type UnknownYear struct {
+	Reasons map[string]int `json:"reasons"`
+	Total   int            `json:"total"`
+}
+
+// writeUnknown writes unknown_channels.json, breaking the "(unknown channel)"
+// counts down by why the watches have no channel.
+func (w *Writer) writeUnknown(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	reasons := []string{
+		aggregate.UnknownRemoved,
+		aggregate.UnknownAd,
+		aggregate.UnknownMusic,
+		aggregate.UnknownNoSubtitles,
+		aggregate.UnknownBlankName,
+	}
+
+	years := make(map[int]UnknownYear)
+	totals := make(map[string]int)
+	total := 0
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		uy := UnknownYear{Reasons: make(map[string]int)}
+		for _, r := range reasons {
+			n := agg.YearUnknownReasons[y][r]
+			uy.Reasons[r] = n
+			uy.Total += n
+			totals[r] += n
+		}
+		years[y] = uy
+		total += uy.Total
+	}
+
+	payload := struct {
+		Total   int                 `json:"total_unknown_watches"`
+		Reasons map[string]int      `json:"reasons"`
+		Years   map[int]UnknownYear `json:"years"`
+		Notes   string              `json:"notes"`
+	}{
+		Total:   total,
+		Reasons: totals,
+		Years:   years,
+		Notes:   "Each watch counted under '(unknown channel)' is given the first reason that applies: removed_video (removed-video placeholders and untitled videos, only counted here with -count-removed), ad (ad views, only counted with -exclude-ads=false), music_track (YouTube Music plays), no_subtitles (any other entry without a channel link) and blank_channel_name (a channel link with no name).",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "unknown_channels.json"), payload)
+}
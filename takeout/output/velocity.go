@@ -0,0 +1,116 @@
+package output
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+type VelocityMonth struct {
+	Month string `json:"month"`
+	// Days is how many days of the month were counted: fewer for the
+	// months the window or the history starts or ends in.
+	Days        int     `json:"days"`
+	TotalVideos int     `json:"total_videos_watched"`
+	PerDay      float64 `json:"videos_per_day"`
+	// Rolling3PerDay is the videos per day over this month and the two
+	// before it, which evens out a single unusual month.
+	Rolling3PerDay float64 `json:"rolling_3_month_videos_per_day"`
+	// ChangePercent is PerDay's change from the month before, or nil for
+	// the first month and after a month without watches.
+	ChangePercent *float64 `json:"change_percent"`
+	// Trend is "accelerating" or "decelerating" when ChangePercent is at
+	// least the threshold up or down.
+	Trend string `json:"trend,omitempty"`
+}
+
+// writeVelocity writes velocity.json: the average videos per day of every
+// month, with the months whose rate changed by w.VelocityChange percent or
+// more from the month before flagged as accelerating or decelerating.
+func (w *Writer) writeVelocity(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	// Months are counted from the first to the last day with a watch in
+	// the window, so the days after the export was taken do not read as a
+	// slowdown.
+	first, last := opts.Window()
+	var firstWatch, lastWatch string
+	for day, n := range agg.DayCounts {
+		if n == 0 {
+			continue
+		}
+		if firstWatch == "" || day < firstWatch {
+			firstWatch = day
+		}
+		if day > lastWatch {
+			lastWatch = day
+		}
+	}
+	if d, err := time.ParseInLocation(time.DateOnly, firstWatch, first.Location()); err == nil && d.After(first) {
+		first = d
+	}
+	if d, err := time.ParseInLocation(time.DateOnly, lastWatch, last.Location()); err == nil && d.Before(last) {
+		last = d
+	}
+
+	months := []VelocityMonth{}
+	accelerating, decelerating := []string{}, []string{}
+	if firstWatch != "" {
+		for m := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, first.Location()); !m.After(last); m = m.AddDate(0, 1, 0) {
+			vm := VelocityMonth{Month: m.Format("2006-01")}
+			for d := m; d.Month() == m.Month(); d = d.AddDate(0, 0, 1) {
+				if d.Before(first) || d.After(last) {
+					continue
+				}
+				vm.Days++
+				vm.TotalVideos += agg.DayCounts[d.Format(time.DateOnly)]
+			}
+			perDay := float64(vm.TotalVideos) / float64(vm.Days)
+			vm.PerDay = math.Round(perDay*100) / 100
+
+			videos, days := vm.TotalVideos, vm.Days
+			for _, prev := range months[max(0, len(months)-2):] {
+				videos += prev.TotalVideos
+				days += prev.Days
+			}
+			vm.Rolling3PerDay = math.Round(float64(videos)/float64(days)*100) / 100
+
+			if n := len(months); n > 0 && months[n-1].TotalVideos > 0 {
+				prev := float64(months[n-1].TotalVideos) / float64(months[n-1].Days)
+				change := math.Round((perDay-prev)/prev*1000) / 10
+				vm.ChangePercent = &change
+				switch threshold := float64(w.VelocityChange); {
+				case change >= threshold:
+					vm.Trend = "accelerating"
+					accelerating = append(accelerating, vm.Month)
+				case change <= -threshold:
+					vm.Trend = "decelerating"
+					decelerating = append(decelerating, vm.Month)
+				}
+			}
+			months = append(months, vm)
+		}
+	}
+
+	payload := struct {
+		StartYear        int             `json:"start_year"`
+		EndYear          int             `json:"end_year"`
+		ThresholdPercent int             `json:"threshold_percent"`
+		Months           []VelocityMonth `json:"months"`
+		Accelerating     []string        `json:"accelerating_months"`
+		Decelerating     []string        `json:"decelerating_months"`
+		Notes            string          `json:"notes"`
+	}{
+		StartYear:        opts.StartYear,
+		EndYear:          opts.EndYear,
+		ThresholdPercent: w.VelocityChange,
+		Months:           months,
+		Accelerating:     accelerating,
+		Decelerating:     decelerating,
+		Notes:            fmt.Sprintf("videos_per_day is a month's watches over its counted days, in the %s time zone; months run from the first to the last day with a watch, and a month counted only in part is averaged over its counted days. A month is accelerating or decelerating when its videos_per_day changed by at least %d%% from the month before.", opts.Location, w.VelocityChange),
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "velocity.json"), payload)
+}
@@ -0,0 +1,144 @@
+package output
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"example.com/hello/takeout/aggregate"
+	"example.com/hello/takeout/parser"
+	"example.com/hello/takeout/youtube"
+)
+
+// ChannelHours is a channel's estimated watch time.
+type ChannelHours struct {
+	ChannelName    string  `json:"channel_name"`
+	ChannelURL     string  `json:"channel_url,omitempty"`
+	WatchCount     int     `json:"watch_count"`
+	EstimatedHours float64 `json:"estimated_hours"`
+}
+
+// CategoryHours is a video category's estimated watch time.
+type CategoryHours struct {
+	Category       string  `json:"category"`
+	WatchCount     int     `json:"watch_count"`
+	EstimatedHours float64 `json:"estimated_hours"`
+}
+
+// WatchTime is the estimated watch time for one year or all time.
+type WatchTime struct {
+	TotalWatches        int `json:"total_videos_watched"`
+	WatchesWithDuration int `json:"watches_with_duration"`
+	// WatchesWithDefault counts the watches estimated with the default
+	// duration instead.
+	WatchesWithDefault int             `json:"watches_with_default_duration"`
+	EstimatedHours     float64         `json:"estimated_hours"`
+	TopChannels        []ChannelHours  `json:"top_channels"`
+	Categories         []CategoryHours `json:"categories"`
+}
+
+// estimatesHours reports whether there are durations to estimate watch time
+// with.
+func (w *Writer) estimatesHours() bool {
+	return w.VideoDetails != nil || w.DefaultDuration > 0
+}
+
+// watchTime estimates hours from per-video counts and the known durations;
+// videos without one count with DefaultDuration, or are left out without
+// it.
+func (w *Writer) watchTime(counts map[string]int, total int, info map[string]aggregate.VideoInfo, topN int) WatchTime {
+	wt := WatchTime{TotalWatches: total}
+	channels := make(map[aggregate.ChannelKey]*ChannelHours)
+	categories := make(map[string]*CategoryHours)
+	var seconds float64
+	for vk, c := range counts {
+		vi := info[vk]
+		v, ok := w.VideoDetails[parser.VideoIDFromURL(vi.URL)]
+		switch {
+		case ok && v.Found:
+			wt.WatchesWithDuration += c
+		case w.DefaultDuration > 0:
+			v = youtube.Video{DurationSeconds: w.DefaultDuration}
+			wt.WatchesWithDefault += c
+		default:
+			continue
+		}
+		s := float64(c * v.DurationSeconds)
+		seconds += s
+
+		ch := channels[vi.Channel]
+		if ch == nil {
+			ch = &ChannelHours{ChannelName: vi.Channel.Name, ChannelURL: vi.Channel.URL}
+			channels[vi.Channel] = ch
+		}
+		ch.WatchCount += c
+		ch.EstimatedHours += s / 3600
+
+		name := v.Category
+		if name == "" {
+			name = "(unknown category)"
+		}
+		cat := categories[name]
+		if cat == nil {
+			cat = &CategoryHours{Category: name}
+			categories[name] = cat
+		}
+		cat.WatchCount += c
+		cat.EstimatedHours += s / 3600
+	}
+	wt.EstimatedHours = roundHours(seconds / 3600)
+
+	wt.TopChannels = make([]ChannelHours, 0, len(channels))
+	for _, ch := range channels {
+		ch.EstimatedHours = roundHours(ch.EstimatedHours)
+		wt.TopChannels = append(wt.TopChannels, *ch)
+	}
+	sort.Slice(wt.TopChannels, func(i, j int) bool {
+		a, b := wt.TopChannels[i], wt.TopChannels[j]
+		if a.EstimatedHours == b.EstimatedHours {
+			return strings.ToLower(a.ChannelName) < strings.ToLower(b.ChannelName)
+		}
+		return a.EstimatedHours > b.EstimatedHours
+	})
+	if topN > 0 && len(wt.TopChannels) > topN {
+		wt.TopChannels = wt.TopChannels[:topN]
+	}
+
+	wt.Categories = make([]CategoryHours, 0, len(categories))
+	for _, cat := range categories {
+		cat.EstimatedHours = roundHours(cat.EstimatedHours)
+		wt.Categories = append(wt.Categories, *cat)
+	}
+	sort.Slice(wt.Categories, func(i, j int) bool {
+		a, b := wt.Categories[i], wt.Categories[j]
+		if a.EstimatedHours == b.EstimatedHours {
+			return a.Category < b.Category
+		}
+		return a.EstimatedHours > b.EstimatedHours
+	})
+	return wt
+}
+
+func roundHours(h float64) float64 { return math.Round(h*100) / 100 }
+
+// writeWatchTime writes watch_time_estimates.json.
+func (w *Writer) writeWatchTime(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+	years := make(map[int]WatchTime)
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		years[y] = w.watchTime(agg.YearVideoCounts[y], agg.YearTotals[y], agg.VideoInfo, w.TopN)
+	}
+	payload := struct {
+		Years   map[int]WatchTime `json:"years"`
+		AllTime WatchTime         `json:"all_time"`
+		Sort    string            `json:"sort"`
+		Notes   string            `json:"notes"`
+	}{
+		Years:   years,
+		AllTime: w.watchTime(agg.AllTimeVideoCounts, agg.TotalAllYears, agg.VideoInfo, w.AllTimeTop),
+		Sort:    "estimated_hours desc, channel_name asc",
+		Notes:   "Every watch is assumed to cover the whole video, so hours are an upper bound. Durations come from the -durations file and the YouTube Data API, categories from the API only. Videos without a duration (removed, private and deleted ones, or any the lookups missed) count with -default-duration in watches_with_default_duration, or without it only toward total_videos_watched.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "watch_time_estimates.json"), payload)
+}
@@ -0,0 +1,122 @@
+package output
+
+import (
+	"math"
+	"path/filepath"
+	"time"
+
+	"example.com/hello/takeout/aggregate"
+)
+
+// WeekendPart is the watches on weekdays or on weekends of a year or all
+// time. Days is how many such days the counted window has, and
+// AveragePerDay TotalVideos over them.
+type WeekendPart struct {
+	TotalVideos    int           `json:"total_videos_watched"`
+	UniqueChannels int           `json:"unique_channels"`
+	Days           int           `json:"days"`
+	AveragePerDay  float64       `json:"average_per_day"`
+	TopChannels    []ChannelStat `json:"top_channels"`
+}
+
+type WeekendYear struct {
+	Year                int         `json:"year,omitempty"`
+	Weekday             WeekendPart `json:"weekday"`
+	Weekend             WeekendPart `json:"weekend"`
+	WeekendSharePercent float64     `json:"weekend_share_percent"`
+	// Ratio is the weekend's average per day over the weekdays'; above 1
+	// means more watching on a weekend day than on a weekday. It is nil
+	// without weekday watches.
+	Ratio *float64 `json:"weekend_to_weekday_ratio"`
+}
+
+// weekendPart ranks counts into a WeekendPart over days days.
+func (w *Writer) weekendPart(counts map[aggregate.ChannelKey]int, total, days int) WeekendPart {
+	stats := aggregate.StatsFromMap(counts)
+	aggregate.SortStatsByCountThenName(stats)
+	p := WeekendPart{TotalVideos: total, UniqueChannels: len(stats), Days: days, TopChannels: limitList(stats, w.TopN)}
+	if days > 0 {
+		p.AveragePerDay = math.Round(float64(total)/float64(days)*100) / 100
+	}
+	return p
+}
+
+// weekendYear splits a year's or all time's channel counts, of which weekend
+// are the weekend watches, into weekdays and weekends.
+func (w *Writer) weekendYear(counts, weekend map[aggregate.ChannelKey]int, total, weekendTotal int, days [2]int) WeekendYear {
+	weekday := make(map[aggregate.ChannelKey]int, len(counts))
+	for k, n := range counts {
+		if n -= weekend[k]; n > 0 {
+			weekday[k] = n
+		}
+	}
+	wy := WeekendYear{
+		Weekday: w.weekendPart(weekday, total-weekendTotal, days[0]),
+		Weekend: w.weekendPart(weekend, weekendTotal, days[1]),
+	}
+	if total > 0 {
+		wy.WeekendSharePercent = math.Round(float64(weekendTotal)/float64(total)*1000) / 10
+	}
+	if days[0] > 0 && days[1] > 0 && total > weekendTotal {
+		r := float64(weekendTotal) / float64(days[1]) / (float64(total-weekendTotal) / float64(days[0]))
+		r = math.Round(r*100) / 100
+		wy.Ratio = &r
+	}
+	return wy
+}
+
+// writeWeekend writes weekend.json: each year's and all time's watches and
+// top channels on weekdays and on weekends, and how the two compare per day.
+func (w *Writer) writeWeekend(agg *aggregate.Aggregator) error {
+	opts := agg.Options()
+
+	// days[y] counts the weekdays and weekend days of year y in the window.
+	days := make(map[int][2]int)
+	var allDays [2]int
+	first, last := opts.Window()
+	eachDay(first, last, func(d time.Time) {
+		i := 0
+		if wd := d.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			i = 1
+		}
+		n := days[d.Year()]
+		n[i]++
+		days[d.Year()] = n
+		allDays[i]++
+	})
+
+	years := make([]WeekendYear, 0, opts.EndYear-opts.StartYear+1)
+	allWeekend := make(map[aggregate.ChannelKey]int)
+	allWeekendTotal := 0
+	for y := opts.StartYear; y <= opts.EndYear; y++ {
+		wy := w.weekendYear(agg.YearCounts[y], agg.YearWeekendCounts[y], agg.YearTotals[y], agg.YearWeekendTotals[y], days[y])
+		wy.Year = y
+		years = append(years, wy)
+		for k, n := range agg.YearWeekendCounts[y] {
+			allWeekend[k] += n
+		}
+		allWeekendTotal += agg.YearWeekendTotals[y]
+	}
+
+	payload := struct {
+		Years   []WeekendYear `json:"years"`
+		AllTime WeekendYear   `json:"all_time"`
+		TopN    int           `json:"top_n"`
+		Notes   string        `json:"notes"`
+	}{
+		Years:   years,
+		AllTime: w.weekendYear(agg.AllTimeCounts, allWeekend, agg.TotalAllYears, allWeekendTotal, allDays),
+		TopN:    w.TopN,
+		Notes:   "Weekends are Saturday and Sunday in the " + opts.Location.String() + " time zone. days counts the weekdays or weekend days in the counted window, so average_per_day compares the two fairly; weekend_to_weekday_ratio is the weekend's average_per_day over the weekdays'. top_channels is sorted by watch_count desc, then name.",
+	}
+	return w.writeJSON(filepath.Join(w.Dir, "weekend.json"), payload)
+}
+
+// eachDay calls fn with every calendar day from first through last, as
+// midnight UTC, where every day is 24 hours.
+func eachDay(first, last time.Time, fn func(time.Time)) {
+	end := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC)
+	for d := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC); !d.After(end); d = d.AddDate(0, 0, 1) {
+		fn(d)
+	}
+}
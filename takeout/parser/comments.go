@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Comment is a comment or live chat message from the Takeout comments.csv
+// or live chats.csv.
+type Comment struct {
+	VideoID  string
+	Time     time.Time // zero if it does not parse
+	Text     string
+	LiveChat bool
+}
+
+// commentFiles are the names, lowercased, of the comment exports in a
+// Takeout archive.
+var commentFiles = []string{"comments.csv", "live chats.csv"}
+
+// commentTimeLayouts are the create timestamp formats of the comment CSVs.
+var commentTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07", "2006-01-02 15:04:05.999999999 MST"}
+
+func isCommentFile(name string) bool {
+	name = strings.ToLower(name)
+	for _, f := range commentFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadComments reads the comments and live chat messages at p: comments.csv
+// or live chats.csv itself, or a Takeout .zip or directory, from which both
+// are read.
+func ReadComments(p string) ([]Comment, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		var found []string
+		err := filepath.WalkDir(p, func(fp string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && isCommentFile(d.Name()) {
+				found = append(found, fp)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("%s: no comments.csv or live chats.csv found", p)
+		}
+		sort.Strings(found)
+		comments := []Comment{}
+		for _, fp := range found {
+			c, err := readCommentsFile(fp)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", fp, err)
+			}
+			comments = append(comments, c...)
+		}
+		return comments, nil
+	case strings.EqualFold(path.Ext(p), ".zip"):
+		return readCommentsZip(p)
+	}
+	return readCommentsFile(p)
+}
+
+func readCommentsFile(p string) ([]Comment, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseComments(f)
+}
+
+func readCommentsZip(p string) ([]Comment, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	comments := []Comment{}
+	found := false
+	for _, f := range zr.File {
+		if !isCommentFile(path.Base(f.Name)) {
+			continue
+		}
+		found = true
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		c, err := parseComments(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		comments = append(comments, c...)
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: no comments.csv or live chats.csv found in archive", p)
+	}
+	return comments, nil
+}
+
+// parseComments reads a comments.csv (Comment ID, Channel ID, Comment Create
+// Timestamp, Price, Parent Comment ID, Post ID, Video ID, Comment Text) or a
+// live chats.csv (Live Chat ID, Channel ID, Live Chat Create Timestamp,
+// Price, Video ID, Live Chat Text). Which one is told by the header's column
+// count, so it is skipped whatever its language. Comments on posts rather
+// than videos have no video ID.
+func parseComments(r io.Reader) ([]Comment, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	comments := []Comment{}
+	if len(records) == 0 {
+		return comments, nil
+	}
+	videoCol, textCol, live := 6, 7, false
+	switch n := len(records[0]); n {
+	case 8:
+	case 6:
+		videoCol, textCol, live = 4, 5, true
+	default:
+		return nil, fmt.Errorf("want the 8 columns of comments.csv or the 6 of live chats.csv, got %d", n)
+	}
+	for i, rec := range records[1:] {
+		if len(rec) == 1 && strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		if len(rec) <= textCol {
+			return nil, fmt.Errorf("line %d: want %d fields, got %d", i+2, textCol+1, len(rec))
+		}
+		c := Comment{VideoID: strings.TrimSpace(rec[videoCol]), Text: commentText(rec[textCol]), LiveChat: live}
+		ts := strings.TrimSpace(rec[2])
+		for _, layout := range commentTimeLayouts {
+			if t, err := time.Parse(layout, ts); err == nil {
+				c.Time = t
+				break
+			}
+		}
+		comments = append(comments, c)
+	}
+	return comments, nil
+}
+
+// commentText joins the text of a comment's segments, which Takeout writes
+// as JSON objects such as {"text":"Nice "},{"text":"@someone"}. Text that is
+// not in that form is returned as is.
+func commentText(raw string) string {
+	raw = strings.TrimSpace(raw)
+	var segments []struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte("["+raw+"]"), &segments); err != nil {
+		return raw
+	}
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteString(s.Text)
+	}
+	return b.String()
+}
@@ -0,0 +1,230 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// htmlActivities decodes the watch-history.html flavour of the Takeout
+// export. Each activity is an "outer-cell" div whose first content cell holds
+// the title link, the channel link and a localized timestamp separated by
+// <br> tags.
+type htmlActivities struct {
+	cr *countingReader
+	sc *bufio.Scanner
+}
+
+var htmlEntryMarker = []byte(`<div class="outer-cell`)
+
+func newHTMLActivities(r io.Reader) *htmlActivities {
+	cr := &countingReader{r: r}
+	sc := bufio.NewScanner(cr)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	sc.Split(splitHTMLEntries)
+	return &htmlActivities{cr: cr, sc: sc}
+}
+
+func (h *htmlActivities) Next() (Activity, error) {
+	for h.sc.Scan() {
+		if a, ok := parseHTMLEntry(h.sc.Bytes()); ok {
+			return a, nil
+		}
+	}
+	if err := h.sc.Err(); err != nil {
+		return Activity{}, err
+	}
+	return Activity{}, io.EOF
+}
+
+func (h *htmlActivities) InputOffset() int64 { return h.cr.n }
+
+func (h *htmlActivities) NextRaw() ([]byte, error) {
+	if h.sc.Scan() {
+		return bytes.Clone(h.sc.Bytes()), nil
+	}
+	if err := h.sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (h *htmlActivities) DecodeRaw(raw []byte) (Activity, bool, error) {
+	a, ok := parseHTMLEntry(raw)
+	return a, ok, nil
+}
+
+// splitHTMLEntries yields the text between consecutive entry markers.
+func splitHTMLEntries(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := bytes.Index(data, htmlEntryMarker)
+	if start < 0 {
+		if atEOF {
+			return len(data), nil, nil
+		}
+		// Keep a marker-sized tail in case the marker straddles reads.
+		if keep := len(data) - len(htmlEntryMarker); keep > 0 {
+			return keep, nil, nil
+		}
+		return 0, nil, nil
+	}
+	body := start + len(htmlEntryMarker)
+	if end := bytes.Index(data[body:], htmlEntryMarker); end >= 0 {
+		return body + end, data[start : body+end], nil
+	}
+	if atEOF {
+		return len(data), data[start:], nil
+	}
+	return start, nil, nil
+}
+
+var (
+	htmlContentCell = regexp.MustCompile(`(?s)<div class="content-cell[^"]*mdl-typography--body-1">(.*?)</div>`)
+	htmlBreak       = regexp.MustCompile(`<br\s*/?>`)
+	htmlLink        = regexp.MustCompile(`(?s)<a href="([^"]*)">(.*?)</a>`)
+	htmlTag         = regexp.MustCompile(`<[^>]*>`)
+	htmlDetails     = regexp.MustCompile(`(?s)<b>Details:</b><br>(.*?)(?:<b>|</div>)`)
+	htmlHeader      = regexp.MustCompile(`(?s)<p class="mdl-typography--title">(.*?)</p>`)
+)
+
+func parseHTMLEntry(entry []byte) (Activity, bool) {
+	m := htmlContentCell.FindSubmatch(entry)
+	if m == nil {
+		return Activity{}, false
+	}
+	parts := htmlBreak.Split(string(m[1]), -1)
+
+	var a Activity
+	if h := htmlHeader.FindSubmatch(entry); h != nil {
+		a.Header = htmlText(string(h[1]))
+	}
+	a.Title = htmlText(parts[0])
+	if link := htmlLink.FindStringSubmatch(parts[0]); link != nil {
+		a.TitleURL = html.UnescapeString(link[1])
+	}
+
+	var rawTime string
+	for _, p := range parts[1:] {
+		if link := htmlLink.FindStringSubmatch(p); link != nil {
+			if len(a.Subtitles) == 0 {
+				a.Subtitles = append(a.Subtitles, Subtitle{Name: htmlText(link[2]), URL: html.UnescapeString(link[1])})
+			}
+			continue
+		}
+		if t := htmlText(p); t != "" {
+			rawTime = t
+		}
+	}
+
+	if d := htmlDetails.FindSubmatch(entry); d != nil {
+		for _, p := range htmlBreak.Split(string(d[1]), -1) {
+			if name := htmlText(strings.ReplaceAll(p, "&emsp;", "")); name != "" {
+				a.Details = append(a.Details, Detail{Name: name})
+			}
+		}
+	}
+
+	// Hand the time on as RFC3339 like the JSON export; if it can't be read,
+	// keep the raw text so it is reported like any other bad timestamp.
+	a.Time = rawTime
+	if t, err := parseHTMLTime(rawTime); err == nil {
+		a.Time = t.UTC().Format(time.RFC3339)
+	}
+	return a, true
+}
+
+func htmlText(s string) string {
+	s = html.UnescapeString(htmlTag.ReplaceAllString(s, ""))
+	s = strings.NewReplacer("\u00a0", " ", "\u202f", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
+
+var htmlTimeLayouts = []string{
+	"Jan 2, 2006, 3:04:05 PM",
+	"Jan 2, 2006, 15:04:05",
+	"2 Jan 2006, 15:04:05",
+	"2 Jan 2006, 3:04:05 PM",
+	"2006-01-02, 15:04:05",
+}
+
+// htmlZoneOffsets maps the zone abbreviations seen in HTML exports to their
+// UTC offsets in hours. time.Parse cannot be trusted with abbreviations: it
+// silently uses a zero offset for ones it doesn't know.
+var htmlZoneOffsets = map[string]float64{
+	"UTC": 0, "GMT": 0, "WET": 0, "BST": 1, "WEST": 1,
+	"CET": 1, "CEST": 2, "EET": 2, "EEST": 3, "MSK": 3,
+	"IST": 5.5, "JST": 9, "KST": 9, "AEST": 10, "AEDT": 11,
+	"EST": -5, "EDT": -4, "CST": -6, "CDT": -5,
+	"MST": -7, "MDT": -6, "PST": -8, "PDT": -7,
+	"AKST": -9, "AKDT": -8, "HST": -10,
+}
+
+// parseHTMLTime parses timestamps like "Jan 2, 2023, 3:04:05 PM EST" or
+// "2 Jan 2023, 15:04:05 GMT+01:00".
+func parseHTMLTime(s string) (time.Time, error) {
+	i := strings.LastIndexByte(s, ' ')
+	if i < 0 {
+		return time.Time{}, fmt.Errorf("no time zone in %q", s)
+	}
+	clock, zone := s[:i], s[i+1:]
+
+	offset, err := htmlZoneOffset(zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, layout := range htmlTimeLayouts {
+		if t, err := time.Parse(layout, clock); err == nil {
+			return t.Add(-offset), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+func htmlZoneOffset(zone string) (time.Duration, error) {
+	if h, ok := htmlZoneOffsets[zone]; ok {
+		return time.Duration(h * float64(time.Hour)), nil
+	}
+	for _, base := range []string{"GMT", "UTC"} {
+		rest, ok := strings.CutPrefix(zone, base)
+		if !ok || rest == "" {
+			continue
+		}
+		sign := time.Duration(1)
+		switch rest[0] {
+		case '-':
+			sign = -1
+		case '+':
+		default:
+			continue
+		}
+		hh, mm, _ := strings.Cut(rest[1:], ":")
+		h, err := strconv.Atoi(hh)
+		if err != nil {
+			break
+		}
+		m := 0
+		if mm != "" {
+			if m, err = strconv.Atoi(mm); err != nil {
+				break
+			}
+		}
+		return sign * (time.Duration(h)*time.Hour + time.Duration(m)*time.Minute), nil
+	}
+	return 0, fmt.Errorf("unknown time zone %q", zone)
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
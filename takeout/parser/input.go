@@ -0,0 +1,249 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// History names one of the YouTube history files in a Takeout export.
+type History string
+
+const (
+	WatchHistory  History = "watch-history"
+	SearchHistory History = "search-history"
+)
+
+// takeoutPath is the location of the history inside a Takeout archive, below
+// the top-level "Takeout/" folder.
+func (h History) takeoutPath() string {
+	return "YouTube and YouTube Music/history/" + string(h) + ".json"
+}
+
+// isFile reports whether name is the JSON or HTML flavour of the history,
+// optionally gzip-compressed.
+func (h History) isFile(name string) bool {
+	name = strings.TrimSuffix(name, ".gz")
+	return name == string(h)+".json" || name == string(h)+".html"
+}
+
+// gzipMagic starts every gzip stream; NewDecoder decompresses input that
+// starts with it.
+const gzipMagic = "\x1f\x8b"
+
+// isMyActivity reports whether p, a slash- or OS-separated path, is the
+// YouTube part of a My Activity export: MyActivity.json or MyActivity.html in
+// a YouTube folder ("Takeout/My Activity/YouTube/MyActivity.json"). It holds
+// watches, searches and other actions in the watch history's format, so it
+// stands in for either history when the export has no YouTube history
+// folder. Other products' MyActivity files are in folders of their own.
+func isMyActivity(p string) bool {
+	p = strings.TrimSuffix(filepath.ToSlash(p), ".gz")
+	dir, name := path.Split(p)
+	if !strings.EqualFold(name, "MyActivity.json") && !strings.EqualFold(name, "MyActivity.html") {
+		return false
+	}
+	return strings.EqualFold(path.Base(strings.TrimSuffix(dir, "/")), "YouTube")
+}
+
+// Stdin is the input path that reads the history from standard input, which
+// must be the JSON or HTML export itself rather than a .zip.
+const Stdin = "-"
+
+// ExpandInputs replaces each directory in paths with the watch-history.json,
+// watch-history.html and .zip files found below it, in sorted order, taking
+// gzip-compressed histories (watch-history.json.gz) too. A directory without
+// a watch history falls back to the My Activity export's
+// YouTube/MyActivity.json or .html.
+func ExpandInputs(paths []string) ([]string, error) {
+	return ExpandHistory(paths, WatchHistory)
+}
+
+// ExpandHistory is ExpandInputs for any history file.
+func ExpandHistory(paths []string, h History) ([]string, error) {
+	var out []string
+	stdin := false
+	for _, p := range paths {
+		if p == Stdin {
+			if stdin {
+				return nil, fmt.Errorf("standard input (%s) given more than once", Stdin)
+			}
+			stdin = true
+			out = append(out, p)
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			out = append(out, p)
+			continue
+		}
+		var found, histories, activities []string
+		err = filepath.WalkDir(p, func(fp string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			switch name := d.Name(); {
+			case h.isFile(name):
+				histories = append(histories, fp)
+			case isMyActivity(fp):
+				activities = append(activities, fp)
+			case strings.EqualFold(filepath.Ext(name), ".zip"):
+				found = append(found, fp)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		// My Activity repeats the history, so it is only read without one.
+		if len(histories) == 0 {
+			histories = activities
+		}
+		found = append(found, histories...)
+		if len(found) == 0 {
+			return nil, fmt.Errorf("%s: no %s or My Activity YouTube/MyActivity files found", p, h)
+		}
+		sort.Strings(found)
+		out = append(out, found...)
+	}
+	return out, nil
+}
+
+// Open opens the watch history at p. p may be the JSON or HTML file itself,
+// a Takeout .zip archive containing it or Stdin. A gzip-compressed file is
+// returned as is, for NewDecoder to decompress.
+func Open(p string) (io.ReadCloser, error) {
+	return OpenHistory(p, WatchHistory)
+}
+
+// OpenHistory is Open for any history file.
+func OpenHistory(p string, h History) (io.ReadCloser, error) {
+	if p == Stdin {
+		return io.NopCloser(os.Stdin), nil
+	}
+	if strings.EqualFold(path.Ext(p), ".zip") {
+		return openFromZip(p, h)
+	}
+	return os.Open(p)
+}
+
+// InputSize returns how many bytes decoding p will read: the file size, the
+// uncompressed size of the history inside a .zip or of a gzip-compressed
+// file, or 0 for Stdin.
+func InputSize(p string) (int64, error) {
+	return HistorySize(p, WatchHistory)
+}
+
+// HistorySize is InputSize for any history file.
+func HistorySize(p string, h History) (int64, error) {
+	if p == Stdin {
+		return 0, nil
+	}
+	if !strings.EqualFold(path.Ext(p), ".zip") {
+		return fileSize(p)
+	}
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+	f := findHistory(zr.File, h)
+	if f == nil {
+		return 0, fmt.Errorf("%s: no %s or My Activity YouTube/MyActivity.json found in archive", p, h.takeoutPath())
+	}
+	return int64(f.UncompressedSize64), nil
+}
+
+// fileSize returns the size of the file at p or, if it is gzip-compressed,
+// the uncompressed size its trailer records. The trailer only keeps the size
+// modulo 4 GiB, and only of the last stream of a concatenated file, so it
+// falls back to the compressed size where that is larger.
+func fileSize(p string) (int64, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+	var magic [2]byte
+	if _, err := f.ReadAt(magic[:], 0); err != nil || string(magic[:]) != gzipMagic || size < 18 {
+		return size, nil
+	}
+	var trailer [4]byte
+	if _, err := f.ReadAt(trailer[:], size-4); err != nil {
+		return 0, err
+	}
+	return max(int64(binary.LittleEndian.Uint32(trailer[:])), size), nil
+}
+
+type zipEntryReader struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (z zipEntryReader) Close() error {
+	err := z.ReadCloser.Close()
+	if cerr := z.archive.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func openFromZip(p string, h History) (io.ReadCloser, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	f := findHistory(zr.File, h)
+	if f == nil {
+		_ = zr.Close()
+		return nil, fmt.Errorf("%s: no %s or My Activity YouTube/MyActivity.json found in archive", p, h.takeoutPath())
+	}
+	rc, err := f.Open()
+	if err != nil {
+		_ = zr.Close()
+		return nil, err
+	}
+	return zipEntryReader{ReadCloser: rc, archive: zr}, nil
+}
+
+// findHistory prefers the standard Takeout path and falls back to any JSON or
+// HTML file with the history's name, since folder names are localized in
+// some exports and HTML is the Takeout default, and then to the YouTube
+// MyActivity file of a My Activity export.
+func findHistory(files []*zip.File, h History) *zip.File {
+	std := h.takeoutPath()
+	var fallback, activity *zip.File
+	for _, f := range files {
+		if strings.HasSuffix(f.Name, "/"+std) || f.Name == std {
+			return f
+		}
+		if fallback == nil && h.isFile(path.Base(f.Name)) {
+			fallback = f
+		}
+		if activity == nil && isMyActivity(f.Name) {
+			activity = f
+		}
+	}
+	if fallback == nil {
+		return activity
+	}
+	return fallback
+}
@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Like is a liked video, from the Takeout "Liked videos" playlist or a
+// "Liked ..." My Activity entry. Only the latter name the channel.
+type Like struct {
+	VideoID     string
+	Time        time.Time // zero if the export has none
+	Title       string
+	ChannelName string
+	ChannelURL  string
+}
+
+// likedFiles are the names, lowercased, of the Liked videos playlist in a
+// Takeout archive: "Liked videos.csv" in older exports and "Liked
+// videos-videos.csv" next to playlists.csv in newer ones.
+var likedFiles = []string{"liked videos.csv", "liked videos-videos.csv"}
+
+// likeTimeLayouts are the Time Added formats of the playlist CSVs.
+var likeTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05 MST", "2006-01-02T15:04:05-07:00", "2006-01-02 15:04:05"}
+
+func isLikedFile(name string) bool {
+	name = strings.ToLower(name)
+	for _, f := range likedFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadLikes reads the liked videos at p: a Liked videos playlist CSV, a My
+// Activity JSON file (only its "Liked ..." entries are kept), a Takeout .zip
+// containing the playlist or a directory with it somewhere below.
+func ReadLikes(p string) ([]Like, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		var found []string
+		err := filepath.WalkDir(p, func(fp string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && isLikedFile(d.Name()) {
+				found = append(found, fp)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("%s: no Liked videos.csv found", p)
+		}
+		sort.Strings(found)
+		p = found[0]
+	case strings.EqualFold(path.Ext(p), ".zip"):
+		return readLikesZip(p)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(path.Ext(p), ".json") {
+		return parseLikeActivities(f)
+	}
+	return parseLikes(f)
+}
+
+func readLikesZip(p string) ([]Like, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var found *zip.File
+	for _, f := range zr.File {
+		if isLikedFile(path.Base(f.Name)) {
+			found = f
+			break
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s: no Liked videos.csv found in archive", p)
+	}
+	rc, err := found.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return parseLikes(rc)
+}
+
+// parseLikes reads the Video ID and Time Added columns of a playlist CSV.
+// Older exports put a playlist header block above the videos, so rows are
+// kept by their shape rather than position: an 11-character video ID
+// followed by its time. Header rows are skipped whatever their language.
+func parseLikes(r io.Reader) ([]Like, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	likes := []Like{}
+	for _, rec := range records {
+		if len(rec) < 2 {
+			continue
+		}
+		id := strings.TrimSpace(rec[0])
+		if !isVideoID(id) {
+			continue
+		}
+		like := Like{VideoID: id}
+		ts := strings.TrimSpace(rec[1])
+		for _, layout := range likeTimeLayouts {
+			if t, err := time.Parse(layout, ts); err == nil {
+				like.Time = t
+				break
+			}
+		}
+		likes = append(likes, like)
+	}
+	return likes, nil
+}
+
+// parseLikeActivities keeps the "Liked ..." entries of a My Activity JSON
+// array.
+func parseLikeActivities(r io.Reader) ([]Like, error) {
+	var activities []Activity
+	if err := json.NewDecoder(r).Decode(&activities); err != nil {
+		return nil, err
+	}
+	likes := []Like{}
+	for _, a := range activities {
+		title, ok := strings.CutPrefix(strings.TrimSpace(a.Title), "Liked ")
+		id := VideoIDFromURL(a.TitleURL)
+		if !ok || id == "" {
+			continue
+		}
+		like := Like{VideoID: id, Title: title}
+		like.ChannelName, like.ChannelURL = a.Channel()
+		if t, err := ParseTime(a.Time); err == nil {
+			like.Time = t
+		}
+		likes = append(likes, like)
+	}
+	return likes, nil
+}
+
+// isVideoID reports whether s has the shape of a YouTube video ID.
+func isVideoID(s string) bool {
+	if len(s) != 11 {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
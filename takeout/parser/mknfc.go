@@ -0,0 +1,180 @@
+//go:build ignore
+
+// mknfc writes nfc_tables.go, the Unicode data NFC needs, from
+// UnicodeData.txt and CompositionExclusions.txt of the Unicode Character
+// Database (https://www.unicode.org/Public/UCD/latest/ucd/):
+//
+//	go run mknfc.go -ucd path/to/ucd
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	ucd := flag.String("ucd", ".", "Directory with UnicodeData.txt and CompositionExclusions.txt")
+	version := flag.String("version", "", "Unicode version of the files, for the generated comment")
+	out := flag.String("o", "nfc_tables.go", "File to write")
+	flag.Parse()
+
+	combining := map[rune]uint8{}
+	decomp := map[rune][]rune{}
+	err := eachLine(filepath.Join(*ucd, "UnicodeData.txt"), func(fields []string) error {
+		r, err := parseRune(fields[0])
+		if err != nil {
+			return err
+		}
+		ccc, err := strconv.ParseUint(fields[3], 10, 8)
+		if err != nil {
+			return fmt.Errorf("%U: combining class: %w", r, err)
+		}
+		if ccc != 0 {
+			combining[r] = uint8(ccc)
+		}
+		// Compatibility decompositions start with a <tag>; NFC only
+		// uses canonical ones.
+		if d := fields[5]; d != "" && !strings.HasPrefix(d, "<") {
+			for _, s := range strings.Fields(d) {
+				dr, err := parseRune(s)
+				if err != nil {
+					return err
+				}
+				decomp[r] = append(decomp[r], dr)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	excluded := map[rune]bool{}
+	err = eachLine(filepath.Join(*ucd, "CompositionExclusions.txt"), func(fields []string) error {
+		r, err := parseRune(fields[0])
+		excluded[r] = true
+		return err
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// A character composes from its two-character decomposition unless it
+	// is excluded or decomposes to a non-starter (Full_Composition_Exclusion
+	// without the singletons, which have no pair to compose from).
+	compose := map[[2]rune]rune{}
+	for r, d := range decomp {
+		if len(d) == 2 && !excluded[r] && combining[r] == 0 && combining[d[0]] == 0 {
+			compose[[2]rune{d[0], d[1]}] = r
+		}
+	}
+	full := map[rune]string{}
+	for r := range decomp {
+		full[r] = string(decompose(r, decomp))
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by mknfc.go from the Unicode %s Character Database. DO NOT EDIT.\n\npackage parser\n\n", *version)
+	b.WriteString("// nfcCombining is the canonical combining class of every character whose\n// class is not 0.\n")
+	b.WriteString("var nfcCombining = map[rune]uint8{\n")
+	writeEntries(&b, sortedKeys(combining), func(r rune) string { return fmt.Sprintf("%#04x: %d", r, combining[r]) })
+	b.WriteString("}\n\n")
+	b.WriteString("// nfcDecomposition is the full canonical decomposition of every character\n// that has one, except the Hangul syllables, which decompose\n// algorithmically.\n")
+	b.WriteString("var nfcDecomposition = map[rune]string{\n")
+	writeEntries(&b, sortedKeys(full), func(r rune) string { return fmt.Sprintf("%#04x: %+q", r, full[r]) })
+	b.WriteString("}\n\n")
+	b.WriteString("// nfcComposition is the primary composite of every pair of characters that\n// composes, except the Hangul syllables.\n")
+	b.WriteString("var nfcComposition = map[[2]rune]rune{\n")
+	pairs := make([][2]rune, 0, len(compose))
+	for p := range compose {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i][0] < pairs[j][0] || (pairs[i][0] == pairs[j][0] && pairs[i][1] < pairs[j][1])
+	})
+	writeEntries(&b, pairs, func(p [2]rune) string { return fmt.Sprintf("{%#04x, %#04x}: %#04x", p[0], p[1], compose[p]) })
+	b.WriteString("}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// decompose returns the full canonical decomposition of r.
+func decompose(r rune, decomp map[rune][]rune) []rune {
+	d, ok := decomp[r]
+	if !ok {
+		return []rune{r}
+	}
+	var out []rune
+	for _, dr := range d {
+		out = append(out, decompose(dr, decomp)...)
+	}
+	return out
+}
+
+// writeEntries writes the map entries of keys, several to a line.
+func writeEntries[K any](b *bytes.Buffer, keys []K, entry func(K) string) {
+	for i, k := range keys {
+		if i%4 == 0 {
+			b.WriteString("\t")
+		}
+		b.WriteString(entry(k) + ",")
+		if i%4 == 3 || i == len(keys)-1 {
+			b.WriteString("\n")
+		} else {
+			b.WriteString(" ")
+		}
+	}
+}
+
+func sortedKeys[V any](m map[rune]V) []rune {
+	keys := make([]rune, 0, len(m))
+	for r := range m {
+		keys = append(keys, r)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// eachLine calls fn with the ;-separated fields of every data line of the
+// UCD file at path, without comments.
+func eachLine(path string, fn func(fields []string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		if err := fn(fields); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return sc.Err()
+}
+
+func parseRune(s string) (rune, error) {
+	n, err := strconv.ParseUint(s, 16, 32)
+	return rune(n), err
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:generate go run mknfc.go -ucd ucd -version 14.0.0
+
+// NormalizeName normalizes a channel name so names that look the same
+// compare equal: it puts the name in Unicode NFC, so an accent typed as a
+// separate combining mark matches the precomposed letter, and with strip
+// also drops emoji and invisible characters (zero-width spaces and joiners,
+// direction marks, variation selectors) and collapses the spaces left
+// behind. A name that is nothing but emoji is only put in NFC.
+func NormalizeName(name string, strip bool) string {
+	name = NFC(name)
+	if !strip {
+		return name
+	}
+	stripped := strings.Join(strings.Fields(strings.Map(func(r rune) rune {
+		if isDecoration(r) {
+			return -1
+		}
+		return r
+	}, name)), " ")
+	if stripped == "" {
+		return name
+	}
+	return stripped
+}
+
+// emoji are the blocks of emoji and pictographs, including the regional
+// indicators that make up flags and the skin tone modifiers.
+var emoji = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x203c, 0x203c, 1}, {0x2049, 0x2049, 1}, {0x2139, 0x2139, 1},
+		{0x231a, 0x231b, 1}, {0x2328, 0x2328, 1}, {0x23cf, 0x23cf, 1},
+		{0x23e9, 0x23f3, 1}, {0x23f8, 0x23fa, 1}, {0x24c2, 0x24c2, 1},
+		{0x25aa, 0x25ab, 1}, {0x25b6, 0x25b6, 1}, {0x25c0, 0x25c0, 1},
+		{0x25fb, 0x25fe, 1}, {0x2600, 0x27bf, 1}, {0x2934, 0x2935, 1},
+		{0x2b05, 0x2b07, 1}, {0x2b1b, 0x2b1c, 1}, {0x2b50, 0x2b50, 1},
+		{0x2b55, 0x2b55, 1}, {0x3030, 0x3030, 1}, {0x303d, 0x303d, 1},
+		{0x3297, 0x3297, 1}, {0x3299, 0x3299, 1},
+	},
+	R32: []unicode.Range32{
+		{0x1f000, 0x1faff, 1},
+	},
+}
+
+// isDecoration reports whether NormalizeName strips r: an emoji, the keycap
+// mark, a variation selector or an invisible formatting character.
+func isDecoration(r rune) bool {
+	return r == '⃣' || unicode.Is(emoji, r) || unicode.Is(unicode.Variation_Selector, r) || unicode.Is(unicode.Cf, r)
+}
+
+// Hangul syllables decompose into and compose from their leading
+// consonant, vowel and optional trailing consonant algorithmically.
+const (
+	hangulBase  = 0xac00
+	hangulL     = 0x1100
+	hangulV     = 0x1161
+	hangulT     = 0x11a7
+	hangulLs    = 19
+	hangulVs    = 21
+	hangulTs    = 28
+	hangulCount = hangulLs * hangulVs * hangulTs
+)
+
+// NFC returns s in Unicode Normalization Form C: canonically decomposed,
+// with combining marks in canonical order, then recomposed. Invalid UTF-8
+// is kept as U+FFFD.
+func NFC(s string) string {
+	// Nothing before the combining diacritics decomposes or composes with
+	// what follows it, so most names need no work.
+	quick := true
+	for _, r := range s {
+		if r >= 0x300 {
+			quick = false
+			break
+		}
+	}
+	if quick && utf8.ValidString(s) {
+		return s
+	}
+
+	runes := make([]rune, 0, len(s))
+	for _, r := range s {
+		runes = appendDecomposed(runes, r)
+	}
+	sortCombining(runes)
+	return string(compose(runes))
+}
+
+// appendDecomposed appends the full canonical decomposition of r.
+func appendDecomposed(dst []rune, r rune) []rune {
+	if i := r - hangulBase; i >= 0 && i < hangulCount {
+		dst = append(dst, hangulL+i/(hangulVs*hangulTs), hangulV+(i%(hangulVs*hangulTs))/hangulTs)
+		if t := i % hangulTs; t != 0 {
+			dst = append(dst, hangulT+t)
+		}
+		return dst
+	}
+	if d, ok := nfcDecomposition[r]; ok {
+		for _, dr := range d {
+			dst = append(dst, dr)
+		}
+		return dst
+	}
+	return append(dst, r)
+}
+
+// sortCombining puts every run of combining marks in canonical order: by
+// combining class, keeping the order of marks of the same class.
+func sortCombining(runes []rune) {
+	for i := 0; i < len(runes); {
+		if nfcCombining[runes[i]] == 0 {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(runes) && nfcCombining[runes[j]] != 0 {
+			j++
+		}
+		slices.SortStableFunc(runes[i:j], func(a, b rune) int {
+			return int(nfcCombining[a]) - int(nfcCombining[b])
+		})
+		i = j
+	}
+}
+
+// compose recomposes canonically ordered runes in place: each character
+// joins the last starter before it into their primary composite unless a
+// character between them blocks it.
+func compose(runes []rune) []rune {
+	out := runes[:0]
+	starter := -1
+	var lastClass uint8
+	for _, r := range runes {
+		class := nfcCombining[r]
+		// r is blocked from the starter by any character between them of
+		// class 0 or at least r's; canonical order leaves the highest
+		// class last.
+		if starter >= 0 && (len(out)-1 == starter || (lastClass != 0 && lastClass < class)) {
+			if c, ok := composePair(out[starter], r); ok {
+				out[starter] = c
+				continue
+			}
+		}
+		if class == 0 {
+			starter = len(out)
+		}
+		lastClass = class
+		out = append(out, r)
+	}
+	return out
+}
+
+// composePair returns the primary composite of a followed by b, if any.
+func composePair(a, b rune) (rune, bool) {
+	if l, v := a-hangulL, b-hangulV; l >= 0 && l < hangulLs && v >= 0 && v < hangulVs {
+		return hangulBase + (l*hangulVs+v)*hangulTs, true
+	}
+	if lv, t := a-hangulBase, b-hangulT; lv >= 0 && lv < hangulCount && lv%hangulTs == 0 && t > 0 && t < hangulTs {
+		return a + t, true
+	}
+	c, ok := nfcComposition[[2]rune{a, b}]
+	return c, ok
+}
@@ -0,0 +1,584 @@
+// Package parser decodes Google Takeout YouTube watch history exports (the
+// JSON and HTML flavours, plain or inside a Takeout .zip) into Activity
+// values, and knows the Takeout quirks needed to interpret them: localized
+// "Watched" prefixes, removed-video titles and channel/video URLs.
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Activity is one entry of the watch history export.
+type Activity struct {
+	Header    string     `json:"header"`
+	Title     string     `json:"title"`
+	TitleURL  string     `json:"titleUrl"`
+	Time      string     `json:"time"`
+	Subtitles []Subtitle `json:"subtitles"`
+	Details   []Detail   `json:"details,omitempty"`
+	Products  []string   `json:"products,omitempty"`
+}
+
+// Subtitle is a link shown under an activity's title; for watch events the
+// first one is the channel.
+type Subtitle struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// Detail is a note Takeout attaches to some activities, such as
+// "From Google Ads" on ad views.
+type Detail struct {
+	Name string `json:"name"`
+}
+
+// Channel returns the trimmed name and URL of the activity's channel, or
+// empty strings if it has none.
+func (a Activity) Channel() (name, url string) {
+	if len(a.Subtitles) == 0 {
+		return "", ""
+	}
+	n := strings.TrimSpace(a.Subtitles[0].Name)
+	u := strings.TrimSpace(a.Subtitles[0].URL)
+	return n, u
+}
+
+// IsAd reports whether the activity is an ad view, which Takeout marks with
+// a "From Google Ads" detail.
+func (a Activity) IsAd() bool {
+	for _, d := range a.Details {
+		if strings.EqualFold(strings.TrimSpace(d.Name), "From Google Ads") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsMusic reports whether the activity comes from YouTube Music, by its
+// header, its products or a music.youtube.com URL.
+func (a Activity) IsMusic() bool {
+	if strings.EqualFold(strings.TrimSpace(a.Header), "YouTube Music") {
+		return true
+	}
+	for _, p := range a.Products {
+		if strings.EqualFold(strings.TrimSpace(p), "YouTube Music") {
+			return true
+		}
+	}
+	u, err := url.Parse(strings.TrimSpace(a.TitleURL))
+	return err == nil && strings.EqualFold(u.Host, "music.youtube.com")
+}
+
+// Key identifies an activity across overlapping exports: the same video
+// watched at the same time is the same event. The video is keyed by its
+// canonical watch URL and the time to the whole second in UTC, so a watch
+// matches itself between the HTML export (whole seconds), the JSON export
+// (milliseconds) and epoch-millisecond times; a time ParseTime rejects is
+// used as is.
+func (a Activity) Key() string {
+	video := WatchURL(a.TitleURL)
+	if video == "" {
+		video = strings.TrimSpace(a.TitleURL)
+	}
+	at := strings.TrimSpace(a.Time)
+	if t, err := ParseTime(at); err == nil {
+		at = t.Truncate(time.Second).UTC().Format(time.RFC3339)
+	}
+	return video + "\x00" + at
+}
+
+// Decoder yields Takeout activities one at a time, returning io.EOF after
+// the last one.
+type Decoder interface {
+	Next() (Activity, error)
+	// InputOffset reports how many input bytes have been consumed.
+	InputOffset() int64
+}
+
+// RawDecoder is a Decoder that can also hand out entries undecoded, so the
+// decoding itself can be spread over several goroutines. NextRaw returns a
+// copy of the next entry's bytes; DecodeRaw, which is safe to call
+// concurrently, turns them into an Activity, with ok false for input that
+// turns out not to be an entry.
+type RawDecoder interface {
+	Decoder
+	NextRaw() ([]byte, error)
+	DecodeRaw(raw []byte) (a Activity, ok bool, err error)
+}
+
+// EntryError is an entry a Decoder could not decode. Unless Stop is set, the
+// decoder skips it and can go on with the next entry; with Stop set the rest
+// of the input cannot be read (e.g. the export is truncated).
+type EntryError struct {
+	// Offset is the entry's byte offset in the input.
+	Offset int64
+	// Snippet is the start of the entry's raw text.
+	Snippet string
+	Err     error
+	Stop    bool
+}
+
+func (e *EntryError) Error() string {
+	return fmt.Sprintf("bad entry at byte %d: %v", e.Offset, e.Err)
+}
+
+func (e *EntryError) Unwrap() error { return e.Err }
+
+// snippetLen is how much of a bad entry Snippet keeps.
+const snippetLen = 200
+
+// Snippet returns the start of raw as a string, at most snippetLen bytes and
+// with "..." appended when it was cut.
+func Snippet(raw []byte) string {
+	if len(raw) <= snippetLen {
+		return string(raw)
+	}
+	return strings.ToValidUTF8(string(raw[:snippetLen]), "") + "..."
+}
+
+// NewDecoder sniffs the input and returns a decoder for the JSON export (a
+// top-level array) or the HTML export. Gzip-compressed input (such as a
+// watch-history.json.gz) is decompressed as it is read, and the decoder's
+// InputOffset counts the decompressed bytes.
+func NewDecoder(r io.Reader) (Decoder, error) {
+	br := bufio.NewReaderSize(r, 1024*1024)
+	if magic, _ := br.Peek(2); string(magic) == gzipMagic {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return NewDecoder(zr)
+	}
+	if bom, _ := br.Peek(3); string(bom) == "\xef\xbb\xbf" {
+		_, _ = br.Discard(3)
+	}
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = br.ReadByte()
+		case '<':
+			return newHTMLActivities(br), nil
+		case 'P':
+			if zip, _ := br.Peek(4); string(zip) == "PK\x03\x04" {
+				return nil, errors.New("input is a .zip archive, which must be given as a file path")
+			}
+			return newJSONActivities(br)
+		default:
+			return newJSONActivities(br)
+		}
+	}
+}
+
+type jsonActivities struct {
+	dec  *json.Decoder
+	hist *history
+}
+
+func newJSONActivities(r io.Reader) (*jsonActivities, error) {
+	hist := &history{r: r}
+	dec := json.NewDecoder(hist)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return nil, fmt.Errorf("expected top-level JSON array")
+	}
+	return &jsonActivities{dec: dec, hist: hist}, nil
+}
+
+func (j *jsonActivities) Next() (Activity, error) {
+	if !j.dec.More() {
+		return Activity{}, j.end()
+	}
+	start := j.dec.InputOffset()
+	j.hist.keep(start)
+	var a Activity
+	if err := j.dec.Decode(&a); err != nil {
+		// A value of the wrong type is skipped over; anything else leaves
+		// the decoder stuck.
+		var te *json.UnmarshalTypeError
+		if !errors.As(err, &te) {
+			return Activity{}, j.stop(err)
+		}
+		raw := j.hist.since(start)[:j.dec.InputOffset()-start]
+		entry := bytes.TrimLeft(raw, ", \t\r\n")
+		return Activity{}, &EntryError{Offset: start + int64(len(raw)-len(entry)), Snippet: Snippet(entry), Err: err}
+	}
+	return a, nil
+}
+
+func (j *jsonActivities) InputOffset() int64 { return j.dec.InputOffset() }
+
+func (j *jsonActivities) NextRaw() ([]byte, error) {
+	if !j.dec.More() {
+		return nil, j.end()
+	}
+	j.hist.keep(j.dec.InputOffset())
+	var raw json.RawMessage
+	if err := j.dec.Decode(&raw); err != nil {
+		return nil, j.stop(err)
+	}
+	return raw, nil
+}
+
+// end reads the closing bracket of the array and returns io.EOF.
+func (j *jsonActivities) end() error {
+	if _, err := j.dec.Token(); err != nil && err != io.EOF {
+		return j.stop(err)
+	}
+	return io.EOF
+}
+
+// stop turns an error that leaves the decoder unable to go on (broken or
+// truncated JSON) into an EntryError with Stop set.
+func (j *jsonActivities) stop(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	rest, _ := io.ReadAll(io.LimitReader(j.dec.Buffered(), snippetLen+64))
+	// The decoder stops before the separator ahead of the broken entry.
+	entry := bytes.TrimLeft(rest, ", \t\r\n")
+	off := j.dec.InputOffset() + int64(len(rest)-len(entry))
+	return &EntryError{Offset: off, Snippet: Snippet(entry), Err: err, Stop: true}
+}
+
+func (j *jsonActivities) DecodeRaw(raw []byte) (Activity, bool, error) {
+	var a Activity
+	err := json.Unmarshal(raw, &a)
+	return a, err == nil, err
+}
+
+// history passes reads through to r and keeps what was read since the
+// offset last passed to keep, so that the JSON decoder's view of an entry
+// it has already consumed can still be quoted.
+type history struct {
+	r    io.Reader
+	buf  []byte
+	base int64 // input offset of buf[0]
+}
+
+func (h *history) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	h.buf = append(h.buf, p[:n]...)
+	return n, err
+}
+
+// keep forgets the bytes before input offset off. It only moves the rest
+// down once they take up half of buf, so each byte is moved a bounded
+// number of times.
+func (h *history) keep(off int64) {
+	if d := int(off - h.base); d > 0 && d >= cap(h.buf)/2 {
+		h.buf = h.buf[:copy(h.buf, h.buf[d:])]
+		h.base = off
+	}
+}
+
+// since returns what was read from input offset off on, which must not be
+// before the offset last passed to keep.
+func (h *history) since(off int64) []byte { return h.buf[off-h.base:] }
+
+// DefaultWatchedPrefixes maps a Takeout export language to the title prefix
+// it uses for watch events.
+var DefaultWatchedPrefixes = map[string]string{
+	"en": "Watched ",
+	"fr": "Vous avez regardé ",
+	"de": "Angesehen: ",
+	"es": "Se ha visto ",
+	"it": "Hai guardato ",
+	"pt": "Assistiu a ",
+}
+
+// LoadWatchedPrefixes returns the built-in prefixes merged with the JSON
+// object in path (language -> prefix), if path is set. An empty prefix in the
+// file drops that language. The result is lowercased and sorted.
+func LoadWatchedPrefixes(path string) ([]string, error) {
+	byLang := make(map[string]string, len(DefaultWatchedPrefixes))
+	for lang, p := range DefaultWatchedPrefixes {
+		byLang[lang] = p
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var overrides map[string]string
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for lang, p := range overrides {
+			if p == "" {
+				delete(byLang, lang)
+				continue
+			}
+			byLang[lang] = p
+		}
+	}
+
+	out := make([]string, 0, len(byLang))
+	for _, p := range byLang {
+		out = append(out, p)
+	}
+	return WatchedPrefixes(out...), nil
+}
+
+// WatchedPrefixes returns prefixes lowercased and sorted, as TrimWatchedPrefix
+// expects them. Use it for prefixes that replace the built-in table.
+func WatchedPrefixes(prefixes ...string) []string {
+	out := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		out = append(out, strings.ToLower(p))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ActionWatched is the action of watch events, recognized by the watched
+// prefixes (see LoadWatchedPrefixes); ActionOther is that of entries
+// matching no known prefix.
+const (
+	ActionWatched = "watched"
+	ActionOther   = "other"
+)
+
+// actionPrefixes maps the other actions of the YouTube history to their
+// lowercased title prefixes: "Viewed" is used for community posts and
+// stories, "Visited" for channel pages and YouTube Music.
+var actionPrefixes = map[string][]string{
+	"viewed":     {"viewed "},
+	"visited":    {"visited "},
+	"searched":   searchedPrefixes,
+	"liked":      {"liked "},
+	"subscribed": {"subscribed to "},
+	"answered":   {"answered "},
+	"voted":      {"voted on "},
+}
+
+// Actions returns the known actions, watched first and then by name.
+func Actions() []string {
+	out := make([]string, 0, len(actionPrefixes)+1)
+	for a := range actionPrefixes {
+		out = append(out, a)
+	}
+	sort.Strings(out)
+	return append([]string{ActionWatched}, out...)
+}
+
+// ParseActions parses a comma-separated list of known actions, such as
+// "watched,viewed", into a list in the order of Actions.
+func ParseActions(s string) ([]string, error) {
+	known := Actions()
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(known, name) {
+			return nil, fmt.Errorf("unknown action %q (want %s)", name, strings.Join(known, ", "))
+		}
+		selected[name] = true
+	}
+	var out []string
+	for _, a := range known {
+		if selected[a] {
+			out = append(out, a)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no action selected")
+	}
+	return out, nil
+}
+
+// Action returns the action of an entry title, with watched as the watched
+// prefixes, and the title after the action's prefix. Titles of no known
+// action are ActionOther and returned whole.
+func Action(title string, watched []string) (action, rest string) {
+	if rest, ok := TrimWatchedPrefix(title, watched); ok {
+		return ActionWatched, rest
+	}
+	for a, prefixes := range actionPrefixes {
+		if rest, ok := TrimWatchedPrefix(title, prefixes); ok {
+			return a, rest
+		}
+	}
+	return ActionOther, title
+}
+
+// TrimWatchedPrefix reports whether title starts with one of the (lowercased)
+// watched prefixes and returns the video title after it.
+func TrimWatchedPrefix(title string, prefixes []string) (string, bool) {
+	for _, p := range prefixes {
+		if len(title) >= len(p) && strings.EqualFold(title[:len(p)], p) {
+			return strings.TrimSpace(title[len(p):]), true
+		}
+	}
+	return "", false
+}
+
+// removedVideoMarkers are title fragments Takeout uses for videos that were
+// deleted or made private after being watched, across the locales we know of.
+var removedVideoMarkers = []string{
+	"a video that has been removed",
+	"une vidéo qui a été supprimée",
+	"ein video, das entfernt wurde",
+	"un vídeo que se ha eliminado",
+}
+
+// IsRemovedVideoTitle reports whether title is Takeout's placeholder for a
+// video that is no longer available.
+func IsRemovedVideoTitle(title string) bool {
+	t := strings.ToLower(title)
+	for _, m := range removedVideoMarkers {
+		if strings.Contains(t, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// liveTitleMarkers are lowercase title fragments that mark a live stream or
+// its recording. Plain "live" is left out: it names concert recordings as
+// often as streams.
+var liveTitleMarkers = []string{
+	"🔴",
+	"livestream",
+	"live stream",
+	"live-stream",
+	"streamed live",
+	"live now",
+	"[live]",
+	"[premiere]",
+	"(premiere)",
+	"stream vod",
+}
+
+// IsLiveTitle reports whether a video title marks it as a live stream or
+// premiere, such as "🔴 LIVE: ..." or "... | Livestream". Titles starting
+// with an upper-case "LIVE" and a separator count too.
+func IsLiveTitle(title string) bool {
+	title = strings.TrimSpace(title)
+	if rest, ok := strings.CutPrefix(title, "LIVE"); ok && strings.IndexAny(strings.TrimSpace(rest), ":-|") == 0 {
+		return true
+	}
+	t := strings.ToLower(title)
+	for _, m := range liveTitleMarkers {
+		if strings.Contains(t, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUntitledVideo reports whether a watched video's title is just a watch
+// URL, which is how Takeout lists some private or deleted videos instead of
+// using the removed-video placeholder.
+func IsUntitledVideo(videoTitle string) bool {
+	t := strings.TrimSpace(videoTitle)
+	return (strings.HasPrefix(t, "https://") || strings.HasPrefix(t, "http://")) && VideoIDFromURL(t) != ""
+}
+
+// VideoIDFromURL extracts the YouTube video ID from a watch URL, or returns
+// "". Besides youtube.com/watch?v=ID it understands youtu.be/ID short links,
+// /shorts/, /live/, /embed/ and /v/ paths, and attribution_link redirects;
+// the host (www., m., music.) and other parameters such as &t= or &si= do
+// not matter.
+func VideoIDFromURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	// Most of an export is plain watch URLs; skip parsing those.
+	if id, ok := strings.CutPrefix(raw, watchURLPrefix); ok && isVideoID(id) {
+		return id
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	if strings.EqualFold(u.Host, "youtu.be") {
+		id, _, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+		return id
+	}
+	if u.Path == "/attribution_link" {
+		if target := u.Query().Get("u"); target != "" && !strings.Contains(target, "attribution_link") {
+			return VideoIDFromURL("https://www.youtube.com" + target)
+		}
+		return ""
+	}
+	for _, prefix := range []string{"/shorts/", "/live/", "/embed/", "/v/"} {
+		if id, ok := strings.CutPrefix(u.Path, prefix); ok {
+			id, _, _ = strings.Cut(strings.Trim(id, "/"), "/")
+			return id
+		}
+	}
+	return u.Query().Get("v")
+}
+
+// watchURLPrefix starts the canonical watch URL of a video.
+const watchURLPrefix = "https://www.youtube.com/watch?v="
+
+// WatchURL rewrites a watch URL to https://www.youtube.com/watch?v=ID, so
+// variants of the same video compare equal, or returns "" if raw has no
+// video ID.
+func WatchURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if id, ok := strings.CutPrefix(raw, watchURLPrefix); ok && isVideoID(id) {
+		return raw
+	}
+	if id := VideoIDFromURL(raw); id != "" {
+		return watchURLPrefix + id
+	}
+	return ""
+}
+
+// CanonicalVideoURL is WatchURL, except that a Shorts link becomes
+// https://www.youtube.com/shorts/ID and stays recognizable as one.
+func CanonicalVideoURL(raw string) string {
+	if IsShortsURL(raw) {
+		return "https://www.youtube.com/shorts/" + VideoIDFromURL(raw)
+	}
+	return WatchURL(raw)
+}
+
+// IsShortsURL reports whether raw is a youtube.com/shorts/ID link.
+func IsShortsURL(raw string) bool {
+	if !strings.Contains(raw, "/shorts/") {
+		return false
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	return err == nil && strings.HasPrefix(u.Path, "/shorts/") && VideoIDFromURL(raw) != ""
+}
+
+// ChannelIDFromURL returns the channel identifier from a channel URL: the
+// UC... ID for /channel/ URLs, or the @handle for handle URLs.
+func ChannelIDFromURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "channel":
+		return parts[1]
+	case len(parts) >= 1 && strings.HasPrefix(parts[0], "@"):
+		return parts[0]
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package parser_test
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"testing"
+
+	"example.com/hello/takeout/output"
+	"example.com/hello/takeout/parser"
+	"example.com/hello/takeout/synth"
+)
+
+var benchEntries = flag.Int("entries", 10000, "Entries in the synthetic history the benchmarks decode")
+
+// syntheticExport returns a watch-history.json of n synthetic entries.
+func syntheticExport(b *testing.B, n int) []byte {
+	b.Helper()
+	var buf bytes.Buffer
+	w, err := output.NewActivityStream(&buf)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := synth.Generate(synth.DefaultConfig(n), w.Write); err != nil {
+		b.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkDecodeJSON decodes a whole export per op, as one worker does.
+func BenchmarkDecodeJSON(b *testing.B) {
+	data := syntheticExport(b, *benchEntries)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec, err := parser.NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			if _, err := dec.Next(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	reportPerEntry(b)
+}
+
+// BenchmarkDecodeRaw splits an export into raw entries and decodes them, the
+// two halves of what -workers spreads over goroutines.
+func BenchmarkDecodeRaw(b *testing.B) {
+	data := syntheticExport(b, *benchEntries)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d, err := parser.NewDecoder(bytes.NewReader(data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		dec := d.(parser.RawDecoder)
+		for {
+			raw, err := dec.NextRaw()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+			if _, _, err := dec.DecodeRaw(raw); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	reportPerEntry(b)
+}
+
+// reportPerEntry reports the time per entry of a benchmark that handles the
+// whole synthetic history per op, which compares across -entries.
+func reportPerEntry(b *testing.B) {
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N)/float64(*benchEntries), "ns/entry")
+}
+
+func BenchmarkParseTime(b *testing.B) {
+	for _, s := range []string{"2024-03-09T21:04:05.123Z", "2024-03-09T21:04:05+01:00", "1709999045123"} {
+		b.Run(s, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.ParseTime(s); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAction(b *testing.B) {
+	prefixes, err := parser.LoadWatchedPrefixes("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, title := range []string{"Watched Some video", "Regardé Une vidéo", "Searched for something"} {
+		b.Run(title, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				parser.Action(title, prefixes)
+			}
+		})
+	}
+}
+
+func BenchmarkVideoIDFromURL(b *testing.B) {
+	for i, u := range []string{
+		"https://www.youtube.com/watch?v=dQw4w9WgXcQ",
+		"https://youtu.be/dQw4w9WgXcQ?t=42",
+		"https://www.youtube.com/shorts/dQw4w9WgXcQ",
+	} {
+		b.Run(fmt.Sprint(i), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if parser.VideoIDFromURL(u) == "" {
+					b.Fatal("no video ID in", u)
+				}
+			}
+		})
+	}
+}
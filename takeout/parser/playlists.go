@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Playlist is a playlist of the Takeout playlists folder, such as Watch
+// Later.
+type Playlist struct {
+	Name   string
+	Videos []PlaylistVideo
+}
+
+// PlaylistVideo is a video of a Playlist and when it was added, which is
+// zero if the export has no readable time.
+type PlaylistVideo struct {
+	VideoID string
+	Added   time.Time
+}
+
+// WatchLater is the name of the Watch Later playlist in English exports.
+const WatchLater = "Watch later"
+
+// IsWatchLater reports whether a playlist is Watch Later.
+func (p Playlist) IsWatchLater() bool {
+	return strings.EqualFold(p.Name, WatchLater)
+}
+
+// playlistsDir is the name, lowercased, of the Takeout folder of playlist
+// CSVs, and playlistsIndex that of the file in it listing the playlists
+// rather than their videos.
+const (
+	playlistsDir   = "playlists"
+	playlistsIndex = "playlists.csv"
+)
+
+// playlistName is the name of the playlist in a CSV of the playlists folder:
+// "Watch later.csv" in older exports and "Watch later-videos.csv" in newer
+// ones.
+func playlistName(file string) string {
+	name := strings.TrimSuffix(file, path.Ext(file))
+	return strings.TrimSuffix(name, "-videos")
+}
+
+// isPlaylistFile reports whether the file at p, slash-separated, is a
+// playlist CSV: a .csv other than the playlists.csv index in a playlists
+// folder.
+func isPlaylistFile(p string) bool {
+	base := path.Base(p)
+	return strings.EqualFold(path.Ext(base), ".csv") && !strings.EqualFold(base, playlistsIndex) &&
+		strings.EqualFold(path.Base(path.Dir(p)), playlistsDir)
+}
+
+// ReadPlaylists reads the playlists at p: one playlist CSV, the playlists
+// folder of a Takeout export, a directory with it somewhere below or a
+// Takeout .zip containing it. Playlists are sorted by name.
+func ReadPlaylists(p string) ([]Playlist, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(path.Ext(p), ".zip") {
+		return readPlaylistsZip(p)
+	}
+	var files []string
+	if info.IsDir() {
+		err := filepath.WalkDir(p, func(fp string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && isPlaylistFile(filepath.ToSlash(fp)) {
+				files = append(files, fp)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("%s: no playlist CSVs found", p)
+		}
+	} else {
+		files = []string{p}
+	}
+
+	playlists := make([]Playlist, 0, len(files))
+	for _, fp := range files {
+		f, err := os.Open(fp)
+		if err != nil {
+			return nil, err
+		}
+		likes, err := parseLikes(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fp, err)
+		}
+		playlists = append(playlists, newPlaylist(filepath.Base(fp), likes))
+	}
+	sortPlaylists(playlists)
+	return playlists, nil
+}
+
+func readPlaylistsZip(p string) ([]Playlist, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var playlists []Playlist
+	for _, f := range zr.File {
+		if !isPlaylistFile(f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		likes, err := parseLikes(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		playlists = append(playlists, newPlaylist(path.Base(f.Name), likes))
+	}
+	if len(playlists) == 0 {
+		return nil, fmt.Errorf("%s: no playlist CSVs found in archive", p)
+	}
+	sortPlaylists(playlists)
+	return playlists, nil
+}
+
+// newPlaylist makes the playlist of a CSV from its rows, which parseLikes
+// reads as they have the layout of the Liked videos playlist.
+func newPlaylist(file string, rows []Like) Playlist {
+	pl := Playlist{Name: playlistName(file), Videos: make([]PlaylistVideo, 0, len(rows))}
+	for _, r := range rows {
+		pl.Videos = append(pl.Videos, PlaylistVideo{VideoID: r.VideoID, Added: r.Time})
+	}
+	return pl
+}
+
+func sortPlaylists(playlists []Playlist) {
+	sort.Slice(playlists, func(i, j int) bool {
+		return strings.ToLower(playlists[i].Name) < strings.ToLower(playlists[j].Name)
+	})
+}
@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"net/url"
+	"strings"
+)
+
+// searchedPrefixes are the title prefixes of search-history entries in the
+// locales we know of, lowercased. They are only a fallback for entries
+// whose titleUrl carries no search_query.
+var searchedPrefixes = []string{
+	"searched for ",
+	"vous avez recherché ",
+	"gesucht nach: ",
+	"has buscado ",
+	"hai cercato ",
+	"pesquisou ",
+}
+
+// SearchQuery returns the query of a search-history entry, taken from the
+// search_query parameter of its URL or, failing that, from the title.
+func (a Activity) SearchQuery() (string, bool) {
+	if u, err := url.Parse(strings.TrimSpace(a.TitleURL)); err == nil {
+		if q := strings.TrimSpace(u.Query().Get("search_query")); q != "" {
+			return q, true
+		}
+	}
+	q, ok := TrimWatchedPrefix(strings.TrimSpace(a.Title), searchedPrefixes)
+	return q, ok && q != ""
+}
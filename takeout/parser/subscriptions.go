@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Subscription is a channel listed in the Takeout subscriptions.csv.
+type Subscription struct {
+	ChannelID string
+	URL       string
+	Title     string
+}
+
+// subscriptionsPath is the location of subscriptions.csv inside a Takeout
+// archive, below the top-level "Takeout/" folder.
+const subscriptionsPath = "YouTube and YouTube Music/subscriptions/subscriptions.csv"
+
+// ReadSubscriptions reads the channel subscriptions at p: subscriptions.csv
+// itself, a Takeout .zip containing it or a directory with it somewhere
+// below.
+func ReadSubscriptions(p string) ([]Subscription, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		var found []string
+		err := filepath.WalkDir(p, func(fp string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.EqualFold(d.Name(), "subscriptions.csv") {
+				found = append(found, fp)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("%s: no subscriptions.csv found", p)
+		}
+		sort.Strings(found)
+		p = found[0]
+	case strings.EqualFold(path.Ext(p), ".zip"):
+		return readSubscriptionsZip(p)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseSubscriptions(f)
+}
+
+func readSubscriptionsZip(p string) ([]Subscription, error) {
+	zr, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var fallback *zip.File
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/"+subscriptionsPath) || f.Name == subscriptionsPath {
+			fallback = f
+			break
+		}
+		if fallback == nil && strings.EqualFold(path.Base(f.Name), "subscriptions.csv") {
+			fallback = f
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("%s: no %s found in archive", p, subscriptionsPath)
+	}
+	rc, err := fallback.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return parseSubscriptions(rc)
+}
+
+// parseSubscriptions reads the Channel Id, Channel Url and Channel Title
+// columns, in that order. The header row is skipped whatever its language.
+func parseSubscriptions(r io.Reader) ([]Subscription, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	subs := []Subscription{}
+	for i, rec := range records {
+		if i == 0 {
+			continue
+		}
+		if len(rec) < 3 {
+			if len(rec) == 1 && strings.TrimSpace(rec[0]) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: want channel id, url and title, got %d fields", i+1, len(rec))
+		}
+		subs = append(subs, Subscription{
+			ChannelID: strings.TrimSpace(rec[0]),
+			URL:       strings.TrimSpace(rec[1]),
+			Title:     strings.TrimSpace(rec[2]),
+		})
+	}
+	return subs, nil
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the timestamp layouts ParseTime accepts after RFC3339,
+// which time.Parse already reads with or without fractional seconds. Times
+// without an offset are taken as UTC, which is what Takeout writes.
+var timeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// ParseTime reads an activity time: RFC3339 with any fractional seconds,
+// the same without an offset (as UTC) or with a space for the T, or a Unix
+// epoch time in milliseconds. Epoch values too small to be milliseconds
+// after 1973 are read as seconds.
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	t, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return t, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, lerr := time.Parse(layout, s); lerr == nil {
+			return t, nil
+		}
+	}
+	if n, nerr := strconv.ParseInt(s, 10, 64); nerr == nil {
+		if n >= 1e11 || n <= -1e11 {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	if s == "" {
+		return time.Time{}, fmt.Errorf("missing time")
+	}
+	return time.Time{}, err
+}
+
+// TimeYear returns the year a time that ParseTime rejected starts with, as
+// in "2023-02-30T10:00:00Z", or 0 if it does not start with one.
+func TimeYear(s string) int {
+	s = strings.TrimSpace(s)
+	if len(s) < 5 || s[4] != '-' {
+		return 0
+	}
+	y, err := strconv.Atoi(s[:4])
+	if err != nil {
+		return 0
+	}
+	return y
+}
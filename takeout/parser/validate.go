@@ -0,0 +1,173 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Validation describes an export as read by Validate: what it holds and
+// what is wrong with it.
+type Validation struct {
+	// Format is "json" or "html".
+	Format  string `json:"format"`
+	Entries int    `json:"entries"`
+	// BadEntries are the entries that could not be decoded and were
+	// skipped.
+	BadEntries int `json:"bad_entries"`
+	// Truncated is set, with the error, when the export stops partway
+	// through an entry, so the entries after it are missing.
+	Truncated      bool   `json:"truncated"`
+	TruncatedError string `json:"truncated_error,omitempty"`
+	// FirstTime and LastTime are the earliest and latest entry times, in
+	// UTC; entries without a readable time are counted in BadTimes.
+	FirstTime string `json:"first_time,omitempty"`
+	LastTime  string `json:"last_time,omitempty"`
+	BadTimes  int    `json:"bad_times"`
+	// Actions counts the entries of each action (see Actions), with the
+	// watched prefixes of every built-in language.
+	Actions map[string]int `json:"actions"`
+	// Locale is the language of DefaultWatchedPrefixes whose prefix most
+	// watch titles start with, or "" if none does; LocaleShare is the share
+	// of all entries that start with it, in percent.
+	Locale      string  `json:"locale,omitempty"`
+	LocaleShare float64 `json:"locale_share_percent"`
+	// MissingChannel counts the entries without a channel, of which
+	// RemovedVideos are removed or private videos and Ads are ad views,
+	// which Takeout lists without one.
+	MissingChannel        int     `json:"missing_channel"`
+	MissingChannelPercent float64 `json:"missing_channel_percent"`
+	RemovedVideos         int     `json:"missing_channel_removed_videos"`
+	Ads                   int     `json:"missing_channel_ads"`
+	MissingTitle          int     `json:"missing_title"`
+	MissingURL            int     `json:"missing_title_url"`
+	// Problems are the reasons the export is not fit to analyze, and
+	// Warnings what analyze will skip or miscount in it.
+	Problems []string `json:"problems"`
+	Warnings []string `json:"warnings"`
+}
+
+// Validate reads a whole watch history export from r, as NewDecoder does,
+// and reports its structure and contents. It only returns an error if r is
+// not an export at all; anything wrong with the entries is in the
+// Validation's Problems and Warnings.
+func Validate(r io.Reader) (*Validation, error) {
+	dec, err := NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	v := &Validation{Format: "json", Actions: make(map[string]int), Problems: []string{}, Warnings: []string{}}
+	if _, ok := dec.(*htmlActivities); ok {
+		v.Format = "html"
+	}
+
+	languages := make([]string, 0, len(DefaultWatchedPrefixes))
+	var prefixes []string
+	for lang, p := range DefaultWatchedPrefixes {
+		languages = append(languages, lang)
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(languages)
+	watched := WatchedPrefixes(prefixes...)
+	byLocale := make(map[string]int)
+
+	var first, last time.Time
+	for {
+		a, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+		var ee *EntryError
+		if errors.As(err, &ee) && !ee.Stop {
+			v.BadEntries++
+			continue
+		}
+		if err != nil {
+			v.Truncated = true
+			v.TruncatedError = err.Error()
+			break
+		}
+		v.Entries++
+
+		if t, err := ParseTime(a.Time); err != nil {
+			v.BadTimes++
+		} else {
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+			if t.After(last) {
+				last = t
+			}
+		}
+
+		action, _ := Action(a.Title, watched)
+		v.Actions[action]++
+		if action == ActionWatched {
+			for _, lang := range languages {
+				if _, ok := TrimWatchedPrefix(a.Title, []string{strings.ToLower(DefaultWatchedPrefixes[lang])}); ok {
+					byLocale[lang]++
+					break
+				}
+			}
+		}
+
+		if strings.TrimSpace(a.Title) == "" {
+			v.MissingTitle++
+		}
+		if strings.TrimSpace(a.TitleURL) == "" {
+			v.MissingURL++
+		}
+		if name, url := a.Channel(); name == "" && url == "" {
+			v.MissingChannel++
+			switch {
+			case a.IsAd():
+				v.Ads++
+			case IsRemovedVideoTitle(a.Title):
+				v.RemovedVideos++
+			}
+		}
+	}
+
+	if !first.IsZero() {
+		v.FirstTime = first.UTC().Format(time.RFC3339)
+		v.LastTime = last.UTC().Format(time.RFC3339)
+	}
+	for _, lang := range languages {
+		if byLocale[lang] > byLocale[v.Locale] {
+			v.Locale = lang
+		}
+	}
+	if v.Entries > 0 {
+		v.LocaleShare = percent(byLocale[v.Locale], v.Entries)
+		v.MissingChannelPercent = percent(v.MissingChannel, v.Entries)
+	}
+
+	switch {
+	case v.Truncated:
+		v.Problems = append(v.Problems, "the export is cut off partway through an entry: "+v.TruncatedError)
+	case v.Entries == 0:
+		v.Problems = append(v.Problems, "the export has no entries")
+	}
+	if v.Entries > 0 && v.Actions[ActionWatched] == 0 {
+		v.Problems = append(v.Problems, "no entry starts with a known watched prefix: this is not a watch history, or its language needs -title-prefix or -prefixes")
+	}
+	switch {
+	case v.Entries > 0 && v.BadTimes == v.Entries:
+		v.Problems = append(v.Problems, "no entry has a readable time")
+	case v.BadTimes > 0:
+		v.Warnings = append(v.Warnings, fmt.Sprintf("%d entries have no readable time and will be skipped", v.BadTimes))
+	}
+	if v.BadEntries > 0 {
+		v.Warnings = append(v.Warnings, fmt.Sprintf("%d entries could not be decoded and will be skipped", v.BadEntries))
+	}
+	return v, nil
+}
+
+// percent is n of total in percent, to one decimal.
+func percent(n, total int) float64 {
+	return math.Round(float64(n)/float64(total)*1000) / 10
+}
@@ -0,0 +1,290 @@
+// Package sheets writes tables into the tabs of an existing Google Sheet with
+// the Sheets API v4, signed in as a Google Cloud service account. The sheet
+// has to be shared with the service account's email address for edit.
+package sheets
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the Sheets API v4 endpoint.
+const DefaultBaseURL = "https://sheets.googleapis.com/v4"
+
+// scope is the OAuth scope that lets the service account edit sheets.
+const scope = "https://www.googleapis.com/auth/spreadsheets"
+
+// Credentials is the JSON key file of a service account, as downloaded from
+// the Google Cloud console.
+type Credentials struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// LoadCredentials reads a service account key file.
+func LoadCredentials(path string) (*Credentials, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Credentials
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.Type != "service_account" || c.ClientEmail == "" || c.PrivateKey == "" {
+		return nil, fmt.Errorf("%s: not a service account key file", path)
+	}
+	if c.TokenURI == "" {
+		c.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &c, nil
+}
+
+// Tab is the content of one tab: rows of cells, each a string or number.
+type Tab struct {
+	Title string
+	Rows  [][]any
+}
+
+// Client writes to one spreadsheet. The zero value is not usable; create one
+// with NewClient.
+type Client struct {
+	SpreadsheetID string
+	// BaseURL defaults to DefaultBaseURL.
+	BaseURL string
+	HTTP    *http.Client
+
+	creds   *Credentials
+	key     *rsa.PrivateKey
+	token   string
+	expires time.Time
+}
+
+// NewClient returns a Client for the spreadsheet with the given ID (the long
+// part of its URL) that signs in with creds.
+func NewClient(spreadsheetID string, creds *Credentials) (*Client, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return nil, errors.New("sheets: private key is not PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("sheets: private key: %w", err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("sheets: private key is not an RSA key")
+	}
+	return &Client{
+		SpreadsheetID: spreadsheetID,
+		BaseURL:       DefaultBaseURL,
+		HTTP:          &http.Client{Timeout: 30 * time.Second},
+		creds:         creds,
+		key:           key,
+	}, nil
+}
+
+// Replace makes each tab of tabs hold exactly its rows, adding the tabs the
+// spreadsheet does not have yet and clearing the ones it has before writing.
+// Other tabs are left alone, so charts and notes on them keep working.
+func (c *Client) Replace(tabs []Tab) error {
+	var sheet struct {
+		Sheets []struct {
+			Properties struct {
+				Title string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := c.call(http.MethodGet, "?fields=sheets.properties.title", nil, &sheet); err != nil {
+		return err
+	}
+	have := make(map[string]bool, len(sheet.Sheets))
+	for _, s := range sheet.Sheets {
+		have[s.Properties.Title] = true
+	}
+
+	type request map[string]any
+	var add []request
+	ranges := make([]string, 0, len(tabs))
+	data := make([]request, 0, len(tabs))
+	for _, t := range tabs {
+		if !have[t.Title] {
+			add = append(add, request{"addSheet": request{"properties": request{"title": t.Title}}})
+			have[t.Title] = true
+		}
+		r := quoteTitle(t.Title)
+		ranges = append(ranges, r)
+		data = append(data, request{"range": r + "!A1", "values": t.Rows})
+	}
+	if len(add) > 0 {
+		if err := c.call(http.MethodPost, ":batchUpdate", request{"requests": add}, nil); err != nil {
+			return err
+		}
+	}
+	if err := c.call(http.MethodPost, "/values:batchClear", request{"ranges": ranges}, nil); err != nil {
+		return err
+	}
+	return c.call(http.MethodPost, "/values:batchUpdate", request{"valueInputOption": "RAW", "data": data}, nil)
+}
+
+// quoteTitle quotes a tab title for an A1 range.
+func quoteTitle(title string) string {
+	return "'" + strings.ReplaceAll(title, "'", "''") + "'"
+}
+
+// call sends one request to the spreadsheet, with path appended to its URL,
+// retrying rate-limit and server errors a few times with backoff.
+func (c *Client) call(method, path string, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	u := strings.TrimRight(c.BaseURL, "/") + "/spreadsheets/" + url.PathEscape(c.SpreadsheetID) + path
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		token, err := c.accessToken()
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(method, u, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if in != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return fmt.Errorf("sheets: %w", err)
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("sheets: %w", err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			if out == nil {
+				return nil
+			}
+			return json.Unmarshal(b, out)
+		}
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if retry && attempt < 3 {
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+		return fmt.Errorf("sheets: %s: %s", resp.Status, apiMessage(b))
+	}
+}
+
+// accessToken returns an OAuth access token for the service account,
+// exchanging a freshly signed JWT for a new one when the last is about to
+// expire.
+func (c *Client) accessToken() (string, error) {
+	if c.token != "" && time.Until(c.expires) > time.Minute {
+		return c.token, nil
+	}
+	now := time.Now()
+	assertion, err := c.signJWT(map[string]any{
+		"iss":   c.creds.ClientEmail,
+		"scope": scope,
+		"aud":   c.creds.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.HTTP.PostForm(c.creds.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", fmt.Errorf("sheets: signing in: %w", err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return "", fmt.Errorf("sheets: signing in: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("sheets: signing in as %s: %s: %s", c.creds.ClientEmail, resp.Status, apiMessage(b))
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(b, &tok); err != nil {
+		return "", fmt.Errorf("sheets: signing in: %w", err)
+	}
+	c.token = tok.AccessToken
+	c.expires = now.Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return c.token, nil
+}
+
+// signJWT signs claims with the service account's key (RS256).
+func (c *Client) signJWT(claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": c.creds.PrivateKeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// apiMessage extracts error.message (or error_description, from the token
+// endpoint) from an API error body.
+func apiMessage(body []byte) string {
+	var e struct {
+		Error            json.RawMessage `json:"error"`
+		ErrorDescription string          `json:"error_description"`
+	}
+	if json.Unmarshal(body, &e) == nil {
+		if e.ErrorDescription != "" {
+			return e.ErrorDescription
+		}
+		var inner struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(e.Error, &inner) == nil && inner.Message != "" {
+			return inner.Message
+		}
+	}
+	return strings.TrimSpace(string(body))
+}
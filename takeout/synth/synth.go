@@ -0,0 +1,151 @@
+// Package synth generates synthetic Takeout watch histories of any size, for
+// benchmarks and for trying the command on more history than a real export
+// has.
+package synth
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"example.com/hello/takeout/parser"
+)
+
+// Config describes a history to generate. The fractions are of all entries
+// and should add up to at most 1: an entry is at most one of an ad, a
+// YouTube Music play, a removed video or another action.
+type Config struct {
+	// Entries is how many activities to generate.
+	Entries int
+	// Channels and Videos are how many distinct channels and videos the
+	// watches spread over; channel and video popularity follows a Zipf
+	// distribution, as in real histories.
+	Channels int
+	Videos   int
+	// Start and End bound the entry times, which are spread evenly between
+	// them.
+	Start, End time.Time
+	// Seed makes the history reproducible: the same Config gives the same
+	// activities.
+	Seed uint64
+
+	AdFraction      float64
+	MusicFraction   float64
+	RemovedFraction float64
+	// OtherFraction are non-watch entries, such as searches and visits.
+	OtherFraction float64
+}
+
+// DefaultConfig is a history of entries activities over 2019 to 2024 whose
+// mix of channels, videos, ads, music plays, removed videos and other
+// actions is like a typical export's.
+func DefaultConfig(entries int) Config {
+	return Config{
+		Entries:         entries,
+		Channels:        max(1, entries/50),
+		Videos:          max(1, entries/3),
+		Start:           time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:             time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Seed:            1,
+		AdFraction:      0.03,
+		MusicFraction:   0.05,
+		RemovedFraction: 0.02,
+		OtherFraction:   0.05,
+	}
+}
+
+// Activities returns the history cfg describes, newest first like a Takeout
+// export.
+func Activities(cfg Config) ([]parser.Activity, error) {
+	out := make([]parser.Activity, 0, cfg.Entries)
+	err := Generate(cfg, func(a parser.Activity) error {
+		out = append(out, a)
+		return nil
+	})
+	return out, err
+}
+
+// Generate passes fn the activities of the history cfg describes, newest
+// first, without holding them in memory; output.ActivityWriter writes them
+// as an export. It stops at fn's first error.
+func Generate(cfg Config, fn func(parser.Activity) error) error {
+	if cfg.Entries < 0 || cfg.Channels < 1 || cfg.Videos < 1 {
+		return fmt.Errorf("synth: need entries >= 0 and at least one channel and video")
+	}
+	if !cfg.Start.Before(cfg.End) {
+		return fmt.Errorf("synth: start %s is not before end %s", cfg.Start, cfg.End)
+	}
+	rng := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x5eed))
+	channels := rand.NewZipf(rng, 1.2, 4, uint64(cfg.Channels-1))
+	videos := rand.NewZipf(rng, 1.1, 8, uint64(cfg.Videos-1))
+
+	// Entries are spread evenly over the span, with jitter, from the end
+	// back so they come out newest first.
+	span := cfg.End.Sub(cfg.Start)
+	step := span / time.Duration(max(cfg.Entries, 1))
+	t := cfg.End
+	for i := 0; i < cfg.Entries; i++ {
+		t = t.Add(-step)
+		at := t.Add(time.Duration(rng.Int64N(int64(step) + 1)))
+		if !at.Before(cfg.End) {
+			at = cfg.End.Add(-time.Millisecond)
+		}
+		a := activity(rng, cfg, int(channels.Uint64()), int(videos.Uint64()), at)
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activity makes one entry: a watch of video v on channel c at t, or an ad,
+// music play, removed video or other action in the proportions of cfg.
+func activity(rng *rand.Rand, cfg Config, c, v int, t time.Time) parser.Activity {
+	id := videoID(v)
+	a := parser.Activity{
+		Header:   "YouTube",
+		Title:    fmt.Sprintf("Watched Synthetic video %d", v),
+		TitleURL: "https://www.youtube.com/watch?v=" + id,
+		Time:     t.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Subtitles: []parser.Subtitle{{
+			Name: fmt.Sprintf("Channel %d", c),
+			URL:  "https://www.youtube.com/channel/" + channelID(c),
+		}},
+		Products: []string{"YouTube"},
+	}
+	switch r := rng.Float64(); {
+	case r < cfg.AdFraction:
+		a.Details = []parser.Detail{{Name: "From Google Ads"}}
+		a.Subtitles = nil
+	case r < cfg.AdFraction+cfg.MusicFraction:
+		a.Header = "YouTube Music"
+		a.TitleURL = "https://music.youtube.com/watch?v=" + id
+		a.Products = []string{"YouTube Music"}
+	case r < cfg.AdFraction+cfg.MusicFraction+cfg.RemovedFraction:
+		a.Title = "Watched a video that has been removed"
+		a.TitleURL = ""
+		a.Subtitles = nil
+	case r < cfg.AdFraction+cfg.MusicFraction+cfg.RemovedFraction+cfg.OtherFraction:
+		a.Title = fmt.Sprintf("Searched for synthetic query %d", v)
+		a.TitleURL = "https://www.youtube.com/results?search_query=synthetic+query+" + fmt.Sprint(v)
+		a.Subtitles = nil
+	}
+	return a
+}
+
+const idAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// videoID makes the 11-character video ID of video v.
+func videoID(v int) string { return encodeID(uint64(v), 11) }
+
+// channelID makes the UC... channel ID of channel c.
+func channelID(c int) string { return "UC" + encodeID(uint64(c)*0x9e3779b97f4a7c15, 22) }
+
+func encodeID(n uint64, length int) string {
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = idAlphabet[n%64]
+		n /= 64
+	}
+	return string(b)
+}
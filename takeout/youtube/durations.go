@@ -0,0 +1,61 @@
+package youtube
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ReadDurations reads a local durations file for estimating watch time
+// without the API: a CSV of video ID and duration, the duration in seconds
+// or as an ISO 8601 duration like the API's ("PT4M13S"). A first row whose
+// duration does not parse is taken for a header. The videos come back with
+// Found set and no category.
+func ReadDurations(path string) (map[string]Video, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	videos := make(map[string]Video, len(records))
+	for i, rec := range records {
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("%s: line %d: want video ID and duration", path, i+1)
+		}
+		id := strings.TrimSpace(rec[0])
+		seconds, err := durationSeconds(strings.TrimSpace(rec[1]))
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("%s: line %d: %w", path, i+1, err)
+		}
+		if id != "" {
+			videos[id] = Video{Found: true, DurationSeconds: seconds}
+		}
+	}
+	return videos, nil
+}
+
+func durationSeconds(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("negative duration %q", s)
+		}
+		return n, nil
+	}
+	d, err := ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, want seconds or an ISO 8601 duration", s)
+	}
+	return int(d.Seconds()), nil
+}
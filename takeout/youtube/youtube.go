@@ -0,0 +1,330 @@
+// Package youtube looks up video durations and categories, and whether
+// videos were streamed live, with the YouTube Data API v3, caching every answer in a local JSON file so repeated runs
+// only ask for videos they have not seen before.
+package youtube
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the YouTube Data API v3 endpoint.
+const DefaultBaseURL = "https://www.googleapis.com/youtube/v3"
+
+// batchSize is the most IDs videos.list accepts per request.
+const batchSize = 50
+
+// Video is what the API reports about one video. Found is false for videos
+// the API does not return (deleted, private or region blocked); they are
+// cached too so they are not asked for again.
+type Video struct {
+	Found           bool   `json:"found"`
+	DurationSeconds int    `json:"duration_seconds,omitempty"`
+	CategoryID      string `json:"category_id,omitempty"`
+	Category        string `json:"category,omitempty"`
+	// Live is set for live streams and premieres, past or upcoming, which
+	// the API gives liveStreamingDetails. Videos cached before it was
+	// looked up are never set.
+	Live bool `json:"live,omitempty"`
+}
+
+// Client fetches video details. The zero value is not usable; create one
+// with NewClient.
+type Client struct {
+	APIKey string
+	// BaseURL defaults to DefaultBaseURL.
+	BaseURL string
+	// Interval is the minimum time between two API requests.
+	Interval time.Duration
+	HTTP     *http.Client
+
+	cachePath  string
+	videos     map[string]Video
+	categories map[string]string
+	dirty      bool
+	last       time.Time
+}
+
+type cacheFile struct {
+	Videos     map[string]Video  `json:"videos"`
+	Categories map[string]string `json:"categories"`
+}
+
+// NewClient returns a Client that reads and updates the cache at cachePath
+// ("" disables caching). A missing cache file is not an error.
+func NewClient(apiKey, cachePath string) (*Client, error) {
+	c := &Client{
+		APIKey:     apiKey,
+		BaseURL:    DefaultBaseURL,
+		HTTP:       &http.Client{Timeout: 30 * time.Second},
+		cachePath:  cachePath,
+		videos:     make(map[string]Video),
+		categories: make(map[string]string),
+	}
+	if cachePath == "" {
+		return c, nil
+	}
+	b, err := os.ReadFile(cachePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cf cacheFile
+	if err := json.Unmarshal(b, &cf); err != nil {
+		return nil, fmt.Errorf("%s: %w", cachePath, err)
+	}
+	for id, v := range cf.Videos {
+		c.videos[id] = v
+	}
+	for id, name := range cf.Categories {
+		c.categories[id] = name
+	}
+	return c, nil
+}
+
+// Videos returns the details of every ID, fetching the ones not in the cache
+// in batches of 50. IDs the API does not know come back with Found false.
+// The cache is saved even when a later batch fails, so an interrupted run
+// (e.g. out of quota) keeps what it already paid for.
+func (c *Client) Videos(ids []string) (map[string]Video, error) {
+	out := make(map[string]Video, len(ids))
+	var missing []string
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		if v, ok := c.videos[id]; ok {
+			out[id] = v
+			continue
+		}
+		if _, queued := out[id]; !queued {
+			out[id] = Video{}
+			missing = append(missing, id)
+		}
+	}
+
+	err := c.fetchVideos(missing)
+	if err == nil {
+		err = c.fetchCategories()
+	}
+	for id := range out {
+		v := c.videos[id]
+		if v.CategoryID != "" {
+			v.Category = c.categories[v.CategoryID]
+		}
+		out[id] = v
+	}
+	if serr := c.save(); err == nil {
+		err = serr
+	}
+	return out, err
+}
+
+func (c *Client) fetchVideos(ids []string) error {
+	for len(ids) > 0 {
+		n := min(batchSize, len(ids))
+		batch := ids[:n]
+		ids = ids[n:]
+
+		var resp struct {
+			Items []struct {
+				ID             string `json:"id"`
+				ContentDetails struct {
+					Duration string `json:"duration"`
+				} `json:"contentDetails"`
+				Snippet struct {
+					CategoryID string `json:"categoryId"`
+				} `json:"snippet"`
+				LiveStreamingDetails *struct{} `json:"liveStreamingDetails"`
+			} `json:"items"`
+		}
+		q := url.Values{"part": {"contentDetails,snippet,liveStreamingDetails"}, "id": {strings.Join(batch, ",")}}
+		if err := c.get("videos", q, &resp); err != nil {
+			return err
+		}
+		for _, id := range batch {
+			c.videos[id] = Video{}
+		}
+		for _, it := range resp.Items {
+			d, err := ParseDuration(it.ContentDetails.Duration)
+			if err != nil {
+				return fmt.Errorf("video %s: %w", it.ID, err)
+			}
+			c.videos[it.ID] = Video{
+				Found:           true,
+				DurationSeconds: int(d / time.Second),
+				CategoryID:      it.Snippet.CategoryID,
+				Live:            it.LiveStreamingDetails != nil,
+			}
+		}
+		c.dirty = true
+	}
+	return nil
+}
+
+// fetchCategories looks up the names of category IDs seen on cached videos
+// but not yet named.
+func (c *Client) fetchCategories() error {
+	var ids []string
+	want := make(map[string]bool)
+	for _, v := range c.videos {
+		if v.CategoryID == "" || want[v.CategoryID] {
+			continue
+		}
+		if _, ok := c.categories[v.CategoryID]; !ok {
+			want[v.CategoryID] = true
+			ids = append(ids, v.CategoryID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var resp struct {
+		Items []struct {
+			ID      string `json:"id"`
+			Snippet struct {
+				Title string `json:"title"`
+			} `json:"snippet"`
+		} `json:"items"`
+	}
+	if err := c.get("videoCategories", url.Values{"part": {"snippet"}, "id": {strings.Join(ids, ",")}}, &resp); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		c.categories[id] = ""
+	}
+	for _, it := range resp.Items {
+		c.categories[it.ID] = it.Snippet.Title
+	}
+	c.dirty = true
+	return nil
+}
+
+// get calls one API method, waiting out Interval since the previous call and
+// retrying rate-limit and server errors a few times with backoff.
+func (c *Client) get(method string, q url.Values, v any) error {
+	q.Set("key", c.APIKey)
+	u := strings.TrimRight(c.BaseURL, "/") + "/" + method + "?" + q.Encode()
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		if wait := c.Interval - time.Since(c.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		c.last = time.Now()
+
+		resp, err := c.HTTP.Get(u)
+		if err != nil {
+			// Drop the request URL net/http wraps errors in; it holds the key.
+			var ue *url.Error
+			if errors.As(err, &ue) {
+				err = ue.Err
+			}
+			return fmt.Errorf("youtube %s: %w", method, err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("youtube %s: %w", method, err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return json.Unmarshal(body, v)
+		}
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if retry && attempt < 3 {
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+		return fmt.Errorf("youtube %s: %s: %s", method, resp.Status, apiMessage(body))
+	}
+}
+
+// apiMessage extracts error.message from an API error body.
+func apiMessage(body []byte) string {
+	var e struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &e) == nil && e.Error.Message != "" {
+		return e.Error.Message
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// save writes the cache back atomically if anything was fetched.
+func (c *Client) save() error {
+	if c.cachePath == "" || !c.dirty {
+		return nil
+	}
+	b, err := json.MarshalIndent(cacheFile{Videos: c.videos, Categories: c.categories}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.cachePath + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.cachePath); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// ParseDuration parses the ISO 8601 durations the API uses, e.g. "PT1H2M3S"
+// or "P1DT2H". Years and months are not accepted since their length varies.
+func ParseDuration(s string) (time.Duration, error) {
+	rest, ok := strings.CutPrefix(s, "P")
+	if !ok || rest == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	var d time.Duration
+	inTime := false
+	for rest != "" {
+		if rest[0] == 'T' {
+			inTime = true
+			rest = rest[1:]
+			continue
+		}
+		i := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		n, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		var unit time.Duration
+		switch {
+		case !inTime && rest[i] == 'W':
+			unit = 7 * 24 * time.Hour
+		case !inTime && rest[i] == 'D':
+			unit = 24 * time.Hour
+		case inTime && rest[i] == 'H':
+			unit = time.Hour
+		case inTime && rest[i] == 'M':
+			unit = time.Minute
+		case inTime && rest[i] == 'S':
+			unit = time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d += time.Duration(n) * unit
+		rest = rest[i+1:]
+	}
+	return d, nil
+}